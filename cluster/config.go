@@ -23,6 +23,11 @@ type Config struct {
 	WriteTimeout            toml.Duration `toml:"write-timeout"`
 	ShardWriterTimeout      toml.Duration `toml:"shard-writer-timeout"`
 	ShardMapperTimeout      toml.Duration `toml:"shard-mapper-timeout"`
+
+	// Query limits. Zero disables a limit.
+	QueryTimeout      toml.Duration `toml:"query-timeout"`
+	MaxSelectSeriesN  int           `toml:"max-select-series"`
+	MaxSelectBucketsN int           `toml:"max-select-buckets"`
 }
 
 // NewConfig returns an instance of Config with defaults.
@@ -14,6 +14,9 @@ func TestConfig_Parse(t *testing.T) {
 	if _, err := toml.Decode(`
 shard-writer-timeout = "10s"
 write-timeout = "20s"
+query-timeout = "30s"
+max-select-series = 1000
+max-select-buckets = 500
 `, &c); err != nil {
 		t.Fatal(err)
 	}
@@ -23,5 +26,11 @@ write-timeout = "20s"
 		t.Fatalf("unexpected shard-writer timeout: %s", c.ShardWriterTimeout)
 	} else if time.Duration(c.WriteTimeout) != 20*time.Second {
 		t.Fatalf("unexpected write timeout s: %s", c.WriteTimeout)
+	} else if time.Duration(c.QueryTimeout) != 30*time.Second {
+		t.Fatalf("unexpected query timeout: %s", c.QueryTimeout)
+	} else if c.MaxSelectSeriesN != 1000 {
+		t.Fatalf("unexpected max select series: %d", c.MaxSelectSeriesN)
+	} else if c.MaxSelectBucketsN != 500 {
+		t.Fatalf("unexpected max select buckets: %d", c.MaxSelectBucketsN)
 	}
 }
@@ -13,6 +13,8 @@ It has these top-level messages:
 	WriteShardResponse
 	MapShardRequest
 	MapShardResponse
+	DeleteShardRequest
+	DeleteShardResponse
 */
 package internal
 
@@ -52,6 +54,7 @@ func (m *WriteShardRequest) GetPoints() [][]byte {
 type WriteShardResponse struct {
 	Code             *int32  `protobuf:"varint,1,req,name=Code" json:"Code,omitempty"`
 	Message          *string `protobuf:"bytes,2,opt,name=Message" json:"Message,omitempty"`
+	Dropped          *int32  `protobuf:"varint,3,opt,name=Dropped" json:"Dropped,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
 }
 
@@ -73,6 +76,13 @@ func (m *WriteShardResponse) GetMessage() string {
 	return ""
 }
 
+func (m *WriteShardResponse) GetDropped() int32 {
+	if m != nil && m.Dropped != nil {
+		return *m.Dropped
+	}
+	return 0
+}
+
 type MapShardRequest struct {
 	ShardID          *uint64 `protobuf:"varint,1,req,name=ShardID" json:"ShardID,omitempty"`
 	Query            *string `protobuf:"bytes,2,req,name=Query" json:"Query,omitempty"`
@@ -152,3 +162,51 @@ func (m *MapShardResponse) GetFields() []string {
 	}
 	return nil
 }
+
+type DeleteShardRequest struct {
+	ShardID          *uint64 `protobuf:"varint,1,req,name=ShardID" json:"ShardID,omitempty"`
+	Query            *string `protobuf:"bytes,2,req,name=Query" json:"Query,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *DeleteShardRequest) Reset()         { *m = DeleteShardRequest{} }
+func (m *DeleteShardRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteShardRequest) ProtoMessage()    {}
+
+func (m *DeleteShardRequest) GetShardID() uint64 {
+	if m != nil && m.ShardID != nil {
+		return *m.ShardID
+	}
+	return 0
+}
+
+func (m *DeleteShardRequest) GetQuery() string {
+	if m != nil && m.Query != nil {
+		return *m.Query
+	}
+	return ""
+}
+
+type DeleteShardResponse struct {
+	Code             *int32  `protobuf:"varint,1,req,name=Code" json:"Code,omitempty"`
+	Message          *string `protobuf:"bytes,2,opt,name=Message" json:"Message,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *DeleteShardResponse) Reset()         { *m = DeleteShardResponse{} }
+func (m *DeleteShardResponse) String() string { return proto.CompactTextString(m) }
+func (*DeleteShardResponse) ProtoMessage()    {}
+
+func (m *DeleteShardResponse) GetCode() int32 {
+	if m != nil && m.Code != nil {
+		return *m.Code
+	}
+	return 0
+}
+
+func (m *DeleteShardResponse) GetMessage() string {
+	if m != nil && m.Message != nil {
+		return *m.Message
+	}
+	return ""
+}
@@ -204,6 +204,16 @@ func (w *PointsWriter) MapShards(wp *WritePointsRequest) (*ShardMapping, error)
 	return mapping, nil
 }
 
+// WritePointsInto writes the points of a SELECT ... INTO statement.
+func (w *PointsWriter) WritePointsInto(p *tsdb.IntoWriteRequest) error {
+	return w.WritePoints(&WritePointsRequest{
+		Database:         p.Database,
+		RetentionPolicy:  p.RetentionPolicy,
+		ConsistencyLevel: ConsistencyLevelOne,
+		Points:           p.Points,
+	})
+}
+
 // WritePoints writes across multiple local and remote data nodes according the consistency level.
 func (w *PointsWriter) WritePoints(p *WritePointsRequest) error {
 	w.statMap.Add(statWriteReq, 1)
@@ -233,21 +243,36 @@ func (w *PointsWriter) WritePoints(p *WritePointsRequest) error {
 		}(shardMappings.Shards[shardID], p.Database, p.RetentionPolicy, points)
 	}
 
+	var partialErr tsdb.PartialWriteError
 	for range shardMappings.Points {
 		select {
 		case <-w.closing:
 			return ErrWriteFailed
 		case err := <-ch:
+			// Points dropped by a shard don't stop the writes to the other shards, the
+			// points dropped by all of them are reported together.
+			if err, ok := err.(tsdb.PartialWriteError); ok {
+				if partialErr.Reason == "" {
+					partialErr.Reason = err.Reason
+				}
+				partialErr.Dropped += err.Dropped
+				continue
+			}
 			if err != nil {
 				return err
 			}
 		}
 	}
+
+	if partialErr.Dropped > 0 {
+		return partialErr
+	}
 	return nil
 }
 
 // writeToShards writes points to a shard and ensures a write consistency level has been met.  If the write
-// partially succeeds, ErrPartialWrite is returned.
+// partially succeeds, ErrPartialWrite is returned. If owners dropped points, the consistency level is met
+// by their writes and a tsdb.PartialWriteError is returned with the largest number of dropped points.
 func (w *PointsWriter) writeToShard(shard *meta.ShardInfo, database, retentionPolicy string,
 	consistency ConsistencyLevel, points []models.Point) error {
 	// The required number of writes to achieve the requested consistency level
@@ -309,6 +334,7 @@ func (w *PointsWriter) writeToShard(shard *meta.ShardInfo, database, retentionPo
 	var wrote int
 	timeout := time.After(w.WriteTimeout)
 	var writeError error
+	var partialErr *tsdb.PartialWriteError
 	for range shard.Owners {
 		select {
 		case <-w.closing:
@@ -318,6 +344,17 @@ func (w *PointsWriter) writeToShard(shard *meta.ShardInfo, database, retentionPo
 			// return timeout error to caller
 			return ErrTimeout
 		case result := <-ch:
+			// Owners check the cardinality limits against their own index, so they may
+			// drop different points. The other points were written by the owner, and the
+			// partial write is returned once the consistency level is met.
+			if err, ok := result.Err.(tsdb.PartialWriteError); ok {
+				w.statMap.Add(statWriteErr, 1)
+				if partialErr == nil || err.Dropped > partialErr.Dropped {
+					partialErr = &err
+				}
+				result.Err = nil
+			}
+
 			// If the write returned an error, continue to the next response
 			if result.Err != nil {
 				w.statMap.Add(statWriteErr, 1)
@@ -334,6 +371,9 @@ func (w *PointsWriter) writeToShard(shard *meta.ShardInfo, database, retentionPo
 
 			// We wrote the required consistency level
 			if wrote >= required {
+				if partialErr != nil {
+					return *partialErr
+				}
 				w.statMap.Add(statWriteOK, 1)
 				return nil
 			}
@@ -10,6 +10,7 @@ import (
 	"github.com/influxdb/influxdb/cluster"
 	"github.com/influxdb/influxdb/meta"
 	"github.com/influxdb/influxdb/models"
+	"github.com/influxdb/influxdb/tsdb"
 )
 
 // Ensures the points writer maps a single point to a single shard.
@@ -208,6 +209,24 @@ func TestPointsWriter_WritePoints(t *testing.T) {
 			expErr:          nil,
 		},
 
+		// Owners dropping points over the cardinality limits
+		{
+			name:            "write all, points dropped by an owner",
+			database:        "mydb",
+			retentionPolicy: "myrp",
+			consistency:     cluster.ConsistencyLevelAll,
+			err:             []error{nil, tsdb.PartialWriteError{Reason: "max-series-per-database limit exceeded", Dropped: 1}, nil},
+			expErr:          tsdb.PartialWriteError{Reason: "max-series-per-database limit exceeded", Dropped: 2},
+		},
+		{
+			name:            "write all, points dropped by an owner, 2/3",
+			database:        "mydb",
+			retentionPolicy: "myrp",
+			consistency:     cluster.ConsistencyLevelAll,
+			err:             []error{nil, tsdb.PartialWriteError{Reason: "max-series-per-database limit exceeded", Dropped: 1}, fmt.Errorf("a failure")},
+			expErr:          cluster.ErrPartialWrite,
+		},
+
 		// Error write error
 		{
 			name:            "no writes succeed",
@@ -328,6 +347,56 @@ func TestPointsWriter_WritePoints(t *testing.T) {
 	}
 }
 
+// Ensure points dropped by a remote owner are returned as a partial write, without being
+// queued for hinted handoff.
+func TestPointsWriter_WritePoints_RemotePartialWrite(t *testing.T) {
+	perr := tsdb.PartialWriteError{Reason: `max-series-per-database limit exceeded: (1/1) series="cpu,host=b"`, Dropped: 1}
+	ts := newTestWriteService(func(shardID uint64, points []models.Point) error { return perr })
+	s := cluster.NewService(cluster.Config{})
+	s.Listener = ts.muxln
+	s.TSDBStore = ts
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	defer ts.Close()
+
+	sw := cluster.NewShardWriter(time.Minute)
+	sw.MetaStore = &metaStore{host: ts.ln.Addr().String()}
+	defer sw.Close()
+
+	// The only owner of the shard is node 1, the write is coordinated by node 2.
+	rp := NewRetentionPolicy("myrp", time.Hour, 1)
+	ms := &MetaStore{
+		NodeIDFn:          func() uint64 { return 2 },
+		RetentionPolicyFn: func(database, name string) (*meta.RetentionPolicyInfo, error) { return rp, nil },
+		CreateShardGroupIfNotExistsFn: func(database, policy string, timestamp time.Time) (*meta.ShardGroupInfo, error) {
+			return &rp.ShardGroups[0], nil
+		},
+		DatabaseFn: func(database string) (*meta.DatabaseInfo, error) { return nil, nil },
+	}
+
+	var hhN int32
+	c := cluster.NewPointsWriter()
+	c.MetaStore = ms
+	c.ShardWriter = sw
+	c.HintedHandoff = &fakeShardWriter{
+		ShardWriteFn: func(shardID, nodeID uint64, points []models.Point) error {
+			atomic.AddInt32(&hhN, 1)
+			return nil
+		},
+	}
+
+	pr := &cluster.WritePointsRequest{Database: "mydb", RetentionPolicy: "myrp", ConsistencyLevel: cluster.ConsistencyLevelOne}
+	pr.AddPoint("cpu", 1.0, time.Unix(0, 0), map[string]string{"host": "a"})
+	pr.AddPoint("cpu", 2.0, time.Unix(0, 0), map[string]string{"host": "b"})
+	if err := c.WritePoints(pr); err != perr {
+		t.Fatalf("unexpected error: %#v", err)
+	} else if n := atomic.LoadInt32(&hhN); n != 0 {
+		t.Fatalf("unexpected hinted handoff writes: %d", n)
+	}
+}
+
 var shardID uint64
 
 type fakeShardWriter struct {
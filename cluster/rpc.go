@@ -103,6 +103,66 @@ func (r *MapShardResponse) UnmarshalBinary(buf []byte) error {
 	return nil
 }
 
+// DeleteShardRequest represents the request to delete points from a remote shard.
+type DeleteShardRequest struct {
+	pb internal.DeleteShardRequest
+}
+
+// ShardID of the delete request
+func (d *DeleteShardRequest) ShardID() uint64 { return d.pb.GetShardID() }
+
+// Query returns the Shard delete request's query
+func (d *DeleteShardRequest) Query() string { return d.pb.GetQuery() }
+
+// SetShardID sets the delete request's shard id
+func (d *DeleteShardRequest) SetShardID(id uint64) { d.pb.ShardID = &id }
+
+// SetQuery sets the Shard delete request's Query
+func (d *DeleteShardRequest) SetQuery(query string) { d.pb.Query = &query }
+
+// MarshalBinary encodes the object to a binary format.
+func (d *DeleteShardRequest) MarshalBinary() ([]byte, error) {
+	return proto.Marshal(&d.pb)
+}
+
+// UnmarshalBinary populates DeleteShardRequest from a binary format.
+func (d *DeleteShardRequest) UnmarshalBinary(buf []byte) error {
+	if err := proto.Unmarshal(buf, &d.pb); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteShardResponse represents the response returned from a remote DeleteShardRequest call
+type DeleteShardResponse struct {
+	pb internal.DeleteShardResponse
+}
+
+// Code returns the Shard delete response's code
+func (r *DeleteShardResponse) Code() int { return int(r.pb.GetCode()) }
+
+// Message returns the Shard delete response's Message
+func (r *DeleteShardResponse) Message() string { return r.pb.GetMessage() }
+
+// SetCode sets the Shard delete response's code
+func (r *DeleteShardResponse) SetCode(code int) { r.pb.Code = proto.Int32(int32(code)) }
+
+// SetMessage sets the Shard delete response's message
+func (r *DeleteShardResponse) SetMessage(message string) { r.pb.Message = &message }
+
+// MarshalBinary encodes the object to a binary format.
+func (r *DeleteShardResponse) MarshalBinary() ([]byte, error) {
+	return proto.Marshal(&r.pb)
+}
+
+// UnmarshalBinary populates DeleteShardResponse from a binary format.
+func (r *DeleteShardResponse) UnmarshalBinary(buf []byte) error {
+	if err := proto.Unmarshal(buf, &r.pb); err != nil {
+		return err
+	}
+	return nil
+}
+
 // WritePointsRequest represents a request to write point data to the cluster
 type WritePointsRequest struct {
 	Database         string
@@ -128,6 +188,13 @@ type WriteShardResponse struct {
 	pb internal.WriteShardResponse
 }
 
+// Codes of a WriteShardResponse.
+const (
+	writeShardOK           = 0
+	writeShardFailed       = 1
+	writeShardPartialWrite = 2 // Some points were dropped, Message holds the reason.
+)
+
 // SetShardID sets the ShardID
 func (w *WriteShardRequest) SetShardID(id uint64) { w.pb.ShardID = &id }
 
@@ -191,6 +258,12 @@ func (w *WriteShardResponse) Code() int { return int(w.pb.GetCode()) }
 // Message returns the Message
 func (w *WriteShardResponse) Message() string { return w.pb.GetMessage() }
 
+// SetDropped sets the number of points dropped by a partial write
+func (w *WriteShardResponse) SetDropped(n int) { w.pb.Dropped = proto.Int32(int32(n)) }
+
+// Dropped returns the number of points dropped by a partial write
+func (w *WriteShardResponse) Dropped() int { return int(w.pb.GetDropped()) }
+
 // MarshalBinary encodes the object to a binary format.
 func (w *WriteShardResponse) MarshalBinary() ([]byte, error) {
 	return proto.Marshal(&w.pb)
@@ -32,6 +32,8 @@ const (
 	writeShardFail      = "write_shard_fail"
 	mapShardReq         = "map_shard_req"
 	mapShardResp        = "map_shard_resp"
+	deleteShardReq      = "delete_shard_req"
+	deleteShardFail     = "delete_shard_fail"
 )
 
 // Service processes data received over raw TCP connections.
@@ -51,6 +53,7 @@ type Service struct {
 		CreateShard(database, policy string, shardID uint64) error
 		WriteToShard(shardID uint64, points []models.Point) error
 		CreateMapper(shardID uint64, stmt influxql.Statement, chunkSize int) (tsdb.Mapper, error)
+		DeleteFromShard(shardID uint64, stmt *influxql.DeleteStatement) error
 	}
 
 	Logger  *log.Logger
@@ -173,6 +176,14 @@ func (s *Service) handleConn(conn net.Conn) {
 					s.Logger.Printf("process map shard error writing response: %s", err.Error())
 				}
 			}
+		case deleteShardRequestMessage:
+			s.statMap.Add(deleteShardReq, 1)
+			err := s.processDeleteShardRequest(buf)
+			if err != nil {
+				s.statMap.Add(deleteShardFail, 1)
+				s.Logger.Printf("process delete shard error: %s", err)
+			}
+			s.deleteShardResponse(conn, err)
 		default:
 			s.Logger.Printf("cluster service message type not found: %d", typ)
 		}
@@ -214,7 +225,11 @@ func (s *Service) processWriteShardRequest(buf []byte) error {
 		return s.TSDBStore.WriteToShard(req.ShardID(), req.Points())
 	}
 
-	if err != nil {
+	// Partial writes are returned as is, so the coordinator can report the dropped points.
+	if _, ok := err.(tsdb.PartialWriteError); ok {
+		s.statMap.Add(writeShardFail, 1)
+		return err
+	} else if err != nil {
 		s.statMap.Add(writeShardFail, 1)
 		return fmt.Errorf("write shard %d: %s", req.ShardID(), err)
 	}
@@ -225,11 +240,15 @@ func (s *Service) processWriteShardRequest(buf []byte) error {
 func (s *Service) writeShardResponse(w io.Writer, e error) {
 	// Build response.
 	var resp WriteShardResponse
-	if e != nil {
-		resp.SetCode(1)
+	if pe, ok := e.(tsdb.PartialWriteError); ok {
+		resp.SetCode(writeShardPartialWrite)
+		resp.SetMessage(pe.Reason)
+		resp.SetDropped(pe.Dropped)
+	} else if e != nil {
+		resp.SetCode(writeShardFailed)
 		resp.SetMessage(e.Error())
 	} else {
-		resp.SetCode(0)
+		resp.SetCode(writeShardOK)
 	}
 
 	// Marshal response to binary.
@@ -321,6 +340,54 @@ func writeMapShardResponseMessage(w io.Writer, msg *MapShardResponse) error {
 	return WriteTLV(w, mapShardResponseMessage, buf)
 }
 
+func (s *Service) processDeleteShardRequest(buf []byte) error {
+	// Decode request
+	var req DeleteShardRequest
+	if err := req.UnmarshalBinary(buf); err != nil {
+		return err
+	}
+
+	// Parse the statement.
+	q, err := influxql.ParseQuery(req.Query())
+	if err != nil {
+		return fmt.Errorf("processing delete shard: %s", err)
+	} else if len(q.Statements) != 1 {
+		return fmt.Errorf("processing delete shard: expected 1 statement but got %d", len(q.Statements))
+	}
+	stmt, ok := q.Statements[0].(*influxql.DeleteStatement)
+	if !ok {
+		return fmt.Errorf("processing delete shard: expected DELETE statement but got %T", q.Statements[0])
+	}
+
+	if err := s.TSDBStore.DeleteFromShard(req.ShardID(), stmt); err != nil {
+		return fmt.Errorf("delete shard %d: %s", req.ShardID(), err)
+	}
+	return nil
+}
+
+func (s *Service) deleteShardResponse(w io.Writer, e error) {
+	// Build response.
+	var resp DeleteShardResponse
+	if e != nil {
+		resp.SetCode(1)
+		resp.SetMessage(e.Error())
+	} else {
+		resp.SetCode(0)
+	}
+
+	// Marshal response to binary.
+	buf, err := resp.MarshalBinary()
+	if err != nil {
+		s.Logger.Printf("error marshalling delete shard response: %s", err)
+		return
+	}
+
+	// Write to connection.
+	if err := WriteTLV(w, deleteShardResponseMessage, buf); err != nil {
+		s.Logger.Printf("delete shard response error: %s", err)
+	}
+}
+
 // ReadTLV reads a type-length-value record from r.
 func ReadTLV(r io.Reader) (byte, []byte, error) {
 	var typ [1]byte
@@ -31,6 +31,7 @@ type testService struct {
 	writeShardFunc   func(shardID uint64, points []models.Point) error
 	createShardFunc  func(database, policy string, shardID uint64) error
 	createMapperFunc func(shardID uint64, stmt influxql.Statement, chunkSize int) (tsdb.Mapper, error)
+	deleteShardFunc  func(shardID uint64, stmt *influxql.DeleteStatement) error
 }
 
 func newTestWriteService(f func(shardID uint64, points []models.Point) error) testService {
@@ -75,6 +76,10 @@ func (t testService) CreateMapper(shardID uint64, stmt influxql.Statement, chunk
 	return t.createMapperFunc(shardID, stmt, chunkSize)
 }
 
+func (t testService) DeleteFromShard(shardID uint64, stmt *influxql.DeleteStatement) error {
+	return t.deleteShardFunc(shardID, stmt)
+}
+
 func writeShardSuccess(shardID uint64, points []models.Point) error {
 	responses <- &serviceResponse{
 		shardID: shardID,
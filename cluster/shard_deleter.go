@@ -0,0 +1,110 @@
+package cluster
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/influxdb/influxdb/influxql"
+	"github.com/influxdb/influxdb/meta"
+)
+
+// ShardDeleter is responsible for deleting points from requested shards. Every
+// owner of a shard holds a copy of its data, so the delete is applied to the
+// local store if this node owns the shard and sent to each of the other owners.
+type ShardDeleter struct {
+	MetaStore interface {
+		NodeID() uint64
+		Node(id uint64) (ni *meta.NodeInfo, err error)
+	}
+
+	TSDBStore interface {
+		DeleteFromShard(shardID uint64, stmt *influxql.DeleteStatement) error
+	}
+
+	timeout time.Duration
+}
+
+// NewShardDeleter returns a deleter of local and remote shards.
+func NewShardDeleter(timeout time.Duration) *ShardDeleter {
+	return &ShardDeleter{
+		timeout: timeout,
+	}
+}
+
+// DeleteFromShard deletes the points matching stmt from every owner of the shard.
+func (s *ShardDeleter) DeleteFromShard(sh meta.ShardInfo, stmt *influxql.DeleteStatement) error {
+	for _, owner := range sh.Owners {
+		if owner.NodeID == s.MetaStore.NodeID() {
+			if err := s.TSDBStore.DeleteFromShard(sh.ID, stmt); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := s.deleteRemote(owner.NodeID, sh.ID, stmt); err != nil {
+			return fmt.Errorf("delete shard %d on node %d: %s", sh.ID, owner.NodeID, err)
+		}
+	}
+	return nil
+}
+
+// deleteRemote sends the delete request to another node and waits for the response.
+func (s *ShardDeleter) deleteRemote(nodeID, shardID uint64, stmt *influxql.DeleteStatement) error {
+	conn, err := s.dial(nodeID)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(s.timeout))
+
+	// Build delete request.
+	var request DeleteShardRequest
+	request.SetShardID(shardID)
+	request.SetQuery(stmt.String())
+
+	// Marshal into protocol buffers.
+	buf, err := request.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	// Write request.
+	if err := WriteTLV(conn, deleteShardRequestMessage, buf); err != nil {
+		return err
+	}
+
+	// Read the response.
+	_, buf, err = ReadTLV(conn)
+	if err != nil {
+		return err
+	}
+
+	// Unmarshal response.
+	var response DeleteShardResponse
+	if err := response.UnmarshalBinary(buf); err != nil {
+		return err
+	}
+
+	if response.Code() != 0 {
+		return fmt.Errorf("error code %d: %s", response.Code(), response.Message())
+	}
+
+	return nil
+}
+
+func (s *ShardDeleter) dial(nodeID uint64) (net.Conn, error) {
+	ni, err := s.MetaStore.Node(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.Dial("tcp", ni.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	// Write the cluster multiplexing header byte
+	conn.Write([]byte{MuxHeader})
+
+	return conn, nil
+}
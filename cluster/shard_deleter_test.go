@@ -0,0 +1,133 @@
+package cluster_test
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdb/influxdb/cluster"
+	"github.com/influxdb/influxdb/influxql"
+	"github.com/influxdb/influxdb/meta"
+	"github.com/influxdb/influxdb/tcp"
+)
+
+// Ensure the shard deleter sends the delete to a remote owner of the shard.
+func TestShardDeleter_DeleteFromShard_Remote(t *testing.T) {
+	var shardID uint64
+	var query string
+	ts := newTestDeleteService(func(id uint64, stmt *influxql.DeleteStatement) error {
+		shardID, query = id, stmt.String()
+		return nil
+	})
+	s := cluster.NewService(cluster.Config{})
+	s.Listener = ts.muxln
+	s.TSDBStore = ts
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	defer ts.Close()
+
+	d := cluster.NewShardDeleter(time.Minute)
+	d.MetaStore = &deleterMetaStore{nodeID: 1, host: ts.ln.Addr().String()}
+	d.TSDBStore = &deleterTSDBStore{fn: func(id uint64, stmt *influxql.DeleteStatement) error {
+		t.Fatal("unexpected local delete")
+		return nil
+	}}
+
+	stmt := mustParseDeleteStatement(`DELETE FROM "db0"."rp0".cpu WHERE host = 'serverA' AND time < '2000-01-01T00:00:00Z'`)
+	sh := meta.ShardInfo{ID: 10, Owners: []meta.ShardOwner{{NodeID: 2}}}
+	if err := d.DeleteFromShard(sh, stmt); err != nil {
+		t.Fatal(err)
+	}
+
+	if shardID != 10 {
+		t.Fatalf("unexpected shard id: %d", shardID)
+	} else if query != stmt.String() {
+		t.Fatalf("unexpected query: %s", query)
+	}
+}
+
+// Ensure the shard deleter deletes from the local store and returns remote errors.
+func TestShardDeleter_DeleteFromShard_RemoteError(t *testing.T) {
+	ts := newTestDeleteService(func(id uint64, stmt *influxql.DeleteStatement) error {
+		return errDeleteFailed
+	})
+	s := cluster.NewService(cluster.Config{})
+	s.Listener = ts.muxln
+	s.TSDBStore = ts
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	defer ts.Close()
+
+	var localShardID uint64
+	d := cluster.NewShardDeleter(time.Minute)
+	d.MetaStore = &deleterMetaStore{nodeID: 1, host: ts.ln.Addr().String()}
+	d.TSDBStore = &deleterTSDBStore{fn: func(id uint64, stmt *influxql.DeleteStatement) error {
+		localShardID = id
+		return nil
+	}}
+
+	stmt := mustParseDeleteStatement(`DELETE FROM "db0"."rp0".cpu`)
+	sh := meta.ShardInfo{ID: 10, Owners: []meta.ShardOwner{{NodeID: 1}, {NodeID: 2}}}
+	if err := d.DeleteFromShard(sh, stmt); err == nil || !strings.Contains(err.Error(), errDeleteFailed.Error()) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if localShardID != 10 {
+		t.Fatalf("unexpected local shard id: %d", localShardID)
+	}
+}
+
+var errDeleteFailed = errors.New("failed to delete")
+
+func newTestDeleteService(f func(shardID uint64, stmt *influxql.DeleteStatement) error) testService {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	mux := tcp.NewMux()
+	muxln := mux.Listen(cluster.MuxHeader)
+	go mux.Serve(ln)
+
+	return testService{
+		deleteShardFunc: f,
+		ln:              ln,
+		muxln:           muxln,
+	}
+}
+
+type deleterMetaStore struct {
+	nodeID uint64
+	host   string
+}
+
+func (m *deleterMetaStore) NodeID() uint64 { return m.nodeID }
+
+func (m *deleterMetaStore) Node(nodeID uint64) (*meta.NodeInfo, error) {
+	return &meta.NodeInfo{
+		ID:   nodeID,
+		Host: m.host,
+	}, nil
+}
+
+type deleterTSDBStore struct {
+	fn func(shardID uint64, stmt *influxql.DeleteStatement) error
+}
+
+func (s *deleterTSDBStore) DeleteFromShard(shardID uint64, stmt *influxql.DeleteStatement) error {
+	return s.fn(shardID, stmt)
+}
+
+func mustParseDeleteStatement(s string) *influxql.DeleteStatement {
+	stmt, err := influxql.ParseStatement(s)
+	if err != nil {
+		panic(err)
+	}
+	return stmt.(*influxql.DeleteStatement)
+}
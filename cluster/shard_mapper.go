@@ -253,6 +253,12 @@ func (r *RemoteMapper) NextChunk() (chunk interface{}, err error) {
 	return mo, nil
 }
 
+// Interrupt closes the connection to the remote node. This stops any read in
+// progress, and the remote node stops mapping once it can no longer send data.
+func (r *RemoteMapper) Interrupt() {
+	r.conn.Close()
+}
+
 // Close the Mapper
 func (r *RemoteMapper) Close() {
 	r.conn.Close()
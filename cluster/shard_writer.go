@@ -7,6 +7,7 @@ import (
 
 	"github.com/influxdb/influxdb/meta"
 	"github.com/influxdb/influxdb/models"
+	"github.com/influxdb/influxdb/tsdb"
 	"gopkg.in/fatih/pool.v2"
 )
 
@@ -15,6 +16,8 @@ const (
 	writeShardResponseMessage
 	mapShardRequestMessage
 	mapShardResponseMessage
+	deleteShardRequestMessage
+	deleteShardResponseMessage
 )
 
 // ShardWriter writes a set of points to a shard.
@@ -82,7 +85,10 @@ func (w *ShardWriter) WriteShard(shardID, ownerID uint64, points []models.Point)
 		return err
 	}
 
-	if response.Code() != 0 {
+	// Points dropped by the remote shard are returned as they would be by a local one.
+	if response.Code() == writeShardPartialWrite {
+		return tsdb.PartialWriteError{Reason: response.Message(), Dropped: response.Dropped()}
+	} else if response.Code() != writeShardOK {
 		return fmt.Errorf("error code %d: %s", response.Code(), response.Message())
 	}
 
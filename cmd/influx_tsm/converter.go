@@ -0,0 +1,243 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/influxdb/influxdb/tsdb"
+	"github.com/influxdb/influxdb/tsdb/engine/tsm1"
+)
+
+const (
+	// tmpExt is appended to the path of a shard while it's converted.
+	tmpExt = ".tsm1tmp"
+
+	// origExt is appended to the path of a converted shard while it replaces the original.
+	origExt = ".orig"
+)
+
+// shardInfo is a b1 or bz1 shard to convert.
+type shardInfo struct {
+	Database        string
+	RetentionPolicy string
+	ID              uint64
+	Format          string
+	Path            string
+	WALPath         string
+}
+
+// String returns the database, retention policy and id of the shard.
+func (si *shardInfo) String() string {
+	return fmt.Sprintf("%s/%s/%d", si.Database, si.RetentionPolicy, si.ID)
+}
+
+// stats are the counts of a converted shard.
+type stats struct {
+	SeriesN  int
+	ValuesN  int
+	Duration time.Duration
+}
+
+// converter converts b1 and bz1 shards to tsm1.
+type converter struct {
+	// BackupDir is where the original shards are copied to, empty for no backup.
+	BackupDir string
+
+	// BatchSize is the number of values read before they're written to data files.
+	BatchSize int
+}
+
+// Convert backs up a shard, writes its data to tsm1 data files and replaces the original
+// shard with them. A shard whose conversion was interrupted is converted from scratch.
+func (c *converter) Convert(si *shardInfo) (*stats, error) {
+	start := time.Now()
+	if c.BackupDir != "" {
+		if err := c.backup(si); err != nil {
+			return nil, fmt.Errorf("backup: %s", err)
+		}
+	}
+
+	tmp := si.Path + tmpExt
+	if err := os.RemoveAll(tmp); err != nil {
+		return nil, err
+	}
+	st, err := convertShard(si, tmp, c.BatchSize)
+	if err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+
+	// Swap the converted shard in. If this is interrupted, the next run finishes it. The
+	// original is removed last, as it's how the next run knows the WAL may remain.
+	if err := os.Rename(si.Path, si.Path+origExt); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, si.Path); err != nil {
+		return nil, err
+	}
+	if err := os.RemoveAll(si.WALPath); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(si.Path + origExt); err != nil {
+		return nil, err
+	}
+
+	st.Duration = time.Since(start)
+	return st, nil
+}
+
+// backup copies a shard and its WAL into the data and wal directories of the backup directory.
+func (c *converter) backup(si *shardInfo) error {
+	dir := filepath.Join(c.BackupDir, "data", si.Database, si.RetentionPolicy)
+	if err := copyFile(si.Path, filepath.Join(dir, filepath.Base(si.Path))); err != nil {
+		return err
+	}
+	if _, err := os.Stat(si.WALPath); os.IsNotExist(err) {
+		return nil
+	}
+	return copyDir(si.WALPath, filepath.Join(c.BackupDir, "wal", si.Database, si.RetentionPolicy, filepath.Base(si.WALPath)))
+}
+
+// convertShard reads every series of a b1 or bz1 shard, including the data still in its
+// WAL, and writes them to a tsm1 engine at dst. Values are read in time order across all
+// series and written in batches of about batchSize values, so each batch is appended to
+// new data files rather than rewriting the previous ones.
+func convertShard(si *shardInfo, dst string, batchSize int) (*stats, error) {
+	index := tsdb.NewDatabaseIndex()
+	sh := tsdb.NewShard(si.ID, index, si.Path, si.WALPath, tsdb.NewEngineOptions())
+	sh.LogOutput = ioutil.Discard
+	if err := sh.Open(); err != nil {
+		return nil, err
+	}
+	defer sh.Close()
+
+	opt := tsdb.NewEngineOptions()
+	opt.Config.BlockCacheMaxMemorySize = 0
+	e := tsm1.NewEngine(dst, "", opt).(*tsm1.Engine)
+	e.SetLogOutput(ioutil.Discard)
+	e.SkipCompaction = true
+	if err := e.Open(); err != nil {
+		return nil, err
+	}
+	defer e.Close()
+
+	tx, err := sh.ReadOnlyTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Write the fields and series of the shard before their values.
+	measurements := index.Measurements()
+	measurementFields := make(map[string]*tsdb.MeasurementFields)
+	var seriesToCreate []*tsdb.SeriesCreate
+	for _, m := range measurements {
+		mf := &tsdb.MeasurementFields{Fields: make(map[string]*tsdb.Field)}
+		for _, f := range sh.FieldCodec(m.Name).Fields() {
+			mf.Fields[f.Name] = f
+		}
+		measurementFields[m.Name] = mf
+
+		for _, key := range m.SeriesKeys() {
+			s := index.Series(key)
+			seriesToCreate = append(seriesToCreate, &tsdb.SeriesCreate{Measurement: m.Name, Series: tsdb.NewSeries(key, s.Tags)})
+		}
+	}
+	if err := e.Write(nil, measurementFields, seriesToCreate); err != nil {
+		return nil, err
+	}
+
+	// Open a cursor on every series, ordered by the time of its next value.
+	var cursors seriesCursorHeap
+	for _, m := range measurements {
+		codec := sh.FieldCodec(m.Name)
+		var fields []string
+		for _, f := range codec.Fields() {
+			fields = append(fields, f.Name)
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		for _, key := range m.SeriesKeys() {
+			c := &seriesCursor{key: key, fields: fields, cursor: tx.Cursor(key, fields, codec, true)}
+			if c.time, c.value = c.cursor.SeekTo(0); c.time != tsdb.EOF {
+				cursors = append(cursors, c)
+			}
+		}
+	}
+	heap.Init(&cursors)
+
+	st := &stats{SeriesN: len(seriesToCreate)}
+	batch := make(map[string]tsm1.Values)
+	var batchN int
+	var lastTime int64
+	for cursors.Len() > 0 {
+		c := cursors[0]
+
+		// A batch only ends between two timestamps, so it's entirely newer than the
+		// data files already written, and the engine appends it to new ones.
+		if batchN >= batchSize && c.time > lastTime {
+			if err := e.Write(batch, nil, nil); err != nil {
+				return nil, err
+			}
+			batch, batchN = make(map[string]tsm1.Values), 0
+		}
+
+		// Cursors return the value of a single field, and a map for several.
+		t := time.Unix(0, c.time)
+		values, ok := c.value.(map[string]interface{})
+		if !ok {
+			values = map[string]interface{}{c.fields[0]: c.value}
+		}
+		for name, value := range values {
+			fk := tsm1.SeriesFieldKey(c.key, name)
+			batch[fk] = append(batch[fk], tsm1.NewValue(t, value))
+		}
+		batchN += len(values)
+		st.ValuesN += len(values)
+		lastTime = c.time
+
+		if c.time, c.value = c.cursor.Next(); c.time == tsdb.EOF {
+			heap.Pop(&cursors)
+		} else {
+			heap.Fix(&cursors, 0)
+		}
+	}
+	if batchN > 0 {
+		if err := e.Write(batch, nil, nil); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+// seriesCursor is a cursor on a series and the value it's positioned on.
+type seriesCursor struct {
+	key    string
+	fields []string
+	cursor tsdb.Cursor
+	time   int64
+	value  interface{}
+}
+
+// seriesCursorHeap is a min-heap of series cursors by the time of their values.
+type seriesCursorHeap []*seriesCursor
+
+func (h seriesCursorHeap) Len() int           { return len(h) }
+func (h seriesCursorHeap) Less(i, j int) bool { return h[i].time < h[j].time }
+func (h seriesCursorHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *seriesCursorHeap) Push(x interface{}) { *h = append(*h, x.(*seriesCursor)) }
+
+func (h *seriesCursorHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	c := old[n-1]
+	*h = old[:n-1]
+	return c
+}
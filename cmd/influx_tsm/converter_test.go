@@ -0,0 +1,180 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdb/influxdb/models"
+	"github.com/influxdb/influxdb/tsdb"
+)
+
+// Ensure b1 and bz1 shards are converted to tsm1 and backed up.
+func TestConverter_Convert(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "influx_tsm")
+	defer os.RemoveAll(dir)
+	dataDir, walDir, backupDir := filepath.Join(dir, "data"), filepath.Join(dir, "wal"), filepath.Join(dir, "backup")
+
+	// Points are created for each shard, as they keep the fields encoded by a shard.
+	points := func() []models.Point {
+		return []models.Point{
+			models.NewPoint("cpu", map[string]string{"host": "a"}, map[string]interface{}{"value": 1.5, "idle": int64(90)}, time.Unix(1, 0)),
+			models.NewPoint("cpu", map[string]string{"host": "a"}, map[string]interface{}{"value": 2.5}, time.Unix(2, 0)),
+			models.NewPoint("cpu", map[string]string{"host": "b"}, map[string]interface{}{"value": 3.5, "idle": int64(80)}, time.Unix(1, 0)),
+			models.NewPoint("status", nil, map[string]interface{}{"up": true, "msg": "ok"}, time.Unix(3, 0)),
+		}
+	}
+	MustCreateShard(dataDir, walDir, "db0", "rp0", 1, "b1", points())
+	MustCreateShard(dataDir, walDir, "db0", "rp0", 2, "bz1", points())
+	MustCreateShard(dataDir, walDir, "db1", "rp0", 3, "bz1", points())
+
+	// A partially converted shard is removed.
+	if err := os.MkdirAll(filepath.Join(dataDir, "db0", "rp0", "2"+tmpExt), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	shards, err := collectShards(dataDir, walDir, []string{"db0"})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(shards) != 2 || shards[0].ID != 2 || shards[0].Format != "bz1" || shards[1].ID != 1 || shards[1].Format != "b1" {
+		t.Fatalf("unexpected shards: %v", shards)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "db0", "rp0", "2"+tmpExt)); !os.IsNotExist(err) {
+		t.Fatal("partially converted shard not removed")
+	}
+
+	c := &converter{BackupDir: backupDir, BatchSize: 2}
+	if n := convertShards(c, shards, 2, log.New(ioutil.Discard, "", 0)); n != 0 {
+		t.Fatalf("unexpected failed conversions: %d", n)
+	}
+
+	for _, id := range []string{"1", "2"} {
+		path := filepath.Join(dataDir, "db0", "rp0", id)
+		if format, err := shardFormat(path); err != nil || format != "tsm1" {
+			t.Fatalf("shard %s: unexpected format: %s, %v", id, format, err)
+		}
+		if format, err := shardFormat(filepath.Join(backupDir, "data", "db0", "rp0", id)); err != nil || format == "tsm1" {
+			t.Fatalf("shard %s: unexpected backup format: %s, %v", id, format, err)
+		}
+
+		// Values are written in time order, so each batch is a new data file.
+		if files, err := filepath.Glob(filepath.Join(path, "*.tsm1")); err != nil {
+			t.Fatal(err)
+		} else if len(files) != 2 || filepath.Base(files[0]) != "0000001.tsm1" || filepath.Base(files[1]) != "0000002.tsm1" {
+			t.Fatalf("shard %s: unexpected data files: %v", id, files)
+		}
+
+		index := tsdb.NewDatabaseIndex()
+		sh := tsdb.NewShard(1, index, path, filepath.Join(walDir, "db0", "rp0", id), tsdb.NewEngineOptions())
+		if err := sh.Open(); err != nil {
+			t.Fatal(err)
+		}
+		if n := index.SeriesN(); n != 3 {
+			t.Fatalf("shard %s: unexpected series count: %d", id, n)
+		}
+		if s := index.Series("cpu,host=b"); s == nil || !reflect.DeepEqual(s.Tags, map[string]string{"host": "b"}) {
+			t.Fatalf("shard %s: unexpected series: %#v", id, s)
+		}
+
+		exp := map[string][]interface{}{
+			"cpu,host=a": {map[string]interface{}{"value": 1.5, "idle": int64(90)}, map[string]interface{}{"value": 2.5}},
+			"cpu,host=b": {map[string]interface{}{"value": 3.5, "idle": int64(80)}},
+			"status":     {map[string]interface{}{"up": true, "msg": "ok"}},
+		}
+		fields := map[string][]string{"cpu": {"idle", "value"}, "status": {"msg", "up"}}
+		tx, err := sh.ReadOnlyTx()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for key, values := range exp {
+			name := strings.Split(key, ",")[0]
+			c := tx.Cursor(key, fields[name], sh.FieldCodec(name), true)
+			var got []interface{}
+			for k, v := c.SeekTo(0); k != tsdb.EOF; k, v = c.Next() {
+				got = append(got, v)
+			}
+			if !reflect.DeepEqual(got, values) {
+				t.Fatalf("shard %s: unexpected values for %s: %v", id, key, got)
+			}
+		}
+		tx.Rollback()
+		sh.Close()
+	}
+
+	// Converted shards are skipped, and other databases are left unconverted.
+	if shards, err := collectShards(dataDir, walDir, nil); err != nil {
+		t.Fatal(err)
+	} else if len(shards) != 1 || shards[0].ID != 3 {
+		t.Fatalf("unexpected shards: %v", shards)
+	}
+}
+
+// Ensure an interrupted swap of a converted shard is completed or rolled back.
+func TestRecoverShards(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "influx_tsm")
+	defer os.RemoveAll(dir)
+	dataDir, walDir := filepath.Join(dir, "data"), filepath.Join(dir, "wal")
+
+	for _, id := range []uint64{1, 2} {
+		points := []models.Point{models.NewPoint("cpu", nil, map[string]interface{}{"value": 1.0}, time.Unix(1, 0))}
+		MustCreateShard(dataDir, walDir, "db0", "rp0", id, "bz1", points)
+	}
+	rpDir := filepath.Join(dataDir, "db0", "rp0")
+
+	// Shard 1 was moved aside before the converted shard was moved in.
+	if err := os.Rename(filepath.Join(rpDir, "1"), filepath.Join(rpDir, "1"+origExt)); err != nil {
+		t.Fatal(err)
+	}
+	// Shard 2 was replaced by the converted shard.
+	if err := os.Rename(filepath.Join(rpDir, "2"), filepath.Join(rpDir, "2"+origExt)); err != nil {
+		t.Fatal(err)
+	} else if err := os.Mkdir(filepath.Join(rpDir, "2"), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	shards, err := collectShards(dataDir, walDir, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(shards) != 1 || shards[0].ID != 1 {
+		t.Fatalf("unexpected shards: %v", shards)
+	}
+	fis, err := ioutil.ReadDir(rpDir)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(fis) != 2 || fis[0].Name() != "1" || fis[1].Name() != "2" {
+		t.Fatalf("unexpected files: %d", len(fis))
+	}
+
+	// The WAL of the converted shard is removed, the original shard keeps its own.
+	if _, err := os.Stat(filepath.Join(walDir, "db0", "rp0", "2")); !os.IsNotExist(err) {
+		t.Fatalf("WAL of converted shard not removed: %v", err)
+	} else if _, err := os.Stat(filepath.Join(walDir, "db0", "rp0", "1")); err != nil {
+		t.Fatalf("WAL of original shard removed: %v", err)
+	}
+}
+
+// MustCreateShard writes points to a new shard of an engine. Panic on error.
+func MustCreateShard(dataDir, walDir, database, rp string, id uint64, engine string, points []models.Point) {
+	dir := filepath.Join(dataDir, database, rp)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		panic(err)
+	}
+
+	opt := tsdb.NewEngineOptions()
+	opt.EngineVersion = engine
+	sh := tsdb.NewShard(id, tsdb.NewDatabaseIndex(), filepath.Join(dir, strconv.FormatUint(id, 10)), filepath.Join(walDir, database, rp, strconv.FormatUint(id, 10)), opt)
+	sh.LogOutput = ioutil.Discard
+	if err := sh.Open(); err != nil {
+		panic(err)
+	}
+	defer sh.Close()
+	if err := sh.WritePoints(points); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,340 @@
+// Command influx_tsm converts b1 and bz1 shards to the tsm1 engine in place. The
+// server must be stopped while shards are converted.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/influxdb/influxdb/tsdb"
+	_ "github.com/influxdb/influxdb/tsdb/engine"
+)
+
+const usage = `Convert b1 and bz1 shards to tsm1.
+
+Usage: influx_tsm [options] -backup <path>
+
+Shards are converted in place, after the originals are copied to the backup
+directory. The server must be stopped during the conversion. An interrupted
+conversion is resumed by running the command again: converted shards are
+skipped and partially converted shards are converted again.
+
+Options:
+`
+
+func main() {
+	var (
+		dataDir   string
+		walDir    string
+		backupDir string
+		noBackup  bool
+		dbs       string
+		parallel  int
+		batchSize int
+	)
+	fs := flag.NewFlagSet("influx_tsm", flag.ExitOnError)
+	fs.StringVar(&dataDir, "datadir", filepath.Join(os.Getenv("HOME"), ".influxdb", "data"), "Data directory of the server.")
+	fs.StringVar(&walDir, "waldir", filepath.Join(os.Getenv("HOME"), ".influxdb", "wal"), "WAL directory of the server.")
+	fs.StringVar(&backupDir, "backup", "", "Directory the original shards are copied to.")
+	fs.BoolVar(&noBackup, "nobackup", false, "Convert without backing up the original shards.")
+	fs.StringVar(&dbs, "dbs", "", "Comma-separated list of databases to convert. Defaults to all.")
+	fs.IntVar(&parallel, "parallel", runtime.NumCPU(), "Number of shards converted concurrently.")
+	fs.IntVar(&batchSize, "batch-size", 1000000, "Number of values read before they're written to data files.")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(os.Args[1:])
+
+	if backupDir == "" && !noBackup {
+		fmt.Fprintln(os.Stderr, "a backup directory is required, or -nobackup to convert without backup")
+		os.Exit(1)
+	}
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	var filter []string
+	if dbs != "" {
+		filter = strings.Split(dbs, ",")
+	}
+
+	shards, err := collectShards(dataDir, walDir, filter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading shards: %s\n", err)
+		os.Exit(1)
+	}
+	if len(shards) == 0 {
+		fmt.Println("no b1 or bz1 shards to convert")
+		return
+	}
+
+	c := &converter{BatchSize: batchSize}
+	if !noBackup {
+		c.BackupDir = backupDir
+	}
+
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger.Printf("converting %d shards, %d at a time", len(shards), parallel)
+	if n := convertShards(c, shards, parallel, logger); n > 0 {
+		logger.Printf("%d shards failed to convert, run the command again to retry", n)
+		os.Exit(1)
+	}
+	logger.Println("conversion complete")
+}
+
+// convertShards converts shards with parallel workers and returns the number of failures.
+func convertShards(c *converter, shards []*shardInfo, parallel int, logger *log.Logger) int {
+	ch := make(chan *shardInfo)
+	var mu sync.Mutex
+	var failedN int
+
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for si := range ch {
+				st, err := c.Convert(si)
+				if err != nil {
+					logger.Printf("error converting %s shard %s: %s", si.Format, si, err)
+					mu.Lock()
+					failedN++
+					mu.Unlock()
+					continue
+				}
+				logger.Printf("converted %s shard %s: %d series, %d values in %s", si.Format, si, st.SeriesN, st.ValuesN, st.Duration)
+			}
+		}()
+	}
+
+	for _, si := range shards {
+		ch <- si
+	}
+	close(ch)
+	wg.Wait()
+	return failedN
+}
+
+// collectShards returns the b1 and bz1 shards of the data directory, of the databases in
+// filter if it's not empty. Conversions that were interrupted are cleaned up first.
+func collectShards(dataDir, walDir string, filter []string) ([]*shardInfo, error) {
+	databases, err := subdirs(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var shards []*shardInfo
+	for _, db := range databases {
+		if len(filter) > 0 && !contains(filter, db) {
+			continue
+		}
+
+		rps, err := subdirs(filepath.Join(dataDir, db))
+		if err != nil {
+			return nil, err
+		}
+		for _, rp := range rps {
+			if rp == tsdb.SeriesIndexDir {
+				continue
+			}
+
+			dir := filepath.Join(dataDir, db, rp)
+			if err := recoverShards(dir, filepath.Join(walDir, db, rp)); err != nil {
+				return nil, err
+			}
+
+			fis, err := ioutil.ReadDir(dir)
+			if err != nil {
+				return nil, err
+			}
+			for _, fi := range fis {
+				id, err := strconv.ParseUint(fi.Name(), 10, 64)
+				if err != nil || fi.IsDir() {
+					continue
+				}
+
+				path := filepath.Join(dir, fi.Name())
+				format, err := shardFormat(path)
+				if err != nil {
+					return nil, fmt.Errorf("shard %s: %s", path, err)
+				}
+				if format != "b1" && format != "bz1" {
+					continue
+				}
+				shards = append(shards, &shardInfo{
+					Database:        db,
+					RetentionPolicy: rp,
+					ID:              id,
+					Format:          format,
+					Path:            path,
+					WALPath:         filepath.Join(walDir, db, rp, fi.Name()),
+				})
+			}
+		}
+	}
+
+	// Convert the newest shards first, they're the most likely to be queried.
+	sort.Sort(sort.Reverse(shardInfos(shards)))
+	return shards, nil
+}
+
+// recoverShards cleans up the shards of a retention policy directory whose conversion was
+// interrupted. Partially written tsm1 shards are removed, and swaps of the original shards
+// by converted ones are completed or rolled back.
+func recoverShards(dir, walDir string) error {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range fis {
+		path := filepath.Join(dir, fi.Name())
+		switch {
+		case strings.HasSuffix(fi.Name(), tmpExt):
+			if err := os.RemoveAll(path); err != nil {
+				return err
+			}
+		case strings.HasSuffix(fi.Name(), origExt):
+			name := strings.TrimSuffix(fi.Name(), origExt)
+			base := filepath.Join(dir, name)
+			if _, err := os.Stat(base); os.IsNotExist(err) {
+				// the converted shard wasn't moved in yet, keep the original
+				if err := os.Rename(path, base); err != nil {
+					return err
+				}
+				continue
+			} else if err != nil {
+				return err
+			}
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			if err := os.RemoveAll(filepath.Join(walDir, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// shardFormat returns the engine of the shard at path, "tsm1" for directories.
+func shardFormat(path string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	} else if fi.IsDir() {
+		return "tsm1", nil
+	}
+
+	db, err := bolt.Open(path, 0666, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	var format string
+	err = db.View(func(tx *bolt.Tx) error {
+		// If no format is specified then it must be an original b1 database.
+		b := tx.Bucket([]byte("meta"))
+		if b == nil {
+			format = "b1"
+			return nil
+		}
+
+		format = string(b.Get([]byte("format")))
+		if format == "v1" {
+			format = "b1"
+		}
+		return nil
+	})
+	return format, err
+}
+
+// subdirs returns the names of the directories in dir.
+func subdirs(dir string) ([]string, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, fi := range fis {
+		if fi.IsDir() {
+			names = append(names, fi.Name())
+		}
+	}
+	return names, nil
+}
+
+func contains(a []string, s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// copyFile copies the file at src to dst, creating the directory of dst.
+func copyFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// copyDir copies the files of the directory src to dst.
+func copyDir(src, dst string) error {
+	fis, err := ioutil.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, fi := range fis {
+		if fi.IsDir() {
+			if err := copyDir(filepath.Join(src, fi.Name()), filepath.Join(dst, fi.Name())); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := copyFile(filepath.Join(src, fi.Name()), filepath.Join(dst, fi.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type shardInfos []*shardInfo
+
+func (a shardInfos) Len() int           { return len(a) }
+func (a shardInfos) Less(i, j int) bool { return a[i].ID < a[j].ID }
+func (a shardInfos) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
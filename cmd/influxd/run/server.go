@@ -59,6 +59,7 @@ type Server struct {
 	PointsWriter  *cluster.PointsWriter
 	ShardWriter   *cluster.ShardWriter
 	ShardMapper   *cluster.ShardMapper
+	ShardDeleter  *cluster.ShardDeleter
 	HintedHandoff *hh.Service
 
 	Services []Service
@@ -105,6 +106,7 @@ func NewServer(c *Config, buildInfo *BuildInfo) (*Server, error) {
 	s.TSDBStore.EngineOptions.MaxWALSize = c.Data.MaxWALSize
 	s.TSDBStore.EngineOptions.WALFlushInterval = time.Duration(c.Data.WALFlushInterval)
 	s.TSDBStore.EngineOptions.WALPartitionFlushDelay = time.Duration(c.Data.WALPartitionFlushDelay)
+	s.TSDBStore.MaxSelectSeriesN = c.Cluster.MaxSelectSeriesN
 
 	// Set the shard mapper
 	s.ShardMapper = cluster.NewShardMapper(time.Duration(c.Cluster.ShardMapperTimeout))
@@ -112,13 +114,22 @@ func NewServer(c *Config, buildInfo *BuildInfo) (*Server, error) {
 	s.ShardMapper.MetaStore = s.MetaStore
 	s.ShardMapper.TSDBStore = s.TSDBStore
 
+	// Set the shard deleter
+	s.ShardDeleter = cluster.NewShardDeleter(time.Duration(c.Cluster.ShardWriterTimeout))
+	s.ShardDeleter.MetaStore = s.MetaStore
+	s.ShardDeleter.TSDBStore = s.TSDBStore
+
 	// Initialize query executor.
 	s.QueryExecutor = tsdb.NewQueryExecutor(s.TSDBStore)
 	s.QueryExecutor.MetaStore = s.MetaStore
 	s.QueryExecutor.MetaStatementExecutor = &meta.StatementExecutor{Store: s.MetaStore}
 	s.QueryExecutor.MonitorStatementExecutor = &monitor.StatementExecutor{Monitor: s.Monitor}
 	s.QueryExecutor.ShardMapper = s.ShardMapper
+	s.QueryExecutor.ShardDeleter = s.ShardDeleter
 	s.QueryExecutor.QueryLogEnabled = c.Data.QueryLogEnabled
+	s.QueryExecutor.QueryTimeout = time.Duration(c.Cluster.QueryTimeout)
+	s.QueryExecutor.MaxSelectSeriesN = c.Cluster.MaxSelectSeriesN
+	s.QueryExecutor.MaxSelectBucketsN = c.Cluster.MaxSelectBucketsN
 
 	// Set the shard writer
 	s.ShardWriter = cluster.NewShardWriter(time.Duration(c.Cluster.ShardWriterTimeout))
@@ -134,6 +145,7 @@ func NewServer(c *Config, buildInfo *BuildInfo) (*Server, error) {
 	s.PointsWriter.TSDBStore = s.TSDBStore
 	s.PointsWriter.ShardWriter = s.ShardWriter
 	s.PointsWriter.HintedHandoff = s.HintedHandoff
+	s.QueryExecutor.IntoWriter = s.PointsWriter
 
 	// Initialize the monitor
 	s.Monitor.Version = s.buildInfo.Version
@@ -1217,9 +1217,9 @@ func TestServer_Query_Count(t *testing.T) {
 			exp:     fmt.Sprintf(`{"results":[{"series":[{"name":"cpu","columns":["time","count"],"values":[["%s",1]]}]}]}`, hour_ago.Format(time.RFC3339Nano)),
 		},
 		&Query{
-			name:    "selecting count(*) should error",
+			name:    "selecting count(*) should count each field",
 			command: `SELECT count(*) FROM db0.rp0.cpu`,
-			exp:     `{"error":"error parsing query: expected field argument in count()"}`,
+			exp:     `{"results":[{"series":[{"name":"cpu","columns":["time","count_value"],"values":[["1970-01-01T00:00:00Z",1]]}]}]}`,
 		},
 	}...)
 
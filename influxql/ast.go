@@ -80,41 +80,47 @@ type Node interface {
 func (*Query) node()     {}
 func (Statements) node() {}
 
-func (*AlterDatabaseRenameStatement) node()   {}
-func (*AlterRetentionPolicyStatement) node()  {}
-func (*CreateContinuousQueryStatement) node() {}
-func (*CreateDatabaseStatement) node()        {}
-func (*CreateRetentionPolicyStatement) node() {}
-func (*CreateUserStatement) node()            {}
-func (*Distinct) node()                       {}
-func (*DeleteStatement) node()                {}
-func (*DropContinuousQueryStatement) node()   {}
-func (*DropDatabaseStatement) node()          {}
-func (*DropMeasurementStatement) node()       {}
-func (*DropRetentionPolicyStatement) node()   {}
-func (*DropSeriesStatement) node()            {}
-func (*DropServerStatement) node()            {}
-func (*DropUserStatement) node()              {}
-func (*GrantStatement) node()                 {}
-func (*GrantAdminStatement) node()            {}
-func (*RevokeStatement) node()                {}
-func (*RevokeAdminStatement) node()           {}
-func (*SelectStatement) node()                {}
-func (*SetPasswordUserStatement) node()       {}
-func (*ShowContinuousQueriesStatement) node() {}
-func (*ShowGrantsForUserStatement) node()     {}
-func (*ShowServersStatement) node()           {}
-func (*ShowDatabasesStatement) node()         {}
-func (*ShowFieldKeysStatement) node()         {}
-func (*ShowRetentionPoliciesStatement) node() {}
-func (*ShowMeasurementsStatement) node()      {}
-func (*ShowSeriesStatement) node()            {}
-func (*ShowShardsStatement) node()            {}
-func (*ShowStatsStatement) node()             {}
-func (*ShowDiagnosticsStatement) node()       {}
-func (*ShowTagKeysStatement) node()           {}
-func (*ShowTagValuesStatement) node()         {}
-func (*ShowUsersStatement) node()             {}
+func (*AlterDatabaseRenameStatement) node()        {}
+func (*AlterRetentionPolicyStatement) node()       {}
+func (*CreateContinuousQueryStatement) node()      {}
+func (*CreateDatabaseStatement) node()             {}
+func (*CreateRetentionPolicyStatement) node()      {}
+func (*CreateUserStatement) node()                 {}
+func (*Distinct) node()                            {}
+func (*DeleteStatement) node()                     {}
+func (*DropContinuousQueryStatement) node()        {}
+func (*DropDatabaseStatement) node()               {}
+func (*DropMeasurementStatement) node()            {}
+func (*DropRetentionPolicyStatement) node()        {}
+func (*DropSeriesStatement) node()                 {}
+func (*DropServerStatement) node()                 {}
+func (*DropUserStatement) node()                   {}
+func (*ExplainStatement) node()                    {}
+func (*GrantStatement) node()                      {}
+func (*GrantAdminStatement) node()                 {}
+func (*KillQueryStatement) node()                  {}
+func (*RevokeStatement) node()                     {}
+func (*RevokeAdminStatement) node()                {}
+func (*SelectStatement) node()                     {}
+func (*SetPasswordUserStatement) node()            {}
+func (*ShowContinuousQueriesStatement) node()      {}
+func (*ShowGrantsForUserStatement) node()          {}
+func (*ShowServersStatement) node()                {}
+func (*ShowDatabasesStatement) node()              {}
+func (*ShowFieldKeysStatement) node()              {}
+func (*ShowRetentionPoliciesStatement) node()      {}
+func (*ShowMeasurementCardinalityStatement) node() {}
+func (*ShowMeasurementsStatement) node()           {}
+func (*ShowQueriesStatement) node()                {}
+func (*ShowSeriesStatement) node()                 {}
+func (*ShowSeriesCardinalityStatement) node()      {}
+func (*ShowShardsStatement) node()                 {}
+func (*ShowStatsStatement) node()                  {}
+func (*ShowDiagnosticsStatement) node()            {}
+func (*ShowTagKeysStatement) node()                {}
+func (*ShowTagValuesStatement) node()              {}
+func (*ShowTagValuesCardinalityStatement) node()   {}
+func (*ShowUsersStatement) node()                  {}
 
 func (*BinaryExpr) node()      {}
 func (*BooleanLiteral) node()  {}
@@ -134,6 +140,7 @@ func (*SortField) node()       {}
 func (SortFields) node()       {}
 func (Sources) node()          {}
 func (*StringLiteral) node()   {}
+func (*SubQuery) node()        {}
 func (*Target) node()          {}
 func (*TimeLiteral) node()     {}
 func (*VarRef) node()          {}
@@ -189,40 +196,46 @@ type ExecutionPrivilege struct {
 // ExecutionPrivileges is a list of privileges required to execute a statement.
 type ExecutionPrivileges []ExecutionPrivilege
 
-func (*AlterDatabaseRenameStatement) stmt()   {}
-func (*AlterRetentionPolicyStatement) stmt()  {}
-func (*CreateContinuousQueryStatement) stmt() {}
-func (*CreateDatabaseStatement) stmt()        {}
-func (*CreateRetentionPolicyStatement) stmt() {}
-func (*CreateUserStatement) stmt()            {}
-func (*DeleteStatement) stmt()                {}
-func (*DropContinuousQueryStatement) stmt()   {}
-func (*DropDatabaseStatement) stmt()          {}
-func (*DropMeasurementStatement) stmt()       {}
-func (*DropRetentionPolicyStatement) stmt()   {}
-func (*DropSeriesStatement) stmt()            {}
-func (*DropServerStatement) stmt()            {}
-func (*DropUserStatement) stmt()              {}
-func (*GrantStatement) stmt()                 {}
-func (*GrantAdminStatement) stmt()            {}
-func (*ShowContinuousQueriesStatement) stmt() {}
-func (*ShowGrantsForUserStatement) stmt()     {}
-func (*ShowServersStatement) stmt()           {}
-func (*ShowDatabasesStatement) stmt()         {}
-func (*ShowFieldKeysStatement) stmt()         {}
-func (*ShowMeasurementsStatement) stmt()      {}
-func (*ShowRetentionPoliciesStatement) stmt() {}
-func (*ShowSeriesStatement) stmt()            {}
-func (*ShowShardsStatement) stmt()            {}
-func (*ShowStatsStatement) stmt()             {}
-func (*ShowDiagnosticsStatement) stmt()       {}
-func (*ShowTagKeysStatement) stmt()           {}
-func (*ShowTagValuesStatement) stmt()         {}
-func (*ShowUsersStatement) stmt()             {}
-func (*RevokeStatement) stmt()                {}
-func (*RevokeAdminStatement) stmt()           {}
-func (*SelectStatement) stmt()                {}
-func (*SetPasswordUserStatement) stmt()       {}
+func (*AlterDatabaseRenameStatement) stmt()        {}
+func (*AlterRetentionPolicyStatement) stmt()       {}
+func (*CreateContinuousQueryStatement) stmt()      {}
+func (*CreateDatabaseStatement) stmt()             {}
+func (*CreateRetentionPolicyStatement) stmt()      {}
+func (*CreateUserStatement) stmt()                 {}
+func (*DeleteStatement) stmt()                     {}
+func (*DropContinuousQueryStatement) stmt()        {}
+func (*DropDatabaseStatement) stmt()               {}
+func (*DropMeasurementStatement) stmt()            {}
+func (*DropRetentionPolicyStatement) stmt()        {}
+func (*DropSeriesStatement) stmt()                 {}
+func (*DropServerStatement) stmt()                 {}
+func (*DropUserStatement) stmt()                   {}
+func (*ExplainStatement) stmt()                    {}
+func (*GrantStatement) stmt()                      {}
+func (*GrantAdminStatement) stmt()                 {}
+func (*KillQueryStatement) stmt()                  {}
+func (*ShowContinuousQueriesStatement) stmt()      {}
+func (*ShowGrantsForUserStatement) stmt()          {}
+func (*ShowServersStatement) stmt()                {}
+func (*ShowDatabasesStatement) stmt()              {}
+func (*ShowFieldKeysStatement) stmt()              {}
+func (*ShowMeasurementCardinalityStatement) stmt() {}
+func (*ShowMeasurementsStatement) stmt()           {}
+func (*ShowQueriesStatement) stmt()                {}
+func (*ShowRetentionPoliciesStatement) stmt()      {}
+func (*ShowSeriesStatement) stmt()                 {}
+func (*ShowSeriesCardinalityStatement) stmt()      {}
+func (*ShowShardsStatement) stmt()                 {}
+func (*ShowStatsStatement) stmt()                  {}
+func (*ShowDiagnosticsStatement) stmt()            {}
+func (*ShowTagKeysStatement) stmt()                {}
+func (*ShowTagValuesStatement) stmt()              {}
+func (*ShowTagValuesCardinalityStatement) stmt()   {}
+func (*ShowUsersStatement) stmt()                  {}
+func (*RevokeStatement) stmt()                     {}
+func (*RevokeAdminStatement) stmt()                {}
+func (*SelectStatement) stmt()                     {}
+func (*SetPasswordUserStatement) stmt()            {}
 
 // Expr represents an expression that can be evaluated to a value.
 type Expr interface {
@@ -251,6 +264,7 @@ type Source interface {
 }
 
 func (*Measurement) source() {}
+func (*SubQuery) source()    {}
 
 // Sources represents a list of sources.
 type Sources []Source
@@ -275,6 +289,9 @@ type SortField struct {
 	// Name of the field
 	Name string
 
+	// Aggregate to sort series by, instead of a field.
+	Call *Call
+
 	// Sort order.
 	Ascending bool
 }
@@ -282,7 +299,10 @@ type SortField struct {
 // String returns a string representation of a sort field
 func (field *SortField) String() string {
 	var buf bytes.Buffer
-	if field.Name != "" {
+	if field.Call != nil {
+		_, _ = buf.WriteString(field.Call.String())
+		_, _ = buf.WriteString(" ")
+	} else if field.Name != "" {
 		_, _ = buf.WriteString(field.Name)
 		_, _ = buf.WriteString(" ")
 	}
@@ -699,6 +719,9 @@ const (
 	NumberFill
 	// PreviousFill means that empty aggregate windows will be filled with whatever the previous aggregate window had
 	PreviousFill
+	// LinearFill means that empty aggregate windows will be filled by interpolating between the nearest
+	// non-empty windows on either side. Windows without a neighbor on both sides are left null.
+	LinearFill
 )
 
 // SelectStatement represents a command for extracting data from the database.
@@ -718,6 +741,9 @@ type SelectStatement struct {
 	// An expression evaluated on data point.
 	Condition Expr
 
+	// An expression evaluated on the results of aggregates.
+	Having Expr
+
 	// Fields to sort results by
 	SortFields SortFields
 
@@ -744,6 +770,10 @@ type SelectStatement struct {
 
 	// The value to fill empty aggregate buckets with, if any
 	FillValue interface{}
+
+	// The time zone that GROUP BY time() windows are aligned to and that times are returned in.
+	// If nil, UTC is used.
+	Location *time.Location
 }
 
 // SourceNames returns a list of source names.
@@ -758,26 +788,24 @@ func (s *SelectStatement) SourceNames() []string {
 	return a
 }
 
-// HasDerivative returns true if one of the function calls in the statement is a
-// derivative aggregate
-func (s *SelectStatement) HasDerivative() bool {
+// TransformCall returns the first function call in the statement that is a
+// transformation, such as derivative() or moving_average(), if any.
+func (s *SelectStatement) TransformCall() *Call {
 	for _, f := range s.FunctionCalls() {
-		if strings.HasSuffix(f.Name, "derivative") {
-			return true
+		if f.IsTransform() {
+			return f
 		}
 	}
-	return false
+	return nil
 }
 
-// IsSimpleDerivative return true if one of the function call is a derivative function with a
+// IsSimpleTransform return true if one of the function calls is a transformation with a
 // variable ref as the first arg
-func (s *SelectStatement) IsSimpleDerivative() bool {
-	for _, f := range s.FunctionCalls() {
-		if strings.HasSuffix(f.Name, "derivative") {
-			// it's nested if the first argument is an aggregate function
-			if _, ok := f.Args[0].(*VarRef); ok {
-				return true
-			}
+func (s *SelectStatement) IsSimpleTransform() bool {
+	if c := s.TransformCall(); c != nil && len(c.Args) > 0 {
+		// it's nested if the first argument is an aggregate function
+		if _, ok := c.Args[0].(*VarRef); ok {
+			return true
 		}
 	}
 	return false
@@ -785,7 +813,50 @@ func (s *SelectStatement) IsSimpleDerivative() bool {
 
 // TimeAscending returns true if the time field is sorted in chronological order.
 func (s *SelectStatement) TimeAscending() bool {
-	return len(s.SortFields) == 0 || s.SortFields[0].Ascending
+	for _, f := range s.SortFields {
+		if f.Call == nil {
+			return f.Ascending
+		}
+	}
+	return true
+}
+
+// SeriesSortField returns the sort field ordering series by an aggregate, if any.
+func (s *SelectStatement) SeriesSortField() *SortField {
+	for _, f := range s.SortFields {
+		if f.Call != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+// RewriteSeriesOrder splits a statement ordering series by an aggregate into a
+// statement selecting every series, in chronological order, and a statement
+// computing the aggregate of each series over the whole time range.
+// This method assumes all validation has passed.
+func (s *SelectStatement) RewriteSeriesOrder() (*SelectStatement, *SelectStatement) {
+	other := s.Clone()
+	other.SortFields = nil
+	other.SLimit, other.SOffset = 0, 0
+
+	rank := s.Clone()
+	rank.Fields = Fields{{Expr: CloneExpr(s.SeriesSortField().Call)}}
+	rank.Target = nil
+	rank.Having = nil
+	rank.SortFields = nil
+	rank.Fill, rank.FillValue = NullFill, nil
+	rank.IsRawQuery = false
+	rank.Limit, rank.Offset, rank.SLimit, rank.SOffset = 0, 0, 0, 0
+	dimensions := rank.Dimensions[:0]
+	for _, d := range rank.Dimensions {
+		if _, ok := d.Expr.(*Call); !ok {
+			dimensions = append(dimensions, d)
+		}
+	}
+	rank.Dimensions = dimensions
+
+	return other, rank
 }
 
 // Clone returns a deep copy of the statement.
@@ -796,6 +867,7 @@ func (s *SelectStatement) Clone() *SelectStatement {
 		Sources:    cloneSources(s.Sources),
 		SortFields: make(SortFields, 0, len(s.SortFields)),
 		Condition:  CloneExpr(s.Condition),
+		Having:     CloneExpr(s.Having),
 		Limit:      s.Limit,
 		Offset:     s.Offset,
 		SLimit:     s.SLimit,
@@ -803,6 +875,7 @@ func (s *SelectStatement) Clone() *SelectStatement {
 		Fill:       s.Fill,
 		FillValue:  s.FillValue,
 		IsRawQuery: s.IsRawQuery,
+		Location:   s.Location,
 	}
 	if s.Target != nil {
 		clone.Target = &Target{
@@ -821,7 +894,11 @@ func (s *SelectStatement) Clone() *SelectStatement {
 		clone.Dimensions = append(clone.Dimensions, &Dimension{Expr: CloneExpr(d.Expr)})
 	}
 	for _, f := range s.SortFields {
-		clone.SortFields = append(clone.SortFields, &SortField{Name: f.Name, Ascending: f.Ascending})
+		sf := &SortField{Name: f.Name, Ascending: f.Ascending}
+		if f.Call != nil {
+			sf.Call = CloneExpr(f.Call).(*Call)
+		}
+		clone.SortFields = append(clone.SortFields, sf)
 	}
 	return clone
 }
@@ -846,6 +923,8 @@ func cloneSource(s Source) Source {
 			m.Regex = &RegexLiteral{Val: regexp.MustCompile(s.Regex.Val.String())}
 		}
 		return m
+	case *SubQuery:
+		return &SubQuery{Statement: s.Statement.Clone()}
 	default:
 		panic("unreachable")
 	}
@@ -893,6 +972,45 @@ func (s *SelectStatement) RewriteWildcards(fields Fields, dimensions Dimensions)
 	return other
 }
 
+// HasWildcardCall returns true if a field calls a function on every field, e.g. mean(*).
+func (s *SelectStatement) HasWildcardCall() bool {
+	for _, f := range s.Fields {
+		if c, ok := f.Expr.(*Call); ok && len(c.Args) > 0 {
+			if _, ok := c.Args[0].(*Wildcard); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RewriteWildcardCalls returns a copy of the statement in which each call on every
+// field, e.g. mean(*), is replaced by a call for each of the fields returned by fn.
+// Each call is named after the function, or its alias, and the field, e.g. mean_value.
+func (s *SelectStatement) RewriteWildcardCalls(fn func(call *Call) []string) *SelectStatement {
+	other := s.Clone()
+	fields := make(Fields, 0, len(other.Fields))
+	for _, f := range other.Fields {
+		c, ok := f.Expr.(*Call)
+		if !ok || len(c.Args) == 0 {
+			fields = append(fields, f)
+			continue
+		} else if _, ok := c.Args[0].(*Wildcard); !ok {
+			fields = append(fields, f)
+			continue
+		}
+
+		prefix := f.Name()
+		for _, name := range fn(c) {
+			call := CloneExpr(c).(*Call)
+			call.Args[0] = &VarRef{Val: name}
+			fields = append(fields, &Field{Expr: call, Alias: prefix + "_" + name})
+		}
+	}
+	other.Fields = fields
+	return other
+}
+
 // RewriteDistinct rewrites the expression to be a call for map/reduce to work correctly
 // This method assumes all validation has passed
 func (s *SelectStatement) RewriteDistinct() {
@@ -904,35 +1022,175 @@ func (s *SelectStatement) RewriteDistinct() {
 	}
 }
 
+// IsJoin returns true if the statement selects from several measurements and
+// its fields are qualified by the measurement they are read from, e.g. requests.count.
+func (s *SelectStatement) IsJoin() bool {
+	if len(s.Sources) < 2 {
+		return false
+	}
+
+	var join bool
+	for _, f := range s.Fields {
+		WalkFunc(f.Expr, func(n Node) {
+			if ref, ok := n.(*VarRef); ok {
+				if i, _ := s.joinSource(ref.Val); i >= 0 {
+					join = true
+				}
+			}
+		})
+	}
+	return join
+}
+
+// joinSource returns the index of the source qualifying a field of a join and the
+// unqualified name of the field. Returns -1 if the field is not qualified.
+func (s *SelectStatement) joinSource(name string) (int, string) {
+	index, prefix := -1, ""
+	for i, src := range s.Sources {
+		mm, ok := src.(*Measurement)
+		if !ok || mm.Name == "" {
+			continue
+		}
+
+		// Measurement names may contain dots so use the longest match.
+		if p := mm.Name + "."; strings.HasPrefix(name, p) && len(p) > len(prefix) {
+			index, prefix = i, p
+		}
+	}
+	return index, strings.TrimPrefix(name, prefix)
+}
+
+// RewriteJoin splits a join into a statement for each of its measurements and a raw
+// statement combining their rows. Each call, or field of a raw query, is selected by
+// the statement of its measurement as a column named f0, f1, etc. The fields of the
+// combining statement refer to those columns and it is only grouped by tags.
+// This method assumes all validation has passed.
+func (s *SelectStatement) RewriteJoin() (*SelectStatement, []*SelectStatement) {
+	sources := make([]*SelectStatement, len(s.Sources))
+	for i := range s.Sources {
+		other := s.Clone()
+		other.Fields = nil
+		other.Sources = Sources{other.Sources[i]}
+		other.Target = nil
+		other.SortFields = nil
+		other.Limit, other.Offset, other.SLimit, other.SOffset = 0, 0, 0, 0
+		sources[i] = other
+	}
+
+	other := s.Clone()
+	columns := make(map[string]string)
+	for _, f := range other.Fields {
+		if f.Alias == "" {
+			f.Alias = f.Name()
+		}
+
+		f.Expr = rewriteJoinTerms(f.Expr, func(expr Expr) Expr {
+			// Select each term once from the statement of its measurement.
+			name, ok := columns[expr.String()]
+			if !ok {
+				name = fmt.Sprintf("f%d", len(columns))
+				columns[expr.String()] = name
+
+				index := 0
+				expr = CloneExpr(expr)
+				WalkFunc(expr, func(n Node) {
+					if ref, ok := n.(*VarRef); ok {
+						index, ref.Val = s.joinSource(ref.Val)
+					}
+				})
+				sources[index].Fields = append(sources[index].Fields, &Field{Expr: expr, Alias: name})
+			}
+			return &VarRef{Val: name}
+		})
+	}
+
+	// The rows of the measurements are already restricted and bucketed by time.
+	other.IsRawQuery = true
+	other.Condition = nil
+	other.Fill, other.FillValue = NullFill, nil
+	dimensions := other.Dimensions[:0]
+	for _, d := range other.Dimensions {
+		if _, ok := d.Expr.(*Call); !ok {
+			dimensions = append(dimensions, d)
+		}
+	}
+	other.Dimensions = dimensions
+
+	return other, sources
+}
+
+// rewriteJoinTerms replaces each call, and each field outside of calls, of a join
+// field expression with the result of fn.
+func rewriteJoinTerms(expr Expr, fn func(Expr) Expr) Expr {
+	switch expr := expr.(type) {
+	case *Call:
+		return fn(expr)
+	case *VarRef:
+		if expr.Val == "time" {
+			return expr
+		}
+		return fn(expr)
+	case *BinaryExpr:
+		expr.LHS = rewriteJoinTerms(expr.LHS, fn)
+		expr.RHS = rewriteJoinTerms(expr.RHS, fn)
+	case *ParenExpr:
+		expr.Expr = rewriteJoinTerms(expr.Expr, fn)
+	}
+	return expr
+}
+
 // ColumnNames will walk all fields and functions and return the appropriate field names for the select statement
 // while maintaining order of the field names
 func (s *SelectStatement) ColumnNames() []string {
+	columnNames, _ := s.columns()
+	return columnNames
+}
+
+// FieldColumns returns the index, in ColumnNames, of the column holding the values of each field.
+func (s *SelectStatement) FieldColumns() []int {
+	_, fieldColumns := s.columns()
+	return fieldColumns
+}
+
+// columns returns the column names of the statement, and the index of the column
+// holding the values of each field.
+func (s *SelectStatement) columns() ([]string, []int) {
 	// Always set the first column to be time, even if they didn't specify it
 	columnNames := []string{"time"}
+	fieldColumns := make([]int, len(s.Fields))
 
 	// First walk each field
-	for _, field := range s.Fields {
+	for i, field := range s.Fields {
 		switch f := field.Expr.(type) {
 		case *Call:
 			if f.Name == "top" || f.Name == "bottom" {
 				if len(f.Args) == 2 {
+					fieldColumns[i] = len(columnNames)
 					columnNames = append(columnNames, f.Name)
 					continue
 				}
 				// We have a special case now where we have to add the column names for the fields TOP or BOTTOM asked for as well
 				columnNames = slices.Union(columnNames, f.Fields(), true)
+				for j, name := range columnNames {
+					if strings.EqualFold(name, f.Name) {
+						fieldColumns[i] = j
+						break
+					}
+				}
 				continue
 			}
+			fieldColumns[i] = len(columnNames)
 			columnNames = append(columnNames, field.Name())
 		default:
 			// time is always first, and we already added it, so ignore it if they asked for it anywhere else.
 			if field.Name() != "time" {
+				fieldColumns[i] = len(columnNames)
 				columnNames = append(columnNames, field.Name())
 			}
 		}
 	}
 
-	return columnNames
+	return columnNames, fieldColumns
 }
 
 // HasTimeFieldSpecified will walk all fields and determine if the user explicitly asked for time
@@ -975,6 +1233,12 @@ func (s *SelectStatement) String() string {
 		_, _ = buf.WriteString(fmt.Sprintf(" fill(%v)", s.FillValue))
 	case PreviousFill:
 		_, _ = buf.WriteString(" fill(previous)")
+	case LinearFill:
+		_, _ = buf.WriteString(" fill(linear)")
+	}
+	if s.Having != nil {
+		_, _ = buf.WriteString(" HAVING ")
+		_, _ = buf.WriteString(s.Having.String())
 	}
 	if len(s.SortFields) > 0 {
 		_, _ = buf.WriteString(" ORDER BY ")
@@ -993,6 +1257,9 @@ func (s *SelectStatement) String() string {
 	if s.SOffset > 0 {
 		_, _ = fmt.Fprintf(&buf, " SOFFSET %d", s.SOffset)
 	}
+	if s.Location != nil {
+		_, _ = fmt.Fprintf(&buf, " tz(%s)", QuoteString(s.Location.String()))
+	}
 	return buf.String()
 }
 
@@ -1058,7 +1325,23 @@ func (s *SelectStatement) validate(tr targetRequirement) error {
 		return err
 	}
 
-	if err := s.validateDerivative(); err != nil {
+	if err := s.validateScalarCalls(); err != nil {
+		return err
+	}
+
+	if err := s.validateTransform(); err != nil {
+		return err
+	}
+
+	if err := s.validateJoin(); err != nil {
+		return err
+	}
+
+	if err := s.validateHaving(); err != nil {
+		return err
+	}
+
+	if err := s.validateSeriesOrder(); err != nil {
 		return err
 	}
 
@@ -1078,14 +1361,16 @@ func (s *SelectStatement) validateDimensions() error {
 	for _, dim := range s.Dimensions {
 		switch expr := dim.Expr.(type) {
 		case *Call:
-			// Ensure the call is time() and it only has one duration argument.
+			// Ensure the call is time() and it has a duration argument and an optional duration offset.
 			// If we already have a duration
 			if expr.Name != "time" {
 				return errors.New("only time() calls allowed in dimensions")
-			} else if len(expr.Args) != 1 {
-				return errors.New("time dimension expected one argument")
+			} else if len(expr.Args) != 1 && len(expr.Args) != 2 {
+				return errors.New("time dimension expected 1 or 2 arguments")
 			} else if lit, ok := expr.Args[0].(*DurationLiteral); !ok {
 				return errors.New("time dimension must have one duration argument")
+			} else if _, ok := expr.Args[len(expr.Args)-1].(*DurationLiteral); !ok {
+				return errors.New("time dimension offset must be a duration")
 			} else if dur != 0 {
 				return errors.New("multiple time dimensions not allowed")
 			} else {
@@ -1118,6 +1403,11 @@ func (s *SelectStatement) validSelectWithAggregate() error {
 		}
 		if len(fieldCalls) != 0 {
 			numAggregates++
+
+			// Expressions combine the results of calls so can't refer to raw fields or tags.
+			if hasVarRefOutsideCall(f.Expr) {
+				return fmt.Errorf("mixing aggregate and non-aggregate queries is not supported")
+			}
 		}
 	}
 	// For TOP, BOTTOM, MAX, MIN, FIRST, LAST (selector functions) it is ok to ask for fields and tags
@@ -1148,16 +1438,63 @@ func (s *SelectStatement) validSelectWithAggregate() error {
 	return nil
 }
 
+// hasVarRefOutsideCall returns true if expr refers to a field or tag other than
+// as the argument of a function call.
+func hasVarRefOutsideCall(expr Expr) bool {
+	switch expr := expr.(type) {
+	case *VarRef:
+		return true
+	case *BinaryExpr:
+		return hasVarRefOutsideCall(expr.LHS) || hasVarRefOutsideCall(expr.RHS)
+	case *ParenExpr:
+		return hasVarRefOutsideCall(expr.Expr)
+	case *Call:
+		if expr.IsScalar() {
+			for _, arg := range expr.Args {
+				if hasVarRefOutsideCall(arg) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// validateScalarCalls ensures the scalar functions called by the fields and the
+// condition have valid arguments.
+func (s *SelectStatement) validateScalarCalls() error {
+	var err error
+	fn := func(n Node) {
+		if c, ok := n.(*Call); ok && c.IsScalar() && err == nil {
+			err = validateScalarCall(c)
+		}
+	}
+	WalkFunc(s.Fields, fn)
+	WalkFunc(s.Condition, fn)
+	return err
+}
+
 func (s *SelectStatement) validateAggregates(tr targetRequirement) error {
 	for _, f := range s.Fields {
 		for _, expr := range walkFunctionCalls(f.Expr) {
 			switch expr.Name {
-			case "derivative", "non_negative_derivative":
+			case "derivative", "non_negative_derivative", "difference", "moving_average", "cumulative_sum", "elapsed":
 				if err := s.validSelectWithAggregate(); err != nil {
 					return err
 				}
-				if min, max, got := 1, 2, len(expr.Args); got > max || got < min {
-					return fmt.Errorf("invalid number of arguments for %s, expected at least %d but no more than %d, got %d", expr.Name, min, max, got)
+				switch expr.Name {
+				case "difference", "cumulative_sum":
+					if exp, got := 1, len(expr.Args); got != exp {
+						return fmt.Errorf("invalid number of arguments for %s, expected %d, got %d", expr.Name, exp, got)
+					}
+				case "moving_average":
+					if exp, got := 2, len(expr.Args); got != exp {
+						return fmt.Errorf("invalid number of arguments for %s, expected %d, got %d", expr.Name, exp, got)
+					}
+				default:
+					if min, max, got := 1, 2, len(expr.Args); got > max || got < min {
+						return fmt.Errorf("invalid number of arguments for %s, expected at least %d but no more than %d, got %d", expr.Name, min, max, got)
+					}
 				}
 				// Validate that if they have grouping by time, they need a sub-call like min/max, etc.
 				groupByInterval, _ := s.GroupByInterval()
@@ -1167,7 +1504,7 @@ func (s *SelectStatement) validateAggregates(tr targetRequirement) error {
 					}
 				}
 
-			case "percentile":
+			case "percentile", "approx_percentile":
 				if err := s.validSelectWithAggregate(); err != nil {
 					return err
 				}
@@ -1176,9 +1513,58 @@ func (s *SelectStatement) validateAggregates(tr targetRequirement) error {
 				}
 				_, ok := expr.Args[1].(*NumberLiteral)
 				if !ok {
-					return fmt.Errorf("expected float argument in percentile()")
+					return fmt.Errorf("expected float argument in %s()", expr.Name)
+				}
+			case "histogram":
+				if err := s.validSelectWithAggregate(); err != nil {
+					return err
+				}
+				if exp, got := 2, len(expr.Args); got < exp {
+					return fmt.Errorf("invalid number of arguments for %s, expected at least %d, got %d", expr.Name, exp, got)
+				}
+				switch expr.Args[0].(type) {
+				case *VarRef:
+				case *Wildcard:
+					if f.Expr != expr {
+						return fmt.Errorf("%s(*) cannot be used in an expression", expr.Name)
+					}
+				default:
+					return fmt.Errorf("expected field argument in %s()", expr.Name)
+				}
+				for i, arg := range expr.Args[1:] {
+					lit, ok := arg.(*NumberLiteral)
+					if !ok {
+						return fmt.Errorf("expected number as bucket edge in %s(), found %s", expr.Name, arg)
+					} else if i > 0 && lit.Val <= expr.Args[i].(*NumberLiteral).Val {
+						return fmt.Errorf("bucket edges in %s() must be in ascending order", expr.Name)
+					}
+				}
+			case "integral":
+				if err := s.validSelectWithAggregate(); err != nil {
+					return err
+				}
+				if min, max, got := 1, 2, len(expr.Args); got > max || got < min {
+					return fmt.Errorf("invalid number of arguments for %s, expected at least %d but no more than %d, got %d", expr.Name, min, max, got)
+				}
+				switch expr.Args[0].(type) {
+				case *VarRef:
+				case *Wildcard:
+					if f.Expr != expr {
+						return fmt.Errorf("%s(*) cannot be used in an expression", expr.Name)
+					}
+				default:
+					return fmt.Errorf("expected field argument in %s()", expr.Name)
+				}
+				if len(expr.Args) > 1 {
+					if lit, ok := expr.Args[1].(*DurationLiteral); !ok || lit.Val <= 0 {
+						return fmt.Errorf("second argument to %s() must be a positive duration", expr.Name)
+					}
 				}
 			case "top", "bottom":
+				// Each point selected is returned as a row so can't be combined with other values.
+				if _, ok := f.Expr.(*Call); !ok {
+					return fmt.Errorf("%s() cannot be used in an expression", expr.Name)
+				}
 				if exp, got := 2, len(expr.Args); got < exp {
 					return fmt.Errorf("invalid number of arguments for %s, expected at least %d, got %d", expr.Name, exp, got)
 				}
@@ -1208,6 +1594,11 @@ func (s *SelectStatement) validateAggregates(tr targetRequirement) error {
 				switch fc := expr.Args[0].(type) {
 				case *VarRef:
 					// do nothing
+				case *Wildcard:
+					// Expanded into a call for each field, so each call must be a field of its own.
+					if f.Expr != expr {
+						return fmt.Errorf("%s(*) cannot be used in an expression", expr.Name)
+					}
 				case *Call:
 					if fc.Name != "distinct" {
 						return fmt.Errorf("expected field argument in %s()", expr.Name)
@@ -1233,15 +1624,50 @@ func (s *SelectStatement) validateAggregates(tr targetRequirement) error {
 		return fmt.Errorf("GROUP BY requires at least one aggregate function")
 	}
 
-	// If we have an aggregate function with a group by time without a where clause, it's an invalid statement
+	// If we have an aggregate function with a group by time without a where clause, it's an invalid statement.
+	// Subqueries are checked once the enclosing statement has been parsed since the time range can be
+	// set on either side.
 	if tr == targetNotRequired { // ignore create continuous query statements
-		if !s.IsRawQuery && groupByDuration > 0 && !HasTimeExpr(s.Condition) {
-			return fmt.Errorf("aggregate functions with GROUP BY time require a WHERE time clause")
+		if err := s.validateGroupByTime(false); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// validateGroupByTime ensures that every GROUP BY time aggregate in the statement and its subqueries
+// is bounded by a time range. The time range of an outer statement applies to its subqueries and the
+// time range of a subquery bounds the statement selecting from it.
+func (s *SelectStatement) validateGroupByTime(outerHasTime bool) error {
+	hasTime := outerHasTime || HasTimeExpr(s.Condition)
+
+	groupByDuration, _ := s.GroupByInterval()
+	if !s.IsRawQuery && groupByDuration > 0 && !hasTime && !s.subQueryHasTime() {
+		return fmt.Errorf("aggregate functions with GROUP BY time require a WHERE time clause")
+	}
+
+	for _, src := range s.Sources {
+		if sq, ok := src.(*SubQuery); ok {
+			if err := sq.Statement.validateGroupByTime(hasTime); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// subQueryHasTime returns true if any subquery of the statement has a time condition.
+func (s *SelectStatement) subQueryHasTime() bool {
+	for _, src := range s.Sources {
+		if sq, ok := src.(*SubQuery); ok {
+			if HasTimeExpr(sq.Statement.Condition) || sq.Statement.subQueryHasTime() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (s *SelectStatement) HasDistinct() bool {
 	// determine if we have a call named distinct
 	for _, f := range s.Fields {
@@ -1334,47 +1760,219 @@ func (s *SelectStatement) validateCountDistinct() error {
 	return nil
 }
 
-func (s *SelectStatement) validateDerivative() error {
-	if !s.HasDerivative() {
+func (s *SelectStatement) validateTransform() error {
+	call := s.TransformCall()
+	if call == nil {
 		return nil
 	}
 
-	// If a derivative is requested, it must be the only field in the query. We don't support
-	// multiple fields in combination w/ derivaties yet.
+	// Both kinds of derivative share their error messages.
+	name := call.Name
+	if strings.HasSuffix(name, "derivative") {
+		name = "derivative"
+	}
+
+	// If a transformation is requested, it must be the only field in the query. We don't support
+	// multiple fields in combination w/ transformations yet.
 	if len(s.Fields) != 1 {
-		return fmt.Errorf("derivative cannot be used with other fields")
+		return fmt.Errorf("%s cannot be used with other fields", name)
 	}
 
 	aggr := s.FunctionCalls()
 	if len(aggr) != 1 {
-		return fmt.Errorf("derivative cannot be used with other fields")
+		return fmt.Errorf("%s cannot be used with other fields", name)
 	}
 
-	// Derivative requires two arguments
-	derivativeCall := aggr[0]
-	if len(derivativeCall.Args) == 0 {
-		return fmt.Errorf("derivative requires a field argument")
+	if len(call.Args) == 0 {
+		return fmt.Errorf("%s requires a field argument", name)
 	}
 
 	// First arg must be a field or aggr over a field e.g. (mean(field))
-	_, callOk := derivativeCall.Args[0].(*Call)
-	_, varOk := derivativeCall.Args[0].(*VarRef)
+	_, callOk := call.Args[0].(*Call)
+	_, varOk := call.Args[0].(*VarRef)
 
 	if !(callOk || varOk) {
-		return fmt.Errorf("derivative requires a field argument")
+		return fmt.Errorf("%s requires a field argument", name)
+	}
+
+	// Check the optional second argument.
+	if len(call.Args) == 2 {
+		switch call.Name {
+		case "moving_average":
+			// Second must be the number of points to average e.g. (10)
+			lit, ok := call.Args[1].(*NumberLiteral)
+			if !ok || lit.Val != float64(int64(lit.Val)) {
+				return fmt.Errorf("moving_average requires an integer window argument")
+			} else if lit.Val <= 1 {
+				return fmt.Errorf("moving_average window must be greater than 1")
+			}
+		default:
+			// Second must be a duration .e.g (1h)
+			lit, ok := call.Args[1].(*DurationLiteral)
+			if !ok {
+				return fmt.Errorf("%s requires a duration argument", name)
+			} else if call.Name == "elapsed" && lit.Val <= 0 {
+				return fmt.Errorf("elapsed requires a positive duration argument")
+			}
+		}
 	}
 
-	// If a duration arg is pased, make sure it's a duration
-	if len(derivativeCall.Args) == 2 {
-		// Second must be a duration .e.g (1h)
-		if _, ok := derivativeCall.Args[1].(*DurationLiteral); !ok {
-			return fmt.Errorf("derivative requires a duration argument")
+	return nil
+}
+
+func (s *SelectStatement) validateJoin() error {
+	if !s.IsJoin() {
+		return nil
+	}
+
+	for _, src := range s.Sources {
+		if mm, ok := src.(*Measurement); !ok || mm.Name == "" {
+			return errors.New("a join must select from named measurements")
+		}
+	}
+	if s.HasWildcard() {
+		return errors.New("wildcards are not supported in a join")
+	}
+
+	// Each call, or field outside of a call, must read from a single measurement.
+	for _, f := range s.Fields {
+		var err error
+		rewriteJoinTerms(f.Expr, func(expr Expr) Expr {
+			if call, ok := expr.(*Call); ok && (call.Name == "top" || call.Name == "bottom") {
+				err = fmt.Errorf("%s() is not supported in a join", call.Name)
+			}
+
+			index := -1
+			WalkFunc(expr, func(n Node) {
+				ref, ok := n.(*VarRef)
+				if !ok || err != nil {
+					return
+				}
+				i, _ := s.joinSource(ref.Val)
+				if i < 0 {
+					err = fmt.Errorf("field %s must be qualified by its measurement in a join", ref.Val)
+				} else if index >= 0 && i != index {
+					err = fmt.Errorf("%s reads from more than one measurement", expr)
+				}
+				index = i
+			})
+			if index < 0 && err == nil {
+				err = fmt.Errorf("%s must read from a measurement in a join", expr)
+			}
+			return expr
+		})
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+func (s *SelectStatement) validateSeriesOrder() error {
+	f := s.SeriesSortField()
+	if f == nil {
+		return nil
+	}
+
+	switch {
+	case f.Call.IsTransform(), f.Call.Name == "top", f.Call.Name == "bottom", f.Call.Name == "distinct":
+		return fmt.Errorf("%s() can't be used to order series", f.Call.Name)
+	case len(f.Call.Args) == 0:
+		return fmt.Errorf("%s() requires a field argument to order series", f.Call.Name)
+	case s.IsJoin():
+		return errors.New("ordering series by an aggregate is not supported in a join")
+	}
+	return nil
+}
+
+func (s *SelectStatement) validateHaving() error {
+	if s.Having == nil {
+		return nil
+	}
+
+	if s.IsRawQuery || s.IsSimpleTransform() {
+		return errors.New("HAVING requires an aggregate function")
+	} else if s.IsJoin() {
+		return errors.New("HAVING is not supported in a join")
+	}
+
+	// Aggregates in the condition must be selected as a field of their own, and names
+	// must refer to a single selected field.
+	_, err := s.havingExpr()
+	return err
+}
+
+// HavingExpr returns the HAVING condition with each aggregate, and each name of a field,
+// replaced by a reference to the column of its field, so it can be evaluated against the
+// values of a result. Columns are referenced by their position, named by ColumnRef, as
+// several fields may share a name.
+func (s *SelectStatement) HavingExpr() Expr {
+	if s.Having == nil {
+		return nil
+	}
+	expr, _ := s.havingExpr()
+	return expr
+}
+
+// ColumnRef returns the name referencing the column at index i in the expression returned
+// by HavingExpr.
+func ColumnRef(i int) string { return "$" + strconv.Itoa(i) }
+
+// havingExpr returns the expression returned by HavingExpr, or an error if the HAVING
+// condition refers to something other than a selected field.
+func (s *SelectStatement) havingExpr() (Expr, error) {
+	_, columns := s.columns()
+
+	var rewrite func(expr Expr) (Expr, error)
+	rewrite = func(expr Expr) (Expr, error) {
+		switch expr := expr.(type) {
+		case *BinaryExpr:
+			lhs, err := rewrite(expr.LHS)
+			if err != nil {
+				return nil, err
+			}
+			rhs, err := rewrite(expr.RHS)
+			if err != nil {
+				return nil, err
+			}
+			return &BinaryExpr{Op: expr.Op, LHS: lhs, RHS: rhs}, nil
+		case *ParenExpr:
+			other, err := rewrite(expr.Expr)
+			if err != nil {
+				return nil, err
+			}
+			return &ParenExpr{Expr: other}, nil
+		case *Call:
+			for i, f := range s.Fields {
+				if c, ok := f.Expr.(*Call); ok && c.String() == expr.String() {
+					return &VarRef{Val: ColumnRef(columns[i])}, nil
+				}
+			}
+			return nil, fmt.Errorf("%s must be selected to be used in HAVING", expr)
+		case *VarRef:
+			if expr.Val == "time" {
+				return &VarRef{Val: ColumnRef(0)}, nil
+			}
+			column := -1
+			for i, f := range s.Fields {
+				if f.Name() != expr.Val {
+					continue
+				} else if column >= 0 {
+					return nil, fmt.Errorf("%s is the name of several fields, use an alias to refer to one in HAVING", expr.Val)
+				}
+				column = columns[i]
+			}
+			if column < 0 {
+				return nil, fmt.Errorf("%s must be the name of a selected field to be used in HAVING", expr.Val)
+			}
+			return &VarRef{Val: ColumnRef(column)}, nil
+		}
+		return expr, nil
+	}
+	return rewrite(s.Having)
+}
+
 // GroupByIterval extracts the time interval, if specified.
 func (s *SelectStatement) GroupByInterval() (time.Duration, error) {
 	// return if we've already pulled it out
@@ -1389,9 +1987,9 @@ func (s *SelectStatement) GroupByInterval() (time.Duration, error) {
 
 	for _, d := range s.Dimensions {
 		if call, ok := d.Expr.(*Call); ok && call.Name == "time" {
-			// Make sure there is exactly one argument.
-			if len(call.Args) != 1 {
-				return 0, errors.New("time dimension expected one argument")
+			// Make sure there is a duration and at most an offset.
+			if len(call.Args) != 1 && len(call.Args) != 2 {
+				return 0, errors.New("time dimension expected 1 or 2 arguments")
 			}
 
 			// Ensure the argument is a duration.
@@ -1406,6 +2004,31 @@ func (s *SelectStatement) GroupByInterval() (time.Duration, error) {
 	return 0, nil
 }
 
+// GroupByOffset extracts the offset of the GROUP BY time() windows from the epoch.
+// The offset is normalized to be within the interval.
+func (s *SelectStatement) GroupByOffset() (time.Duration, error) {
+	interval, err := s.GroupByInterval()
+	if err != nil || interval == 0 {
+		return 0, err
+	}
+
+	for _, d := range s.Dimensions {
+		if call, ok := d.Expr.(*Call); ok && call.Name == "time" && len(call.Args) == 2 {
+			lit, ok := call.Args[1].(*DurationLiteral)
+			if !ok {
+				return 0, errors.New("time dimension offset must be a duration")
+			}
+
+			offset := lit.Val % interval
+			if offset < 0 {
+				offset += interval
+			}
+			return offset, nil
+		}
+	}
+	return 0, nil
+}
+
 // SetTimeRange sets the start and end time of the select statement to [start, end). i.e. start inclusive, end exclusive.
 // This is used commonly for continuous queries so the start and end are in buckets.
 func (s *SelectStatement) SetTimeRange(start, end time.Time) error {
@@ -1436,6 +2059,9 @@ func (s *SelectStatement) rewriteWithoutTimeDimensions() string {
 			}
 			return n
 		case *Call:
+			if n.IsScalar() {
+				return n
+			}
 			return &BooleanLiteral{Val: true}
 		default:
 			return n
@@ -1566,6 +2192,13 @@ func walkNames(exp Expr) []string {
 	case *VarRef:
 		return []string{expr.Val}
 	case *Call:
+		if expr.IsScalar() {
+			var ret []string
+			for _, arg := range expr.Args {
+				ret = append(ret, walkNames(arg)...)
+			}
+			return ret
+		}
 		if len(expr.Args) == 0 {
 			return nil
 		}
@@ -1605,12 +2238,20 @@ func (s *SelectStatement) FunctionCallsByPosition() [][]*Call {
 	return a
 }
 
-// walkFunctionCalls walks the Field of a query for any function calls made
+// walkFunctionCalls walks the Field of a query for any function calls made.
+// Scalar functions are not returned but the calls in their arguments are.
 func walkFunctionCalls(exp Expr) []*Call {
 	switch expr := exp.(type) {
 	case *VarRef:
 		return nil
 	case *Call:
+		if expr.IsScalar() {
+			var ret []*Call
+			for _, arg := range expr.Args {
+				ret = append(ret, walkFunctionCalls(arg)...)
+			}
+			return ret
+		}
 		return []*Call{expr}
 	case *BinaryExpr:
 		var ret []*Call
@@ -1683,6 +2324,31 @@ type Target struct {
 	Measurement *Measurement
 }
 
+// MeasurementName returns the name of the measurement that results read from the
+// measurement name are written into. References to capture groups, like ${1}, are
+// replaced by the groups the first matching regex source captures from name. An
+// empty name, or the :MEASUREMENT placeholder within the name, is replaced by name.
+func (t *Target) MeasurementName(name string, sources Sources) string {
+	target := t.Measurement.Name
+	if target == "" {
+		return name
+	}
+
+	if strings.Contains(target, "$") {
+		for _, src := range sources {
+			mm, ok := src.(*Measurement)
+			if !ok || mm.Regex == nil {
+				continue
+			}
+			if m := mm.Regex.Val.FindStringSubmatchIndex(name); m != nil {
+				target = string(mm.Regex.Val.ExpandString(nil, target, name, m))
+				break
+			}
+		}
+	}
+	return strings.Replace(target, ":MEASUREMENT", name, -1)
+}
+
 // String returns a string representation of the Target.
 func (t *Target) String() string {
 	if t == nil {
@@ -1711,13 +2377,13 @@ type DeleteStatement struct {
 // String returns a string representation of the delete statement.
 func (s *DeleteStatement) String() string {
 	var buf bytes.Buffer
-	_, _ = buf.WriteString("DELETE ")
+	_, _ = buf.WriteString("DELETE FROM ")
 	_, _ = buf.WriteString(s.Source.String())
 	if s.Condition != nil {
 		_, _ = buf.WriteString(" WHERE ")
 		_, _ = buf.WriteString(s.Condition.String())
 	}
-	return s.String()
+	return buf.String()
 }
 
 // RequiredPrivileges returns the privilege required to execute a DeleteStatement.
@@ -1778,6 +2444,36 @@ func (s *ShowSeriesStatement) RequiredPrivileges() ExecutionPrivileges {
 	return ExecutionPrivileges{{Admin: false, Name: "", Privilege: ReadPrivilege}}
 }
 
+// ShowSeriesCardinalityStatement represents a command for counting the series in the database.
+type ShowSeriesCardinalityStatement struct {
+	// Measurement(s) the series are counted for.
+	Sources Sources
+
+	// An expression evaluated on a series name or tag.
+	Condition Expr
+}
+
+// String returns a string representation of the statement.
+func (s *ShowSeriesCardinalityStatement) String() string {
+	var buf bytes.Buffer
+	_, _ = buf.WriteString("SHOW SERIES CARDINALITY")
+
+	if s.Sources != nil {
+		_, _ = buf.WriteString(" FROM ")
+		_, _ = buf.WriteString(s.Sources.String())
+	}
+	if s.Condition != nil {
+		_, _ = buf.WriteString(" WHERE ")
+		_, _ = buf.WriteString(s.Condition.String())
+	}
+	return buf.String()
+}
+
+// RequiredPrivileges returns the privilege required to execute a ShowSeriesCardinalityStatement.
+func (s *ShowSeriesCardinalityStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: false, Name: "", Privilege: ReadPrivilege}}
+}
+
 // DropSeriesStatement represents a command for removing a series from the database.
 type DropSeriesStatement struct {
 	// Data source that fields are extracted from (optional)
@@ -1990,6 +2686,29 @@ func (s *ShowMeasurementsStatement) RequiredPrivileges() ExecutionPrivileges {
 	return ExecutionPrivileges{{Admin: false, Name: "", Privilege: ReadPrivilege}}
 }
 
+// ShowMeasurementCardinalityStatement represents a command for counting measurements.
+type ShowMeasurementCardinalityStatement struct {
+	// An expression evaluated on a series name or tag.
+	Condition Expr
+}
+
+// String returns a string representation of the statement.
+func (s *ShowMeasurementCardinalityStatement) String() string {
+	var buf bytes.Buffer
+	_, _ = buf.WriteString("SHOW MEASUREMENT CARDINALITY")
+
+	if s.Condition != nil {
+		_, _ = buf.WriteString(" WHERE ")
+		_, _ = buf.WriteString(s.Condition.String())
+	}
+	return buf.String()
+}
+
+// RequiredPrivileges returns the privilege(s) required to execute a ShowMeasurementCardinalityStatement
+func (s *ShowMeasurementCardinalityStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: false, Name: "", Privilege: ReadPrivilege}}
+}
+
 // DropMeasurementStatement represents a command to drop a measurement.
 type DropMeasurementStatement struct {
 	// Name of the measurement to be dropped.
@@ -2061,6 +2780,58 @@ func (s *ShowShardsStatement) RequiredPrivileges() ExecutionPrivileges {
 	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
 }
 
+// ExplainStatement represents a command for describing how a SELECT statement
+// is executed. With ANALYZE the statement is also run.
+type ExplainStatement struct {
+	Statement *SelectStatement
+
+	// Analyze runs the statement and reports the time spent in each phase.
+	Analyze bool
+}
+
+// String returns a string representation of the explain statement.
+func (s *ExplainStatement) String() string {
+	var buf bytes.Buffer
+	_, _ = buf.WriteString("EXPLAIN ")
+	if s.Analyze {
+		_, _ = buf.WriteString("ANALYZE ")
+	}
+	_, _ = buf.WriteString(s.Statement.String())
+	return buf.String()
+}
+
+// RequiredPrivileges returns the privileges required to execute the SELECT statement.
+func (s *ExplainStatement) RequiredPrivileges() ExecutionPrivileges {
+	return s.Statement.RequiredPrivileges()
+}
+
+// ShowQueriesStatement represents a command for listing the queries running on the server.
+type ShowQueriesStatement struct{}
+
+// String returns a string representation of the statement.
+func (s *ShowQueriesStatement) String() string { return "SHOW QUERIES" }
+
+// RequiredPrivileges returns the privileges required to execute the statement.
+func (s *ShowQueriesStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
+}
+
+// KillQueryStatement represents a command for stopping a running query.
+type KillQueryStatement struct {
+	// ID of the query to be killed, as listed by SHOW QUERIES.
+	QueryID uint64
+}
+
+// String returns a string representation of the statement.
+func (s *KillQueryStatement) String() string {
+	return "KILL QUERY " + strconv.FormatUint(s.QueryID, 10)
+}
+
+// RequiredPrivileges returns the privileges required to execute the statement.
+func (s *KillQueryStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
+}
+
 // ShowDiagnosticsStatement represents a command for show node diagnostics.
 type ShowDiagnosticsStatement struct {
 	// Module
@@ -2203,6 +2974,52 @@ func (s *ShowTagValuesStatement) RequiredPrivileges() ExecutionPrivileges {
 	return ExecutionPrivileges{{Admin: false, Name: "", Privilege: ReadPrivilege}}
 }
 
+// ShowTagValuesCardinalityStatement represents a command for counting the values of tag keys.
+type ShowTagValuesCardinalityStatement struct {
+	// Data source that tag values are counted for.
+	Sources Sources
+
+	// Tag key(s) to count values of.
+	TagKeys []string
+
+	// An expression evaluated on a series name or tag.
+	Condition Expr
+}
+
+// String returns a string representation of the statement.
+func (s *ShowTagValuesCardinalityStatement) String() string {
+	var buf bytes.Buffer
+	_, _ = buf.WriteString("SHOW TAG VALUES CARDINALITY")
+
+	if s.Sources != nil {
+		_, _ = buf.WriteString(" FROM ")
+		_, _ = buf.WriteString(s.Sources.String())
+	}
+	if len(s.TagKeys) == 1 {
+		_, _ = buf.WriteString(" WITH KEY = ")
+		_, _ = buf.WriteString(QuoteIdent(s.TagKeys[0]))
+	} else if len(s.TagKeys) > 1 {
+		_, _ = buf.WriteString(" WITH KEY IN (")
+		for i, k := range s.TagKeys {
+			if i > 0 {
+				_, _ = buf.WriteString(", ")
+			}
+			_, _ = buf.WriteString(QuoteIdent(k))
+		}
+		_, _ = buf.WriteString(")")
+	}
+	if s.Condition != nil {
+		_, _ = buf.WriteString(" WHERE ")
+		_, _ = buf.WriteString(s.Condition.String())
+	}
+	return buf.String()
+}
+
+// RequiredPrivileges returns the privilege(s) required to execute a ShowTagValuesCardinalityStatement
+func (s *ShowTagValuesCardinalityStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: false, Name: "", Privilege: ReadPrivilege}}
+}
+
 // ShowUsersStatement represents a command for listing users.
 type ShowUsersStatement struct{}
 
@@ -2428,6 +3245,16 @@ func (m *Measurement) String() string {
 	return buf.String()
 }
 
+// SubQuery is a source with a SelectStatement as the backing store.
+type SubQuery struct {
+	Statement *SelectStatement
+}
+
+// String returns a string representation of the subquery.
+func (s *SubQuery) String() string {
+	return fmt.Sprintf("(%s)", s.Statement.String())
+}
+
 // VarRef represents a reference to a variable.
 type VarRef struct {
 	Val string
@@ -2456,6 +3283,27 @@ func (c *Call) String() string {
 	return fmt.Sprintf("%s(%s)", c.Name, strings.Join(str, ", "))
 }
 
+// IsTransform returns true if the call is to a transformation function, such as derivative()
+// or cumulative_sum(), which is calculated over the consecutive values of a series instead of
+// all values in an interval.
+func (c *Call) IsTransform() bool {
+	switch c.Name {
+	case "derivative", "non_negative_derivative", "difference", "moving_average", "cumulative_sum", "elapsed":
+		return true
+	}
+	return false
+}
+
+// IsScalar returns true if the call is to a scalar function, such as strlen() or upper(),
+// which is evaluated on each value instead of aggregating them.
+func (c *Call) IsScalar() bool {
+	switch c.Name {
+	case "strlen", "substr", "lower", "upper", "concat", "regex_extract":
+		return true
+	}
+	return false
+}
+
 // Fields will extract any field names from the call.  Only specific calls support this.
 func (c *Call) Fields() []string {
 	switch c.Name {
@@ -2838,6 +3686,9 @@ func Walk(v Visitor, node Node) {
 	case *CreateContinuousQueryStatement:
 		Walk(v, n.Source)
 
+	case *ExplainStatement:
+		Walk(v, n.Statement)
+
 	case *Dimension:
 		Walk(v, n.Expr)
 
@@ -2846,6 +3697,10 @@ func Walk(v Visitor, node Node) {
 			Walk(v, c)
 		}
 
+	case *DeleteStatement:
+		Walk(v, n.Source)
+		Walk(v, n.Condition)
+
 	case *DropSeriesStatement:
 		Walk(v, n.Sources)
 		Walk(v, n.Condition)
@@ -2876,6 +3731,13 @@ func Walk(v Visitor, node Node) {
 		Walk(v, n.Sources)
 		Walk(v, n.Condition)
 
+	case *ShowSeriesCardinalityStatement:
+		Walk(v, n.Sources)
+		Walk(v, n.Condition)
+
+	case *ShowMeasurementCardinalityStatement:
+		Walk(v, n.Condition)
+
 	case *ShowTagKeysStatement:
 		Walk(v, n.Sources)
 		Walk(v, n.Condition)
@@ -2886,6 +3748,10 @@ func Walk(v Visitor, node Node) {
 		Walk(v, n.Condition)
 		Walk(v, n.SortFields)
 
+	case *ShowTagValuesCardinalityStatement:
+		Walk(v, n.Sources)
+		Walk(v, n.Condition)
+
 	case *ShowFieldKeysStatement:
 		Walk(v, n.Sources)
 		Walk(v, n.SortFields)
@@ -2895,6 +3761,9 @@ func Walk(v Visitor, node Node) {
 			Walk(v, sf)
 		}
 
+	case *SubQuery:
+		Walk(v, n.Statement)
+
 	case Sources:
 		for _, s := range n {
 			Walk(v, s)
@@ -3001,10 +3870,21 @@ func Eval(expr Expr, m map[string]interface{}) interface{} {
 		return expr.Val
 	case *ParenExpr:
 		return Eval(expr.Expr, m)
+	case *RegexLiteral:
+		return expr.Val
 	case *StringLiteral:
 		return expr.Val
 	case *VarRef:
 		return m[expr.Val]
+	case *Call:
+		if !expr.IsScalar() {
+			return nil
+		}
+		args := make([]interface{}, len(expr.Args))
+		for i, arg := range expr.Args {
+			args[i] = Eval(arg, m)
+		}
+		return evalScalarCall(expr.Name, args)
 	default:
 		return nil
 	}
@@ -3059,7 +3939,10 @@ func evalBinaryExpr(expr *BinaryExpr, m map[string]interface{}) interface{} {
 		// we parse all number literals as float 64, so we have to convert from
 		// an interface to the float64, then cast to an int64 for comparison
 		rhsf, _ := rhs.(float64)
-		rhs := int64(rhsf)
+		rhs, ok := rhs.(int64)
+		if !ok {
+			rhs = int64(rhsf)
+		}
 		switch expr.Op {
 		case EQ:
 			return lhs == rhs
@@ -420,6 +420,131 @@ func TestSelectStatement_RewriteWildcards(t *testing.T) {
 	}
 }
 
+// Ensure calls on every field are rewritten into a call on each field.
+func TestSelectStatement_RewriteWildcardCalls(t *testing.T) {
+	var tests = []struct {
+		stmt    string
+		rewrite string
+	}{
+		{
+			stmt:    `SELECT mean(value) FROM cpu`,
+			rewrite: `SELECT mean(value) FROM cpu`,
+		},
+		{
+			stmt:    `SELECT mean(*), max(value) FROM cpu`,
+			rewrite: `SELECT mean(value1) AS "mean_value1", mean(value2) AS "mean_value2", max(value) FROM cpu`,
+		},
+		{
+			stmt:    `SELECT percentile(*, 95) AS p95 FROM cpu GROUP BY *`,
+			rewrite: `SELECT percentile(value1, 95.000) AS "p95_value1", percentile(value2, 95.000) AS "p95_value2" FROM cpu GROUP BY *`,
+		},
+	}
+
+	for i, tt := range tests {
+		stmt := MustParseSelectStatement(tt.stmt)
+		rw := stmt.RewriteWildcardCalls(func(c *influxql.Call) []string { return []string{"value1", "value2"} })
+		if rw := rw.String(); tt.rewrite != rw {
+			t.Errorf("%d. %q: unexpected rewrite:\n\nexp=%s\n\ngot=%s\n\n", i, tt.stmt, tt.rewrite, rw)
+		}
+		if got, exp := stmt.HasWildcardCall(), tt.stmt != tt.rewrite; got != exp {
+			t.Errorf("%d. %q: unexpected HasWildcardCall: %v", i, tt.stmt, got)
+		}
+	}
+}
+
+// Ensure the name of a target measurement is derived from the measurement read.
+func TestTarget_MeasurementName(t *testing.T) {
+	var tests = []struct {
+		stmt string
+		name string
+		exp  string
+	}{
+		{stmt: `SELECT mean(value) INTO cpu_1h FROM cpu`, name: "cpu", exp: "cpu_1h"},
+		{stmt: `SELECT mean(value) INTO rp.:MEASUREMENT FROM /cpu/`, name: "cpu2", exp: "cpu2"},
+		{stmt: `SELECT mean(value) INTO ":MEASUREMENT_1h" FROM /cpu/`, name: "cpu2", exp: "cpu2_1h"},
+		{stmt: `SELECT mean(value) INTO "${1}_${2}" FROM /^(cpu|mem)(.*)$/`, name: "cpu_load", exp: "cpu__load"},
+		{stmt: `SELECT mean(value) INTO "${name}_1h" FROM cpu, /^(?P<name>[a-z]+)[0-9]+$/`, name: "mem1", exp: "mem_1h"},
+		{stmt: `SELECT mean(value) INTO "${1}_1h" FROM /^(cpu)$/`, name: "mem", exp: "${1}_1h"},
+	}
+
+	for i, tt := range tests {
+		stmt := MustParseSelectStatement(tt.stmt)
+		if got := stmt.Target.MeasurementName(tt.name, stmt.Sources); got != tt.exp {
+			t.Errorf("%d. %q: unexpected name: exp=%s got=%s", i, tt.stmt, tt.exp, got)
+		}
+	}
+}
+
+// Ensure a join is split into a statement for each measurement and one combining them.
+func TestSelectStatement_RewriteJoin(t *testing.T) {
+	var tests = []struct {
+		stmt    string
+		rewrite []string
+	}{
+		{
+			stmt: `SELECT sum(errors.count) / sum(requests.count) AS ratio FROM requests, errors WHERE host = 'a' AND time > now() - 1h GROUP BY time(1m), host LIMIT 10`,
+			rewrite: []string{
+				`SELECT f0 / f1 AS "ratio" FROM requests, errors GROUP BY host LIMIT 10`,
+				`SELECT sum(count) AS "f1" FROM requests WHERE host = 'a' AND time > now() - 1h GROUP BY time(1m), host`,
+				`SELECT sum(count) AS "f0" FROM errors WHERE host = 'a' AND time > now() - 1h GROUP BY time(1m), host`,
+			},
+		},
+		{
+			stmt: `SELECT "cpu.load".value - mem.value, mem.value FROM "cpu.load", mem`,
+			rewrite: []string{
+				`SELECT f0 - f1, f1 AS "mem.value" FROM "cpu.load", mem`,
+				`SELECT value AS "f0" FROM "cpu.load"`,
+				`SELECT value AS "f1" FROM mem`,
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		stmt := MustParseSelectStatement(tt.stmt)
+		if !stmt.IsJoin() {
+			t.Errorf("%d. %q: expected a join", i, tt.stmt)
+			continue
+		}
+
+		outer, inners := stmt.RewriteJoin()
+		got := []string{outer.String()}
+		for _, inner := range inners {
+			got = append(got, inner.String())
+		}
+		if !reflect.DeepEqual(got, tt.rewrite) {
+			t.Errorf("%d. %q: unexpected rewrite:\n\nexp=%#v\n\ngot=%#v\n\n", i, tt.stmt, tt.rewrite, got)
+		}
+	}
+
+	if MustParseSelectStatement(`SELECT value FROM cpu, mem`).IsJoin() {
+		t.Error("unexpected join")
+	}
+}
+
+// Ensure HAVING refers to the columns of the fields it names by their position.
+func TestSelectStatement_HavingExpr(t *testing.T) {
+	var tests = []struct {
+		stmt string
+		expr string
+	}{
+		{
+			stmt: `SELECT mean(value), mean(other) FROM cpu GROUP BY host HAVING mean(other) > 10`,
+			expr: `"$2" > 10.000`,
+		},
+		{
+			stmt: `SELECT max(value) AS peak, top(value, host, 2), count(value) FROM cpu HAVING peak > 1 AND (count(value) < 5 OR top > 2)`,
+			expr: `"$1" > 1.000 AND ("$4" < 5.000 OR "$2" > 2.000)`,
+		},
+	}
+
+	for i, tt := range tests {
+		stmt := MustParseSelectStatement(tt.stmt)
+		if got := stmt.HavingExpr().String(); got != tt.expr {
+			t.Errorf("%d. %q: unexpected expression:\n\nexp=%s\n\ngot=%s\n\n", i, tt.stmt, tt.expr, got)
+		}
+	}
+}
+
 // Ensure that the IsRawQuery flag gets set properly
 func TestSelectStatement_IsRawQuerySet(t *testing.T) {
 	var tests = []struct {
@@ -603,6 +728,12 @@ func TestParseString(t *testing.T) {
 		{
 			stmt: `SELECT * FROM myseries`,
 		},
+		{
+			stmt: `SELECT max(mean) FROM (SELECT mean(value) FROM cpu WHERE time > now() - 1h GROUP BY time(1m), host) GROUP BY time(1h)`,
+		},
+		{
+			stmt: `SELECT mean(value) FROM cpu WHERE time > now() - 1d GROUP BY time(1d, 2h) tz('Europe/Berlin')`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -651,6 +782,24 @@ func TestEval(t *testing.T) {
 		{in: `foo = 'bar'`, out: true, data: map[string]interface{}{"foo": "bar"}},
 		{in: `foo = 'bar'`, out: nil, data: map[string]interface{}{"foo": nil}},
 		{in: `foo <> 'bar'`, out: true, data: map[string]interface{}{"foo": "xxx"}},
+
+		// String functions.
+		{in: `strlen(foo)`, out: int64(5), data: map[string]interface{}{"foo": "héllo"}},
+		{in: `strlen(foo) > 3`, out: true, data: map[string]interface{}{"foo": "héllo"}},
+		{in: `strlen(foo)`, out: nil, data: map[string]interface{}{"foo": float64(1)}},
+		{in: `substr(foo, 2)`, out: "éllo", data: map[string]interface{}{"foo": "héllo"}},
+		{in: `substr(foo, 2, 3)`, out: "éll", data: map[string]interface{}{"foo": "héllo"}},
+		{in: `substr(foo, 0, 2)`, out: "hé", data: map[string]interface{}{"foo": "héllo"}},
+		{in: `substr(foo, 9)`, out: "", data: map[string]interface{}{"foo": "héllo"}},
+		{in: `upper(foo) = 'BAR'`, out: true, data: map[string]interface{}{"foo": "bar"}},
+		{in: `lower('FOO')`, out: "foo"},
+		{in: `concat(foo, '-', 1, 2.5, true)`, out: "bar-12.5true", data: map[string]interface{}{"foo": "bar"}},
+		{in: `concat(foo, bar)`, out: nil, data: map[string]interface{}{"foo": "bar"}},
+		{in: `concat(foo, '-', n)`, out: "bar-3", data: map[string]interface{}{"foo": "bar", "n": int64(3)}},
+		{in: `regex_extract(foo, /[0-9]+/)`, out: "42", data: map[string]interface{}{"foo": "server42a"}},
+		{in: `regex_extract(foo, /([a-z]+)([0-9]+)/, 1)`, out: "server", data: map[string]interface{}{"foo": "server42a"}},
+		{in: `regex_extract(foo, /([a-z]+)(-[0-9]+)?/, 2)`, out: nil, data: map[string]interface{}{"foo": "server"}},
+		{in: `regex_extract(foo, /[0-9]+/)`, out: nil, data: map[string]interface{}{"foo": "server"}},
 	} {
 		// Evaluate expression.
 		out := influxql.Eval(MustParseExpr(tt.in), tt.data)
@@ -0,0 +1,168 @@
+package influxql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// validateScalarCall ensures a call to a scalar function has the arguments it expects.
+func validateScalarCall(c *Call) error {
+	var min, max int
+	switch c.Name {
+	case "strlen", "lower", "upper":
+		min, max = 1, 1
+	case "substr":
+		min, max = 2, 3
+	case "regex_extract":
+		min, max = 2, 3
+	case "concat":
+		min, max = 1, -1
+	}
+
+	if got := len(c.Args); max == -1 && got < min {
+		return fmt.Errorf("invalid number of arguments for %s, expected at least %d, got %d", c.Name, min, got)
+	} else if min == max && got != min {
+		return fmt.Errorf("invalid number of arguments for %s, expected %d, got %d", c.Name, min, got)
+	} else if max != -1 && (got < min || got > max) {
+		return fmt.Errorf("invalid number of arguments for %s, expected at least %d but no more than %d, got %d", c.Name, min, max, got)
+	}
+
+	for i, arg := range c.Args {
+		switch arg.(type) {
+		case *Wildcard, *Distinct:
+			return fmt.Errorf("expected field argument in %s()", c.Name)
+		case *RegexLiteral:
+			if c.Name != "regex_extract" || i != 1 {
+				return fmt.Errorf("unexpected regex argument in %s()", c.Name)
+			}
+		}
+	}
+
+	if c.Name == "regex_extract" {
+		re, ok := c.Args[1].(*RegexLiteral)
+		if !ok {
+			return fmt.Errorf("expected regex argument in %s()", c.Name)
+		}
+		if len(c.Args) == 3 {
+			lit, ok := c.Args[2].(*NumberLiteral)
+			if !ok || lit.Val != float64(int(lit.Val)) || lit.Val < 0 || int(lit.Val) > re.Val.NumSubexp() {
+				return fmt.Errorf("invalid capture group %s in %s()", c.Args[2], c.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// evalScalarCall evaluates a scalar function on the values of its arguments.
+// Nil is returned if an argument has no value or a value of the wrong type.
+func evalScalarCall(name string, args []interface{}) interface{} {
+	switch name {
+	case "strlen":
+		if s, ok := args[0].(string); ok {
+			return int64(utf8.RuneCountInString(s))
+		}
+	case "lower":
+		if s, ok := args[0].(string); ok {
+			return strings.ToLower(s)
+		}
+	case "upper":
+		if s, ok := args[0].(string); ok {
+			return strings.ToUpper(s)
+		}
+	case "substr":
+		return evalSubstr(args)
+	case "concat":
+		var buf []string
+		for _, arg := range args {
+			s, ok := scalarString(arg)
+			if !ok {
+				return nil
+			}
+			buf = append(buf, s)
+		}
+		return strings.Join(buf, "")
+	case "regex_extract":
+		s, ok := args[0].(string)
+		re, _ := args[1].(*regexp.Regexp)
+		if !ok || re == nil {
+			return nil
+		}
+
+		group := 0
+		if len(args) == 3 {
+			n, ok := scalarInt(args[2])
+			if !ok {
+				return nil
+			}
+			group = n
+		}
+
+		m := re.FindStringSubmatchIndex(s)
+		if m == nil || group < 0 || 2*group+1 >= len(m) || m[2*group] < 0 {
+			return nil
+		}
+		return s[m[2*group]:m[2*group+1]]
+	}
+	return nil
+}
+
+// evalSubstr returns the characters of a string starting at a 1-based position,
+// up to an optional length.
+func evalSubstr(args []interface{}) interface{} {
+	s, ok := args[0].(string)
+	start, ok2 := scalarInt(args[1])
+	if !ok || !ok2 {
+		return nil
+	}
+
+	r := []rune(s)
+	if start < 1 {
+		start = 1
+	}
+	if start > len(r) {
+		return ""
+	}
+	r = r[start-1:]
+
+	if len(args) == 3 {
+		n, ok := scalarInt(args[2])
+		if !ok {
+			return nil
+		} else if n < 0 {
+			n = 0
+		}
+		if n < len(r) {
+			r = r[:n]
+		}
+	}
+	return string(r)
+}
+
+// scalarInt returns the integer value of a number argument.
+func scalarInt(v interface{}) (int, bool) {
+	switch v := v.(type) {
+	case float64:
+		return int(v), true
+	case int64:
+		return int(v), true
+	}
+	return 0, false
+}
+
+// scalarString returns the string representation of a value concatenated by concat().
+func scalarString(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
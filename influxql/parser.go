@@ -23,7 +23,8 @@ const (
 
 // Parser represents an InfluxQL parser.
 type Parser struct {
-	s *bufScanner
+	s      *bufScanner
+	params map[string]interface{}
 }
 
 // NewParser returns a new instance of Parser.
@@ -31,6 +32,12 @@ func NewParser(r io.Reader) *Parser {
 	return &Parser{s: newBufScanner(r)}
 }
 
+// SetParams sets the values bound to the $name parameters of the query. Each
+// parameter is replaced by a literal of its value's type, see BindParam.
+func (p *Parser) SetParams(params map[string]interface{}) {
+	p.params = params
+}
+
 // ParseQuery parses a query string and returns its AST representation.
 func ParseQuery(s string) (*Query, error) { return NewParser(strings.NewReader(s)).ParseQuery() }
 
@@ -96,8 +103,12 @@ func (p *Parser) ParseStatement() (Statement, error) {
 		return p.parseAlterStatement()
 	case SET:
 		return p.parseSetPasswordUserStatement()
+	case KILL:
+		return p.parseKillQueryStatement()
+	case EXPLAIN:
+		return p.parseExplainStatement()
 	default:
-		return nil, newParseError(tokstr(tok, lit), []string{"SELECT", "DELETE", "SHOW", "CREATE", "DROP", "GRANT", "REVOKE", "ALTER", "SET"}, pos)
+		return nil, newParseError(tokstr(tok, lit), []string{"SELECT", "DELETE", "SHOW", "CREATE", "DROP", "GRANT", "REVOKE", "ALTER", "SET", "KILL", "EXPLAIN"}, pos)
 	}
 }
 
@@ -120,8 +131,16 @@ func (p *Parser) parseShowStatement() (Statement, error) {
 			return p.parseShowFieldKeysStatement()
 		}
 		return nil, newParseError(tokstr(tok, lit), []string{"KEYS", "VALUES"}, pos)
+	case MEASUREMENT:
+		tok, pos, lit := p.scanIgnoreWhitespace()
+		if tok == CARDINALITY {
+			return p.parseShowMeasurementCardinalityStatement()
+		}
+		return nil, newParseError(tokstr(tok, lit), []string{"CARDINALITY"}, pos)
 	case MEASUREMENTS:
 		return p.parseShowMeasurementsStatement()
+	case QUERIES:
+		return p.parseShowQueriesStatement()
 	case RETENTION:
 		tok, pos, lit := p.scanIgnoreWhitespace()
 		if tok == POLICIES {
@@ -129,6 +148,10 @@ func (p *Parser) parseShowStatement() (Statement, error) {
 		}
 		return nil, newParseError(tokstr(tok, lit), []string{"POLICIES"}, pos)
 	case SERIES:
+		if tok, _, _ := p.scanIgnoreWhitespace(); tok == CARDINALITY {
+			return p.parseShowSeriesCardinalityStatement()
+		}
+		p.unscan()
 		return p.parseShowSeriesStatement()
 	case SHARDS:
 		return p.parseShowShardsStatement()
@@ -141,6 +164,10 @@ func (p *Parser) parseShowStatement() (Statement, error) {
 		if tok == KEYS {
 			return p.parseShowTagKeysStatement()
 		} else if tok == VALUES {
+			if tok, _, _ := p.scanIgnoreWhitespace(); tok == CARDINALITY {
+				return p.parseShowTagValuesCardinalityStatement()
+			}
+			p.unscan()
 			return p.parseShowTagValuesStatement()
 		}
 		return nil, newParseError(tokstr(tok, lit), []string{"KEYS", "VALUES"}, pos)
@@ -153,7 +180,9 @@ func (p *Parser) parseShowStatement() (Statement, error) {
 		"DATABASES",
 		"FIELD",
 		"GRANTS",
+		"MEASUREMENT",
 		"MEASUREMENTS",
+		"QUERIES",
 		"RETENTION",
 		"SERIES",
 		"SERVERS",
@@ -464,7 +493,17 @@ func (p *Parser) parseDuration() (time.Duration, error) {
 // parseIdent parses an identifier.
 func (p *Parser) parseIdent() (string, error) {
 	tok, pos, lit := p.scanIgnoreWhitespace()
-	if tok != IDENT {
+	if tok == BOUNDPARAM {
+		expr, err := p.parseBoundParam(lit, pos)
+		if err != nil {
+			return "", err
+		}
+		ref, ok := expr.(*VarRef)
+		if !ok {
+			return "", &ParseError{Message: fmt.Sprintf("parameter %s must be an identifier", lit), Pos: pos}
+		}
+		return ref.Val, nil
+	} else if tok != IDENT {
 		return "", newParseError(tokstr(tok, lit), []string{"identifier"}, pos)
 	}
 	return lit, nil
@@ -745,7 +784,7 @@ func (p *Parser) parseSelectStatement(tr targetRequirement) (*SelectStatement, e
 	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != FROM {
 		return nil, newParseError(tokstr(tok, lit), []string{"FROM"}, pos)
 	}
-	if stmt.Sources, err = p.parseSources(); err != nil {
+	if stmt.Sources, err = p.parseSources(true); err != nil {
 		return nil, err
 	}
 
@@ -764,6 +803,11 @@ func (p *Parser) parseSelectStatement(tr targetRequirement) (*SelectStatement, e
 		return nil, err
 	}
 
+	// Parse aggregate condition: "HAVING EXPR".
+	if stmt.Having, err = p.parseHaving(); err != nil {
+		return nil, err
+	}
+
 	// Parse sort: "ORDER BY FIELD+".
 	if stmt.SortFields, err = p.parseOrderBy(); err != nil {
 		return nil, err
@@ -789,13 +833,13 @@ func (p *Parser) parseSelectStatement(tr targetRequirement) (*SelectStatement, e
 		return nil, err
 	}
 
+	// Parse time zone: "tz('<location>')".
+	if stmt.Location, err = p.parseLocation(); err != nil {
+		return nil, err
+	}
+
 	// Set if the query is a raw data query or one with an aggregate
-	stmt.IsRawQuery = true
-	WalkFunc(stmt.Fields, func(n Node) {
-		if _, ok := n.(*Call); ok {
-			stmt.IsRawQuery = false
-		}
-	})
+	stmt.IsRawQuery = len(stmt.FunctionCalls()) == 0
 
 	if err := stmt.validate(tr); err != nil {
 		return nil, err
@@ -810,6 +854,7 @@ type targetRequirement int
 const (
 	targetRequired targetRequirement = iota
 	targetNotRequired
+	targetSubquery
 )
 
 // parseTarget parses a string and returns a Target.
@@ -820,6 +865,8 @@ func (p *Parser) parseTarget(tr targetRequirement) (*Target, error) {
 		}
 		p.unscan()
 		return nil, nil
+	} else if tr == targetSubquery {
+		return nil, &ParseError{Message: "subqueries cannot have an INTO clause", Pos: pos}
 	}
 
 	// db, rp, and / or measurement
@@ -865,7 +912,7 @@ func (p *Parser) parseDeleteStatement() (*DeleteStatement, error) {
 	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != FROM {
 		return nil, newParseError(tokstr(tok, lit), []string{"FROM"}, pos)
 	}
-	source, err := p.parseSource()
+	source, err := p.parseSource(false)
 	if err != nil {
 		return nil, err
 	}
@@ -889,7 +936,7 @@ func (p *Parser) parseShowSeriesStatement() (*ShowSeriesStatement, error) {
 
 	// Parse optional FROM.
 	if tok, _, _ := p.scanIgnoreWhitespace(); tok == FROM {
-		if stmt.Sources, err = p.parseSources(); err != nil {
+		if stmt.Sources, err = p.parseSources(false); err != nil {
 			return nil, err
 		}
 	} else {
@@ -919,6 +966,43 @@ func (p *Parser) parseShowSeriesStatement() (*ShowSeriesStatement, error) {
 	return stmt, nil
 }
 
+// parseShowSeriesCardinalityStatement parses a string and returns a ShowSeriesCardinalityStatement.
+// This function assumes the "SHOW SERIES CARDINALITY" tokens have already been consumed.
+func (p *Parser) parseShowSeriesCardinalityStatement() (*ShowSeriesCardinalityStatement, error) {
+	stmt := &ShowSeriesCardinalityStatement{}
+	var err error
+
+	// Parse optional FROM.
+	if tok, _, _ := p.scanIgnoreWhitespace(); tok == FROM {
+		if stmt.Sources, err = p.parseSources(false); err != nil {
+			return nil, err
+		}
+	} else {
+		p.unscan()
+	}
+
+	// Parse condition: "WHERE EXPR".
+	if stmt.Condition, err = p.parseCondition(); err != nil {
+		return nil, err
+	}
+
+	return stmt, nil
+}
+
+// parseShowMeasurementCardinalityStatement parses a string and returns a ShowMeasurementCardinalityStatement.
+// This function assumes the "SHOW MEASUREMENT CARDINALITY" tokens have already been consumed.
+func (p *Parser) parseShowMeasurementCardinalityStatement() (*ShowMeasurementCardinalityStatement, error) {
+	stmt := &ShowMeasurementCardinalityStatement{}
+	var err error
+
+	// Parse condition: "WHERE EXPR".
+	if stmt.Condition, err = p.parseCondition(); err != nil {
+		return nil, err
+	}
+
+	return stmt, nil
+}
+
 // parseShowMeasurementsStatement parses a string and returns a ShowSeriesStatement.
 // This function assumes the "SHOW MEASUREMENTS" tokens have already been consumed.
 func (p *Parser) parseShowMeasurementsStatement() (*ShowMeasurementsStatement, error) {
@@ -976,7 +1060,7 @@ func (p *Parser) parseShowTagKeysStatement() (*ShowTagKeysStatement, error) {
 
 	// Parse optional source.
 	if tok, _, _ := p.scanIgnoreWhitespace(); tok == FROM {
-		if stmt.Sources, err = p.parseSources(); err != nil {
+		if stmt.Sources, err = p.parseSources(false); err != nil {
 			return nil, err
 		}
 	} else {
@@ -1024,7 +1108,7 @@ func (p *Parser) parseShowTagValuesStatement() (*ShowTagValuesStatement, error)
 
 	// Parse optional source.
 	if tok, _, _ := p.scanIgnoreWhitespace(); tok == FROM {
-		if stmt.Sources, err = p.parseSources(); err != nil {
+		if stmt.Sources, err = p.parseSources(false); err != nil {
 			return nil, err
 		}
 	} else {
@@ -1059,6 +1143,34 @@ func (p *Parser) parseShowTagValuesStatement() (*ShowTagValuesStatement, error)
 	return stmt, nil
 }
 
+// parseShowTagValuesCardinalityStatement parses a string and returns a ShowTagValuesCardinalityStatement.
+// This function assumes the "SHOW TAG VALUES CARDINALITY" tokens have already been consumed.
+func (p *Parser) parseShowTagValuesCardinalityStatement() (*ShowTagValuesCardinalityStatement, error) {
+	stmt := &ShowTagValuesCardinalityStatement{}
+	var err error
+
+	// Parse optional source.
+	if tok, _, _ := p.scanIgnoreWhitespace(); tok == FROM {
+		if stmt.Sources, err = p.parseSources(false); err != nil {
+			return nil, err
+		}
+	} else {
+		p.unscan()
+	}
+
+	// Parse required WITH KEY.
+	if stmt.TagKeys, err = p.parseTagKeys(); err != nil {
+		return nil, err
+	}
+
+	// Parse condition: "WHERE EXPR".
+	if stmt.Condition, err = p.parseCondition(); err != nil {
+		return nil, err
+	}
+
+	return stmt, nil
+}
+
 // parseTagKeys parses a string and returns a list of tag keys.
 func (p *Parser) parseTagKeys() ([]string, error) {
 	var err error
@@ -1114,7 +1226,7 @@ func (p *Parser) parseShowFieldKeysStatement() (*ShowFieldKeysStatement, error)
 
 	// Parse optional source.
 	if tok, _, _ := p.scanIgnoreWhitespace(); tok == FROM {
-		if stmt.Sources, err = p.parseSources(); err != nil {
+		if stmt.Sources, err = p.parseSources(false); err != nil {
 			return nil, err
 		}
 	} else {
@@ -1164,7 +1276,7 @@ func (p *Parser) parseDropSeriesStatement() (*DropSeriesStatement, error) {
 
 	if tok == FROM {
 		// Parse source.
-		if stmt.Sources, err = p.parseSources(); err != nil {
+		if stmt.Sources, err = p.parseSources(false); err != nil {
 			return nil, err
 		}
 	} else {
@@ -1499,6 +1611,53 @@ func (p *Parser) parseShowShardsStatement() (*ShowShardsStatement, error) {
 	return &ShowShardsStatement{}, nil
 }
 
+// parseShowQueriesStatement parses a string for "SHOW QUERIES" statement.
+// This function assumes the "SHOW QUERIES" tokens have already been consumed.
+func (p *Parser) parseShowQueriesStatement() (*ShowQueriesStatement, error) {
+	return &ShowQueriesStatement{}, nil
+}
+
+// parseExplainStatement parses a string and returns an ExplainStatement.
+// This function assumes the EXPLAIN token has already been consumed.
+func (p *Parser) parseExplainStatement() (*ExplainStatement, error) {
+	stmt := &ExplainStatement{}
+
+	// Parse optional ANALYZE token.
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	if tok == ANALYZE {
+		stmt.Analyze = true
+		tok, pos, lit = p.scanIgnoreWhitespace()
+	}
+
+	// Only SELECT statements can be explained.
+	if tok != SELECT {
+		return nil, newParseError(tokstr(tok, lit), []string{"SELECT"}, pos)
+	}
+
+	s, err := p.parseSelectStatement(targetNotRequired)
+	if err != nil {
+		return nil, err
+	}
+	stmt.Statement = s
+	return stmt, nil
+}
+
+// parseKillQueryStatement parses a string and returns a KillQueryStatement.
+// This function assumes the KILL token has already been consumed.
+func (p *Parser) parseKillQueryStatement() (*KillQueryStatement, error) {
+	// Expect a "QUERY" token.
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != QUERY {
+		return nil, newParseError(tokstr(tok, lit), []string{"QUERY"}, pos)
+	}
+
+	// Parse the query's ID.
+	id, err := p.parseUInt64()
+	if err != nil {
+		return nil, err
+	}
+	return &KillQueryStatement{QueryID: id}, nil
+}
+
 // parseShowStatsStatement parses a string and returns a ShowStatsStatement.
 // This function assumes the "SHOW STATS" tokens have already been consumed.
 func (p *Parser) parseShowStatsStatement() (*ShowStatsStatement, error) {
@@ -1654,11 +1813,12 @@ func (p *Parser) parseAlias() (string, error) {
 }
 
 // parseSources parses a comma delimited list of sources.
-func (p *Parser) parseSources() (Sources, error) {
+// Subqueries are only allowed as sources when subqueries is true.
+func (p *Parser) parseSources(subqueries bool) (Sources, error) {
 	var sources Sources
 
 	for {
-		s, err := p.parseSource()
+		s, err := p.parseSource(subqueries)
 		if err != nil {
 			return nil, err
 		}
@@ -1683,7 +1843,18 @@ func (p *Parser) peekRune() rune {
 	return r
 }
 
-func (p *Parser) parseSource() (Source, error) {
+func (p *Parser) parseSource(subqueries bool) (Source, error) {
+	// Attempt to parse a subquery.
+	if subqueries {
+		if isWhitespace(p.peekRune()) {
+			p.consumeWhitespace()
+		}
+		if p.peekRune() == '(' {
+			p.scan()
+			return p.parseSubQuery()
+		}
+	}
+
 	m := &Measurement{}
 
 	// Attempt to parse a regex.
@@ -1734,6 +1905,24 @@ func (p *Parser) parseSource() (Source, error) {
 	return m, nil
 }
 
+// parseSubQuery parses a parenthesized SELECT statement used as a source.
+// This function assumes the opening parenthesis has already been consumed.
+func (p *Parser) parseSubQuery() (*SubQuery, error) {
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != SELECT {
+		return nil, newParseError(tokstr(tok, lit), []string{"SELECT"}, pos)
+	}
+
+	stmt, err := p.parseSelectStatement(targetSubquery)
+	if err != nil {
+		return nil, err
+	}
+
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != RPAREN {
+		return nil, newParseError(tokstr(tok, lit), []string{")"}, pos)
+	}
+	return &SubQuery{Statement: stmt}, nil
+}
+
 // parseCondition parses the "WHERE" clause of the query, if it exists.
 func (p *Parser) parseCondition() (Expr, error) {
 	// Check if the WHERE token exists.
@@ -1751,6 +1940,15 @@ func (p *Parser) parseCondition() (Expr, error) {
 	return expr, nil
 }
 
+// parseHaving parses the "HAVING" clause of the query, if it exists.
+func (p *Parser) parseHaving() (Expr, error) {
+	if tok, _, _ := p.scanIgnoreWhitespace(); tok != HAVING {
+		p.unscan()
+		return nil, nil
+	}
+	return p.ParseExpr()
+}
+
 // parseDimensions parses the "GROUP BY" clause of the query, if it exists.
 func (p *Parser) parseDimensions() (Dimensions, error) {
 	// If the next token is not GROUP then exit.
@@ -1800,6 +1998,13 @@ func (p *Parser) parseDimension() (*Dimension, error) {
 
 // parseFill parses the fill call and its options.
 func (p *Parser) parseFill() (FillOption, interface{}, error) {
+	// If the next token is not the fill identifier then exit.
+	if tok, _, lit := p.scanIgnoreWhitespace(); tok != IDENT || strings.ToLower(lit) != "fill" {
+		p.unscan()
+		return NullFill, nil, nil
+	}
+	p.unscan()
+
 	// Parse the expression first.
 	expr, err := p.ParseExpr()
 	if err != nil {
@@ -1816,7 +2021,7 @@ func (p *Parser) parseFill() (FillOption, interface{}, error) {
 		return NullFill, nil, nil
 	}
 	if len(lit.Args) != 1 {
-		return NullFill, nil, errors.New("fill requires an argument, e.g.: 0, null, none, previous")
+		return NullFill, nil, errors.New("fill requires an argument, e.g.: 0, null, none, previous, linear")
 	}
 	switch lit.Args[0].String() {
 	case "null":
@@ -1825,6 +2030,8 @@ func (p *Parser) parseFill() (FillOption, interface{}, error) {
 		return NoFill, nil, nil
 	case "previous":
 		return PreviousFill, nil, nil
+	case "linear":
+		return LinearFill, nil, nil
 	default:
 		num, ok := lit.Args[0].(*NumberLiteral)
 		if !ok {
@@ -1834,6 +2041,33 @@ func (p *Parser) parseFill() (FillOption, interface{}, error) {
 	}
 }
 
+// parseLocation parses the "tz('<location>')" clause of the query, if it exists.
+func (p *Parser) parseLocation() (*time.Location, error) {
+	// If the next token is not the tz identifier then exit.
+	if tok, _, lit := p.scanIgnoreWhitespace(); tok != IDENT || strings.ToLower(lit) != "tz" {
+		p.unscan()
+		return nil, nil
+	}
+
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != LPAREN {
+		return nil, newParseError(tokstr(tok, lit), []string{"("}, pos)
+	}
+
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	if tok != STRING {
+		return nil, newParseError(tokstr(tok, lit), []string{"string"}, pos)
+	}
+	loc, err := time.LoadLocation(lit)
+	if err != nil {
+		return nil, &ParseError{Message: err.Error(), Pos: pos}
+	}
+
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != RPAREN {
+		return nil, newParseError(tokstr(tok, lit), []string{")"}, pos)
+	}
+	return loc, nil
+}
+
 // parseOptionalTokenAndInt parses the specified token followed
 // by an int, if it exists.
 func (p *Parser) parseOptionalTokenAndInt(t Token) (int, error) {
@@ -1906,8 +2140,8 @@ func (p *Parser) parseSortFields() (SortFields, error) {
 			return nil, err
 		}
 
-		if lit != "time" {
-			return nil, errors.New("only ORDER BY time supported at this time")
+		if field.Call == nil && lit != "time" {
+			return nil, errors.New("only ORDER BY time or an aggregate supported at this time")
 		}
 
 		fields = append(fields, field)
@@ -1934,7 +2168,7 @@ func (p *Parser) parseSortFields() (SortFields, error) {
 	}
 
 	if len(fields) > 1 {
-		return nil, errors.New("only ORDER BY time supported at this time")
+		return nil, errors.New("only ORDER BY time or an aggregate supported at this time")
 	}
 
 	return fields, nil
@@ -1951,6 +2185,16 @@ func (p *Parser) parseSortField() (*SortField, error) {
 	}
 	field.Name = ident
 
+	// Series may be sorted by an aggregate, e.g. mean(value).
+	if tok, _, _ := p.scan(); tok == LPAREN {
+		if field.Call, err = p.parseCall(ident); err != nil {
+			return nil, err
+		}
+		field.Name = ""
+	} else {
+		p.unscan()
+	}
+
 	// Check for optional ASC or DESC clause. Default is ASC.
 	tok, _, _ := p.scanIgnoreWhitespace()
 	if tok != ASC && tok != DESC {
@@ -2081,26 +2325,11 @@ func (p *Parser) parseUnaryExpr() (Expr, error) {
 
 		return nil, newParseError(tokstr(tok0, lit), []string{"(", "identifier"}, pos)
 	case STRING:
-		// If literal looks like a date time then parse it as a time literal.
-		if isDateTimeString(lit) {
-			t, err := time.Parse(DateTimeFormat, lit)
-			if err != nil {
-				// try to parse it as an RFCNano time
-				t, err := time.Parse(time.RFC3339Nano, lit)
-				if err != nil {
-					return nil, &ParseError{Message: "unable to parse datetime", Pos: pos}
-				}
-				return &TimeLiteral{Val: t}, nil
-			}
-			return &TimeLiteral{Val: t}, nil
-		} else if isDateString(lit) {
-			t, err := time.Parse(DateFormat, lit)
-			if err != nil {
-				return nil, &ParseError{Message: "unable to parse date", Pos: pos}
-			}
-			return &TimeLiteral{Val: t}, nil
+		expr, err := stringLiteral(lit)
+		if err != nil {
+			return nil, &ParseError{Message: err.Error(), Pos: pos}
 		}
-		return &StringLiteral{Val: lit}, nil
+		return expr, nil
 	case NUMBER:
 		v, err := strconv.ParseFloat(lit, 64)
 		if err != nil {
@@ -2120,11 +2349,133 @@ func (p *Parser) parseUnaryExpr() (Expr, error) {
 			return nil, &ParseError{Message: err.Error(), Pos: pos}
 		}
 		return &RegexLiteral{Val: re}, nil
+	case BOUNDPARAM:
+		return p.parseBoundParam(lit, pos)
 	default:
 		return nil, newParseError(tokstr(tok, lit), []string{"identifier", "string", "number", "bool"}, pos)
 	}
 }
 
+// stringLiteral returns the literal of a quoted string. Strings that look like
+// a date or a date time are parsed as time literals.
+func stringLiteral(lit string) (Expr, error) {
+	if isDateTimeString(lit) {
+		t, err := time.Parse(DateTimeFormat, lit)
+		if err != nil {
+			// try to parse it as an RFCNano time
+			t, err := time.Parse(time.RFC3339Nano, lit)
+			if err != nil {
+				return nil, errors.New("unable to parse datetime")
+			}
+			return &TimeLiteral{Val: t}, nil
+		}
+		return &TimeLiteral{Val: t}, nil
+	} else if isDateString(lit) {
+		t, err := time.Parse(DateFormat, lit)
+		if err != nil {
+			return nil, errors.New("unable to parse date")
+		}
+		return &TimeLiteral{Val: t}, nil
+	}
+	return &StringLiteral{Val: lit}, nil
+}
+
+// parseBoundParam returns the expression bound to the $name parameter lit.
+func (p *Parser) parseBoundParam(lit string, pos Pos) (Expr, error) {
+	v, ok := p.params[strings.TrimPrefix(lit, "$")]
+	if !ok {
+		return nil, &ParseError{Message: fmt.Sprintf("missing parameter: %s", lit), Pos: pos}
+	}
+
+	expr, err := BindParam(v)
+	if err != nil {
+		return nil, &ParseError{Message: fmt.Sprintf("unable to bind parameter %s: %s", lit, err), Pos: pos}
+	}
+	return expr, nil
+}
+
+// BindParam returns the expression replacing a parameter bound to v. Strings,
+// numbers, booleans, times, durations and regular expressions are replaced by
+// literals, strings looking like a date staying string literals. Values decoded
+// from JSON may also be an object with a single "string", "number", "time",
+// "duration", "regex" or "identifier" key, an identifier being a field or tag name
+// in expressions and a name, such as a measurement, elsewhere.
+func BindParam(v interface{}) (Expr, error) {
+	switch v := v.(type) {
+	case string:
+		return &StringLiteral{Val: v}, nil
+	case float64:
+		return &NumberLiteral{Val: v}, nil
+	case int64:
+		return &NumberLiteral{Val: float64(v)}, nil
+	case int:
+		return &NumberLiteral{Val: float64(v)}, nil
+	case bool:
+		return &BooleanLiteral{Val: v}, nil
+	case time.Time:
+		return &TimeLiteral{Val: v}, nil
+	case time.Duration:
+		return &DurationLiteral{Val: v}, nil
+	case *regexp.Regexp:
+		return &RegexLiteral{Val: v}, nil
+	case map[string]interface{}:
+		if len(v) != 1 {
+			return nil, errors.New("typed value must have a single key")
+		}
+		for typ, val := range v {
+			return bindTypedParam(typ, val)
+		}
+	}
+	return nil, fmt.Errorf("unsupported type %T", v)
+}
+
+// bindTypedParam returns the expression replacing a parameter bound to a value
+// of the given type.
+func bindTypedParam(typ string, v interface{}) (Expr, error) {
+	if typ == "number" {
+		switch v := v.(type) {
+		case float64, int64, int:
+			return BindParam(v)
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, errors.New("unable to parse number")
+			}
+			return &NumberLiteral{Val: f}, nil
+		}
+		return nil, fmt.Errorf("invalid number: %v", v)
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a string", typ)
+	}
+	switch typ {
+	case "string":
+		return &StringLiteral{Val: s}, nil
+	case "time":
+		if !isDateTimeString(s) && !isDateString(s) {
+			return nil, errors.New("unable to parse time")
+		}
+		return stringLiteral(s)
+	case "duration":
+		d, err := ParseDuration(s)
+		if err != nil {
+			return nil, err
+		}
+		return &DurationLiteral{Val: d}, nil
+	case "regex":
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, err
+		}
+		return &RegexLiteral{Val: re}, nil
+	case "identifier":
+		return &VarRef{Val: s}, nil
+	}
+	return nil, fmt.Errorf("unknown type %q", typ)
+}
+
 // parseRegex parses a regular expression.
 func (p *Parser) parseRegex() (*RegexLiteral, error) {
 	nextRune := p.peekRune()
@@ -2132,8 +2483,22 @@ func (p *Parser) parseRegex() (*RegexLiteral, error) {
 		p.consumeWhitespace()
 	}
 
-	// If the next character is not a '/', then return nils.
+	// A parameter bound to a regular expression may be used in its place.
 	nextRune = p.peekRune()
+	if nextRune == '$' {
+		tok, pos, lit := p.scan()
+		if tok == BOUNDPARAM {
+			if expr, err := p.parseBoundParam(lit, pos); err == nil {
+				if re, ok := expr.(*RegexLiteral); ok {
+					return re, nil
+				}
+			}
+		}
+		p.unscan()
+		return nil, nil
+	}
+
+	// If the next character is not a '/', then return nils.
 	if nextRune != '/' {
 		return nil, nil
 	}
@@ -2171,10 +2536,23 @@ func (p *Parser) parseCall(name string) (*Call, error) {
 	// Otherwise parse function call arguments.
 	var args []Expr
 	for {
-		// Parse an expression argument.
-		arg, err := p.ParseExpr()
-		if err != nil {
-			return nil, err
+		// Arguments after the first one may be regular expressions, e.g. regex_extract(host, /[0-9]+/).
+		var arg Expr
+		if len(args) > 0 {
+			re, err := p.parseRegex()
+			if err != nil {
+				return nil, err
+			} else if re != nil {
+				arg = re
+			}
+		}
+
+		// Otherwise parse an expression argument.
+		if arg == nil {
+			var err error
+			if arg, err = p.ParseExpr(); err != nil {
+				return nil, err
+			}
 		}
 		args = append(args, arg)
 
@@ -212,6 +212,29 @@ func TestParser_ParseStatement(t *testing.T) {
 			},
 		},
 
+		// transformations
+		{
+			s: `SELECT moving_average(field1, 3) FROM myseries;`,
+			stmt: &influxql.SelectStatement{
+				IsRawQuery: false,
+				Fields: []*influxql.Field{
+					{Expr: &influxql.Call{Name: "moving_average", Args: []influxql.Expr{&influxql.VarRef{Val: "field1"}, &influxql.NumberLiteral{Val: 3}}}},
+				},
+				Sources: []influxql.Source{&influxql.Measurement{Name: "myseries"}},
+			},
+		},
+
+		{
+			s: `SELECT elapsed(max(field1), 1s) FROM myseries;`,
+			stmt: &influxql.SelectStatement{
+				IsRawQuery: false,
+				Fields: []*influxql.Field{
+					{Expr: &influxql.Call{Name: "elapsed", Args: []influxql.Expr{&influxql.Call{Name: "max", Args: []influxql.Expr{&influxql.VarRef{Val: "field1"}}}, &influxql.DurationLiteral{Val: time.Second}}}},
+				},
+				Sources: []influxql.Source{&influxql.Measurement{Name: "myseries"}},
+			},
+		},
+
 		// SELECT statement (lowercase)
 		{
 			s: `select my_field from myseries`,
@@ -284,6 +307,75 @@ func TestParser_ParseStatement(t *testing.T) {
 			},
 		},
 
+		// select string functions of fields
+		{
+			s: `SELECT upper(host), regex_extract(msg, /([a-z]+)-[0-9]+/, 1) AS "app" FROM cpu WHERE strlen(msg) > 3`,
+			stmt: &influxql.SelectStatement{
+				IsRawQuery: true,
+				Fields: []*influxql.Field{
+					{Expr: &influxql.Call{Name: "upper", Args: []influxql.Expr{&influxql.VarRef{Val: "host"}}}},
+					{
+						Expr: &influxql.Call{
+							Name: "regex_extract",
+							Args: []influxql.Expr{
+								&influxql.VarRef{Val: "msg"},
+								&influxql.RegexLiteral{Val: regexp.MustCompile("([a-z]+)-[0-9]+")},
+								&influxql.NumberLiteral{Val: 1},
+							},
+						},
+						Alias: "app",
+					},
+				},
+				Sources: []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+				Condition: &influxql.BinaryExpr{
+					Op:  influxql.GT,
+					LHS: &influxql.Call{Name: "strlen", Args: []influxql.Expr{&influxql.VarRef{Val: "msg"}}},
+					RHS: &influxql.NumberLiteral{Val: 3},
+				},
+			},
+		},
+
+		// select a string function of an aggregate
+		{
+			s: `SELECT concat(last(host), '-', last(region)) FROM cpu`,
+			stmt: &influxql.SelectStatement{
+				IsRawQuery: false,
+				Fields: []*influxql.Field{
+					{
+						Expr: &influxql.Call{
+							Name: "concat",
+							Args: []influxql.Expr{
+								&influxql.Call{Name: "last", Args: []influxql.Expr{&influxql.VarRef{Val: "host"}}},
+								&influxql.StringLiteral{Val: "-"},
+								&influxql.Call{Name: "last", Args: []influxql.Expr{&influxql.VarRef{Val: "region"}}},
+							},
+						},
+					},
+				},
+				Sources: []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+			},
+		},
+
+		// select arithmetic between aggregates
+		{
+			s: `select sum(errors) / sum(requests) * 100 from http`,
+			stmt: &influxql.SelectStatement{
+				IsRawQuery: false,
+				Fields: []*influxql.Field{
+					{Expr: &influxql.BinaryExpr{
+						Op: influxql.MUL,
+						LHS: &influxql.BinaryExpr{
+							Op:  influxql.DIV,
+							LHS: &influxql.Call{Name: "sum", Args: []influxql.Expr{&influxql.VarRef{Val: "errors"}}},
+							RHS: &influxql.Call{Name: "sum", Args: []influxql.Expr{&influxql.VarRef{Val: "requests"}}},
+						},
+						RHS: &influxql.NumberLiteral{Val: 100},
+					}},
+				},
+				Sources: []influxql.Source{&influxql.Measurement{Name: "http"}},
+			},
+		},
+
 		// select percentile statements
 		{
 			s: `select percentile("field1", 2.0) from cpu`,
@@ -296,6 +388,17 @@ func TestParser_ParseStatement(t *testing.T) {
 			},
 		},
 
+		{
+			s: `select approx_percentile("field1", 99.9) from cpu`,
+			stmt: &influxql.SelectStatement{
+				IsRawQuery: false,
+				Fields: []*influxql.Field{
+					{Expr: &influxql.Call{Name: "approx_percentile", Args: []influxql.Expr{&influxql.VarRef{Val: "field1"}, &influxql.NumberLiteral{Val: 99.9}}}},
+				},
+				Sources: []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+			},
+		},
+
 		// select top statements
 		{
 			s: `select top("field1", 2) from cpu`,
@@ -606,6 +709,39 @@ func TestParser_ParseStatement(t *testing.T) {
 			},
 		},
 
+		// SELECT statement ordering series by an aggregate
+		{
+			s: `SELECT mean(value) FROM cpu GROUP BY host ORDER BY mean(value) DESC SLIMIT 10`,
+			stmt: &influxql.SelectStatement{
+				Fields: []*influxql.Field{{
+					Expr: &influxql.Call{
+						Name: "mean",
+						Args: []influxql.Expr{&influxql.VarRef{Val: "value"}}}}},
+				Sources:    []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+				Dimensions: []*influxql.Dimension{{Expr: &influxql.VarRef{Val: "host"}}},
+				SortFields: []*influxql.SortField{{Call: &influxql.Call{Name: "mean", Args: []influxql.Expr{&influxql.VarRef{Val: "value"}}}}},
+				SLimit:     10,
+			},
+		},
+
+		// SELECT statement with HAVING
+		{
+			s: `SELECT mean(value) FROM cpu GROUP BY host HAVING mean(value) > 90`,
+			stmt: &influxql.SelectStatement{
+				Fields: []*influxql.Field{{
+					Expr: &influxql.Call{
+						Name: "mean",
+						Args: []influxql.Expr{&influxql.VarRef{Val: "value"}}}}},
+				Sources:    []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+				Dimensions: []*influxql.Dimension{{Expr: &influxql.VarRef{Val: "host"}}},
+				Having: &influxql.BinaryExpr{
+					Op:  influxql.GT,
+					LHS: &influxql.Call{Name: "mean", Args: []influxql.Expr{&influxql.VarRef{Val: "value"}}},
+					RHS: &influxql.NumberLiteral{Val: 90},
+				},
+			},
+		},
+
 		// SELECT statement with FILL(none) -- check case insensitivity
 		{
 			s: fmt.Sprintf(`SELECT mean(value) FROM cpu where time < '%s' GROUP BY time(5m) FILL(none)`, now.UTC().Format(time.RFC3339Nano)),
@@ -644,6 +780,74 @@ func TestParser_ParseStatement(t *testing.T) {
 			},
 		},
 
+		// SELECT statement with linear fill
+		{
+			s: fmt.Sprintf(`SELECT mean(value) FROM cpu where time < '%s' GROUP BY time(5m) fill(linear)`, now.UTC().Format(time.RFC3339Nano)),
+			stmt: &influxql.SelectStatement{
+				Fields: []*influxql.Field{{
+					Expr: &influxql.Call{
+						Name: "mean",
+						Args: []influxql.Expr{&influxql.VarRef{Val: "value"}}}}},
+				Sources: []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+				Condition: &influxql.BinaryExpr{
+					Op:  influxql.LT,
+					LHS: &influxql.VarRef{Val: "time"},
+					RHS: &influxql.TimeLiteral{Val: now.UTC()},
+				},
+				Dimensions: []*influxql.Dimension{{Expr: &influxql.Call{Name: "time", Args: []influxql.Expr{&influxql.DurationLiteral{Val: 5 * time.Minute}}}}},
+				Fill:       influxql.LinearFill,
+			},
+		},
+
+		// SELECT statement with a GROUP BY time() offset and time zone
+		{
+			s: fmt.Sprintf(`SELECT mean(value) FROM cpu where time < '%s' GROUP BY time(1d, 2h) tz('Europe/Berlin')`, now.UTC().Format(time.RFC3339Nano)),
+			stmt: &influxql.SelectStatement{
+				Fields: []*influxql.Field{{
+					Expr: &influxql.Call{
+						Name: "mean",
+						Args: []influxql.Expr{&influxql.VarRef{Val: "value"}}}}},
+				Sources: []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+				Condition: &influxql.BinaryExpr{
+					Op:  influxql.LT,
+					LHS: &influxql.VarRef{Val: "time"},
+					RHS: &influxql.TimeLiteral{Val: now.UTC()},
+				},
+				Dimensions: []*influxql.Dimension{{Expr: &influxql.Call{Name: "time", Args: []influxql.Expr{
+					&influxql.DurationLiteral{Val: 24 * time.Hour},
+					&influxql.DurationLiteral{Val: 2 * time.Hour},
+				}}}},
+				Location: mustLoadLocation("Europe/Berlin"),
+			},
+		},
+
+		// SELECT statement with a subquery
+		{
+			s: fmt.Sprintf(`SELECT max(mean) FROM (SELECT mean(value) FROM cpu GROUP BY host) WHERE time < '%s' GROUP BY time(1h)`, now.UTC().Format(time.RFC3339Nano)),
+			stmt: &influxql.SelectStatement{
+				Fields: []*influxql.Field{{
+					Expr: &influxql.Call{
+						Name: "max",
+						Args: []influxql.Expr{&influxql.VarRef{Val: "mean"}}}}},
+				Sources: []influxql.Source{&influxql.SubQuery{
+					Statement: &influxql.SelectStatement{
+						Fields: []*influxql.Field{{
+							Expr: &influxql.Call{
+								Name: "mean",
+								Args: []influxql.Expr{&influxql.VarRef{Val: "value"}}}}},
+						Sources:    []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+						Dimensions: []*influxql.Dimension{{Expr: &influxql.VarRef{Val: "host"}}},
+					},
+				}},
+				Condition: &influxql.BinaryExpr{
+					Op:  influxql.LT,
+					LHS: &influxql.VarRef{Val: "time"},
+					RHS: &influxql.TimeLiteral{Val: now.UTC()},
+				},
+				Dimensions: []*influxql.Dimension{{Expr: &influxql.Call{Name: "time", Args: []influxql.Expr{&influxql.DurationLiteral{Val: time.Hour}}}}},
+			},
+		},
+
 		// DELETE statement
 		{
 			s: `DELETE FROM myseries WHERE host = 'hosta.influxdb.org'`,
@@ -657,6 +861,27 @@ func TestParser_ParseStatement(t *testing.T) {
 			},
 		},
 
+		// DELETE statement with a time range
+		{
+			s: `DELETE FROM cpu WHERE region = 'uswest' AND time < '2000-01-01T00:00:00Z'`,
+			stmt: &influxql.DeleteStatement{
+				Source: &influxql.Measurement{Name: "cpu"},
+				Condition: &influxql.BinaryExpr{
+					Op: influxql.AND,
+					LHS: &influxql.BinaryExpr{
+						Op:  influxql.EQ,
+						LHS: &influxql.VarRef{Val: "region"},
+						RHS: &influxql.StringLiteral{Val: "uswest"},
+					},
+					RHS: &influxql.BinaryExpr{
+						Op:  influxql.LT,
+						LHS: &influxql.VarRef{Val: "time"},
+						RHS: &influxql.TimeLiteral{Val: mustParseTime("2000-01-01T00:00:00Z")},
+					},
+				},
+			},
+		},
+
 		// SHOW SERVERS
 		{
 			s:    `SHOW SERVERS`,
@@ -732,6 +957,41 @@ func TestParser_ParseStatement(t *testing.T) {
 			},
 		},
 
+		// SHOW SERIES CARDINALITY
+		{
+			s:    `SHOW SERIES CARDINALITY`,
+			stmt: &influxql.ShowSeriesCardinalityStatement{},
+		},
+
+		// SHOW SERIES CARDINALITY FROM ... WHERE ...
+		{
+			s: `SHOW SERIES CARDINALITY FROM /[cg]pu/ WHERE region = 'uswest'`,
+			stmt: &influxql.ShowSeriesCardinalityStatement{
+				Sources: []influxql.Source{
+					&influxql.Measurement{
+						Regex: &influxql.RegexLiteral{Val: regexp.MustCompile(`[cg]pu`)},
+					},
+				},
+				Condition: &influxql.BinaryExpr{
+					Op:  influxql.EQ,
+					LHS: &influxql.VarRef{Val: "region"},
+					RHS: &influxql.StringLiteral{Val: "uswest"},
+				},
+			},
+		},
+
+		// SHOW MEASUREMENT CARDINALITY WHERE ...
+		{
+			s: `SHOW MEASUREMENT CARDINALITY WHERE region = 'uswest'`,
+			stmt: &influxql.ShowMeasurementCardinalityStatement{
+				Condition: &influxql.BinaryExpr{
+					Op:  influxql.EQ,
+					LHS: &influxql.VarRef{Val: "region"},
+					RHS: &influxql.StringLiteral{Val: "uswest"},
+				},
+			},
+		},
+
 		// SHOW MEASUREMENTS WHERE with ORDER BY and LIMIT
 		{
 			skip: true,
@@ -955,6 +1215,28 @@ func TestParser_ParseStatement(t *testing.T) {
 			},
 		},
 
+		// SHOW TAG VALUES CARDINALITY FROM ... WITH KEY IN ... WHERE ...
+		{
+			s: `SHOW TAG VALUES CARDINALITY FROM cpu WITH KEY IN (region, host) WHERE region = 'uswest'`,
+			stmt: &influxql.ShowTagValuesCardinalityStatement{
+				Sources: []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+				TagKeys: []string{"region", "host"},
+				Condition: &influxql.BinaryExpr{
+					Op:  influxql.EQ,
+					LHS: &influxql.VarRef{Val: "region"},
+					RHS: &influxql.StringLiteral{Val: "uswest"},
+				},
+			},
+		},
+
+		// SHOW TAG VALUES CARDINALITY WITH KEY = ...
+		{
+			s: `SHOW TAG VALUES CARDINALITY WITH KEY = host`,
+			stmt: &influxql.ShowTagValuesCardinalityStatement{
+				TagKeys: []string{"host"},
+			},
+		},
+
 		// SHOW USERS
 		{
 			s:    `SHOW USERS`,
@@ -1154,6 +1436,33 @@ func TestParser_ParseStatement(t *testing.T) {
 			},
 		},
 
+		// CREATE CONTINUOUS QUERY calling a function on every field with a capture group in the target
+		{
+			s: `CREATE CONTINUOUS QUERY myquery ON testdb BEGIN SELECT mean(*) INTO "policy1"."${1}_1h" FROM /^(cpu|mem)$/ GROUP BY time(1h), * END`,
+			stmt: &influxql.CreateContinuousQueryStatement{
+				Name:     "myquery",
+				Database: "testdb",
+				Source: &influxql.SelectStatement{
+					Fields: []*influxql.Field{{Expr: &influxql.Call{Name: "mean", Args: []influxql.Expr{&influxql.Wildcard{}}}}},
+					Target: &influxql.Target{
+						Measurement: &influxql.Measurement{RetentionPolicy: "policy1", Name: "${1}_1h", IsTarget: true},
+					},
+					Sources: []influxql.Source{&influxql.Measurement{Regex: &influxql.RegexLiteral{Val: regexp.MustCompile(`^(cpu|mem)$`)}}},
+					Dimensions: []*influxql.Dimension{
+						{
+							Expr: &influxql.Call{
+								Name: "time",
+								Args: []influxql.Expr{
+									&influxql.DurationLiteral{Val: 1 * time.Hour},
+								},
+							},
+						},
+						{Expr: &influxql.Wildcard{}},
+					},
+				},
+			},
+		},
+
 		// CREATE DATABASE statement
 		{
 			s: `CREATE DATABASE testdb`,
@@ -1444,6 +1753,40 @@ func TestParser_ParseStatement(t *testing.T) {
 			stmt: &influxql.ShowShardsStatement{},
 		},
 
+		// SHOW QUERIES
+		{
+			s:    `SHOW QUERIES`,
+			stmt: &influxql.ShowQueriesStatement{},
+		},
+
+		// EXPLAIN
+		{
+			s: `EXPLAIN SELECT value FROM cpu`,
+			stmt: &influxql.ExplainStatement{
+				Statement: &influxql.SelectStatement{
+					IsRawQuery: true,
+					Fields:     []*influxql.Field{{Expr: &influxql.VarRef{Val: "value"}}},
+					Sources:    []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+				},
+			},
+		},
+		{
+			s: `EXPLAIN ANALYZE SELECT count(value) FROM cpu`,
+			stmt: &influxql.ExplainStatement{
+				Statement: &influxql.SelectStatement{
+					Fields:  []*influxql.Field{{Expr: &influxql.Call{Name: "count", Args: []influxql.Expr{&influxql.VarRef{Val: "value"}}}}},
+					Sources: []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+				},
+				Analyze: true,
+			},
+		},
+
+		// KILL QUERY
+		{
+			s:    `KILL QUERY 42`,
+			stmt: &influxql.KillQueryStatement{QueryID: 42},
+		},
+
 		// SHOW DIAGNOSTICS
 		{
 			s:    `SHOW DIAGNOSTICS`,
@@ -1457,10 +1800,10 @@ func TestParser_ParseStatement(t *testing.T) {
 		},
 
 		// Errors
-		{s: ``, err: `found EOF, expected SELECT, DELETE, SHOW, CREATE, DROP, GRANT, REVOKE, ALTER, SET at line 1, char 1`},
+		{s: ``, err: `found EOF, expected SELECT, DELETE, SHOW, CREATE, DROP, GRANT, REVOKE, ALTER, SET, KILL, EXPLAIN at line 1, char 1`},
 		{s: `SELECT`, err: `found EOF, expected identifier, string, number, bool at line 1, char 8`},
 		{s: `SELECT time FROM myseries`, err: `at least 1 non-time field must be queried`},
-		{s: `blah blah`, err: `found blah, expected SELECT, DELETE, SHOW, CREATE, DROP, GRANT, REVOKE, ALTER, SET at line 1, char 1`},
+		{s: `blah blah`, err: `found blah, expected SELECT, DELETE, SHOW, CREATE, DROP, GRANT, REVOKE, ALTER, SET, KILL, EXPLAIN at line 1, char 1`},
 		{s: `SELECT field1 X`, err: `found X, expected FROM at line 1, char 15`},
 		{s: `SELECT field1 FROM "series" WHERE X +;`, err: `found ;, expected identifier, string, number, bool at line 1, char 38`},
 		{s: `SELECT field1 FROM myseries GROUP`, err: `found EOF, expected BY at line 1, char 35`},
@@ -1481,6 +1824,8 @@ func TestParser_ParseStatement(t *testing.T) {
 		{s: `SELECT percentile() FROM myseries`, err: `invalid number of arguments for percentile, expected 2, got 0`},
 		{s: `SELECT percentile(field1) FROM myseries`, err: `invalid number of arguments for percentile, expected 2, got 1`},
 		{s: `SELECT percentile(field1, foo) FROM myseries`, err: `expected float argument in percentile()`},
+		{s: `SELECT approx_percentile(field1) FROM myseries`, err: `invalid number of arguments for approx_percentile, expected 2, got 1`},
+		{s: `SELECT approx_percentile(field1, foo) FROM myseries`, err: `expected float argument in approx_percentile()`},
 		{s: `SELECT field1 FROM myseries OFFSET`, err: `found EOF, expected number at line 1, char 36`},
 		{s: `SELECT field1 FROM myseries OFFSET 10.5`, err: `fractional parts not allowed in OFFSET at line 1, char 36`},
 		{s: `SELECT field1 FROM myseries ORDER`, err: `found EOF, expected BY at line 1, char 35`},
@@ -1488,18 +1833,56 @@ func TestParser_ParseStatement(t *testing.T) {
 		{s: `SELECT field1 FROM myseries ORDER BY /`, err: `found /, expected identifier, ASC, DESC at line 1, char 38`},
 		{s: `SELECT field1 FROM myseries ORDER BY 1`, err: `found 1, expected identifier, ASC, DESC at line 1, char 38`},
 		{s: `SELECT field1 FROM myseries ORDER BY time ASC,`, err: `found EOF, expected identifier at line 1, char 47`},
-		{s: `SELECT field1 FROM myseries ORDER BY time, field1`, err: `only ORDER BY time supported at this time`},
+		{s: `SELECT field1 FROM myseries ORDER BY time, field1`, err: `only ORDER BY time or an aggregate supported at this time`},
 		{s: `SELECT field1 AS`, err: `found EOF, expected identifier at line 1, char 18`},
 		{s: `SELECT field1 FROM foo group by time(1s)`, err: `GROUP BY requires at least one aggregate function`},
 		{s: `SELECT count(value), value FROM foo`, err: `mixing aggregate and non-aggregate queries is not supported`},
 		{s: `SELECT count(value)/10, value FROM foo`, err: `mixing aggregate and non-aggregate queries is not supported`},
+		{s: `SELECT mean(value) / value FROM foo`, err: `mixing aggregate and non-aggregate queries is not supported`},
+		{s: `SELECT sum(requests.count) / sum(count) FROM requests, errors`, err: `field count must be qualified by its measurement in a join`},
+		{s: `SELECT sum(requests.count) / sum(errors.count) FROM requests, /err.*/`, err: `a join must select from named measurements`},
+		{s: `SELECT top(requests.count, 2) FROM requests, errors`, err: `top() is not supported in a join`},
+		{s: `SELECT value FROM cpu HAVING value > 1`, err: `HAVING requires an aggregate function`},
+		{s: `SELECT value FROM cpu GROUP BY host ORDER BY derivative(value) DESC`, err: `derivative() can't be used to order series`},
+		{s: `SELECT value FROM cpu GROUP BY host ORDER BY count() DESC`, err: `count() requires a field argument to order series`},
+		{s: `SELECT value FROM cpu GROUP BY host ORDER BY mean(value) DESC, time`, err: `only ORDER BY time or an aggregate supported at this time`},
+		{s: `SELECT mean(value) FROM cpu HAVING max(value) > 1`, err: `max(value) must be selected to be used in HAVING`},
+		{s: `SELECT mean(value) FROM cpu GROUP BY host HAVING host = 'a'`, err: `host must be the name of a selected field to be used in HAVING`},
+		{s: `SELECT mean(value), mean(other) FROM cpu HAVING mean > 1`, err: `mean is the name of several fields, use an alias to refer to one in HAVING`},
+		{s: `SELECT mean(value) FROM cpu HAVING`, err: `found EOF, expected identifier, string, number, bool at line 1, char 36`},
+		{s: `SELECT top(value, 1) * 2 FROM foo`, err: `top() cannot be used in an expression`},
+		{s: `SELECT mean(*) * 2 FROM foo`, err: `mean(*) cannot be used in an expression`},
+		{s: `SELECT histogram(value) FROM foo`, err: `invalid number of arguments for histogram, expected at least 2, got 1`},
+		{s: `SELECT histogram(value, 10, 'a') FROM foo`, err: `expected number as bucket edge in histogram(), found 'a'`},
+		{s: `SELECT histogram(value, 10, 10) FROM foo`, err: `bucket edges in histogram() must be in ascending order`},
+		{s: `SELECT mode(value, 1) FROM foo`, err: `invalid number of arguments for mode, expected 1, got 2`},
+		{s: `SELECT integral() FROM foo`, err: `invalid number of arguments for integral, expected at least 1 but no more than 2, got 0`},
+		{s: `SELECT integral(value, 10) FROM foo`, err: `second argument to integral() must be a positive duration`},
+		{s: `SELECT integral(mean(value)) FROM foo`, err: `expected field argument in integral()`},
+		{s: `SELECT time_weighted_mean(value, 1s) FROM foo`, err: `invalid number of arguments for time_weighted_mean, expected 1, got 2`},
+		{s: `SELECT strlen(host, region) FROM foo`, err: `invalid number of arguments for strlen, expected 1, got 2`},
+		{s: `SELECT substr(host) FROM foo`, err: `invalid number of arguments for substr, expected at least 2 but no more than 3, got 1`},
+		{s: `SELECT concat() FROM foo`, err: `invalid number of arguments for concat, expected at least 1, got 0`},
+		{s: `SELECT upper(*) FROM foo`, err: `expected field argument in upper()`},
+		{s: `SELECT regex_extract(host, 'a') FROM foo`, err: `expected regex argument in regex_extract()`},
+		{s: `SELECT regex_extract(host, /(a)/, 2) FROM foo`, err: `invalid capture group 2.000 in regex_extract()`},
+		{s: `SELECT substr(host, /a/) FROM foo`, err: `unexpected regex argument in substr()`},
+		{s: `SELECT value FROM foo WHERE strlen() > 1`, err: `invalid number of arguments for strlen, expected 1, got 0`},
+		{s: `SELECT concat(host, last(value)) FROM foo`, err: `mixing aggregate and non-aggregate queries is not supported`},
 		{s: `SELECT count(value) FROM foo group by time(1s)`, err: `aggregate functions with GROUP BY time require a WHERE time clause`},
 		{s: `SELECT count(value) FROM foo group by time(1s) where host = 'hosta.influxdb.org'`, err: `aggregate functions with GROUP BY time require a WHERE time clause`},
+		{s: `SELECT max(mean) FROM (SELECT mean(value) FROM cpu GROUP BY time(1m)) GROUP BY time(1h)`, err: `aggregate functions with GROUP BY time require a WHERE time clause`},
+		{s: `SELECT max(value) FROM (SELECT value INTO foo FROM cpu)`, err: `subqueries cannot have an INTO clause at line 1, char 38`},
+		{s: `SELECT max(value) FROM (SELECT value FROM cpu`, err: `found EOF, expected ) at line 1, char 47`},
+		{s: `SELECT max(value) FROM (DELETE FROM cpu)`, err: `found DELETE, expected SELECT at line 1, char 25`},
 		{s: `SELECT count(value) FROM foo group by time`, err: `time() is a function and expects at least one argument`},
 		{s: `SELECT count(value) FROM foo group by 'time'`, err: `only time and tag dimensions allowed`},
-		{s: `SELECT count(value) FROM foo where time > now() and time < now() group by time()`, err: `time dimension expected one argument`},
+		{s: `SELECT count(value) FROM foo where time > now() and time < now() group by time()`, err: `time dimension expected 1 or 2 arguments`},
 		{s: `SELECT count(value) FROM foo where time > now() and time < now() group by time(b)`, err: `time dimension must have one duration argument`},
 		{s: `SELECT count(value) FROM foo where time > now() and time < now() group by time(1s), time(2s)`, err: `multiple time dimensions not allowed`},
+		{s: `SELECT count(value) FROM foo where time > now() and time < now() group by time(1s, b)`, err: `time dimension offset must be a duration`},
+		{s: `SELECT count(value) FROM foo where time > now() and time < now() group by time(1s) tz(1)`, err: `found 1, expected string at line 1, char 87`},
+		{s: `SELECT count(value) FROM foo where time > now() and time < now() group by time(1s) tz('Not/A_Zone')`, err: `unknown time zone Not/A_Zone at line 1, char 86`},
 		{s: `SELECT field1 FROM 12`, err: `found 12, expected identifier at line 1, char 20`},
 		{s: `SELECT 1000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000 FROM myseries`, err: `unable to parse number at line 1, char 8`},
 		{s: `SELECT 10.5h FROM myseries`, err: `found h, expected FROM at line 1, char 12`},
@@ -1521,6 +1904,15 @@ func TestParser_ParseStatement(t *testing.T) {
 		{s: `select non_negative_derivative() from myseries`, err: `invalid number of arguments for non_negative_derivative, expected at least 1 but no more than 2, got 0`},
 		{s: `select non_negative_derivative(mean(value), 1h, 3) from myseries`, err: `invalid number of arguments for non_negative_derivative, expected at least 1 but no more than 2, got 3`},
 		{s: `SELECT non_negative_derivative(value) FROM myseries group by time(1h)`, err: `aggregate function required inside the call to non_negative_derivative`},
+		{s: `SELECT difference(value, 1h) FROM myseries`, err: `invalid number of arguments for difference, expected 1, got 2`},
+		{s: `SELECT difference(value) FROM myseries group by time(1h)`, err: `aggregate function required inside the call to difference`},
+		{s: `SELECT difference(value), max(value) FROM myseries`, err: `difference cannot be used with other fields`},
+		{s: `SELECT cumulative_sum() FROM myseries`, err: `invalid number of arguments for cumulative_sum, expected 1, got 0`},
+		{s: `SELECT moving_average(value) FROM myseries`, err: `invalid number of arguments for moving_average, expected 2, got 1`},
+		{s: `SELECT moving_average(value, 1.5) FROM myseries`, err: `moving_average requires an integer window argument`},
+		{s: `SELECT moving_average(value, 1) FROM myseries`, err: `moving_average window must be greater than 1`},
+		{s: `SELECT elapsed(value, 2) FROM myseries`, err: `elapsed requires a duration argument`},
+		{s: `SELECT elapsed(value, 0s) FROM myseries`, err: `elapsed requires a positive duration argument`},
 		{s: `SELECT field1 from myseries WHERE host =~ 'asd' LIMIT 1`, err: `found asd, expected regex at line 1, char 42`},
 		{s: `SELECT value > 2 FROM cpu`, err: `invalid operator > in SELECT clause at line 1, char 8; operator is intended for WHERE clause`},
 		{s: `SELECT value = 2 FROM cpu`, err: `invalid operator = in SELECT clause at line 1, char 8; operator is intended for WHERE clause`},
@@ -1535,13 +1927,20 @@ func TestParser_ParseStatement(t *testing.T) {
 		{s: `DROP SERVER`, err: `found EOF, expected number at line 1, char 13`},
 		{s: `DROP SERVER abc`, err: `found abc, expected number at line 1, char 13`},
 		{s: `DROP SERVER 1 1`, err: `found 1, expected FORCE at line 1, char 15`},
+		{s: `KILL`, err: `found EOF, expected QUERY at line 1, char 6`},
+		{s: `EXPLAIN`, err: `found EOF, expected SELECT at line 1, char 9`},
+		{s: `EXPLAIN ANALYZE SHOW QUERIES`, err: `found SHOW, expected SELECT at line 1, char 17`},
+		{s: `KILL QUERY`, err: `found EOF, expected number at line 1, char 12`},
+		{s: `KILL QUERY abc`, err: `found abc, expected number at line 1, char 12`},
 		{s: `SHOW CONTINUOUS`, err: `found EOF, expected QUERIES at line 1, char 17`},
 		{s: `SHOW RETENTION`, err: `found EOF, expected POLICIES at line 1, char 16`},
 		{s: `SHOW RETENTION ON`, err: `found ON, expected POLICIES at line 1, char 16`},
 		{s: `SHOW RETENTION POLICIES`, err: `found EOF, expected ON at line 1, char 25`},
 		{s: `SHOW RETENTION POLICIES mydb`, err: `found mydb, expected ON at line 1, char 25`},
 		{s: `SHOW RETENTION POLICIES ON`, err: `found EOF, expected identifier at line 1, char 28`},
-		{s: `SHOW FOO`, err: `found FOO, expected CONTINUOUS, DATABASES, DIAGNOSTICS, FIELD, GRANTS, MEASUREMENTS, RETENTION, SERIES, SERVERS, SHARDS, STATS, TAG, USERS at line 1, char 6`},
+		{s: `SHOW FOO`, err: `found FOO, expected CONTINUOUS, DATABASES, DIAGNOSTICS, FIELD, GRANTS, MEASUREMENT, MEASUREMENTS, QUERIES, RETENTION, SERIES, SERVERS, SHARDS, STATS, TAG, USERS at line 1, char 6`},
+		{s: `SHOW MEASUREMENT`, err: `found EOF, expected CARDINALITY at line 1, char 18`},
+		{s: `SHOW TAG VALUES CARDINALITY`, err: `found EOF, expected WITH at line 1, char 29`},
 		{s: `SHOW STATS FOR`, err: `found EOF, expected string at line 1, char 16`},
 		{s: `SHOW DIAGNOSTICS FOR`, err: `found EOF, expected string at line 1, char 22`},
 		{s: `SHOW GRANTS`, err: `found EOF, expected FOR at line 1, char 13`},
@@ -1883,6 +2282,81 @@ func TestParser_ParseExpr(t *testing.T) {
 	}
 }
 
+// Ensure parameters are bound to literals of their value's type.
+func TestParser_ParseStatement_Params(t *testing.T) {
+	params := map[string]interface{}{
+		"host":     `server'01`,
+		"value":    float64(10),
+		"start":    map[string]interface{}{"time": "2000-01-01T00:00:00Z"},
+		"enabled":  true,
+		"interval": map[string]interface{}{"duration": "10m"},
+		"re":       map[string]interface{}{"regex": "^us-(east|west)$"},
+		"field":    map[string]interface{}{"identifier": "my value"},
+		"name":     map[string]interface{}{"identifier": "cpu"},
+		"date":     "2000-01-01",
+	}
+
+	var tests = []struct {
+		s   string
+		exp string
+		err string
+	}{
+		{
+			s:   `SELECT mean($field) FROM db.rp.$name WHERE host = $host AND value > $value AND time > $start GROUP BY time($interval)`,
+			exp: `SELECT mean("my value") FROM "db"."rp".cpu WHERE host = 'server\'01' AND value > 10.000 AND time > '2000-01-01T00:00:00Z' GROUP BY time(10m)`,
+		},
+		{
+			s:   `SELECT * FROM $re WHERE region =~ $re OR enabled = $enabled OR day = $date`,
+			exp: `SELECT * FROM /^us-(east|west)$/ WHERE region =~ /^us-(east|west)$/ OR enabled = true OR day = '2000-01-01'`,
+		},
+		{
+			s:   `DROP MEASUREMENT $name`,
+			exp: `DROP MEASUREMENT cpu`,
+		},
+		{s: `SELECT value FROM cpu WHERE host = $missing`, err: `missing parameter: $missing at line 1, char 36`},
+		{s: `SELECT value FROM $host`, err: `parameter $host must be an identifier at line 1, char 19`},
+		{s: `SELECT value FROM cpu WHERE host =~ $host`, err: `found $host, expected regex at line 1, char 37`},
+	}
+
+	for i, tt := range tests {
+		p := influxql.NewParser(strings.NewReader(tt.s))
+		p.SetParams(params)
+		stmt, err := p.ParseStatement()
+		if errstring(err) != tt.err {
+			t.Errorf("%d. %q: error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.s, tt.err, err)
+		} else if err == nil && stmt.String() != tt.exp {
+			t.Errorf("%d. %q: unexpected statement:\n\nexp=%s\n\ngot=%s\n\n", i, tt.s, tt.exp, stmt)
+		}
+	}
+
+	// Only values typed as times are bound to time literals.
+	for v, exp := range map[interface{}]string{
+		"2000-01-01T00:00:00Z":                "*influxql.StringLiteral",
+		"2000-01-01":                          "*influxql.StringLiteral",
+		mustParseTime("2000-01-01T00:00:00Z"): "*influxql.TimeLiteral",
+	} {
+		if expr, err := influxql.BindParam(v); err != nil {
+			t.Errorf("binding %#v: %s", v, err)
+		} else if got := fmt.Sprintf("%T", expr); got != exp {
+			t.Errorf("binding %#v: exp %s, got %s", v, exp, got)
+		}
+	}
+	for _, v := range []string{"2000-01-01T00:00:00Z", "2000-01-01"} {
+		if expr, err := influxql.BindParam(map[string]interface{}{"time": v}); err != nil {
+			t.Errorf("binding time %q: %s", v, err)
+		} else if _, ok := expr.(*influxql.TimeLiteral); !ok {
+			t.Errorf("binding time %q: unexpected expression %T", v, expr)
+		}
+	}
+
+	// Unsupported values are rejected.
+	for _, v := range []interface{}{[]interface{}{1}, map[string]interface{}{"duration": "x"}, map[string]interface{}{"time": "x"}, map[string]interface{}{"bogus": "x"}} {
+		if _, err := influxql.BindParam(v); err == nil {
+			t.Errorf("expected error binding %#v", v)
+		}
+	}
+}
+
 // Ensure a time duration can be parsed.
 func TestParseDuration(t *testing.T) {
 	var tests = []struct {
@@ -2033,6 +2507,37 @@ func TestDropSeriesStatement_String(t *testing.T) {
 	}
 }
 
+// Ensure DeleteStatement can convert to a string
+func TestDeleteStatement_String(t *testing.T) {
+	var tests = []struct {
+		s    string
+		stmt influxql.Statement
+	}{
+		{
+			s:    `DELETE FROM src`,
+			stmt: &influxql.DeleteStatement{Source: &influxql.Measurement{Name: "src"}},
+		},
+		{
+			s: `DELETE FROM src WHERE host = 'hosta.influxdb.org'`,
+			stmt: &influxql.DeleteStatement{
+				Source: &influxql.Measurement{Name: "src"},
+				Condition: &influxql.BinaryExpr{
+					Op:  influxql.EQ,
+					LHS: &influxql.VarRef{Val: "host"},
+					RHS: &influxql.StringLiteral{Val: "hosta.influxdb.org"},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s := test.stmt.String()
+		if s != test.s {
+			t.Errorf("error rendering string. expected %s, actual: %s", test.s, s)
+		}
+	}
+}
+
 func BenchmarkParserParseStatement(b *testing.B) {
 	b.ReportAllocs()
 	s := `SELECT field FROM "series" WHERE value > 10`
@@ -2108,6 +2613,12 @@ func mustParseDuration(s string) time.Duration {
 	return d
 }
 
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	panicIfErr(err)
+	return loc
+}
+
 func panicIfErr(err error) {
 	if err != nil {
 		panic(err)
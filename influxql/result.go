@@ -72,6 +72,9 @@ func GetProcessor(expr Expr, startIndex int) (Processor, int) {
 	case *VarRef:
 		return newEchoProcessor(startIndex), startIndex + 1
 	case *Call:
+		if expr.IsScalar() {
+			return getScalarCallProcessor(expr, startIndex)
+		}
 		return newEchoProcessor(startIndex), startIndex + 1
 	case *BinaryExpr:
 		return getBinaryProcessor(expr, startIndex)
@@ -87,6 +90,8 @@ func GetProcessor(expr Expr, startIndex int) (Processor, int) {
 		return newLiteralProcessor(expr.Val), startIndex
 	case *DurationLiteral:
 		return newLiteralProcessor(expr.Val), startIndex
+	case *RegexLiteral:
+		return newLiteralProcessor(expr.Val), startIndex
 	}
 	panic("unreachable")
 }
@@ -105,6 +110,22 @@ func newLiteralProcessor(val interface{}) Processor {
 	}
 }
 
+func getScalarCallProcessor(expr *Call, startIndex int) (Processor, int) {
+	args := make([]Processor, len(expr.Args))
+	index := startIndex
+	for i, arg := range expr.Args {
+		args[i], index = GetProcessor(arg, index)
+	}
+
+	return func(values []interface{}) interface{} {
+		a := make([]interface{}, len(args))
+		for i, p := range args {
+			a[i] = p(values)
+		}
+		return evalScalarCall(expr.Name, a)
+	}, index
+}
+
 func getBinaryProcessor(expr *BinaryExpr, startIndex int) (Processor, int) {
 	lhs, index := GetProcessor(expr.LHS, startIndex)
 	rhs, index := GetProcessor(expr.RHS, index)
@@ -145,7 +166,8 @@ func newBinaryExprEvaluator(op Token, lhs, rhs Processor) Processor {
 		return func(values []interface{}) interface{} {
 			l := lhs(values)
 			r := rhs(values)
-			if lf, rf, ok := processorValuesAsFloat64(l, r); ok {
+			// Division by zero has no value rather than an infinite one, which can't be encoded.
+			if lf, rf, ok := processorValuesAsFloat64(l, r); ok && rf != 0 {
 				return lf / rf
 			}
 			return nil
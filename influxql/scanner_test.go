@@ -63,6 +63,11 @@ func TestScanner_Scan(t *testing.T) {
 		{s: `_foo`, tok: influxql.IDENT, lit: `_foo`},
 		{s: `Zx12_3U_-`, tok: influxql.IDENT, lit: `Zx12_3U_`},
 		{s: `"foo"`, tok: influxql.IDENT, lit: `foo`},
+
+		// Bound parameters
+		{s: `$foo`, tok: influxql.BOUNDPARAM, lit: `$foo`},
+		{s: `$_f00 `, tok: influxql.BOUNDPARAM, lit: `$_f00`},
+		{s: `$`, tok: influxql.ILLEGAL, lit: `$`},
 		{s: `"foo\\bar"`, tok: influxql.IDENT, lit: `foo\bar`},
 		{s: `"foo\bar"`, tok: influxql.BADESCAPE, lit: `\b`, pos: influxql.Pos{Line: 0, Char: 5}},
 		{s: `"foo\"bar\""`, tok: influxql.IDENT, lit: `foo"bar"`},
@@ -108,10 +113,12 @@ func TestScanner_Scan(t *testing.T) {
 		// Keywords
 		{s: `ALL`, tok: influxql.ALL},
 		{s: `ALTER`, tok: influxql.ALTER},
+		{s: `ANALYZE`, tok: influxql.ANALYZE},
 		{s: `AS`, tok: influxql.AS},
 		{s: `ASC`, tok: influxql.ASC},
 		{s: `BEGIN`, tok: influxql.BEGIN},
 		{s: `BY`, tok: influxql.BY},
+		{s: `CARDINALITY`, tok: influxql.CARDINALITY},
 		{s: `CREATE`, tok: influxql.CREATE},
 		{s: `CONTINUOUS`, tok: influxql.CONTINUOUS},
 		{s: `DATABASE`, tok: influxql.DATABASE},
@@ -128,12 +135,14 @@ func TestScanner_Scan(t *testing.T) {
 		{s: `FROM`, tok: influxql.FROM},
 		{s: `GRANT`, tok: influxql.GRANT},
 		{s: `GROUP`, tok: influxql.GROUP},
+		{s: `HAVING`, tok: influxql.HAVING},
 		{s: `IF`, tok: influxql.IF},
 		{s: `INNER`, tok: influxql.INNER},
 		{s: `INSERT`, tok: influxql.INSERT},
 		{s: `INTO`, tok: influxql.INTO},
 		{s: `KEY`, tok: influxql.KEY},
 		{s: `KEYS`, tok: influxql.KEYS},
+		{s: `KILL`, tok: influxql.KILL},
 		{s: `LIMIT`, tok: influxql.LIMIT},
 		{s: `SHOW`, tok: influxql.SHOW},
 		{s: `SHARDS`, tok: influxql.SHARDS},
@@ -25,6 +25,7 @@ const (
 	FALSE        // false
 	REGEX        // Regular expressions
 	BADREGEX     // `.*
+	BOUNDPARAM   // $param
 	literal_end
 
 	operator_beg
@@ -58,10 +59,12 @@ const (
 	// Keywords
 	ALL
 	ALTER
+	ANALYZE
 	AS
 	ASC
 	BEGIN
 	BY
+	CARDINALITY
 	CREATE
 	CONTINUOUS
 	DATABASE
@@ -82,6 +85,7 @@ const (
 	GRANT
 	GRANTS
 	GROUP
+	HAVING
 	IF
 	IN
 	INF
@@ -90,6 +94,7 @@ const (
 	INTO
 	KEY
 	KEYS
+	KILL
 	LIMIT
 	MEASUREMENT
 	MEASUREMENTS
@@ -144,6 +149,7 @@ var tokens = [...]string{
 	TRUE:         "TRUE",
 	FALSE:        "FALSE",
 	REGEX:        "REGEX",
+	BOUNDPARAM:   "BOUNDPARAM",
 
 	ADD: "+",
 	SUB: "-",
@@ -171,10 +177,12 @@ var tokens = [...]string{
 
 	ALL:          "ALL",
 	ALTER:        "ALTER",
+	ANALYZE:      "ANALYZE",
 	AS:           "AS",
 	ASC:          "ASC",
 	BEGIN:        "BEGIN",
 	BY:           "BY",
+	CARDINALITY:  "CARDINALITY",
 	CREATE:       "CREATE",
 	CONTINUOUS:   "CONTINUOUS",
 	DATABASE:     "DATABASE",
@@ -195,6 +203,7 @@ var tokens = [...]string{
 	GRANT:        "GRANT",
 	GRANTS:       "GRANTS",
 	GROUP:        "GROUP",
+	HAVING:       "HAVING",
 	IF:           "IF",
 	IN:           "IN",
 	INF:          "INF",
@@ -203,6 +212,7 @@ var tokens = [...]string{
 	INTO:         "INTO",
 	KEY:          "KEY",
 	KEYS:         "KEYS",
+	KILL:         "KILL",
 	LIMIT:        "LIMIT",
 	MEASUREMENT:  "MEASUREMENT",
 	MEASUREMENTS: "MEASUREMENTS",
@@ -70,6 +70,7 @@ BINS=(
     influx
     influx_stress
     influx_inspect
+    influx_tsm
     )
 
 ###########################################################################
@@ -0,0 +1,282 @@
+// Package hll implements HyperLogLog sketches for estimating the number of
+// distinct values in a set using a small, fixed amount of memory.
+//
+// Sketches with few values are kept in a sparse form and switch to a dense
+// array of registers as they grow. Sketches of the same precision can be merged,
+// and are encoded in a compact binary form.
+package hll
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+	"sort"
+)
+
+const (
+	// MinPrecision is the smallest precision supported.
+	MinPrecision = 4
+
+	// MaxPrecision is the largest precision supported.
+	MaxPrecision = 18
+
+	// DefaultPrecision is the precision of sketches created by NewDefaultSketch.
+	// It uses 16KB of registers and has a standard error of about 0.8%.
+	DefaultPrecision = 14
+
+	// version is the version of the binary encoding.
+	version = 1
+)
+
+const (
+	denseFormat  = 0
+	sparseFormat = 1
+)
+
+var (
+	// ErrInvalidPrecision is returned when the precision is out of range.
+	ErrInvalidPrecision = errors.New("hll: precision out of range")
+
+	// ErrPrecisionMismatch is returned when merging sketches of different precisions.
+	ErrPrecisionMismatch = errors.New("hll: cannot merge sketches of different precisions")
+
+	// ErrInvalidEncoding is returned when a sketch cannot be decoded.
+	ErrInvalidEncoding = errors.New("hll: invalid encoding")
+)
+
+// Sketch is a HyperLogLog sketch.
+type Sketch struct {
+	p uint8  // precision, the number of bits of the hash used for the register index
+	m uint32 // number of registers
+
+	registers []uint8          // dense registers, nil while the sketch is sparse
+	sparse    map[uint32]uint8 // non-zero registers by index while the sketch is sparse
+}
+
+// NewSketch returns a new sketch with 2^p registers.
+func NewSketch(p uint8) (*Sketch, error) {
+	if p < MinPrecision || p > MaxPrecision {
+		return nil, ErrInvalidPrecision
+	}
+	return &Sketch{
+		p:      p,
+		m:      1 << p,
+		sparse: make(map[uint32]uint8),
+	}, nil
+}
+
+// NewDefaultSketch returns a new sketch with the default precision.
+func NewDefaultSketch() *Sketch {
+	s, _ := NewSketch(DefaultPrecision)
+	return s
+}
+
+// Add adds the value v to the sketch.
+func (s *Sketch) Add(v []byte) {
+	h := fnv.New64a()
+	h.Write(v)
+	s.insert(mix(h.Sum64()))
+}
+
+// insert adds a hashed value to the sketch.
+func (s *Sketch) insert(x uint64) {
+	// The first p bits select the register, the rank of the remaining bits is stored.
+	i := uint32(x >> (64 - s.p))
+	s.set(i, rank(x<<s.p, 64-s.p))
+}
+
+// set sets register i to r if r is larger than its current value.
+func (s *Sketch) set(i uint32, r uint8) {
+	if s.registers != nil {
+		if r > s.registers[i] {
+			s.registers[i] = r
+		}
+		return
+	}
+
+	if r > s.sparse[i] {
+		s.sparse[i] = r
+		if len(s.sparse) > s.sparseLimit() {
+			s.toDense()
+		}
+	}
+}
+
+// sparseLimit returns the number of registers above which the sketch is dense.
+func (s *Sketch) sparseLimit() int { return int(s.m / 8) }
+
+// toDense converts the sketch to the dense form.
+func (s *Sketch) toDense() {
+	s.registers = make([]uint8, s.m)
+	for i, r := range s.sparse {
+		s.registers[i] = r
+	}
+	s.sparse = nil
+}
+
+// Merge merges other into the sketch. Both sketches must have the same precision.
+func (s *Sketch) Merge(other *Sketch) error {
+	if s.p != other.p {
+		return ErrPrecisionMismatch
+	}
+
+	if other.registers == nil {
+		for i, r := range other.sparse {
+			s.set(i, r)
+		}
+		return nil
+	}
+
+	if s.registers == nil {
+		s.toDense()
+	}
+	for i, r := range other.registers {
+		if r > s.registers[i] {
+			s.registers[i] = r
+		}
+	}
+	return nil
+}
+
+// Count returns the estimated number of distinct values added to the sketch.
+func (s *Sketch) Count() uint64 {
+	m := float64(s.m)
+
+	// Sum the harmonic mean of the registers, counting the empty ones.
+	var sum float64
+	var zeros int
+	if s.registers == nil {
+		for _, r := range s.sparse {
+			sum += 1 / float64(uint64(1)<<r)
+		}
+		zeros = int(s.m) - len(s.sparse)
+		sum += float64(zeros)
+	} else {
+		for _, r := range s.registers {
+			if r == 0 {
+				zeros++
+			}
+			sum += 1 / float64(uint64(1)<<r)
+		}
+	}
+
+	estimate := alpha(s.m) * m * m / sum
+
+	// Use linear counting for small cardinalities where the estimate is biased.
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// MarshalBinary encodes the sketch. Sparse sketches are encoded as the
+// index deltas and values of their non-zero registers.
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	if s.registers != nil {
+		b := make([]byte, 3, 3+len(s.registers))
+		b[0], b[1], b[2] = version, s.p, denseFormat
+		return append(b, s.registers...), nil
+	}
+
+	indices := make([]int, 0, len(s.sparse))
+	for i := range s.sparse {
+		indices = append(indices, int(i))
+	}
+	sort.Ints(indices)
+
+	b := make([]byte, 3, 3+binary.MaxVarintLen32+len(indices)*4)
+	b[0], b[1], b[2] = version, s.p, sparseFormat
+
+	var buf [binary.MaxVarintLen32]byte
+	b = append(b, buf[:binary.PutUvarint(buf[:], uint64(len(indices)))]...)
+	prev := 0
+	for _, i := range indices {
+		b = append(b, buf[:binary.PutUvarint(buf[:], uint64(i-prev))]...)
+		b = append(b, s.sparse[uint32(i)])
+		prev = i
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a sketch encoded by MarshalBinary.
+func (s *Sketch) UnmarshalBinary(b []byte) error {
+	if len(b) < 3 || b[0] != version {
+		return ErrInvalidEncoding
+	}
+
+	other, err := NewSketch(b[1])
+	if err != nil {
+		return err
+	}
+
+	switch b[2] {
+	case denseFormat:
+		if len(b[3:]) != int(other.m) {
+			return ErrInvalidEncoding
+		}
+		other.sparse = nil
+		other.registers = make([]uint8, other.m)
+		copy(other.registers, b[3:])
+	case sparseFormat:
+		b = b[3:]
+		n, sz := binary.Uvarint(b)
+		if sz <= 0 || n > uint64(other.m) {
+			return ErrInvalidEncoding
+		}
+		b = b[sz:]
+
+		var i uint64
+		for j := uint64(0); j < n; j++ {
+			delta, sz := binary.Uvarint(b)
+			if sz <= 0 || len(b) < sz+1 {
+				return ErrInvalidEncoding
+			}
+			i += delta
+			if i >= uint64(other.m) {
+				return ErrInvalidEncoding
+			}
+			other.set(uint32(i), b[sz])
+			b = b[sz+1:]
+		}
+	default:
+		return ErrInvalidEncoding
+	}
+
+	*s = *other
+	return nil
+}
+
+// rank returns the position of the leftmost 1 bit in x, counting from 1.
+// If none of the first max bits are set then max+1 is returned.
+func rank(x uint64, max uint8) uint8 {
+	r := uint8(1)
+	for r <= max && x&(1<<63) == 0 {
+		r++
+		x <<= 1
+	}
+	return r
+}
+
+// alpha returns the bias correction constant for m registers.
+func alpha(m uint32) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	}
+	return 0.7213 / (1 + 1.079/float64(m))
+}
+
+// mix spreads the bits of a hash so the leading bits are well distributed.
+func mix(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
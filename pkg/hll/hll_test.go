@@ -0,0 +1,82 @@
+package hll_test
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/influxdb/influxdb/pkg/hll"
+)
+
+// Ensure the sketch estimates the number of distinct values within its error.
+func TestSketch_Count(t *testing.T) {
+	for _, n := range []int{0, 1, 10, 1000, 5000, 100000} {
+		s := hll.NewDefaultSketch()
+		for i := 0; i < n; i++ {
+			// Add every value twice to ensure duplicates are not counted.
+			s.Add([]byte(strconv.Itoa(i)))
+			s.Add([]byte(strconv.Itoa(i)))
+		}
+
+		if got := s.Count(); math.Abs(float64(got)-float64(n)) > float64(n)*0.03 {
+			t.Errorf("count(%d): got %d", n, got)
+		}
+	}
+}
+
+// Ensure merged sketches estimate the number of distinct values in their union.
+func TestSketch_Merge(t *testing.T) {
+	a, b := hll.NewDefaultSketch(), hll.NewDefaultSketch()
+	for i := 0; i < 20000; i++ {
+		a.Add([]byte(strconv.Itoa(i)))
+	}
+	for i := 10000; i < 30000; i++ {
+		b.Add([]byte(strconv.Itoa(i)))
+	}
+
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	} else if got := a.Count(); math.Abs(float64(got)-30000) > 30000*0.03 {
+		t.Fatalf("unexpected count: %d", got)
+	}
+
+	// Sketches of different precisions cannot be merged.
+	c, err := hll.NewSketch(10)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := a.Merge(c); err != hll.ErrPrecisionMismatch {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure sparse and dense sketches can be encoded and decoded.
+func TestSketch_MarshalBinary(t *testing.T) {
+	for _, n := range []int{0, 10, 100000} {
+		s := hll.NewDefaultSketch()
+		for i := 0; i < n; i++ {
+			s.Add([]byte(strconv.Itoa(i)))
+		}
+
+		b, err := s.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Small sketches should be encoded compactly.
+		if n == 10 && len(b) > 64 {
+			t.Errorf("sparse sketch encoded in %d bytes", len(b))
+		}
+
+		other := &hll.Sketch{}
+		if err := other.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(s, other) {
+			t.Errorf("sketch mismatch after decoding %d values", n)
+		}
+	}
+
+	if err := (&hll.Sketch{}).UnmarshalBinary([]byte{1, 14}); err != hll.ErrInvalidEncoding {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,257 @@
+// Package tdigest implements the merging t-digest, a sketch for estimating
+// quantiles of a set of values using a small amount of memory.
+//
+// Values are clustered into centroids which are kept small near the
+// extreme quantiles, so estimates of quantiles such as p99 are accurate.
+// Digests can be merged, and are encoded in a compact binary form.
+package tdigest
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+)
+
+const (
+	// DefaultCompression is the compression of digests created by NewDefault.
+	// Digests keep at most a few hundred centroids.
+	DefaultCompression = 100
+
+	// version is the version of the binary encoding.
+	version = 1
+)
+
+// ErrInvalidEncoding is returned when a digest cannot be decoded.
+var ErrInvalidEncoding = errors.New("tdigest: invalid encoding")
+
+// Centroid is the mean of a cluster of values and the number of values in it.
+type Centroid struct {
+	Mean   float64
+	Weight float64
+}
+
+type centroids []Centroid
+
+func (a centroids) Len() int           { return len(a) }
+func (a centroids) Less(i, j int) bool { return a[i].Mean < a[j].Mean }
+func (a centroids) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
+// Digest is a t-digest.
+type Digest struct {
+	compression float64
+	min, max    float64
+
+	merged   centroids // compressed centroids, sorted by mean
+	unmerged centroids // centroids added since the last compression
+}
+
+// New returns a new digest. Larger compressions keep more centroids and
+// give more accurate estimates.
+func New(compression float64) *Digest {
+	return &Digest{
+		compression: compression,
+		min:         math.Inf(+1),
+		max:         math.Inf(-1),
+	}
+}
+
+// NewDefault returns a new digest with the default compression.
+func NewDefault() *Digest { return New(DefaultCompression) }
+
+// Add adds the value x to the digest.
+func (d *Digest) Add(x float64) {
+	if math.IsNaN(x) {
+		return
+	}
+	d.add(Centroid{Mean: x, Weight: 1})
+}
+
+// add adds a centroid to the digest, compressing it when enough have been added.
+func (d *Digest) add(c Centroid) {
+	d.min = math.Min(d.min, c.Mean)
+	d.max = math.Max(d.max, c.Mean)
+
+	d.unmerged = append(d.unmerged, c)
+	if len(d.unmerged) > int(5*d.compression) {
+		d.compress()
+	}
+}
+
+// Merge adds the values of other to the digest.
+func (d *Digest) Merge(other *Digest) {
+	for _, c := range other.merged {
+		d.add(c)
+	}
+	for _, c := range other.unmerged {
+		d.add(c)
+	}
+
+	// The centroids of other only hold the extremes approximately.
+	if other.Count() > 0 {
+		d.min = math.Min(d.min, other.min)
+		d.max = math.Max(d.max, other.max)
+	}
+}
+
+// Count returns the number of values added to the digest.
+func (d *Digest) Count() float64 {
+	var n float64
+	for _, c := range d.merged {
+		n += c.Weight
+	}
+	for _, c := range d.unmerged {
+		n += c.Weight
+	}
+	return n
+}
+
+// compress merges the unmerged centroids into the merged ones. Neighbouring
+// centroids are combined while they stay within the size allowed at their
+// quantile, which is smallest at the extremes.
+func (d *Digest) compress() {
+	if len(d.unmerged) == 0 {
+		return
+	}
+
+	all := append(d.merged, d.unmerged...)
+	sort.Sort(all)
+
+	var total float64
+	for _, c := range all {
+		total += c.Weight
+	}
+
+	merged := make(centroids, 0, len(d.merged))
+	cur := all[0]
+	var weightSoFar float64
+	limit := d.quantileLimit(0)
+	for _, c := range all[1:] {
+		if (weightSoFar+cur.Weight+c.Weight)/total <= limit {
+			cur.Weight += c.Weight
+			cur.Mean += (c.Mean - cur.Mean) * c.Weight / cur.Weight
+			continue
+		}
+
+		weightSoFar += cur.Weight
+		merged = append(merged, cur)
+		limit = d.quantileLimit(weightSoFar / total)
+		cur = c
+	}
+	d.merged = append(merged, cur)
+	d.unmerged = nil
+}
+
+// quantileLimit returns the largest quantile a centroid starting at quantile q may reach.
+// It uses the scale function k(q) = compression / 2π * asin(2q - 1).
+func (d *Digest) quantileLimit(q float64) float64 {
+	k := d.compression/(2*math.Pi)*math.Asin(2*q-1) + 1
+	if k >= d.compression/4 {
+		return 1
+	}
+	return (math.Sin(k*2*math.Pi/d.compression) + 1) / 2
+}
+
+// Quantile returns the estimated value at quantile q, between 0 and 1.
+// Returns NaN if the digest is empty.
+func (d *Digest) Quantile(q float64) float64 {
+	d.compress()
+	cs := d.merged
+	if len(cs) == 0 {
+		return math.NaN()
+	} else if len(cs) == 1 {
+		return cs[0].Mean
+	}
+	q = math.Max(0, math.Min(1, q))
+
+	var total float64
+	for _, c := range cs {
+		total += c.Weight
+	}
+	index := q * total
+
+	// Interpolate between the minimum and the center of the first centroid.
+	if index < cs[0].Weight/2 {
+		return d.min + index/(cs[0].Weight/2)*(cs[0].Mean-d.min)
+	}
+
+	// Interpolate between the centers of the centroids either side of the index.
+	weightSoFar := cs[0].Weight / 2
+	for i := 0; i < len(cs)-1; i++ {
+		dw := (cs[i].Weight + cs[i+1].Weight) / 2
+		if weightSoFar+dw > index {
+			return cs[i].Mean + (index-weightSoFar)/dw*(cs[i+1].Mean-cs[i].Mean)
+		}
+		weightSoFar += dw
+	}
+
+	// Interpolate between the center of the last centroid and the maximum.
+	last := cs[len(cs)-1]
+	frac := math.Min(1, (index-weightSoFar)/(last.Weight/2))
+	return last.Mean + frac*(d.max-last.Mean)
+}
+
+// MarshalBinary encodes the digest as its compression, extremes and centroids.
+func (d *Digest) MarshalBinary() ([]byte, error) {
+	d.compress()
+
+	b := make([]byte, 0, 1+3*8+binary.MaxVarintLen64+len(d.merged)*(8+binary.MaxVarintLen64))
+	b = append(b, version)
+	b = appendFloat64(b, d.compression)
+	b = appendFloat64(b, d.min)
+	b = appendFloat64(b, d.max)
+
+	var buf [binary.MaxVarintLen64]byte
+	b = append(b, buf[:binary.PutUvarint(buf[:], uint64(len(d.merged)))]...)
+	for _, c := range d.merged {
+		// Weights are counts of values so are stored as integers.
+		b = appendFloat64(b, c.Mean)
+		b = append(b, buf[:binary.PutUvarint(buf[:], uint64(c.Weight))]...)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a digest encoded by MarshalBinary.
+func (d *Digest) UnmarshalBinary(b []byte) error {
+	if len(b) < 1+3*8 || b[0] != version {
+		return ErrInvalidEncoding
+	}
+	b = b[1:]
+
+	other := New(readFloat64(b[0:]))
+	other.min, other.max = readFloat64(b[8:]), readFloat64(b[16:])
+	b = b[24:]
+
+	n, sz := binary.Uvarint(b)
+	if sz <= 0 || n > uint64(len(b)) {
+		return ErrInvalidEncoding
+	}
+	b = b[sz:]
+
+	other.merged = make(centroids, 0, n)
+	for i := uint64(0); i < n; i++ {
+		if len(b) < 8 {
+			return ErrInvalidEncoding
+		}
+		mean := readFloat64(b)
+		weight, sz := binary.Uvarint(b[8:])
+		if sz <= 0 {
+			return ErrInvalidEncoding
+		}
+		other.merged = append(other.merged, Centroid{Mean: mean, Weight: float64(weight)})
+		b = b[8+sz:]
+	}
+
+	*d = *other
+	return nil
+}
+
+func appendFloat64(b []byte, f float64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], math.Float64bits(f))
+	return append(b, buf[:]...)
+}
+
+func readFloat64(b []byte) float64 {
+	return math.Float64frombits(binary.BigEndian.Uint64(b))
+}
@@ -0,0 +1,102 @@
+package tdigest_test
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/influxdb/influxdb/pkg/tdigest"
+)
+
+// Ensure quantiles are estimated accurately, especially at the extremes.
+func TestDigest_Quantile(t *testing.T) {
+	rand.Seed(42)
+	values := make([]float64, 100000)
+	d := tdigest.NewDefault()
+	for i := range values {
+		values[i] = rand.NormFloat64()
+		d.Add(values[i])
+	}
+	sort.Float64s(values)
+
+	for _, q := range []float64{0, 0.01, 0.25, 0.5, 0.75, 0.99, 0.999, 1} {
+		// Compare the rank of the estimate to the expected rank.
+		got := d.Quantile(q)
+		rank := float64(sort.SearchFloat64s(values, got)) / float64(len(values))
+		if math.Abs(rank-q) > 0.005 {
+			t.Errorf("quantile(%v): got %v with rank %v", q, got, rank)
+		}
+	}
+
+	if d.Count() != float64(len(values)) {
+		t.Errorf("unexpected count: %v", d.Count())
+	}
+}
+
+// Ensure an empty digest has no quantiles and a single value is every quantile.
+func TestDigest_Quantile_Small(t *testing.T) {
+	d := tdigest.NewDefault()
+	if v := d.Quantile(0.5); !math.IsNaN(v) {
+		t.Fatalf("unexpected quantile: %v", v)
+	}
+
+	d.Add(3)
+	if v := d.Quantile(0.99); v != 3 {
+		t.Fatalf("unexpected quantile: %v", v)
+	}
+
+	for i := 1; i <= 10; i++ {
+		d.Add(float64(i))
+	}
+	if v := d.Quantile(0); v != 1 {
+		t.Fatalf("unexpected min: %v", v)
+	} else if v := d.Quantile(1); v != 10 {
+		t.Fatalf("unexpected max: %v", v)
+	}
+}
+
+// Ensure merged digests estimate the quantiles of their combined values.
+func TestDigest_Merge(t *testing.T) {
+	a, b := tdigest.NewDefault(), tdigest.NewDefault()
+	for i := 0; i < 50000; i++ {
+		a.Add(float64(i))
+		b.Add(float64(i + 50000))
+	}
+
+	a.Merge(b)
+	if v := a.Quantile(0.5); math.Abs(v-50000) > 500 {
+		t.Fatalf("unexpected median: %v", v)
+	} else if v := a.Quantile(0.99); math.Abs(v-99000) > 100 {
+		t.Fatalf("unexpected p99: %v", v)
+	} else if v := a.Quantile(1); v != 99999 {
+		t.Fatalf("unexpected max: %v", v)
+	}
+}
+
+// Ensure digests can be encoded and decoded.
+func TestDigest_MarshalBinary(t *testing.T) {
+	d := tdigest.NewDefault()
+	for i := 0; i < 10000; i++ {
+		d.Add(float64(i))
+	}
+
+	b, err := d.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(b) > 10000 {
+		t.Fatalf("digest encoded in %d bytes", len(b))
+	}
+
+	other := &tdigest.Digest{}
+	if err := other.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(d, other) {
+		t.Fatalf("digest mismatch:\ngot %#v\nexp %#v", other, d)
+	}
+
+	if err := other.UnmarshalBinary(b[:len(b)-1]); err != tdigest.ErrInvalidEncoding {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -38,7 +38,7 @@ type ContinuousQuerier interface {
 
 // queryExecutor is an internal interface to make testing easier.
 type queryExecutor interface {
-	ExecuteQuery(query *influxql.Query, database string, chunkSize int) (<-chan *influxql.Result, error)
+	ExecuteQuery(query *influxql.Query, database string, chunkSize int, user string, closing <-chan struct{}) (<-chan *influxql.Result, error)
 }
 
 // metaStore is an internal interface to make testing easier.
@@ -322,12 +322,15 @@ func (s *Service) ExecuteContinuousQuery(dbi *meta.DatabaseInfo, cqi *meta.Conti
 // runContinuousQueryAndWriteResult will run the query against the cluster and write the results back in
 func (s *Service) runContinuousQueryAndWriteResult(cq *ContinuousQuery) error {
 	// Wrap the CQ's inner SELECT statement in a Query for the QueryExecutor.
+	// The results are written by the service, not by the statement's INTO clause.
+	stmt := cq.q.Clone()
+	stmt.Target = nil
 	q := &influxql.Query{
-		Statements: influxql.Statements([]influxql.Statement{cq.q}),
+		Statements: influxql.Statements([]influxql.Statement{stmt}),
 	}
 
 	// Execute the SELECT.
-	ch, err := s.QueryExecutor.ExecuteQuery(q, cq.Database, NoChunkingSize)
+	ch, err := s.QueryExecutor.ExecuteQuery(q, cq.Database, NoChunkingSize, "", nil)
 	if err != nil {
 		return err
 	}
@@ -340,32 +343,13 @@ func (s *Service) runContinuousQueryAndWriteResult(cq *ContinuousQuery) error {
 		}
 
 		for _, row := range result.Series {
-			// Get the measurement name for the result.
-			measurement := cq.intoMeasurement()
-			if measurement == "" {
-				measurement = row.Name
-			}
-			// Convert the result row to points.
-			part, err := s.convertRowToPoints(measurement, row)
+			// Convert the result row to points, nil values are not written.
+			// They are returned if the CQ runs before data is written to the measurement.
+			part, err := tsdb.ConvertRowToPoints(cq.q.Target.MeasurementName(row.Name, cq.q.Sources), row)
 			if err != nil {
 				log.Println(err)
 				continue
 			}
-
-			if len(part) == 0 {
-				continue
-			}
-
-			// If the points have any nil values, can't write.
-			// This happens if the CQ is created and running before data is written to the measurement.
-			for _, p := range part {
-				fields := p.Fields()
-				for _, v := range fields {
-					if v == nil {
-						return nil
-					}
-				}
-			}
 			points = append(points, part...)
 		}
 	}
@@ -396,39 +380,6 @@ func (s *Service) runContinuousQueryAndWriteResult(cq *ContinuousQuery) error {
 	return nil
 }
 
-// convertRowToPoints will convert a query result Row into Points that can be written back in.
-// Used for continuous and INTO queries
-func (s *Service) convertRowToPoints(measurementName string, row *models.Row) ([]models.Point, error) {
-	// figure out which parts of the result are the time and which are the fields
-	timeIndex := -1
-	fieldIndexes := make(map[string]int)
-	for i, c := range row.Columns {
-		if c == "time" {
-			timeIndex = i
-		} else {
-			fieldIndexes[c] = i
-		}
-	}
-
-	if timeIndex == -1 {
-		return nil, errors.New("error finding time index in result")
-	}
-
-	points := make([]models.Point, 0, len(row.Values))
-	for _, v := range row.Values {
-		vals := make(map[string]interface{})
-		for fieldName, fieldIndex := range fieldIndexes {
-			vals[fieldName] = v[fieldIndex]
-		}
-
-		p := models.NewPoint(measurementName, row.Tags, vals, v[timeIndex].(time.Time))
-
-		points = append(points, p)
-	}
-
-	return points, nil
-}
-
 // ContinuousQuery is a local wrapper / helper around continuous queries.
 type ContinuousQuery struct {
 	Database string
@@ -444,9 +395,8 @@ func (cq *ContinuousQuery) intoDB() string {
 	return cq.Database
 }
 
-func (cq *ContinuousQuery) intoRP() string          { return cq.q.Target.Measurement.RetentionPolicy }
-func (cq *ContinuousQuery) setIntoRP(rp string)     { cq.q.Target.Measurement.RetentionPolicy = rp }
-func (cq *ContinuousQuery) intoMeasurement() string { return cq.q.Target.Measurement.Name }
+func (cq *ContinuousQuery) intoRP() string      { return cq.q.Target.Measurement.RetentionPolicy }
+func (cq *ContinuousQuery) setIntoRP(rp string) { cq.q.Target.Measurement.RetentionPolicy = rp }
 
 // NewContinuousQuery returns a ContinuousQuery object with a parsed influxql.CreateContinuousQueryStatement
 func NewContinuousQuery(database string, cqi *meta.ContinuousQueryInfo) (*ContinuousQuery, error) {
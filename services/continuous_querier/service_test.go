@@ -102,6 +102,40 @@ func TestExecuteContinuousQuery_ReferenceSource(t *testing.T) {
 	}
 }
 
+// Test ExecuteContinuousQuery when INTO measurements are named after capture groups of the FROM regexp.
+func TestExecuteContinuousQuery_CaptureGroups(t *testing.T) {
+	s := NewTestService(t)
+	ms := s.MetaStore.(*MetaStore)
+	ms.CreateDatabase("db4", "default")
+	ms.CreateContinuousQuery("db4", "cq4", `CREATE CONTINUOUS QUERY cq4 ON db4 BEGIN SELECT mean(*) INTO "1hAverages"."${1}_1h" FROM /^(cpu)[0-9]?$/ GROUP BY time(10s) END`)
+	dbis, _ := s.MetaStore.Databases()
+	dbi := dbis[3]
+	cqi := dbi.ContinuousQueries[0]
+
+	qe := s.QueryExecutor.(*QueryExecutor)
+	qe.Results = []*influxql.Result{genResult(2, 1)}
+	qe.Results[0].Series[1].Values[0] = append(qe.Results[0].Series[1].Values[0][:1], nil)
+
+	pw := s.PointsWriter.(*PointsWriter)
+	pw.WritePointsFn = func(p *cluster.WritePointsRequest) error {
+		// Values that are nil aren't written.
+		if len(p.Points) != 1 {
+			return fmt.Errorf("exp = 1, got = %d", len(p.Points))
+		}
+
+		exp := "cpu_1h,host=server01 value=0"
+		if got := p.Points[0].String(); !strings.Contains(got, exp) {
+			return fmt.Errorf("\n\tExpected '${1}' to be expanded to the group captured by the FROM regexp.\n\tqry = %s\n\texp = %s\n\tgot = %s\n", cqi.Query, exp, got)
+		}
+		return nil
+	}
+
+	err := s.ExecuteContinuousQuery(&dbi, &cqi, time.Now())
+	if err != nil {
+		t.Error(err)
+	}
+}
+
 // Test the service happy path.
 func TestContinuousQueryService(t *testing.T) {
 	s := NewTestService(t)
@@ -425,7 +459,7 @@ func NewQueryExecutor(t *testing.T) *QueryExecutor {
 }
 
 // ExecuteQuery returns a channel that the caller can read query results from.
-func (qe *QueryExecutor) ExecuteQuery(query *influxql.Query, database string, chunkSize int) (<-chan *influxql.Result, error) {
+func (qe *QueryExecutor) ExecuteQuery(query *influxql.Query, database string, chunkSize int, user string, closing <-chan struct{}) (<-chan *influxql.Result, error) {
 
 	// If the test set a callback, call it.
 	if qe.ExecuteQueryFn != nil {
@@ -25,6 +25,7 @@ import (
 	"github.com/influxdb/influxdb/meta"
 	"github.com/influxdb/influxdb/models"
 	"github.com/influxdb/influxdb/services/continuous_querier"
+	"github.com/influxdb/influxdb/tsdb"
 	"github.com/influxdb/influxdb/uuid"
 )
 
@@ -62,7 +63,7 @@ type Handler struct {
 
 	QueryExecutor interface {
 		Authorize(u *meta.UserInfo, q *influxql.Query, db string) error
-		ExecuteQuery(q *influxql.Query, db string, chunkSize int) (<-chan *influxql.Result, error)
+		ExecuteQuery(q *influxql.Query, db string, chunkSize int, user string, closing <-chan struct{}) (<-chan *influxql.Result, error)
 	}
 
 	PointsWriter interface {
@@ -232,6 +233,16 @@ func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request, user *meta.
 	p := influxql.NewParser(strings.NewReader(qp))
 	db := q.Get("db")
 
+	// Bind the values of the query's $name parameters, passed as a JSON object.
+	if params := q.Get("params"); params != "" {
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(params), &m); err != nil {
+			httpError(w, "error parsing query parameters: "+err.Error(), pretty, http.StatusBadRequest)
+			return
+		}
+		p.SetParams(m)
+	}
+
 	// Parse query from query string.
 	query, err := p.ParseQuery()
 	if err != nil {
@@ -267,9 +278,29 @@ func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request, user *meta.
 		}
 	}
 
+	// Interrupt the query if the client goes away.
+	closing := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		notify := notifier.CloseNotify()
+		go func() {
+			select {
+			case <-notify:
+				close(closing)
+			case <-done:
+			}
+		}()
+	}
+
+	var username string
+	if user != nil {
+		username = user.Name
+	}
+
 	// Execute query.
 	w.Header().Add("content-type", "application/json")
-	results, err := h.QueryExecutor.ExecuteQuery(query, db, chunkSize)
+	results, err := h.QueryExecutor.ExecuteQuery(query, db, chunkSize, username, closing)
 
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -428,8 +459,8 @@ func (h *Handler) serveWriteJSON(w http.ResponseWriter, r *http.Request, body []
 		ConsistencyLevel: cluster.ConsistencyLevelOne,
 		Points:           points,
 	}); err != nil {
-		h.statMap.Add(statPointsWrittenFail, int64(len(points)))
-		if influxdb.IsClientError(err) {
+		h.addPointsWrittenFail(len(points), err)
+		if influxdb.IsClientError(err) || isPartialWrite(err) {
 			h.writeError(w, influxql.Result{Err: err}, http.StatusBadRequest)
 		} else {
 			h.writeError(w, influxql.Result{Err: err}, http.StatusInternalServerError)
@@ -441,6 +472,22 @@ func (h *Handler) serveWriteJSON(w http.ResponseWriter, r *http.Request, body []
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// isPartialWrite returns true if points of a write were dropped because they're invalid.
+func isPartialWrite(err error) bool {
+	_, ok := err.(tsdb.PartialWriteError)
+	return ok
+}
+
+// addPointsWrittenFail counts the points of a write which failed. Only the points dropped
+// by a partial write failed, the others were written.
+func (h *Handler) addPointsWrittenFail(n int, err error) {
+	if err, ok := err.(tsdb.PartialWriteError); ok {
+		h.statMap.Add(statPointsWrittenOK, int64(n-err.Dropped))
+		n = err.Dropped
+	}
+	h.statMap.Add(statPointsWrittenFail, int64(n))
+}
+
 func (h *Handler) writeError(w http.ResponseWriter, result influxql.Result, statusCode int) {
 	w.WriteHeader(statusCode)
 	w.Write([]byte(result.Err.Error()))
@@ -526,8 +573,8 @@ func (h *Handler) serveWriteLine(w http.ResponseWriter, r *http.Request, body []
 		RetentionPolicy:  r.FormValue("rp"),
 		ConsistencyLevel: consistency,
 		Points:           points,
-	}); influxdb.IsClientError(err) {
-		h.statMap.Add(statPointsWrittenFail, int64(len(points)))
+	}); influxdb.IsClientError(err) || isPartialWrite(err) {
+		h.addPointsWrittenFail(len(points), err)
 		h.writeError(w, influxql.Result{Err: err}, http.StatusBadRequest)
 		return
 	} else if err != nil {
@@ -720,6 +767,13 @@ func (w gzipResponseWriter) Flush() {
 	w.Writer.(*gzip.Writer).Flush()
 }
 
+func (w gzipResponseWriter) CloseNotify() <-chan bool {
+	if notifier, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return nil
+}
+
 // determines if the client can accept compressed responses, and encodes accordingly
 func gzipFilter(inner http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -3,17 +3,21 @@ package httpd_test
 import (
 	"encoding/json"
 	"errors"
+	"expvar"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/influxdb/influxdb"
 	"github.com/influxdb/influxdb/client"
+	"github.com/influxdb/influxdb/cluster"
 	"github.com/influxdb/influxdb/influxql"
 	"github.com/influxdb/influxdb/meta"
 	"github.com/influxdb/influxdb/models"
@@ -175,6 +179,36 @@ func TestHandler_QueryRegex(t *testing.T) {
 	h.ServeHTTP(w, MustNewRequest("GET", "/query?db=test&q=SELECT%20%2A%20FROM%20test%20WHERE%20url%20%3D~%20%2Fhttp%5C%3A%5C%2F%5C%2Fwww.akamai%5C.com%2F", nil))
 }
 
+// Ensure the handler binds the parameters of a query.
+func TestHandler_Query_Params(t *testing.T) {
+	h := NewHandler(false)
+	h.QueryExecutor.ExecuteQueryFn = func(q *influxql.Query, db string, chunkSize int) (<-chan *influxql.Result, error) {
+		if exp := `SELECT mean(value) FROM cpu WHERE host = 'it\'s "quoted"' AND region =~ /^us.*$/ AND value > 10.000 AND time > '2000-01-01T00:00:00Z' GROUP BY time(5m)`; q.String() != exp {
+			t.Fatalf("unexpected query: %s", q.String())
+		}
+		return NewResultChan(nil), nil
+	}
+
+	params := url.Values{}
+	params.Set("q", `SELECT mean(value) FROM $m WHERE host = $host AND region =~ $region AND value > $min AND time > $start GROUP BY time($interval)`)
+	params.Set("params", `{"m":{"identifier":"cpu"},"host":"it's \"quoted\"","region":{"regex":"^us.*$"},"min":10,"start":{"time":"2000-01-01T00:00:00Z"},"interval":{"duration":"5m"}}`)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewJSONRequest("GET", "/query?db=foo&"+params.Encode(), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d: %s", w.Code, w.Body.String())
+	}
+
+	params.Set("params", `{"m":"cpu"}`)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewJSONRequest("GET", "/query?db=foo&"+params.Encode(), nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w.Body.String() != `{"error":"error parsing query: parameter $m must be an identifier at line 1, char 25"}` {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+}
+
 // Ensure the handler merges results from the same statement.
 func TestHandler_Query_MergeResults(t *testing.T) {
 	h := NewHandler(false)
@@ -284,6 +318,28 @@ func TestHandler_Query_ErrResult(t *testing.T) {
 	}
 }
 
+// Ensure only the points dropped by a partial write are counted as failed.
+func TestHandler_Write_PartialWrite(t *testing.T) {
+	h := NewHandler(false)
+	h.MetaStore.DatabaseFn = func(name string) (*meta.DatabaseInfo, error) {
+		return &meta.DatabaseInfo{Name: name}, nil
+	}
+	h.PointsWriter.WritePointsFn = func(p *cluster.WritePointsRequest) error {
+		return tsdb.PartialWriteError{Reason: "max-series-per-database limit exceeded", Dropped: 1}
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewRequest("POST", "/write?db=foo", strings.NewReader("cpu,host=a value=1\ncpu,host=b value=2\ncpu,host=c value=3")))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+	if v := h.StatMap.Get("points_written_ok").String(); v != "2" {
+		t.Fatalf("unexpected points written: %s", v)
+	} else if v := h.StatMap.Get("points_written_fail").String(); v != "1" {
+		t.Fatalf("unexpected points failed: %s", v)
+	}
+}
+
 func TestMarshalJSON_NoPretty(t *testing.T) {
 	if b := httpd.MarshalJSON(struct {
 		Name string `json:"name"`
@@ -381,6 +437,8 @@ type Handler struct {
 	MetaStore     HandlerMetaStore
 	QueryExecutor HandlerQueryExecutor
 	TSDBStore     HandlerTSDBStore
+	PointsWriter  HandlerPointsWriter
+	StatMap       *expvar.Map
 }
 
 // NewHandler returns a new instance of Handler.
@@ -388,9 +446,11 @@ func NewHandler(requireAuthentication bool) *Handler {
 	statMap := influxdb.NewStatistics("httpd", "httpd", nil)
 	h := &Handler{
 		Handler: httpd.NewHandler(requireAuthentication, true, false, statMap),
+		StatMap: statMap,
 	}
 	h.Handler.MetaStore = &h.MetaStore
 	h.Handler.QueryExecutor = &h.QueryExecutor
+	h.Handler.PointsWriter = &h.PointsWriter
 	h.Handler.Version = "0.0.0"
 	return h
 }
@@ -424,10 +484,19 @@ func (e *HandlerQueryExecutor) Authorize(u *meta.UserInfo, q *influxql.Query, db
 	return e.AuthorizeFn(u, q, db)
 }
 
-func (e *HandlerQueryExecutor) ExecuteQuery(q *influxql.Query, db string, chunkSize int) (<-chan *influxql.Result, error) {
+func (e *HandlerQueryExecutor) ExecuteQuery(q *influxql.Query, db string, chunkSize int, user string, closing <-chan struct{}) (<-chan *influxql.Result, error) {
 	return e.ExecuteQueryFn(q, db, chunkSize)
 }
 
+// HandlerPointsWriter is a mock implementation of Handler.PointsWriter.
+type HandlerPointsWriter struct {
+	WritePointsFn func(p *cluster.WritePointsRequest) error
+}
+
+func (w *HandlerPointsWriter) WritePoints(p *cluster.WritePointsRequest) error {
+	return w.WritePointsFn(p)
+}
+
 // HandlerTSDBStore is a mock implementation of Handler.TSDBStore
 type HandlerTSDBStore struct {
 	CreateMapperFn func(shardID uint64, query string, chunkSize int) (tsdb.Mapper, error)
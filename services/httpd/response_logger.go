@@ -31,6 +31,13 @@ func (l *responseLogger) Flush() {
 	l.w.(http.Flusher).Flush()
 }
 
+func (l *responseLogger) CloseNotify() <-chan bool {
+	if notifier, ok := l.w.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return nil
+}
+
 func (l *responseLogger) Write(b []byte) (int, error) {
 	if l.status == 0 {
 		// Set status if WriteHeader has not been called
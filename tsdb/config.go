@@ -10,6 +10,13 @@ const (
 	// DefaultEngine is the default engine for new shards
 	DefaultEngine = "bz1"
 
+	// DefaultSeriesIndex is the default type of the index of the series of a database
+	DefaultSeriesIndex = "inmem"
+
+	// DefaultSeriesIndexMaxLoadedSeries is the default number of series of a disk-backed
+	// series index kept in memory for queries
+	DefaultSeriesIndexMaxLoadedSeries = 1000000
+
 	// DefaultMaxWALSize is the default size of the WAL before it is flushed.
 	DefaultMaxWALSize = 100 * 1024 * 1024 // 100MB
 
@@ -51,12 +58,30 @@ const (
 	DefaultIndexMinCompactionInterval  = time.Minute
 	DefaultIndexMinCompactionFileCount = 5
 	DefaultIndexCompactionFullAge      = 5 * time.Minute
+
+	// DefaultMaxSeriesPerDatabase is the maximum number of series a database can hold, 0 for no limit
+	DefaultMaxSeriesPerDatabase = 0
+
+	// DefaultMaxValuesPerTag is the maximum number of values a tag key of a measurement can have, 0 for no limit
+	DefaultMaxValuesPerTag = 0
+
+	// DefaultBlockCacheMaxMemorySize is the size of the cache of decoded tsm1 blocks
+	DefaultBlockCacheMaxMemorySize = 64 * 1024 * 1024 // 64MB
 )
 
 type Config struct {
 	Dir    string `toml:"dir"`
 	Engine string `toml:"engine"`
 
+	// Series index options, "inmem" or "disk"
+	SeriesIndex                string `toml:"series-index"`
+	SeriesIndexMaxLoadedSeries int    `toml:"series-index-max-loaded-series"`
+
+	// Cardinality limits enforced on writes, zero disables them. Points creating
+	// series over the limits are dropped.
+	MaxSeriesPerDatabase int `toml:"max-series-per-database"`
+	MaxValuesPerTag      int `toml:"max-values-per-tag"`
+
 	// WAL config options for b1 (introduced in 0.9.2)
 	MaxWALSize             int           `toml:"max-wal-size"`
 	WALFlushInterval       toml.Duration `toml:"wal-flush-interval"`
@@ -93,6 +118,10 @@ type Config struct {
 	// in the WAL that a full compaction should be performed.
 	IndexCompactionFullAge time.Duration `toml:"index-compaction-full-age"`
 
+	// BlockCacheMaxMemorySize is the approximate size in bytes of the decoded tsm1 blocks
+	// cached for queries, shared by all shards. Zero disables the cache.
+	BlockCacheMaxMemorySize int `toml:"block-cache-max-memory-size"`
+
 	// Query logging
 	QueryLogEnabled bool `toml:"query-log-enabled"`
 }
@@ -100,6 +129,7 @@ type Config struct {
 func NewConfig() Config {
 	return Config{
 		Engine:                 DefaultEngine,
+		SeriesIndex:            DefaultSeriesIndex,
 		MaxWALSize:             DefaultMaxWALSize,
 		WALFlushInterval:       toml.Duration(DefaultWALFlushInterval),
 		WALPartitionFlushDelay: toml.Duration(DefaultWALPartitionFlushDelay),
@@ -116,6 +146,11 @@ func NewConfig() Config {
 		IndexMinCompactionFileCount: DefaultIndexMinCompactionFileCount,
 		IndexCompactionFullAge:      DefaultIndexCompactionFullAge,
 		IndexMinCompactionInterval:  DefaultIndexMinCompactionInterval,
+		BlockCacheMaxMemorySize:     DefaultBlockCacheMaxMemorySize,
+
+		SeriesIndexMaxLoadedSeries: DefaultSeriesIndexMaxLoadedSeries,
+		MaxSeriesPerDatabase:       DefaultMaxSeriesPerDatabase,
+		MaxValuesPerTag:            DefaultMaxValuesPerTag,
 
 		QueryLogEnabled: true,
 	}
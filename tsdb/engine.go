@@ -30,6 +30,7 @@ type Engine interface {
 	WritePoints(points []models.Point, measurementFieldsToSave map[string]*MeasurementFields, seriesToCreate []*SeriesCreate) error
 	DeleteSeries(keys []string) error
 	DeleteMeasurement(name string, seriesKeys []string) error
+	DeleteSeriesRange(keys []string, min, max int64) error
 	SeriesCount() (n int, err error)
 
 	// PerformMaintenance will get called periodically by the store
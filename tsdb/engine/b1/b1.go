@@ -200,6 +200,11 @@ func (e *Engine) LoadMetadataIndex(shard *tsdb.Shard, index *tsdb.DatabaseIndex,
 			measurementFields[m.Name] = mf
 		}
 
+		// Series already in a disk-backed series index aren't loaded again.
+		if index.SeriesIndexed(shard) {
+			return nil
+		}
+
 		// load series metadata
 		meta = tx.Bucket([]byte("series"))
 		c = meta.Cursor()
@@ -208,7 +213,9 @@ func (e *Engine) LoadMetadataIndex(shard *tsdb.Shard, index *tsdb.DatabaseIndex,
 			if err := series.UnmarshalBinary(v); err != nil {
 				return err
 			}
-			index.CreateSeriesIndexIfNotExists(tsdb.MeasurementFromSeriesKey(string(k)), series)
+			if _, err := index.CreateSeriesIndexIfNotExists(tsdb.MeasurementFromSeriesKey(string(k)), series); err != nil {
+				return err
+			}
 		}
 		return nil
 	})
@@ -350,6 +357,41 @@ func (e *Engine) DeleteSeries(keys []string) error {
 	return nil
 }
 
+// DeleteSeriesRange deletes the points of the series between min and max, inclusive.
+func (e *Engine) DeleteSeriesRange(keys []string, min, max int64) error {
+	// Flush the WAL first so every point is in its series bucket.
+	if err := e.Flush(0); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.db.Update(func(tx *bolt.Tx) error {
+		for _, key := range keys {
+			b := tx.Bucket([]byte(key))
+			if b == nil {
+				continue
+			}
+
+			// Collect the points in the range before deleting so the cursor isn't invalidated.
+			var a [][]byte
+			c := b.Cursor()
+			for k, _ := c.First(); k != nil; k, _ = c.Next() {
+				if timestamp := int64(btou64(k)); timestamp >= min && timestamp <= max {
+					a = append(a, k)
+				}
+			}
+			for _, k := range a {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
 // DeleteMeasurement deletes a measurement and all related series.
 func (e *Engine) DeleteMeasurement(name string, seriesKeys []string) error {
 	e.mu.Lock()
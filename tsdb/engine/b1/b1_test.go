@@ -142,6 +142,63 @@ func TestEngine_WritePoints_Reverse(t *testing.T) {
 	}
 }
 
+// Ensure points within a time range can be deleted from a series.
+func TestEngine_DeleteSeriesRange(t *testing.T) {
+	e := OpenDefaultEngine()
+	defer e.Close()
+
+	// Create metadata.
+	mf := &tsdb.MeasurementFields{Fields: make(map[string]*tsdb.Field)}
+	mf.CreateFieldIfNotExists("value", influxql.Float, true)
+	seriesToCreate := []*tsdb.SeriesCreate{
+		{Series: tsdb.NewSeries(string(models.MakeKey([]byte("temperature"), nil)), nil)},
+	}
+
+	// Parse points.
+	points, err := models.ParsePointsWithPrecision([]byte("temperature value=100 1\ntemperature value=200 2\ntemperature value=300 3"), time.Now().UTC(), "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range points {
+		data, err := mf.Codec.EncodeFields(p.Fields())
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.SetData(data)
+	}
+
+	// Write and flush the points.
+	if err := e.WritePoints(points, map[string]*tsdb.MeasurementFields{"temperature": mf}, seriesToCreate); err != nil {
+		t.Fatal(err)
+	} else if err := e.Flush(0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Delete the middle point.
+	if err := e.DeleteSeriesRange([]string{"temperature"}, time.Unix(2, 0).UnixNano(), time.Unix(2, 0).UnixNano()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ensure only the points outside the range remain.
+	tx := e.MustBegin(false)
+	defer tx.Rollback()
+
+	c := tx.Cursor("temperature", []string{"value"}, mf.Codec, true)
+	if k, v := c.SeekTo(0); k != time.Unix(1, 0).UnixNano() {
+		t.Fatalf("unexpected key: %v", k)
+	} else if v == nil || v.(float64) != 100 {
+		t.Errorf("unexpected value: %#v", v)
+	} else if k, v := c.Next(); k != time.Unix(3, 0).UnixNano() {
+		t.Fatalf("unexpected key: %v", k)
+	} else if v == nil || v.(float64) != 300 {
+		t.Errorf("unexpected value: %#v", v)
+	}
+
+	if k, v := c.Next(); k != tsdb.EOF {
+		t.Fatalf("unexpected key/value: %#v / %#v", k, v)
+	}
+}
+
 // Engine represents a test wrapper for b1.Engine.
 type Engine struct {
 	*b1.Engine
@@ -74,6 +74,7 @@ type WAL interface {
 	WritePoints(points []models.Point, measurementFieldsToSave map[string]*tsdb.MeasurementFields, seriesToCreate []*tsdb.SeriesCreate) error
 	LoadMetadataIndex(index *tsdb.DatabaseIndex, measurementFields map[string]*tsdb.MeasurementFields) error
 	DeleteSeries(keys []string) error
+	DeleteSeriesRange(keys []string, min, max int64) error
 	Cursor(series string, fields []string, dec *tsdb.FieldCodec, ascending bool) tsdb.Cursor
 	Open() error
 	Close() error
@@ -200,6 +201,11 @@ func (e *Engine) LoadMetadataIndex(shard *tsdb.Shard, index *tsdb.DatabaseIndex,
 			measurementFields[m.Name] = mf
 		}
 
+		// Series already in a disk-backed series index aren't loaded again.
+		if index.SeriesIndexed(shard) {
+			return nil
+		}
+
 		// Load series metadata
 		series, err := e.readSeries(tx)
 		if err != nil {
@@ -216,7 +222,9 @@ func (e *Engine) LoadMetadataIndex(shard *tsdb.Shard, index *tsdb.DatabaseIndex,
 		for _, key := range a {
 			s := series[key]
 			s.InitializeShards()
-			index.CreateSeriesIndexIfNotExists(tsdb.MeasurementFromSeriesKey(string(key)), s)
+			if _, err := index.CreateSeriesIndexIfNotExists(tsdb.MeasurementFromSeriesKey(string(key)), s); err != nil {
+				return err
+			}
 		}
 		return nil
 	}); err != nil {
@@ -557,6 +565,68 @@ func (e *Engine) DeleteMeasurement(name string, seriesKeys []string) error {
 	})
 }
 
+// DeleteSeriesRange deletes the points of the series between min and max, inclusive.
+func (e *Engine) DeleteSeriesRange(keys []string, min, max int64) error {
+	// remove from the WAL first so the points won't get flushed after removing from Bolt
+	if err := e.WAL.DeleteSeriesRange(keys, min, max); err != nil {
+		return err
+	}
+
+	return e.db.Update(func(tx *bolt.Tx) error {
+		for _, k := range keys {
+			bkt := tx.Bucket([]byte("points")).Bucket([]byte(k))
+			if bkt == nil {
+				continue
+			}
+			if err := e.deleteRange(bkt, min, max); err != nil {
+				return fmt.Errorf("delete series range: %s", err)
+			}
+		}
+		return nil
+	})
+}
+
+// deleteRange removes the points between min and max from a series bucket. Blocks
+// overlapping the range are unpacked and the remaining points are rewritten.
+func (e *Engine) deleteRange(bkt *bolt.Bucket, min, max int64) error {
+	var blocks [][]byte
+	var remaining [][]byte
+	c := bkt.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		// Skip over blocks that don't overlap the range.
+		bmin, bmax := int64(btou64(k)), int64(btou64(v[0:8]))
+		if bmax < min || bmin > max {
+			continue
+		}
+
+		// Decode block.
+		buf, err := snappy.Decode(nil, v[8:])
+		if err != nil {
+			return fmt.Errorf("decode block: %s", err)
+		}
+
+		// Copy out any entries that are outside the range.
+		for _, entry := range SplitEntries(buf) {
+			if t := int64(btou64(entry[0:8])); t < min || t > max {
+				remaining = append(remaining, entry)
+			}
+		}
+		blocks = append(blocks, k)
+	}
+
+	// Delete the blocks after iterating so the cursor isn't invalidated.
+	for _, k := range blocks {
+		if err := bkt.Delete(k); err != nil {
+			return err
+		}
+	}
+
+	if len(remaining) == 0 {
+		return nil
+	}
+	return e.writeBlocks(bkt, remaining)
+}
+
 // SeriesCount returns the number of series buckets on the shard.
 func (e *Engine) SeriesCount() (n int, err error) {
 	err = e.db.View(func(tx *bolt.Tx) error {
@@ -434,6 +434,8 @@ func (w *EnginePointsWriter) LoadMetadataIndex(index *tsdb.DatabaseIndex, measur
 
 func (w *EnginePointsWriter) DeleteSeries(keys []string) error { return nil }
 
+func (w *EnginePointsWriter) DeleteSeriesRange(keys []string, min, max int64) error { return nil }
+
 func (w *EnginePointsWriter) Open() error { return nil }
 
 func (w *EnginePointsWriter) Close() error { return nil }
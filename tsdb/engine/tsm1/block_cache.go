@@ -0,0 +1,192 @@
+package tsm1
+
+import (
+	"container/list"
+	"expvar"
+	"sync"
+
+	"github.com/influxdb/influxdb"
+)
+
+// Statistics kept by the block cache. Hits and misses are also kept by each engine.
+const (
+	statBlockCacheHits      = "block_cache_hits"
+	statBlockCacheMisses    = "block_cache_misses"
+	statBlockCacheEvictions = "block_cache_evictions"
+	statBlockCacheSize      = "block_cache_size"
+)
+
+// blockCacheEntrySize is the approximate memory used by an entry besides its values.
+const blockCacheEntrySize = 128
+
+var (
+	sharedBlockCacheMu sync.Mutex
+	sharedBlockCache   *blockCache
+)
+
+// getBlockCache returns the block cache shared by the engines of the process, with a maximum
+// size of maxSize bytes. It returns nil if maxSize is zero, disabling the cache.
+func getBlockCache(maxSize int) *blockCache {
+	if maxSize <= 0 {
+		return nil
+	}
+
+	sharedBlockCacheMu.Lock()
+	defer sharedBlockCacheMu.Unlock()
+	if sharedBlockCache == nil {
+		sharedBlockCache = newBlockCache(maxSize)
+	} else {
+		sharedBlockCache.setMaxSize(maxSize)
+	}
+	return sharedBlockCache
+}
+
+// blockCache is a LRU cache of decoded blocks of data files, bounded by the approximate size
+// of the values it holds. Cached values are shared by cursors and must not be modified.
+type blockCache struct {
+	mu      sync.Mutex
+	maxSize int
+	size    int
+	lru     *list.List                             // most recently used first
+	files   map[*dataFile]map[uint32]*list.Element // blocks by file and position
+
+	statMap *expvar.Map
+}
+
+// blockCacheEntry is an element of the LRU list of a block cache.
+type blockCacheEntry struct {
+	f      *dataFile
+	pos    uint32
+	values Values
+	size   int
+}
+
+func newBlockCache(maxSize int) *blockCache {
+	return &blockCache{
+		maxSize: maxSize,
+		lru:     list.New(),
+		files:   make(map[*dataFile]map[uint32]*list.Element),
+		statMap: influxdb.NewStatistics("tsm1_block_cache", "tsm1_block_cache", nil),
+	}
+}
+
+// setMaxSize sets the maximum size of the cache, evicting blocks if it shrinks.
+func (c *blockCache) setMaxSize(maxSize int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxSize = maxSize
+	c.evict()
+}
+
+// get returns the values of the block at pos in a data file, if they are cached.
+func (c *blockCache) get(f *dataFile, pos uint32) (Values, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.files[f][pos]
+	if e == nil {
+		c.statMap.Add(statBlockCacheMisses, 1)
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	c.statMap.Add(statBlockCacheHits, 1)
+	return e.Value.(*blockCacheEntry).values, true
+}
+
+// add caches the values of the block at pos in a data file, evicting the least recently
+// used blocks if the cache gets too large.
+func (c *blockCache) add(f *dataFile, pos uint32, values Values) {
+	if c == nil {
+		return
+	}
+
+	size := blockCacheEntrySize
+	for _, v := range values {
+		size += v.Size()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if size > c.maxSize {
+		return
+	}
+
+	blocks := c.files[f]
+	if blocks == nil {
+		blocks = make(map[uint32]*list.Element)
+		c.files[f] = blocks
+	} else if blocks[pos] != nil {
+		return
+	}
+	blocks[pos] = c.lru.PushFront(&blockCacheEntry{f: f, pos: pos, values: values, size: size})
+	c.size += size
+	c.evict()
+}
+
+// removeFile removes the blocks of a data file from the cache. It's called once the file is
+// deleted by a compaction or a delete, or closed.
+func (c *blockCache) removeFile(f *dataFile) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range c.files[f] {
+		c.size -= e.Value.(*blockCacheEntry).size
+		c.lru.Remove(e)
+	}
+	delete(c.files, f)
+	c.setSizeStat()
+}
+
+// evict removes the least recently used blocks until the cache is under its maximum size.
+// The caller must hold the lock on the cache.
+func (c *blockCache) evict() {
+	for c.size > c.maxSize {
+		e := c.lru.Back()
+		entry := e.Value.(*blockCacheEntry)
+		c.lru.Remove(e)
+		c.size -= entry.size
+
+		blocks := c.files[entry.f]
+		delete(blocks, entry.pos)
+		if len(blocks) == 0 {
+			delete(c.files, entry.f)
+		}
+		c.statMap.Add(statBlockCacheEvictions, 1)
+	}
+	c.setSizeStat()
+}
+
+// setSizeStat sets the size statistic. The caller must hold the lock on the cache.
+func (c *blockCache) setSizeStat() {
+	v := new(expvar.Int)
+	v.Set(int64(c.size))
+	c.statMap.Set(statBlockCacheSize, v)
+}
+
+// decodeBlock returns the values of the block at pos in a data file, from the block cache
+// if they're cached.
+func (e *Engine) decodeBlock(f *dataFile, pos uint32, block []byte) Values {
+	if e.blockCache == nil {
+		values, _ := DecodeBlock(block)
+		return values
+	}
+
+	if values, ok := e.blockCache.get(f, pos); ok {
+		e.statMap.Add(statBlockCacheHits, 1)
+		return values
+	}
+	e.statMap.Add(statBlockCacheMisses, 1)
+
+	values, err := DecodeBlock(block)
+	if err != nil {
+		return values
+	}
+	e.blockCache.add(f, pos, values)
+	return values
+}
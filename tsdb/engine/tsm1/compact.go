@@ -0,0 +1,151 @@
+package tsm1
+
+import (
+	"expvar"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultMaxCompactionLevel is the highest level data files are compacted into.
+	// Files at this level are only rewritten by full compactions.
+	DefaultMaxCompactionLevel = 4
+
+	// DefaultCompactionLevelSizeRatio is how much larger the files of a level are than
+	// the files of the level below it.
+	DefaultCompactionLevelSizeRatio = 4
+)
+
+// Statistics kept by the engine.
+const (
+	statCompactions               = "compactions"
+	statFullCompactions           = "full_compactions"
+	statCompactionBytesRewritten  = "compaction_bytes_rewritten"
+	statLevelFilesFormat          = "level%d_files"
+	statLevelBytesRewrittenFormat = "level%d_bytes_rewritten"
+)
+
+// dataFileName returns the name of the data file with id holding data compacted to level.
+// Files written by a flush of the WAL are at level zero and have no level in their name.
+func dataFileName(id, level int) string {
+	if level == 0 {
+		return fmt.Sprintf("%07d.%s", id, Format)
+	}
+	return fmt.Sprintf("%07d-%02d.%s", id, level, Format)
+}
+
+// parseDataFileName returns the id and the level of a data file from its name.
+func parseDataFileName(name string) (id, level int, err error) {
+	base := strings.TrimSuffix(filepath.Base(name), "."+Format)
+	parts := strings.Split(base, "-")
+	if len(parts) > 2 {
+		return 0, 0, fmt.Errorf("file %s has wrong name format to have an id", name)
+	}
+
+	n, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("file %s has wrong name format to have an id", name)
+	}
+	if len(parts) == 2 {
+		l, err := strconv.ParseUint(parts[1], 10, 8)
+		if err != nil {
+			return 0, 0, fmt.Errorf("file %s has wrong name format to have a level", name)
+		}
+		level = int(l)
+	}
+	return int(n), level, nil
+}
+
+// fileLevel returns the level of a data file, from the number of compactions it went
+// through or from its size if it's larger than the files of that level. This keeps large
+// files written before levels existed out of the compactions of small files.
+func (e *Engine) fileLevel(df *dataFile) int {
+	level := df.level
+	limit := uint64(e.RotateFileSize) * uint64(e.CompactionLevelSizeRatio)
+	for l := 0; l < e.MaxCompactionLevel; l++ {
+		if uint64(df.size) < limit {
+			break
+		}
+		if l+1 > level {
+			level = l + 1
+		}
+		limit *= uint64(e.CompactionLevelSizeRatio)
+	}
+	if level > e.MaxCompactionLevel {
+		level = e.MaxCompactionLevel
+	}
+	return level
+}
+
+// planCompaction returns the files to compact together and the level of the resulting
+// file. The lowest level with at least MinCompactionFileCount contiguous files older than
+// CompactionAge is compacted first. Files at the highest level are never returned.
+func (e *Engine) planCompaction(files dataFiles) (dataFiles, int) {
+	minN := e.MinCompactionFileCount
+	if minN < 2 {
+		minN = 2
+	}
+
+	for level := 0; level < e.MaxCompactionLevel; level++ {
+		var run dataFiles
+		var size uint64
+		for _, df := range files {
+			// only compact contiguous ranges of files of the same level, and keep the
+			// resulting file under the maximum size
+			eligible := e.fileLevel(df) == level && time.Since(df.modTime) > e.CompactionAge
+			if !eligible || size+uint64(df.size) >= MaxDataFileSize {
+				if len(run) >= minN {
+					return run, level + 1
+				}
+				run, size = nil, 0
+				if !eligible {
+					continue
+				}
+			}
+			run = append(run, df)
+			size += uint64(df.size)
+		}
+		if len(run) >= minN {
+			return run, level + 1
+		}
+	}
+	return nil, 0
+}
+
+// planFullCompaction returns the longest contiguous range of files under the maximum file
+// size, which are compacted into the highest level. Nothing is returned if there's less
+// than two of them, so a fully compacted shard isn't rewritten again.
+func (e *Engine) planFullCompaction(files dataFiles) (dataFiles, int) {
+	var longest, run dataFiles
+	for _, df := range files {
+		if df.size < MaxDataFileSize {
+			run = append(run, df)
+			if len(run) > len(longest) {
+				longest = run
+			}
+			continue
+		}
+		run = nil
+	}
+	if len(longest) < 2 {
+		return nil, 0
+	}
+	return longest, e.MaxCompactionLevel
+}
+
+// updateLevelStats sets the statistics of the number of files at each level. The caller
+// must hold a lock on the files.
+func (e *Engine) updateLevelStats() {
+	counts := make([]int64, e.MaxCompactionLevel+1)
+	for _, df := range e.files {
+		counts[e.fileLevel(df)]++
+	}
+	for level, n := range counts {
+		v := new(expvar.Int)
+		v.Set(n)
+		e.statMap.Set(fmt.Sprintf(statLevelFilesFormat, level), v)
+	}
+}
@@ -94,6 +94,46 @@ func (c *combinedEngineCursor) read() (key int64, value interface{}) {
 	return
 }
 
+// tombstoneCursor wraps a cursor and skips over any values that
+// fall within time ranges that have been deleted, but not yet
+// flushed from the data files
+type tombstoneCursor struct {
+	cursor tsdb.Cursor
+	ranges deleteRanges
+}
+
+func newTombstoneCursor(c tsdb.Cursor, ranges deleteRanges) tsdb.Cursor {
+	return &tombstoneCursor{
+		cursor: c,
+		ranges: ranges,
+	}
+}
+
+// SeekTo will seek the underlying cursor and return the first value not deleted
+func (c *tombstoneCursor) SeekTo(seek int64) (int64, interface{}) {
+	k, v := c.cursor.SeekTo(seek)
+	return c.skipDeleted(k, v)
+}
+
+// Next returns the next value that isn't in a deleted range
+func (c *tombstoneCursor) Next() (int64, interface{}) {
+	k, v := c.cursor.Next()
+	return c.skipDeleted(k, v)
+}
+
+// Ascending returns true if the cursor is time ascending
+func (c *tombstoneCursor) Ascending() bool {
+	return c.cursor.Ascending()
+}
+
+// skipDeleted advances the underlying cursor until it's past any deleted values
+func (c *tombstoneCursor) skipDeleted(k int64, v interface{}) (int64, interface{}) {
+	for k != tsdb.EOF && c.ranges.contains(k) {
+		k, v = c.cursor.Next()
+	}
+	return k, v
+}
+
 // multieFieldCursor wraps cursors for multiple fields on the same series
 // key. Instead of returning a plain interface value in the call for Next(),
 // it returns a map[string]interface{} for the field values
@@ -197,13 +237,17 @@ type cursor struct {
 
 	// time acending slice of read only data files
 	files []*dataFile
+
+	// engine decodes the blocks, through its block cache
+	engine *Engine
 }
 
-func newCursor(id uint64, files []*dataFile, ascending bool) *cursor {
+func newCursor(id uint64, files []*dataFile, ascending bool, engine *Engine) *cursor {
 	return &cursor{
 		id:        id,
 		ascending: ascending,
 		files:     files,
+		engine:    engine,
 	}
 }
 
@@ -469,7 +513,7 @@ func (c *cursor) blockLength(pos uint32) uint32 {
 func (c *cursor) decodeBlock(position uint32) {
 	length := c.blockLength(position)
 	block := c.f.mmap[position+blockHeaderSize : position+blockHeaderSize+length]
-	c.vals, _ = DecodeBlock(block)
+	c.vals = c.engine.decodeBlock(c.f, position, block)
 
 	// only adavance the position if we're asceending.
 	// Descending queries use the blockPositions
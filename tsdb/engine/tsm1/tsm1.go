@@ -3,6 +3,7 @@ package tsm1
 import (
 	"encoding/binary"
 	"encoding/json"
+	"expvar"
 	"fmt"
 	"hash/fnv"
 	"io"
@@ -19,6 +20,7 @@ import (
 	"time"
 
 	"github.com/golang/snappy"
+	"github.com/influxdb/influxdb"
 	"github.com/influxdb/influxdb/models"
 	"github.com/influxdb/influxdb/tsdb"
 )
@@ -114,6 +116,15 @@ type Engine struct {
 	MaxPointsPerBlock          int
 	RotateBlockSize            int
 
+	// MaxCompactionLevel is the level of fully compacted files, and CompactionLevelSizeRatio
+	// how much larger the files of a level are than the ones of the level below.
+	MaxCompactionLevel       int
+	CompactionLevelSizeRatio int
+
+	// blockCache holds decoded blocks of the data files. It's shared by the
+	// engines of all shards and nil if disabled.
+	blockCache *blockCache
+
 	// filesLock is only for modifying and accessing the files slice
 	filesLock          sync.RWMutex
 	files              dataFiles
@@ -129,12 +140,19 @@ type Engine struct {
 	// but haven't yet been compacted and flushed
 	deleteMeasurements map[string]bool
 
+	// deleteRanges is a map of IDs to the time ranges deleted from them that
+	// haven't yet been flushed. Queries skip over values in these ranges
+	deleteRanges map[uint64]deleteRanges
+
 	collisionsLock sync.RWMutex
 	collisions     map[string]uint64
 
 	// queryLock keeps data files from being deleted or the store from
 	// being closed while queries are running
 	queryLock sync.RWMutex
+
+	// expvar-based stats.
+	statMap *expvar.Map
 }
 
 // NewEngine returns a new instance of Engine.
@@ -160,6 +178,11 @@ func NewEngine(path string, walPath string, opt tsdb.EngineOptions) tsdb.Engine
 		IndexMinCompactionInterval: opt.Config.IndexMinCompactionInterval,
 		MaxPointsPerBlock:          DefaultMaxPointsPerBlock,
 		RotateBlockSize:            DefaultRotateBlockSize,
+		MaxCompactionLevel:         DefaultMaxCompactionLevel,
+		CompactionLevelSizeRatio:   DefaultCompactionLevelSizeRatio,
+		blockCache:                 getBlockCache(opt.Config.BlockCacheMaxMemorySize),
+
+		statMap: influxdb.NewStatistics("tsm1_engine:"+path, "tsm1_engine", map[string]string{"path": path}),
 	}
 	e.WAL.Index = e
 
@@ -185,19 +208,25 @@ func (e *Engine) PerformMaintenance() {
 
 	e.filesLock.RLock()
 	running := e.compactionRunning
-	deletesPending := len(e.deletes) > 0
+	deletesPending := len(e.deletes) > 0 || len(e.deleteRanges) > 0
 	e.filesLock.RUnlock()
 	if running || deletesPending {
 		return
 	}
 
 	// do a full compaction if all the index files are older than the compaction time
-	for _, f := range e.copyFilesCollection() {
+	files := e.copyFilesCollection()
+	for _, f := range files {
 		if time.Since(f.modTime) < e.IndexCompactionFullAge {
 			return
 		}
 	}
 
+	// skip shards which are already fully compacted
+	if a, _ := e.planFullCompaction(files); len(a) == 0 {
+		return
+	}
+
 	go e.Compact(true)
 }
 
@@ -228,7 +257,7 @@ func (e *Engine) Open() error {
 			continue
 		}
 
-		id, err := idFromFileName(fn)
+		id, _, err := parseDataFileName(fn)
 		if err != nil {
 			return err
 		}
@@ -246,6 +275,7 @@ func (e *Engine) Open() error {
 		e.files = append(e.files, df)
 	}
 	sort.Sort(e.files)
+	e.updateLevelStats()
 
 	if err := e.readCollisions(); err != nil {
 		return err
@@ -253,6 +283,7 @@ func (e *Engine) Open() error {
 
 	e.deletes = make(map[uint64]string)
 	e.deleteMeasurements = make(map[string]bool)
+	e.deleteRanges = make(map[uint64]deleteRanges)
 
 	// mark the last compaction as now so it doesn't try to compact while
 	// flushing the WAL on load
@@ -285,12 +316,14 @@ func (e *Engine) Close() error {
 
 	for _, df := range e.files {
 		_ = df.Close()
+		e.blockCache.removeFile(df)
 	}
 	e.files = nil
 	e.currentFileID = 0
 	e.collisions = nil
 	e.deletes = nil
 	e.deleteMeasurements = nil
+	e.deleteRanges = nil
 	return nil
 }
 
@@ -320,6 +353,11 @@ func (e *Engine) LoadMetadataIndex(shard *tsdb.Shard, index *tsdb.DatabaseIndex,
 		measurementFields[m.Name] = mf
 	}
 
+	// Series already in a disk-backed series index aren't loaded again.
+	if index.SeriesIndexed(shard) {
+		return nil
+	}
+
 	// Load series metadata
 	series, err := e.readSeries()
 	if err != nil {
@@ -336,7 +374,9 @@ func (e *Engine) LoadMetadataIndex(shard *tsdb.Shard, index *tsdb.DatabaseIndex,
 	for _, key := range a {
 		s := series[key]
 		s.InitializeShards()
-		index.CreateSeriesIndexIfNotExists(tsdb.MeasurementFromSeriesKey(string(key)), s)
+		if _, err := index.CreateSeriesIndexIfNotExists(tsdb.MeasurementFromSeriesKey(string(key)), s); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -351,7 +391,7 @@ func (e *Engine) WritePoints(points []models.Point, measurementFieldsToSave map[
 func (e *Engine) Write(pointsByKey map[string]Values, measurementFieldsToSave map[string]*tsdb.MeasurementFields, seriesToCreate []*tsdb.SeriesCreate) error {
 	// Flush any deletes before writing new data from the WAL
 	e.filesLock.RLock()
-	hasDeletes := len(e.deletes) > 0
+	hasDeletes := len(e.deletes) > 0 || len(e.deleteRanges) > 0
 	e.filesLock.RUnlock()
 	if hasDeletes {
 		e.flushDeletes()
@@ -463,6 +503,17 @@ func (e *Engine) MarkMeasurementDelete(name string) {
 	e.deleteMeasurements[name] = true
 }
 
+// MarkDeleteRange will mark the values of the given keys between min and max (inclusive)
+// for deletion in memory. They will be removed from the data files on the next flush
+func (e *Engine) MarkDeleteRange(keys []string, min, max int64) {
+	e.filesLock.Lock()
+	defer e.filesLock.Unlock()
+	for _, k := range keys {
+		id := e.keyToID(k)
+		e.deleteRanges[id] = append(e.deleteRanges[id], deleteRange{min: min, max: max})
+	}
+}
+
 // filesAndLock returns the data files that match the given range and
 // ensures that the write lock will hold for the entire range
 func (e *Engine) filesAndLock(min, max int64) (a dataFiles, lockStart, lockEnd int64) {
@@ -509,12 +560,9 @@ func (e *Engine) Compact(fullCompaction bool) error {
 	// still there after we've obtained the write lock
 	var minTime, maxTime int64
 	var files dataFiles
+	var level int
 	for {
-		if fullCompaction {
-			files = e.copyFilesCollection()
-		} else {
-			files = e.filesToCompact()
-		}
+		files, level = e.planCompactionFiles(fullCompaction)
 		if len(files) < 2 {
 			return nil
 		}
@@ -525,12 +573,7 @@ func (e *Engine) Compact(fullCompaction bool) error {
 
 		// if the files are different after obtaining the write lock, one or more
 		// was rewritten. Release the lock and try again. This shouldn't happen really.
-		var filesAfterLock dataFiles
-		if fullCompaction {
-			filesAfterLock = e.copyFilesCollection()
-		} else {
-			filesAfterLock = e.filesToCompact()
-		}
+		filesAfterLock, _ := e.planCompactionFiles(fullCompaction)
 		if !reflect.DeepEqual(files, filesAfterLock) {
 			e.writeLock.UnlockRange(minTime, maxTime)
 			continue
@@ -561,10 +604,15 @@ func (e *Engine) Compact(fullCompaction bool) error {
 	if fullCompaction {
 		s = "FULL "
 	}
-	fileName := e.nextFileName()
+	fileName := e.nextFileName(level)
 	e.logger.Printf("Starting %scompaction in partition %s of %d files to new file %s", s, e.path, len(files), fileName)
 	st := time.Now()
 
+	var rewritten int64
+	for _, df := range files {
+		rewritten += int64(df.size)
+	}
+
 	positions := make([]uint32, len(files))
 	ids := make([]uint64, len(files))
 
@@ -710,10 +758,19 @@ func (e *Engine) Compact(fullCompaction bool) error {
 	newFiles = append(newFiles, newDF)
 	sort.Sort(newFiles)
 	e.files = newFiles
+	e.updateLevelStats()
 	e.filesLock.Unlock()
 
 	e.logger.Printf("Compaction of %s took %s", e.path, time.Since(st))
 
+	if fullCompaction {
+		e.statMap.Add(statFullCompactions, 1)
+	} else {
+		e.statMap.Add(statCompactions, 1)
+	}
+	e.statMap.Add(statCompactionBytesRewritten, rewritten)
+	e.statMap.Add(fmt.Sprintf(statLevelBytesRewrittenFormat, level), rewritten)
+
 	// delete the old files in a goroutine so running queries won't block the write
 	// from completing
 	e.deletesPending.Add(1)
@@ -722,6 +779,7 @@ func (e *Engine) Compact(fullCompaction bool) error {
 			if err := f.Delete(); err != nil {
 				e.logger.Println("ERROR DELETING:", f.f.Name())
 			}
+			e.blockCache.removeFile(f)
 		}
 		e.deletesPending.Done()
 	}()
@@ -783,29 +841,23 @@ func (e *Engine) shouldCompact() bool {
 	e.filesLock.RLock()
 	running := e.compactionRunning
 	since := time.Since(e.lastCompactionTime)
-	deletesPending := len(e.deletes) > 0
+	deletesPending := len(e.deletes) > 0 || len(e.deleteRanges) > 0
 	e.filesLock.RUnlock()
 	if running || since < e.IndexMinCompactionInterval || deletesPending {
 		return false
 	}
-	return len(e.filesToCompact()) >= e.MinCompactionFileCount
+	files, _ := e.planCompactionFiles(false)
+	return len(files) > 0
 }
 
-func (e *Engine) filesToCompact() dataFiles {
+// planCompactionFiles returns the files to compact and the level of the resulting file.
+func (e *Engine) planCompactionFiles(fullCompaction bool) (dataFiles, int) {
 	e.filesLock.RLock()
 	defer e.filesLock.RUnlock()
-
-	var a dataFiles
-	for _, df := range e.files {
-		if time.Since(df.modTime) > e.CompactionAge && df.size < MaxDataFileSize {
-			a = append(a, df)
-		} else if len(a) > 0 {
-			// only compact contiguous ranges. If we hit the negative case and
-			// there are files to compact, stop here
-			break
-		}
+	if fullCompaction {
+		return e.planFullCompaction(e.files)
 	}
-	return a
+	return e.planCompaction(e.files)
 }
 
 func (e *Engine) convertKeysAndWriteMetadata(pointsByKey map[string]Values, measurementFieldsToSave map[string]*tsdb.MeasurementFields, seriesToCreate []*tsdb.SeriesCreate) (err error, minTime, maxTime int64, valuesByID map[uint64]Values) {
@@ -1028,7 +1080,11 @@ func (e *Engine) rewriteFile(oldDF *dataFile, valuesByID map[uint64]Values) erro
 	// always write in order by ID
 	sort.Sort(uint64slice(ids))
 
-	f, err := e.openFileAndCheckpoint(e.nextFileName())
+	level := 0
+	if oldDF != nil {
+		level = oldDF.level
+	}
+	f, err := e.openFileAndCheckpoint(e.nextFileName(level))
 	if err != nil {
 		return err
 	}
@@ -1167,6 +1223,7 @@ func (e *Engine) rewriteFile(oldDF *dataFile, valuesByID map[uint64]Values) erro
 	files = append(files, newDF)
 	sort.Sort(files)
 	e.files = files
+	e.updateLevelStats()
 	e.filesLock.Unlock()
 
 	// remove the old data file. no need to block returning the write,
@@ -1177,6 +1234,7 @@ func (e *Engine) rewriteFile(oldDF *dataFile, valuesByID map[uint64]Values) erro
 			if err := oldDF.Delete(); err != nil {
 				e.logger.Println("ERROR DELETING FROM REWRITE:", oldDF.f.Name())
 			}
+			e.blockCache.removeFile(oldDF)
 			e.deletesPending.Done()
 		}()
 	}
@@ -1194,6 +1252,7 @@ func (e *Engine) flushDeletes() error {
 
 	measurements := make(map[string]bool)
 	deletes := make(map[uint64]string)
+	ranges := make(map[uint64]deleteRanges)
 	e.filesLock.RLock()
 	for name, _ := range e.deleteMeasurements {
 		measurements[name] = true
@@ -1201,6 +1260,9 @@ func (e *Engine) flushDeletes() error {
 	for id, key := range e.deletes {
 		deletes[id] = key
 	}
+	for id, r := range e.deleteRanges {
+		ranges[id] = r
+	}
 	e.filesLock.RUnlock()
 
 	// if we're deleting measurements, rewrite the field data
@@ -1233,7 +1295,7 @@ func (e *Engine) flushDeletes() error {
 	files := e.copyFilesCollection()
 	newFiles := make(dataFiles, 0, len(files))
 	for _, f := range files {
-		newFiles = append(newFiles, e.writeNewFileExcludeDeletes(f))
+		newFiles = append(newFiles, e.writeNewFileExcludeDeletes(f, deletes, ranges))
 	}
 
 	// update the delete map and files
@@ -1241,6 +1303,7 @@ func (e *Engine) flushDeletes() error {
 	defer e.filesLock.Unlock()
 
 	e.files = newFiles
+	e.updateLevelStats()
 
 	// remove the things we've deleted from the map
 	for name, _ := range measurements {
@@ -1249,6 +1312,14 @@ func (e *Engine) flushDeletes() error {
 	for id, _ := range deletes {
 		delete(e.deletes, id)
 	}
+	for id, r := range ranges {
+		// keep any ranges that were marked while the files were being rewritten
+		if remaining := e.deleteRanges[id][len(r):]; len(remaining) > 0 {
+			e.deleteRanges[id] = remaining
+			continue
+		}
+		delete(e.deleteRanges, id)
+	}
 
 	e.deletesPending.Add(1)
 	go func() {
@@ -1256,14 +1327,17 @@ func (e *Engine) flushDeletes() error {
 			if err := oldDF.Delete(); err != nil {
 				e.logger.Println("ERROR DELETING FROM REWRITE:", oldDF.f.Name())
 			}
+			e.blockCache.removeFile(oldDF)
 		}
 		e.deletesPending.Done()
 	}()
 	return nil
 }
 
-func (e *Engine) writeNewFileExcludeDeletes(oldDF *dataFile) *dataFile {
-	f, err := e.openFileAndCheckpoint(e.nextFileName())
+// writeNewFileExcludeDeletes writes a copy of the data file without the deleted IDs and
+// without any values that fall in the deleted time ranges
+func (e *Engine) writeNewFileExcludeDeletes(oldDF *dataFile, deletes map[uint64]string, ranges map[uint64]deleteRanges) *dataFile {
+	f, err := e.openFileAndCheckpoint(e.nextFileName(oldDF.level))
 	if err != nil {
 		panic(fmt.Sprintf("error opening new data file: %s", err.Error()))
 	}
@@ -1273,26 +1347,46 @@ func (e *Engine) writeNewFileExcludeDeletes(oldDF *dataFile) *dataFile {
 
 	indexPosition := oldDF.indexPosition()
 	currentPosition := uint32(fileHeaderSize)
+	writePosition := uint32(fileHeaderSize)
 	currentID := uint64(0)
+	buf := make([]byte, e.MaxPointsPerBlock*20)
 	for currentPosition < indexPosition {
 		id := btou64(oldDF.mmap[currentPosition : currentPosition+8])
 		length := btou32(oldDF.mmap[currentPosition+8 : currentPosition+blockHeaderSize])
 		newPosition := currentPosition + blockHeaderSize + length
 
-		if _, ok := e.deletes[id]; ok {
+		if _, ok := deletes[id]; ok {
 			currentPosition = newPosition
 			continue
 		}
 
-		if _, err := f.Write(oldDF.mmap[currentPosition:newPosition]); err != nil {
+		data := oldDF.mmap[currentPosition:newPosition]
+		if r, ok := ranges[id]; ok {
+			block, err := excludeDeleteRanges(oldDF.mmap[currentPosition+blockHeaderSize:newPosition], r, buf)
+			if err != nil {
+				panic(fmt.Sprintf("error removing deleted range from block: %s", err.Error()))
+			}
+			data = nil
+			if len(block) > 0 {
+				data = append(append(u64tob(id), u32tob(uint32(len(block)))...), block...)
+			}
+		}
+		currentPosition = newPosition
+
+		// the whole block was in a deleted range
+		if len(data) == 0 {
+			continue
+		}
+
+		if _, err := f.Write(data); err != nil {
 			panic(fmt.Sprintf("error writing new index file: %s", err.Error()))
 		}
 		if id != currentID {
 			currentID = id
 			ids = append(ids, id)
-			positions = append(positions, currentPosition)
+			positions = append(positions, writePosition)
 		}
-		currentPosition = newPosition
+		writePosition += uint32(len(data))
 	}
 
 	df, err := e.writeIndexAndGetDataFile(f, oldDF.MinTime(), oldDF.MaxTime(), ids, positions)
@@ -1303,11 +1397,35 @@ func (e *Engine) writeNewFileExcludeDeletes(oldDF *dataFile) *dataFile {
 	return df
 }
 
-func (e *Engine) nextFileName() string {
+// excludeDeleteRanges decodes the block and returns it encoded without the values
+// that fall in any of the ranges. The returned block is empty if no values are left
+func excludeDeleteRanges(block []byte, ranges deleteRanges, buf []byte) ([]byte, error) {
+	values, err := DecodeBlock(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// leave the block as is if none of its values were deleted
+	remaining := make(Values, 0, len(values))
+	for _, v := range values {
+		if !ranges.contains(v.UnixNano()) {
+			remaining = append(remaining, v)
+		}
+	}
+	if len(remaining) == len(values) {
+		return block, nil
+	} else if len(remaining) == 0 {
+		return nil, nil
+	}
+
+	return remaining.Encode(buf)
+}
+
+func (e *Engine) nextFileName(level int) string {
 	e.filesLock.Lock()
 	defer e.filesLock.Unlock()
 	e.currentFileID++
-	return filepath.Join(e.path, fmt.Sprintf("%07d.%s", e.currentFileID, Format))
+	return filepath.Join(e.path, dataFileName(e.currentFileID, level))
 }
 
 func (e *Engine) readCompressedFile(name string) ([]byte, error) {
@@ -1422,6 +1540,22 @@ func (e *Engine) DeleteMeasurement(name string, seriesKeys []string) error {
 	return e.WAL.DeleteMeasurement(name, seriesKeys)
 }
 
+// DeleteSeriesRange deletes the values of the series between min and max, inclusive.
+// The range is kept as a tombstone for queries until the next flush removes it from the data files.
+func (e *Engine) DeleteSeriesRange(seriesKeys []string, min, max int64) error {
+	e.metaLock.Lock()
+	fields, err := e.readFields()
+	if err != nil {
+		e.metaLock.Unlock()
+		return err
+	}
+	keyFields := e.keysWithFields(fields, seriesKeys)
+	e.metaLock.Unlock()
+
+	// the WAL marks the range on the engine once any running flush has finished
+	return e.WAL.DeleteSeriesRange(keyFields, min, max)
+}
+
 // SeriesCount returns the number of series buckets on the shard.
 func (e *Engine) SeriesCount() (n int, err error) {
 	return 0, nil
@@ -1782,6 +1916,7 @@ type dataFile struct {
 	mu      sync.RWMutex
 	size    uint32
 	modTime time.Time
+	level   int // number of compactions the data was written by
 	mmap    []byte
 }
 
@@ -1808,11 +1943,15 @@ func NewDataFile(f *os.File) (*dataFile, error) {
 		return nil, err
 	}
 
+	// files without a level in their name were written by a flush
+	_, level, _ := parseDataFileName(f.Name())
+
 	return &dataFile{
 		f:       f,
 		mmap:    mmap,
 		size:    uint32(fInfo.Size()),
 		modTime: fInfo.ModTime(),
+		level:   level,
 	}, nil
 }
 
@@ -1928,6 +2067,23 @@ func (d *dataFile) block(pos uint32) (id uint64, t int64, block []byte) {
 	return
 }
 
+// deleteRange is a tombstone for the values of a key between min and max, inclusive
+type deleteRange struct {
+	min, max int64
+}
+
+type deleteRanges []deleteRange
+
+// contains returns true if t falls within any of the ranges
+func (a deleteRanges) contains(t int64) bool {
+	for _, r := range a {
+		if t >= r.min && t <= r.max {
+			return true
+		}
+	}
+	return false
+}
+
 type dataFiles []*dataFile
 
 func (a dataFiles) Len() int           { return len(a) }
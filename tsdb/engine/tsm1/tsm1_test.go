@@ -2,6 +2,7 @@ package tsm1_test
 
 import (
 	"encoding/binary"
+	"expvar"
 	"fmt"
 	"io/ioutil"
 	"math"
@@ -358,6 +359,158 @@ func TestEngine_Compaction(t *testing.T) {
 	verify("cpu,host=B", []models.Point{p2, p4, p6, p8}, 0)
 }
 
+// Ensure small files are compacted into larger levels, and files at the highest level
+// are left alone until a full compaction.
+func TestEngine_Compaction_Leveled(t *testing.T) {
+	e := OpenDefaultEngine()
+	defer e.Cleanup()
+
+	e.RotateFileSize = 10
+	e.CompactionLevelSizeRatio = 1000
+	e.MaxCompactionLevel = 2
+	e.MinCompactionFileCount = 2
+	e.CompactionAge = 0
+
+	write := func(ts ...int64) {
+		for _, t := range ts {
+			p := parsePoint(fmt.Sprintf("cpu,host=A value=1.1 %d", t))
+			if err := e.WritePoints([]models.Point{p}, nil, nil); err != nil {
+				panic(err)
+			}
+		}
+	}
+	compact := func(n int) {
+		if err := e.Compact(false); err != nil {
+			t.Fatalf("error compacting: %s", err)
+		}
+		if count := e.DataFileCount(); count != n {
+			t.Fatalf("expected %d data files but got %d", n, count)
+		}
+	}
+	levelFiles := func(level int) string {
+		values := expvar.Get("tsm1_engine:" + e.Path()).(*expvar.Map).Get("values").(*expvar.Map)
+		return values.Get(fmt.Sprintf("level%d_files", level)).String()
+	}
+
+	// Level 0 files are compacted into a level 1 file.
+	write(1000000000, 2000000000, 3000000000)
+	compact(1)
+
+	// New level 0 files aren't compacted with the level 1 file.
+	write(4000000000, 5000000000)
+	compact(2)
+	if n := levelFiles(1); n != "2" {
+		t.Fatalf("unexpected level 1 file count: %s", n)
+	}
+
+	// The level 1 files are compacted into the highest level, which isn't compacted again.
+	compact(1)
+	write(6000000000)
+	compact(2)
+	if n := levelFiles(2); n != "1" {
+		t.Fatalf("unexpected level 2 file count: %s", n)
+	}
+
+	// Levels are kept in the file names.
+	if err := e.Close(); err != nil {
+		t.Fatalf("error closing: %s", err.Error())
+	}
+	if err := e.Open(); err != nil {
+		t.Fatalf("error opening: %s", err.Error())
+	}
+	compact(2)
+
+	// A full compaction rewrites every file.
+	if err := e.Compact(true); err != nil {
+		t.Fatalf("error compacting: %s", err)
+	}
+	if count := e.DataFileCount(); count != 1 {
+		t.Fatalf("expected 1 data file but got %d", count)
+	}
+
+	tx, _ := e.Begin(false)
+	defer tx.Rollback()
+	c := tx.Cursor("cpu,host=A", []string{"value"}, nil, true)
+	var n int
+	for k, _ := c.SeekTo(0); k != tsdb.EOF; k, _ = c.Next() {
+		n++
+	}
+	if n != 6 {
+		t.Fatalf("expected 6 points but got %d", n)
+	}
+}
+
+// Ensure decoded blocks are cached and invalidated when their files are compacted.
+func TestEngine_BlockCache(t *testing.T) {
+	e := OpenDefaultEngine()
+	defer e.Cleanup()
+
+	e.RotateFileSize = 10
+	e.CompactionAge = 0
+	e.MinCompactionFileCount = 2
+
+	for _, p := range []string{"cpu,host=A value=1.1 1000000000", "cpu,host=A value=1.2 2000000000", "cpu,host=A value=1.3 3000000000"} {
+		if err := e.WritePoints([]models.Point{parsePoint(p)}, nil, nil); err != nil {
+			t.Fatalf("failed to write points: %s", err.Error())
+		}
+	}
+
+	read := func() []interface{} {
+		tx, _ := e.Begin(false)
+		defer tx.Rollback()
+		c := tx.Cursor("cpu,host=A", []string{"value"}, nil, true)
+		var values []interface{}
+		for k, v := c.SeekTo(0); k != tsdb.EOF; k, v = c.Next() {
+			values = append(values, v)
+		}
+		return values
+	}
+	stat := func(name string) string {
+		values := expvar.Get("tsm1_engine:" + e.Path()).(*expvar.Map).Get("values").(*expvar.Map)
+		if v := values.Get(name); v != nil {
+			return v.String()
+		}
+		return "0"
+	}
+	exp := []interface{}{1.1, 1.2, 1.3}
+
+	// The first read decodes the blocks and the second one reads them from the cache.
+	if values := read(); !reflect.DeepEqual(values, exp) {
+		t.Fatalf("unexpected values: %v", values)
+	}
+	if hits, misses := stat("block_cache_hits"), stat("block_cache_misses"); hits != "0" || misses != "3" {
+		t.Fatalf("unexpected hits and misses: %s, %s", hits, misses)
+	}
+	if values := read(); !reflect.DeepEqual(values, exp) {
+		t.Fatalf("unexpected values: %v", values)
+	}
+	if hits, misses := stat("block_cache_hits"), stat("block_cache_misses"); hits != "3" || misses != "3" {
+		t.Fatalf("unexpected hits and misses: %s, %s", hits, misses)
+	}
+
+	// Overwrite a value and compact the files, the blocks of the new file are decoded.
+	if err := e.WritePoints([]models.Point{parsePoint("cpu,host=A value=2.2 2000000000")}, nil, nil); err != nil {
+		t.Fatalf("failed to write points: %s", err.Error())
+	}
+	if err := e.Compact(true); err != nil {
+		t.Fatalf("error compacting: %s", err)
+	}
+	exp = []interface{}{1.1, 2.2, 1.3}
+	if values := read(); !reflect.DeepEqual(values, exp) {
+		t.Fatalf("unexpected values after compaction: %v", values)
+	}
+	misses := stat("block_cache_misses")
+	if hits := stat("block_cache_hits"); hits != "3" || misses == "3" {
+		t.Fatalf("unexpected hits and misses after compaction: %s, %s", hits, misses)
+	}
+	if values := read(); !reflect.DeepEqual(values, exp) {
+		t.Fatalf("unexpected values after compaction: %v", values)
+	}
+	if hits := stat("block_cache_hits"); hits == "3" || stat("block_cache_misses") != misses {
+		t.Fatalf("unexpected hits and misses after compaction: %s, %s", hits, stat("block_cache_misses"))
+	}
+}
+
 // Ensure that if two keys have the same fnv64-a id, we handle it
 func TestEngine_KeyCollisionsAreHandled(t *testing.T) {
 	e := OpenDefaultEngine()
@@ -1236,6 +1389,89 @@ func TestEngine_Deletes(t *testing.T) {
 	}()
 }
 
+// Ensure deleting a time range removes values from the WAL and the index, but
+// keeps values written after the delete.
+func TestEngine_DeleteSeriesRange(t *testing.T) {
+	e := OpenDefaultEngine()
+	defer e.Cleanup()
+
+	fields := []string{"value"}
+	// Create metadata.
+	mf := &tsdb.MeasurementFields{Fields: make(map[string]*tsdb.Field)}
+	mf.CreateFieldIfNotExists("value", influxql.Float, false)
+	atag := map[string]string{"host": "A"}
+	btag := map[string]string{"host": "B"}
+	seriesToCreate := []*tsdb.SeriesCreate{
+		{Series: tsdb.NewSeries(string(models.MakeKey([]byte("cpu"), atag)), atag)},
+		{Series: tsdb.NewSeries(string(models.MakeKey([]byte("cpu"), btag)), btag)},
+	}
+
+	p1 := parsePoint("cpu,host=A value=1.1 1000000000")
+	p2 := parsePoint("cpu,host=A value=1.2 2000000000")
+	p3 := parsePoint("cpu,host=A value=1.3 3000000000")
+	p4 := parsePoint("cpu,host=A value=1.4 2500000000")
+	p5 := parsePoint("cpu,host=A value=1.5 4000000000")
+	p6 := parsePoint("cpu,host=B value=2.1 2000000000")
+	p7 := parsePoint("cpu,host=A value=1.6 2000000000")
+
+	e.SkipCompaction = true
+	e.WAL.SkipCache = false
+
+	// write some points to the index and leave others in the WAL
+	if err := e.WritePoints([]models.Point{p1, p2, p3, p6}, map[string]*tsdb.MeasurementFields{"cpu": mf}, seriesToCreate); err != nil {
+		t.Fatalf("failed to write points: %s", err.Error())
+	}
+	if err := e.WAL.Flush(); err != nil {
+		t.Fatalf("error flushing wal: %s", err.Error())
+	}
+	if err := e.WritePoints([]models.Point{p4, p5}, nil, nil); err != nil {
+		t.Fatalf("failed to write points: %s", err.Error())
+	}
+
+	if err := e.DeleteSeriesRange([]string{"cpu,host=A"}, 2000000000, 3000000000); err != nil {
+		t.Fatalf("failed to delete series range: %s", err.Error())
+	}
+
+	verify := func(series string, exp ...int64) {
+		tx, _ := e.Begin(false)
+		defer tx.Rollback()
+		c := tx.Cursor(series, fields, nil, true)
+		var got []int64
+		for k, _ := c.SeekTo(0); k != tsdb.EOF; k, _ = c.Next() {
+			got = append(got, k)
+		}
+		if !reflect.DeepEqual(got, exp) {
+			t.Fatalf("%s times wrong:\n\texp:%v\n\tgot:%v\n", series, exp, got)
+		}
+	}
+
+	// the range is removed from the WAL and skipped in the index
+	verify("cpu,host=A", p1.UnixNano(), p5.UnixNano())
+	verify("cpu,host=B", p6.UnixNano())
+
+	// write into the deleted range and flush the delete through to the index
+	if err := e.WritePoints([]models.Point{p7}, nil, nil); err != nil {
+		t.Fatalf("failed to write points: %s", err.Error())
+	}
+	if err := e.WAL.Flush(); err != nil {
+		t.Fatalf("error flushing wal: %s", err.Error())
+	}
+
+	verify("cpu,host=A", p1.UnixNano(), p7.UnixNano(), p5.UnixNano())
+	verify("cpu,host=B", p6.UnixNano())
+
+	// open and close to verify the delete was persisted
+	if err := e.Close(); err != nil {
+		t.Fatalf("error closing: %s", err.Error())
+	}
+	if err := e.Open(); err != nil {
+		t.Fatalf("error opening: %s", err.Error())
+	}
+
+	verify("cpu,host=A", p1.UnixNano(), p7.UnixNano(), p5.UnixNano())
+	verify("cpu,host=B", p6.UnixNano())
+}
+
 func TestEngine_IndexGoodAfterFlush(t *testing.T) {
 	e := OpenDefaultEngine()
 	defer e.Cleanup()
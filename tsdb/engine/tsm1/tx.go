@@ -25,7 +25,10 @@ func (t *tx) Cursor(series string, fields []string, dec *tsdb.FieldCodec, ascend
 		if isDeleted {
 			indexCursor = &emptyCursor{ascending: ascending}
 		} else {
-			indexCursor = newCursor(id, t.files, ascending)
+			indexCursor = newCursor(id, t.files, ascending, t.engine)
+		}
+		if ranges, ok := t.engine.deleteRanges[id]; ok {
+			indexCursor = newTombstoneCursor(indexCursor, ranges)
 		}
 		wc := t.engine.WAL.Cursor(series, fields, dec, ascending)
 		return NewCombinedEngineCursor(wc, indexCursor, ascending)
@@ -43,7 +46,10 @@ func (t *tx) Cursor(series string, fields []string, dec *tsdb.FieldCodec, ascend
 		if isDeleted {
 			indexCursor = &emptyCursor{ascending: ascending}
 		} else {
-			indexCursor = newCursor(id, t.files, ascending)
+			indexCursor = newCursor(id, t.files, ascending, t.engine)
+		}
+		if ranges, ok := t.engine.deleteRanges[id]; ok {
+			indexCursor = newTombstoneCursor(indexCursor, ranges)
 		}
 		wc := t.engine.WAL.Cursor(series, []string{field}, dec, ascending)
 		// double up the fields since there's one for the wal and one for the index
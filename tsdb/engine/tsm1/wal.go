@@ -54,10 +54,11 @@ const (
 type walEntryType byte
 
 const (
-	pointsEntry walEntryType = 0x01
-	fieldsEntry walEntryType = 0x02
-	seriesEntry walEntryType = 0x03
-	deleteEntry walEntryType = 0x04
+	pointsEntry      walEntryType = 0x01
+	fieldsEntry      walEntryType = 0x02
+	seriesEntry      walEntryType = 0x03
+	deleteEntry      walEntryType = 0x04
+	deleteRangeEntry walEntryType = 0x05
 )
 
 type Log struct {
@@ -76,6 +77,7 @@ type Log struct {
 	cacheLock              sync.RWMutex
 	lastWriteTime          time.Time
 	flushRunning           bool
+	flushDone              *sync.Cond // signaled when flushRunning is cleared
 	cache                  map[string]Values
 	cacheDirtySort         map[string]bool   // this map should be small, only for dirty vals
 	flushCache             map[string]Values // temporary map while flushing
@@ -120,10 +122,11 @@ type IndexWriter interface {
 	Write(valuesByKey map[string]Values, measurementFieldsToSave map[string]*tsdb.MeasurementFields, seriesToCreate []*tsdb.SeriesCreate) error
 	MarkDeletes(keys []string)
 	MarkMeasurementDelete(name string)
+	MarkDeleteRange(keys []string, min, max int64)
 }
 
 func NewLog(path string) *Log {
-	return &Log{
+	l := &Log{
 		path: path,
 
 		// these options should be overriden by any options in the config
@@ -134,6 +137,8 @@ func NewLog(path string) *Log {
 		MaxMemorySizeThreshold:   tsdb.DefaultMaxMemorySizeThreshold,
 		logger:                   log.New(os.Stderr, "[tsm1wal] ", log.LstdFlags),
 	}
+	l.flushDone = sync.NewCond(&l.cacheLock)
+	return l
 }
 
 // Open opens and initializes the Log. Will recover from previous unclosed shutdowns
@@ -399,6 +404,15 @@ func (l *Log) readFileToCache(fileName string) error {
 			if d.MeasurementName != "" {
 				l.deleteMeasurementFromCache(d.MeasurementName)
 			}
+		case deleteRangeEntry:
+			d := &deleteRangeData{}
+			if err := json.Unmarshal(data, &d); err != nil {
+				return err
+			}
+			l.Index.MarkDeleteRange(d.Keys, d.Min, d.Max)
+			l.cacheLock.Lock()
+			l.deleteRangeFromCache(d.Keys, d.Min, d.Max)
+			l.cacheLock.Unlock()
 		}
 	}
 }
@@ -495,6 +509,61 @@ func (l *Log) deleteKeysFromCache(keys []string) {
 	l.seriesToCreateCache = seriesCreate
 }
 
+// DeleteSeriesRange removes the values of the keys between min and max, inclusive. It waits
+// for any running flush to finish and holds off new ones until the range is marked on the
+// index, so values cached before the delete can't be written to the index after it.
+func (l *Log) DeleteSeriesRange(keys []string, min, max int64) error {
+	l.cacheLock.Lock()
+	for l.flushRunning {
+		l.flushDone.Wait()
+	}
+	l.flushRunning = true
+	l.deleteRangeFromCache(keys, min, max)
+	l.cacheLock.Unlock()
+
+	defer func() {
+		l.cacheLock.Lock()
+		l.flushRunning = false
+		l.flushDone.Broadcast()
+		l.cacheLock.Unlock()
+	}()
+
+	l.Index.MarkDeleteRange(keys, min, max)
+
+	js, err := json.Marshal(&deleteRangeData{Keys: keys, Min: min, Max: max})
+	if err != nil {
+		return err
+	}
+	return l.writeToLog(deleteRangeEntry, snappy.Encode(nil, js))
+}
+
+// deleteRangeFromCache removes the cached values of the keys between min and max.
+// The cacheLock must be held by the caller.
+func (l *Log) deleteRangeFromCache(keys []string, min, max int64) {
+	for _, k := range keys {
+		values, ok := l.cache[k]
+		if !ok {
+			continue
+		}
+
+		a := make(Values, 0, len(values))
+		for _, v := range values {
+			if t := v.UnixNano(); t >= min && t <= max {
+				l.memorySize -= v.Size()
+				continue
+			}
+			a = append(a, v)
+		}
+
+		if len(a) == 0 {
+			delete(l.cache, k)
+			delete(l.cacheDirtySort, k)
+			continue
+		}
+		l.cache[k] = a
+	}
+}
+
 // Close will finish any flush that is currently in process and close file handles
 func (l *Log) Close() error {
 	l.writeLock.Lock()
@@ -547,6 +616,7 @@ func (l *Log) flush(flush flushType) error {
 	defer func() {
 		l.cacheLock.Lock()
 		l.flushRunning = false
+		l.flushDone.Broadcast()
 		l.cacheLock.Unlock()
 	}()
 
@@ -779,6 +849,13 @@ type deleteData struct {
 	Keys            []string
 }
 
+// deleteRangeData holds the information for a delete entry of a time range
+type deleteRangeData struct {
+	Keys []string
+	Min  int64
+	Max  int64
+}
+
 // idFromFileName parses the segment file ID from its name
 func idFromFileName(name string) (int, error) {
 	parts := strings.Split(filepath.Base(name), ".")
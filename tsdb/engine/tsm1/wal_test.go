@@ -176,3 +176,5 @@ func (m *MockIndexWriter) Write(valuesByKey map[string]tsm1.Values, measurementF
 func (m *MockIndexWriter) MarkDeletes(keys []string) {}
 
 func (m *MockIndexWriter) MarkMeasurementDelete(name string) {}
+
+func (m *MockIndexWriter) MarkDeleteRange(keys []string, min, max int64) {}
@@ -319,7 +319,9 @@ func (l *Log) LoadMetadataIndex(index *tsdb.DatabaseIndex, measurementFields map
 				seriesToCreate = append(seriesToCreate, sc)
 
 				sc.Series.InitializeShards()
-				index.CreateSeriesIndexIfNotExists(tsdb.MeasurementFromSeriesKey(string(sc.Series.Key)), sc.Series)
+				if _, err := index.CreateSeriesIndexIfNotExists(tsdb.MeasurementFromSeriesKey(string(sc.Series.Key)), sc.Series); err != nil {
+					return err
+				}
 			}
 		}
 	}
@@ -355,6 +357,21 @@ func (l *Log) DeleteSeries(keys []string) error {
 	return l.partition.deleteSeries(keys)
 }
 
+// DeleteSeriesRange will flush the metadata that is in the WAL to the index and remove
+// the points of the keys between min and max (inclusive) from the WAL. It's up to the
+// caller to remove the range from the index.
+func (l *Log) DeleteSeriesRange(keys []string, min, max int64) error {
+	if err := l.flushMetadata(); err != nil {
+		return err
+	}
+
+	// we want to stop any writes from happening to ensure the data gets cleared
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.partition.deleteSeriesRange(keys, min, max)
+}
+
 // readMetadataFile will read the entire contents of the meta file and return a slice of the
 // seriesAndFields objects that were written in. It ignores file errors since those can't be
 // recovered.
@@ -1278,6 +1295,38 @@ func (p *Partition) deleteSeries(keys []string) error {
 	return p.flushAndCompact(deleteFlush)
 }
 
+// deleteSeriesRange will remove the points of the keys between min and max from the cache
+// and flush the rest to the index, so the removed points don't get written later.
+func (p *Partition) deleteSeriesRange(keys []string, min, max int64) error {
+	p.mu.Lock()
+	for _, k := range keys {
+		entry := p.cache[k]
+		if entry == nil {
+			continue
+		}
+
+		var a [][]byte
+		for _, v := range entry.points {
+			if t := int64(btou64(v[0:8])); t >= min && t <= max {
+				entry.size -= len(v)
+				p.memorySize -= uint64(len(v))
+				p.statMap.Add(statMemorySize, -int64(len(v)))
+				continue
+			}
+			a = append(a, v)
+		}
+
+		if len(a) == 0 {
+			delete(p.cache, k)
+			continue
+		}
+		entry.points = a
+	}
+	p.mu.Unlock()
+
+	return p.flushAndCompact(deleteFlush)
+}
+
 // compactionInfo is a data object with information about a compaction running
 // and the series that will be flushed to the index
 type compactionInfo struct {
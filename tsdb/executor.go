@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/influxdb/influxdb/influxql"
@@ -22,9 +23,10 @@ const (
 	IgnoredChunkSize = 0
 )
 
-// Executor is an interface for a query executor.
+// Executor is an interface for a query executor. Execution stops, and a row
+// with ErrQueryInterrupted is sent, once the closing channel is closed.
 type Executor interface {
-	Execute() <-chan *models.Row
+	Execute(closing <-chan struct{}) <-chan *models.Row
 }
 
 type SelectExecutor struct {
@@ -32,13 +34,18 @@ type SelectExecutor struct {
 	mappers        []*StatefulMapper
 	chunkSize      int
 	limitedTagSets map[string]struct{} // Set tagsets for which data has reached the LIMIT.
+	closing        <-chan struct{}     // Closed when execution is interrupted.
+	done           chan struct{}       // Closed when execution completes.
+	reduceTime     time.Duration       // Time spent combining mapper output, reported by EXPLAIN ANALYZE.
+	ranking        *seriesRanking      // Orders series by an aggregate, if set.
+	closeOnce      sync.Once
 }
 
 // NewSelectExecutor returns a new SelectExecutor.
 func NewSelectExecutor(stmt *influxql.SelectStatement, mappers []Mapper, chunkSize int) *SelectExecutor {
 	a := []*StatefulMapper{}
 	for _, m := range mappers {
-		a = append(a, &StatefulMapper{m, nil, false})
+		a = append(a, &StatefulMapper{Mapper: m})
 	}
 	return &SelectExecutor{
 		stmt:           stmt,
@@ -49,21 +56,41 @@ func NewSelectExecutor(stmt *influxql.SelectStatement, mappers []Mapper, chunkSi
 }
 
 // Execute begins execution of the query and returns a channel to receive rows.
-func (e *SelectExecutor) Execute() <-chan *models.Row {
+func (e *SelectExecutor) Execute(closing <-chan struct{}) <-chan *models.Row {
 	// Create output channel and stream data in a separate goroutine.
 	out := make(chan *models.Row, 0)
 
+	// Mappers waiting on other nodes are interrupted as soon as execution is,
+	// the others stop at the next chunk.
+	e.closing, e.done = closing, make(chan struct{})
+	mappers := make([]Mapper, len(e.mappers))
+	for i, m := range e.mappers {
+		mappers[i] = m.Mapper
+	}
+	interruptMappers(mappers, closing, e.done)
+
 	// Certain operations on the SELECT statement can be performed by the SelectExecutor without
 	// assistance from the Mappers. This allows the SelectExecutor to prepare aggregation functions
 	// and mathematical functions.
 	e.stmt.RewriteDistinct()
 
-	if (e.stmt.IsRawQuery && !e.stmt.HasDistinct()) || e.stmt.IsSimpleDerivative() {
+	// Series ordered by an aggregate are ranked before the statement is executed.
+	if e.ranking != nil {
+		go e.ranking.execute(e, out, closing)
+		return out
+	}
+
+	e.execute(out)
+	return out
+}
+
+// execute starts reading the rows of the statement from the mappers into out.
+func (e *SelectExecutor) execute(out chan *models.Row) {
+	if (e.stmt.IsRawQuery && !e.stmt.HasDistinct()) || e.stmt.IsSimpleTransform() {
 		go e.executeRaw(out)
 	} else {
 		go e.executeAggregate(out)
 	}
-	return out
 }
 
 // mappersDrained returns whether all the executors Mappers have been drained of data.
@@ -143,7 +170,7 @@ func (e *SelectExecutor) executeRaw(out chan *models.Row) {
 	// Open the mappers.
 	for _, m := range e.mappers {
 		if err := m.Open(); err != nil {
-			out <- &models.Row{Err: err}
+			out <- &models.Row{Err: e.mapperError(err)}
 			return
 		}
 	}
@@ -157,6 +184,9 @@ func (e *SelectExecutor) executeRaw(out chan *models.Row) {
 		}
 		selectFields = sf.list()
 		aliasFields = selectFields
+	} else if hasMath(e.stmt.Fields) {
+		selectFields = mathFieldNames(e.stmt.Fields)
+		aliasFields = selectFields
 	} else {
 		selectFields = e.stmt.Fields.Names()
 		aliasFields = e.stmt.Fields.AliasNames()
@@ -169,6 +199,11 @@ func (e *SelectExecutor) executeRaw(out chan *models.Row) {
 	// Keep looping until all mappers drained.
 	var err error
 	for {
+		if e.interrupted() {
+			out <- &models.Row{Err: ErrQueryInterrupted}
+			return
+		}
+
 		// Get the next chunk from each Mapper.
 		for _, m := range e.mappers {
 			if m.drained {
@@ -180,7 +215,7 @@ func (e *SelectExecutor) executeRaw(out chan *models.Row) {
 				if m.bufferedChunk == nil {
 					m.bufferedChunk, err = m.NextChunk()
 					if err != nil {
-						out <- &models.Row{Err: err}
+						out <- &models.Row{Err: e.mapperError(err)}
 						return
 					}
 					if m.bufferedChunk == nil {
@@ -223,6 +258,7 @@ func (e *SelectExecutor) executeRaw(out chan *models.Row) {
 		// Send out data for the next alphabetically-lowest tagset. All Mappers emit data in this order,
 		// so by always continuing with the lowest tagset until it is finished, we process all data in
 		// the required order, and don't "miss" any.
+		start := time.Now()
 		tagset := e.nextMapperTagSet()
 		if tagset != currTagset {
 			currTagset = tagset
@@ -319,21 +355,22 @@ func (e *SelectExecutor) executeRaw(out chan *models.Row) {
 				selectNames: selectFields,
 				aliasNames:  aliasFields,
 				fields:      e.stmt.Fields,
+				location:    e.location(),
 				c:           out,
 			}
-		}
-		if e.stmt.HasDerivative() {
-			interval, err := derivativeInterval(e.stmt)
-			if err != nil {
-				out <- &models.Row{Err: err}
-				return
-			}
-			rowWriter.transformer = &RawQueryDerivativeProcessor{
-				IsNonNegative:      e.stmt.FunctionCalls()[0].Name == "non_negative_derivative",
-				DerivativeInterval: interval,
+
+			// Transformations keep their state across the chunks of a tagset.
+			if c := e.stmt.TransformCall(); c != nil {
+				rowWriter.transformer, err = NewTransformer(e.stmt, c)
+				if err != nil {
+					out <- &models.Row{Err: err}
+					return
+				}
 			}
 		}
 
+		e.reduceTime += time.Since(start)
+
 		// Emit the data via the limiter.
 		if limited := rowWriter.Add(chunkedOutput.Values); limited {
 			// Limit for this tagset was reached, mark it and start draining a new tagset.
@@ -370,7 +407,7 @@ func (e *SelectExecutor) executeAggregate(out chan *models.Row) {
 	// Open the mappers.
 	for _, m := range e.mappers {
 		if err := m.Open(); err != nil {
-			out <- &models.Row{Err: err}
+			out <- &models.Row{Err: e.mapperError(err)}
 			return
 		}
 	}
@@ -389,7 +426,7 @@ func (e *SelectExecutor) executeAggregate(out chan *models.Row) {
 	for _, m := range e.mappers {
 		m.bufferedChunk, err = m.NextChunk()
 		if err != nil {
-			out <- &models.Row{Err: err}
+			out <- &models.Row{Err: e.mapperError(err)}
 			return
 		}
 		if m.bufferedChunk == nil {
@@ -401,9 +438,16 @@ func (e *SelectExecutor) executeAggregate(out chan *models.Row) {
 	if len(e.stmt.SortFields) > 0 {
 		ascending = e.stmt.SortFields[0].Ascending
 	}
+	having := e.stmt.HavingExpr()
+	var seriesN int // Series returned, or skipped by SOFFSET, once filtered by HAVING.
 
 	// Keep looping until all mappers drained.
 	for !e.mappersDrained() {
+		if e.interrupted() {
+			out <- &models.Row{Err: ErrQueryInterrupted}
+			return
+		}
+
 		// Send out data for the next alphabetically-lowest tagset. All Mappers send out in this order
 		// so collect data for this tagset, ignoring all others.
 		tagset := e.nextMapperTagSet()
@@ -418,9 +462,14 @@ func (e *SelectExecutor) executeAggregate(out chan *models.Row) {
 
 			for {
 				if m.bufferedChunk == nil {
+					if e.interrupted() {
+						out <- &models.Row{Err: ErrQueryInterrupted}
+						return
+					}
+
 					m.bufferedChunk, err = m.NextChunk()
 					if err != nil {
-						out <- &models.Row{Err: err}
+						out <- &models.Row{Err: e.mapperError(err)}
 						return
 					}
 					if m.bufferedChunk == nil {
@@ -441,6 +490,7 @@ func (e *SelectExecutor) executeAggregate(out chan *models.Row) {
 		}
 
 		// Prep a row, ready for kicking out.
+		start := time.Now()
 		var row *models.Row
 
 		// Prep for bucketing data by start time of the interval.
@@ -484,7 +534,7 @@ func (e *SelectExecutor) executeAggregate(out chan *models.Row) {
 		values := make([][]interface{}, len(tMins))
 		for i, t := range tMins {
 			values[i] = make([]interface{}, 0, len(columnNames))
-			values[i] = append(values[i], time.Unix(0, t).UTC()) // Time value is always first.
+			values[i] = append(values[i], time.Unix(0, t).In(e.location())) // Time value is always first.
 
 			for j, f := range reduceFuncs {
 				reducedVal := f(buckets[t][j])
@@ -504,14 +554,40 @@ func (e *SelectExecutor) executeAggregate(out chan *models.Row) {
 		// Handle any fill options
 		values = e.processFill(values)
 
-		// process derivatives
-		values = e.processDerivative(values)
+		// Calculate any transformation, such as a derivative, of consecutive values.
+		values, err = e.processTransform(values)
+		if err != nil {
+			out <- &models.Row{Err: err}
+			return
+		}
+
+		// Drop the results failing the HAVING condition, before LIMIT and OFFSET.
+		if having != nil {
+			values = e.limitResults(processHaving(having, values))
+		}
+
+		e.reduceTime += time.Since(start)
+
+		// A tagset without any result left by HAVING is dropped entirely.
+		if having != nil && len(values) == 0 {
+			continue
+		}
 
 		// If we have multiple tag sets we'll want to filter out the empty ones
 		if len(availTagSets) > 1 && resultsEmpty(values) {
 			continue
 		}
 
+		// Series left by HAVING are limited by SLIMIT and SOFFSET here.
+		if having != nil {
+			seriesN++
+			if seriesN <= e.stmt.SOffset {
+				continue
+			} else if e.stmt.SLimit > 0 && seriesN > e.stmt.SOffset+e.stmt.SLimit {
+				break
+			}
+		}
+
 		row.Values = values
 		out <- row
 	}
@@ -519,6 +595,18 @@ func (e *SelectExecutor) executeAggregate(out chan *models.Row) {
 	close(out)
 }
 
+// limitResults returns the results within the LIMIT and OFFSET of the statement.
+func (e *SelectExecutor) limitResults(results [][]interface{}) [][]interface{} {
+	if e.stmt.Offset >= len(results) {
+		return nil
+	}
+	results = results[e.stmt.Offset:]
+	if e.stmt.Limit > 0 && e.stmt.Limit < len(results) {
+		results = results[:e.stmt.Limit]
+	}
+	return results
+}
+
 // processFill will take the results and return new results (or the same if no fill modifications are needed)
 // with whatever fill options the query has.
 func (e *SelectExecutor) processFill(results [][]interface{}) [][]interface{} {
@@ -547,6 +635,18 @@ func (e *SelectExecutor) processFill(results [][]interface{}) [][]interface{} {
 		return newResults
 	}
 
+	if e.stmt.Fill == influxql.LinearFill {
+		return fillLinear(results)
+	}
+
+	// Histograms hold an array of counts, so a number can't stand for a missing one.
+	histograms := make(map[int]struct{})
+	for i, column := range e.stmt.FieldColumns() {
+		if c, ok := e.stmt.Fields[i].Expr.(*influxql.Call); ok && c.Name == "histogram" {
+			histograms[column] = struct{}{}
+		}
+	}
+
 	// They're either filling with previous values or a specific number
 	for i, vals := range results {
 		// start at 1 because the first value is always time
@@ -558,7 +658,9 @@ func (e *SelectExecutor) processFill(results [][]interface{}) [][]interface{} {
 						vals[j] = results[i-1][j]
 					}
 				case influxql.NumberFill:
-					vals[j] = e.stmt.FillValue
+					if _, ok := histograms[j]; !ok {
+						vals[j] = e.stmt.FillValue
+					}
 				}
 			}
 		}
@@ -566,33 +668,165 @@ func (e *SelectExecutor) processFill(results [][]interface{}) [][]interface{} {
 	return results
 }
 
-// processDerivative returns the derivatives of the results
-func (e *SelectExecutor) processDerivative(results [][]interface{}) [][]interface{} {
-	// Return early if we're not supposed to process the derivatives
-	if e.stmt.HasDerivative() {
-		interval, err := derivativeInterval(e.stmt)
+// fillLinear fills nil values by interpolating between the nearest non-nil values before
+// and after them in the same column. Values at the leading and trailing edges, which
+// are missing a neighbor, and non-numeric values are left nil.
+func fillLinear(results [][]interface{}) [][]interface{} {
+	// start at 1 because the first value is always time
+	for j := 1; len(results) > 0 && j < len(results[0]); j++ {
+		prev := -1
+		for i, vals := range results {
+			if j >= len(vals) || vals[j] == nil {
+				continue
+			}
+
+			if prev >= 0 && i-prev > 1 {
+				interpolateWindows(results, j, prev, i)
+			}
+			prev = i
+		}
+	}
+	return results
+}
+
+// interpolateWindows fills column j of every row between the rows start and end.
+func interpolateWindows(results [][]interface{}, j, start, end int) {
+	t0, ok0 := resultTime(results[start][0])
+	t1, ok1 := resultTime(results[end][0])
+
+	for i := start + 1; i < end; i++ {
+		// Use the row offsets as the position if the windows don't have usable times.
+		pos := float64(i-start) / float64(end-start)
+		if t, ok := resultTime(results[i][0]); ok && ok0 && ok1 && t1 > t0 {
+			pos = float64(t-t0) / float64(t1-t0)
+		}
+
+		switch v0 := results[start][j].(type) {
+		case int64:
+			if v1, ok := results[end][j].(int64); ok {
+				results[i][j] = v0 + int64(float64(v1-v0)*pos)
+			} else if v1, ok := results[end][j].(float64); ok {
+				results[i][j] = float64(v0) + (v1-float64(v0))*pos
+			}
+		case float64:
+			switch v1 := results[end][j].(type) {
+			case float64:
+				results[i][j] = v0 + (v1-v0)*pos
+			case int64:
+				results[i][j] = v0 + (float64(v1)-v0)*pos
+			}
+		}
+	}
+}
+
+// resultTime returns the time of a result row in nanoseconds. Selectors format the
+// time of their rows as a string so both forms are accepted.
+func resultTime(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v.UnixNano(), true
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
 		if err != nil {
-			return results // XXX need to handle this better.
+			return 0, false
 		}
+		return t.UnixNano(), true
+	}
+	return 0, false
+}
 
-		// Determines whether to drop negative differences
-		isNonNegative := e.stmt.FunctionCalls()[0].Name == "non_negative_derivative"
-		return ProcessAggregateDerivative(results, isNonNegative, interval)
+// processTransform returns the results of the statement's transformation, if any,
+// calculated over the consecutive results
+func (e *SelectExecutor) processTransform(results [][]interface{}) ([][]interface{}, error) {
+	c := e.stmt.TransformCall()
+	if c == nil {
+		return results, nil
 	}
-	return results
+
+	t, err := NewTransformer(e.stmt, c)
+	if err != nil {
+		return nil, err
+	}
+	return transformResults(t, results), nil
+}
+
+// location returns the time zone that times are returned in.
+func (e *SelectExecutor) location() *time.Location {
+	if e.stmt.Location != nil {
+		return e.stmt.Location
+	}
+	return time.UTC
 }
 
 // Close closes the executor such that all resources are released. Once closed,
-// an executor may not be re-used.
+// an executor may not be re-used. Closing an executor more than once is a no-op.
 func (e *SelectExecutor) close() {
-	if e != nil {
+	if e == nil {
+		return
+	}
+	e.closeOnce.Do(func() {
+		if e.done != nil {
+			close(e.done)
+		}
 		for _, m := range e.mappers {
 			m.Close()
 		}
+		if e.ranking != nil {
+			e.ranking.executor.close()
+		}
+	})
+}
+
+// interrupted returns true if execution has been interrupted.
+func (e *SelectExecutor) interrupted() bool {
+	return isClosed(e.closing)
+}
+
+// mapperError returns the error to send for an error returned by a mapper.
+// Mappers return errors when they are interrupted, which are reported as ErrQueryInterrupted.
+func (e *SelectExecutor) mapperError(err error) error {
+	if e.interrupted() {
+		return ErrQueryInterrupted
+	}
+	return err
+}
+
+// interruptMappers interrupts the mappers which implement Interrupter once closing
+// is closed, unless done is closed first.
+func interruptMappers(mappers []Mapper, closing <-chan struct{}, done <-chan struct{}) {
+	if closing == nil {
+		return
+	}
+	go func() {
+		select {
+		case <-closing:
+			for _, m := range mappers {
+				if i, ok := m.(Interrupter); ok {
+					i.Interrupt()
+				}
+			}
+		case <-done:
+		}
+	}()
+}
+
+// isClosed returns true if the channel c has been closed. A nil channel is never closed.
+func isClosed(c <-chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
 	}
 }
 
 func (e *SelectExecutor) processFunctions(results [][]interface{}, columnNames []string) ([][]interface{}, error) {
+	// Selectors combined with other calls in expressions only contribute their values,
+	// i.e. select max(rx) - min(rx) from foo
+	if hasMath(e.stmt.Fields) && len(e.stmt.FunctionCalls()) > 1 {
+		return processSelectorValues(results), nil
+	}
+
 	callInPosition := e.stmt.FunctionCallsByPosition()
 	hasTimeField := e.stmt.HasTimeFieldSpecified()
 
@@ -637,6 +871,18 @@ func (e *SelectExecutor) processSelectors(results [][]interface{}, callPosition
 	return results, nil
 }
 
+// processSelectorValues replaces the points chosen by selectors with their values.
+func processSelectorValues(results [][]interface{}) [][]interface{} {
+	for _, vals := range results {
+		for j := 1; j < len(vals); j++ {
+			if p, ok := vals[j].(PositionPoint); ok {
+				vals[j] = p.Value
+			}
+		}
+	}
+	return results
+}
+
 func (e *SelectExecutor) selectorPointToQueryResult(row []interface{}, hasTimeField bool, columnIndex int, p PositionPoint, tMin time.Time, columnNames []string) []interface{} {
 	// if the row doesn't have enough columns, expand it
 	if len(row) != len(columnNames) {
@@ -644,10 +890,10 @@ func (e *SelectExecutor) selectorPointToQueryResult(row []interface{}, hasTimeFi
 	}
 	callCount := len(e.stmt.FunctionCalls())
 	if callCount == 1 {
-		tm := time.Unix(0, p.Time).UTC().Format(time.RFC3339Nano)
+		tm := time.Unix(0, p.Time).In(e.location()).Format(time.RFC3339Nano)
 		// If we didn't explicity ask for time, and we have a group by, then use TMIN for the time returned
 		if len(e.stmt.Dimensions) > 0 && !hasTimeField {
-			tm = tMin.UTC().Format(time.RFC3339Nano)
+			tm = tMin.In(e.location()).Format(time.RFC3339Nano)
 		}
 		row[0] = tm
 	}
@@ -699,10 +945,10 @@ func (e *SelectExecutor) processAggregates(results [][]interface{}, columnNames
 }
 
 func (e *SelectExecutor) aggregatePointToQueryResult(p PositionPoint, tMin time.Time, call *influxql.Call, columnNames []string) []interface{} {
-	tm := time.Unix(0, p.Time).UTC().Format(time.RFC3339Nano)
+	tm := time.Unix(0, p.Time).In(e.location()).Format(time.RFC3339Nano)
 	// If we didn't explicity ask for time, and we have a group by, then use TMIN for the time returned
 	if len(e.stmt.Dimensions) > 0 && !e.stmt.HasTimeFieldSpecified() {
-		tm = tMin.UTC().Format(time.RFC3339Nano)
+		tm = tMin.In(e.location()).Format(time.RFC3339Nano)
 	}
 	vals := []interface{}{tm}
 	for _, c := range columnNames {
@@ -733,15 +979,14 @@ type limitedRowWriter struct {
 	fields      influxql.Fields
 	selectNames []string
 	aliasNames  []string
+	location    *time.Location
 	c           chan *models.Row
 
 	currValues  []*MapperValue
 	totalOffSet int
 	totalSent   int
 
-	transformer interface {
-		Process(input []*MapperValue) []*MapperValue
-	}
+	transformer Transformer
 }
 
 // Add accepts a slice of values, and will emit those values as per chunking requirements.
@@ -869,6 +1114,11 @@ func (r *limitedRowWriter) processValues(values []*MapperValue) *models.Row {
 		Columns: aliasFields,
 	}
 
+	// Each mathematical expression is evaluated into a single column.
+	if hasMath(r.fields) {
+		row.Columns = append([]string{"time"}, r.fields.AliasNames()...)
+	}
+
 	// Kick out an empty row it no results available.
 	if len(values) == 0 {
 		return row
@@ -882,7 +1132,7 @@ func (r *limitedRowWriter) processValues(values []*MapperValue) *models.Row {
 		vals := make([]interface{}, len(selectFields))
 
 		if singleValue {
-			vals[0] = time.Unix(0, v.Time).UTC()
+			vals[0] = time.Unix(0, v.Time).In(r.location)
 			switch val := v.Value.(type) {
 			case map[string]interface{}:
 				vals[1] = val[selectFields[1]]
@@ -890,10 +1140,14 @@ func (r *limitedRowWriter) processValues(values []*MapperValue) *models.Row {
 				vals[1] = val
 			}
 		} else {
-			fields := v.Value.(map[string]interface{})
+			fields, ok := v.Value.(map[string]interface{})
+			if !ok {
+				// A field read by several expressions is returned on its own.
+				fields = map[string]interface{}{selectFields[1]: v.Value}
+			}
 
 			// time is always the first value
-			vals[0] = time.Unix(0, v.Time).UTC()
+			vals[0] = time.Unix(0, v.Time).In(r.location)
 
 			// populate the other values
 			for i := 1; i < len(selectFields); i++ {
@@ -949,7 +1203,7 @@ func (rqdp *RawQueryDerivativeProcessor) Process(input []*MapperValue) []*Mapper
 		return input
 	}
 
-	if len(input) == 1 {
+	if len(input) == 1 && rqdp.LastValueFromPreviousChunk == nil {
 		return []*MapperValue{
 			&MapperValue{
 				Time:  input[0].Time,
@@ -958,12 +1212,15 @@ func (rqdp *RawQueryDerivativeProcessor) Process(input []*MapperValue) []*Mapper
 		}
 	}
 
+	// Start from the last value of the previous chunk, if there was one.
+	start := 0
 	if rqdp.LastValueFromPreviousChunk == nil {
 		rqdp.LastValueFromPreviousChunk = input[0]
+		start = 1
 	}
 
 	derivativeValues := []*MapperValue{}
-	for i := 1; i < len(input); i++ {
+	for i := start; i < len(input); i++ {
 		v := input[i]
 
 		// If we can't use the current or prev value (wrong time, nil), just append
@@ -1006,16 +1263,7 @@ func (rqdp *RawQueryDerivativeProcessor) Process(input []*MapperValue) []*Mapper
 // processForMath will apply any math that was specified in the select statement
 // against the passed in results
 func processForMath(fields influxql.Fields, results [][]interface{}) [][]interface{} {
-	hasMath := false
-	for _, f := range fields {
-		if _, ok := f.Expr.(*influxql.BinaryExpr); ok {
-			hasMath = true
-		} else if _, ok := f.Expr.(*influxql.ParenExpr); ok {
-			hasMath = true
-		}
-	}
-
-	if !hasMath {
+	if !hasMath(fields) {
 		return results
 	}
 
@@ -1038,74 +1286,61 @@ func processForMath(fields influxql.Fields, results [][]interface{}) [][]interfa
 	return mathResults
 }
 
-// ProcessAggregateDerivative returns the derivatives of an aggregate result set
-func ProcessAggregateDerivative(results [][]interface{}, isNonNegative bool, interval time.Duration) [][]interface{} {
-	// Return early if we can't calculate derivatives
-	if len(results) == 0 {
-		return results
-	}
-
-	// If we only have 1 value, then the value did not change, so return
-	// a single row w/ 0.0
-	if len(results) == 1 {
-		return [][]interface{}{
-			[]interface{}{results[0][0], 0.0},
-		}
-	}
-
-	// Otherwise calculate the derivatives as the difference between consecutive
-	// points divided by the elapsed time.  Then normalize to the requested
-	// interval.
-	derivatives := [][]interface{}{}
-	for i := 1; i < len(results); i++ {
-		prev := results[i-1]
-		cur := results[i]
-
-		// If current value is nil, append nil for the value
-		if prev[1] == nil || cur[1] == nil {
-			derivatives = append(derivatives, []interface{}{
-				cur[0], nil,
-			})
-			continue
+// processHaving returns the results for which cond is true. Each value of a result
+// is referenced in cond by the position of its column, as named by influxql.ColumnRef.
+func processHaving(cond influxql.Expr, results [][]interface{}) [][]interface{} {
+	var refs []string
+	filtered := results[:0]
+	for _, vals := range results {
+		for i := len(refs); i < len(vals); i++ {
+			refs = append(refs, influxql.ColumnRef(i))
 		}
 
-		// Check the value's type to ensure it's an numeric, if not, return a nil result. We only check the first value
-		// because derivatives cannot be combined with other aggregates currently.
-		validType := false
-		switch cur[1].(type) {
-		case int64:
-			validType = true
-		case float64:
-			validType = true
+		m := make(map[string]interface{}, len(vals))
+		for i, v := range vals {
+			m[refs[i]] = v
 		}
 
-		if !validType {
-			derivatives = append(derivatives, []interface{}{
-				cur[0], nil,
-			})
-			continue
-		}
-
-		elapsed := cur[0].(time.Time).Sub(prev[0].(time.Time))
-		diff := int64toFloat64(cur[1]) - int64toFloat64(prev[1])
-		value := 0.0
-		if elapsed > 0 {
-			value = float64(diff) / (float64(elapsed) / float64(interval))
+		if influxql.EvalBool(cond, m) {
+			filtered = append(filtered, vals)
 		}
+	}
+	return filtered
+}
 
-		// Drop negative values for non-negative derivatives
-		if isNonNegative && diff < 0 {
-			continue
-		}
+// mathFieldNames returns the fields read by the mathematical expressions of a raw
+// query, in the order their values are expected by the processors of the expressions.
+func mathFieldNames(fields influxql.Fields) []string {
+	var names []string
+	for _, f := range fields {
+		influxql.WalkFunc(f.Expr, func(n influxql.Node) {
+			if ref, ok := n.(*influxql.VarRef); ok {
+				names = append(names, ref.Val)
+			}
+		})
+	}
+	return names
+}
 
-		val := []interface{}{
-			cur[0],
-			value,
+// hasMath returns true if any of the fields is a mathematical expression or a
+// scalar function call.
+func hasMath(fields influxql.Fields) bool {
+	for _, f := range fields {
+		switch expr := f.Expr.(type) {
+		case *influxql.BinaryExpr, *influxql.ParenExpr:
+			return true
+		case *influxql.Call:
+			if expr.IsScalar() {
+				return true
+			}
 		}
-		derivatives = append(derivatives, val)
 	}
+	return false
+}
 
-	return derivatives
+// ProcessAggregateDerivative returns the derivatives of an aggregate result set
+func ProcessAggregateDerivative(results [][]interface{}, isNonNegative bool, interval time.Duration) [][]interface{} {
+	return transformResults(&aggregateDerivativeProcessor{isNonNegative: isNonNegative, interval: interval}, results)
 }
 
 // derivativeInterval returns the time interval for the one (and only) derivative func
@@ -433,6 +433,257 @@ func TestWritePointsAndExecuteTwoShardsTagSetOrdering(t *testing.T) {
 	}
 }
 
+// Test that linear fill interpolates across windows returned by different shards.
+func TestWritePointsAndExecuteTwoShardsFillLinear(t *testing.T) {
+	// Create the mock planner and its metastore
+	store, query_executor := testStoreAndQueryExecutor()
+	defer os.RemoveAll(store.Path())
+	query_executor.MetaStore = &testQEMetastore{
+		sgFunc: func(database, policy string, min, max time.Time) (a []meta.ShardGroupInfo, err error) {
+			return []meta.ShardGroupInfo{
+				{
+					ID: sgID,
+					Shards: []meta.ShardInfo{
+						{
+							ID:     uint64(sID0),
+							Owners: []meta.ShardOwner{{NodeID: nID}},
+						},
+					},
+				},
+				{
+					ID: sgID,
+					Shards: []meta.ShardInfo{
+						{
+							ID:     uint64(sID1),
+							Owners: []meta.ShardOwner{{NodeID: nID}},
+						},
+					},
+				},
+			}, nil
+		},
+	}
+
+	// Write a point to each shard with empty windows between them.
+	if err := store.WriteToShard(sID0, []models.Point{models.NewPoint(
+		"cpu",
+		map[string]string{"host": "x"},
+		map[string]interface{}{"value": 10.0},
+		time.Unix(10, 0).UTC(),
+	)}); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if err := store.WriteToShard(sID1, []models.Point{models.NewPoint(
+		"cpu",
+		map[string]string{"host": "x"},
+		map[string]interface{}{"value": 40.0},
+		time.Unix(40, 0).UTC(),
+	)}); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	var tests = []struct {
+		skip      bool   // Skip test
+		stmt      string // Query statement
+		chunkSize int    // Chunk size for driving the executor
+		expected  string // Expected results, rendered as a string
+	}{
+		{
+			stmt:     `SELECT mean(value) FROM cpu WHERE time >= '1970-01-01T00:00:05Z' AND time < '1970-01-01T00:01:00Z' GROUP BY time(10s) fill(linear)`,
+			expected: `[{"name":"cpu","columns":["time","mean"],"values":[["1970-01-01T00:00:00Z",null],["1970-01-01T00:00:10Z",10],["1970-01-01T00:00:20Z",20],["1970-01-01T00:00:30Z",30],["1970-01-01T00:00:40Z",40],["1970-01-01T00:00:50Z",null]]}]`,
+		},
+		{
+			stmt:     `SELECT count(value) FROM cpu WHERE time >= '1970-01-01T00:00:05Z' AND time < '1970-01-01T00:01:00Z' GROUP BY time(10s) fill(linear)`,
+			expected: `[{"name":"cpu","columns":["time","count"],"values":[["1970-01-01T00:00:00Z",null],["1970-01-01T00:00:10Z",1],["1970-01-01T00:00:20Z",1],["1970-01-01T00:00:30Z",1],["1970-01-01T00:00:40Z",1],["1970-01-01T00:00:50Z",null]]}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		if tt.skip {
+			t.Logf("Skipping test %s", tt.stmt)
+			continue
+		}
+		executor, err := query_executor.PlanSelect(mustParseSelectStatement(tt.stmt), tt.chunkSize)
+		if err != nil {
+			t.Fatalf("failed to plan query: %s", err.Error())
+		}
+		got := executeAndGetResults(executor)
+		if got != tt.expected {
+			t.Fatalf("Test %s\nexp: %s\ngot: %s\n", tt.stmt, tt.expected, got)
+		}
+	}
+}
+
+// Test that GROUP BY time() windows can be offset and aligned to a time zone.
+func TestWritePointsAndExecuteGroupByTimeOffsetAndTimeZone(t *testing.T) {
+	// Create the mock planner and its metastore
+	store, query_executor := testStoreAndQueryExecutor()
+	defer os.RemoveAll(store.Path())
+	query_executor.MetaStore = &testQEMetastore{
+		sgFunc: func(database, policy string, min, max time.Time) (a []meta.ShardGroupInfo, err error) {
+			return []meta.ShardGroupInfo{
+				{
+					ID: sgID,
+					Shards: []meta.ShardInfo{
+						{
+							ID:     uint64(sID0),
+							Owners: []meta.ShardOwner{{NodeID: nID}},
+						},
+					},
+				},
+			}, nil
+		},
+	}
+
+	// Write points either side of local midnight in Berlin around the start of
+	// daylight saving time on 2015-03-29, and either side of 02:00 UTC.
+	var points []models.Point
+	for i, ts := range []string{"2015-03-27T22:30:00Z", "2015-03-27T23:30:00Z", "2015-03-28T22:30:00Z", "2015-03-28T23:30:00Z", "2015-03-29T22:30:00Z"} {
+		points = append(points, models.NewPoint("cpu", nil, map[string]interface{}{"value": float64(i + 1)}, mustParseTime(ts)))
+	}
+	for i, ts := range []string{"2015-03-28T01:00:00Z", "2015-03-28T03:00:00Z"} {
+		points = append(points, models.NewPoint("mem", nil, map[string]interface{}{"value": float64(i + 1)}, mustParseTime(ts)))
+	}
+	if err := store.WriteToShard(sID0, points); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	var tests = []struct {
+		skip      bool   // Skip test
+		stmt      string // Query statement
+		chunkSize int    // Chunk size for driving the executor
+		expected  string // Expected results, rendered as a string
+	}{
+		{
+			stmt:     `SELECT sum(value) FROM mem WHERE time >= '2015-03-27T12:00:00Z' AND time < '2015-03-29T00:00:00Z' GROUP BY time(1d, 2h)`,
+			expected: `[{"name":"mem","columns":["time","sum"],"values":[["2015-03-27T02:00:00Z",1],["2015-03-28T02:00:00Z",2]]}]`,
+		},
+		{
+			stmt:     `SELECT sum(value) FROM mem WHERE time >= '2015-03-27T12:00:00Z' AND time < '2015-03-29T00:00:00Z' GROUP BY time(1d, -22h)`,
+			expected: `[{"name":"mem","columns":["time","sum"],"values":[["2015-03-27T02:00:00Z",1],["2015-03-28T02:00:00Z",2]]}]`,
+		},
+		{
+			stmt:     `SELECT sum(value) FROM cpu WHERE time >= '2015-03-27T00:00:00Z' AND time < '2015-03-30T12:00:00Z' GROUP BY time(1d) tz('Europe/Berlin')`,
+			expected: `[{"name":"cpu","columns":["time","sum"],"values":[["2015-03-27T00:00:00+01:00",1],["2015-03-28T00:00:00+01:00",5],["2015-03-29T00:00:00+01:00",4],["2015-03-30T00:00:00+02:00",5]]}]`,
+		},
+		{
+			stmt:     `SELECT value FROM mem WHERE time >= '2015-03-27T12:00:00Z' AND time < '2015-03-29T00:00:00Z' tz('Europe/Berlin')`,
+			expected: `[{"name":"mem","columns":["time","value"],"values":[["2015-03-28T02:00:00+01:00",1],["2015-03-28T04:00:00+01:00",2]]}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		if tt.skip {
+			t.Logf("Skipping test %s", tt.stmt)
+			continue
+		}
+		executor, err := query_executor.PlanSelect(mustParseSelectStatement(tt.stmt), tt.chunkSize)
+		if err != nil {
+			t.Fatalf("failed to plan query: %s", err.Error())
+		}
+		got := executeAndGetResults(executor)
+		if got != tt.expected {
+			t.Fatalf("Test %s\nexp: %s\ngot: %s\n", tt.stmt, tt.expected, got)
+		}
+	}
+}
+
+// Test that transformations are calculated over raw values and over aggregates.
+func TestWritePointsAndExecuteTransforms(t *testing.T) {
+	// Create the mock planner and its metastore
+	store, query_executor := testStoreAndQueryExecutor()
+	defer os.RemoveAll(store.Path())
+	query_executor.MetaStore = &testQEMetastore{
+		sgFunc: func(database, policy string, min, max time.Time) (a []meta.ShardGroupInfo, err error) {
+			return []meta.ShardGroupInfo{
+				{
+					ID: sgID,
+					Shards: []meta.ShardInfo{
+						{
+							ID:     uint64(sID0),
+							Owners: []meta.ShardOwner{{NodeID: nID}},
+						},
+					},
+				},
+			}, nil
+		},
+	}
+
+	var points []models.Point
+	for i, v := range []float64{2, 5, 9, 10} {
+		points = append(points, models.NewPoint("cpu", nil, map[string]interface{}{"value": v}, time.Unix(int64(i+1)*10, 0).UTC()))
+	}
+	for i, v := range []int64{1, 4, 3} {
+		points = append(points, models.NewPoint("mem", nil, map[string]interface{}{"value": v}, time.Unix(int64(i+1)*10, 0).UTC()))
+	}
+	if err := store.WriteToShard(sID0, points); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	var tests = []struct {
+		skip      bool   // Skip test
+		stmt      string // Query statement
+		chunkSize int    // Chunk size for driving the executor
+		expected  string // Expected results, rendered as a string
+	}{
+		{
+			stmt:     `SELECT difference(value) FROM cpu`,
+			expected: `[{"name":"cpu","columns":["time","difference"],"values":[["1970-01-01T00:00:20Z",3],["1970-01-01T00:00:30Z",4],["1970-01-01T00:00:40Z",1]]}]`,
+		},
+		{
+			stmt:     `SELECT difference(value) FROM mem`,
+			expected: `[{"name":"mem","columns":["time","difference"],"values":[["1970-01-01T00:00:20Z",3],["1970-01-01T00:00:30Z",-1]]}]`,
+		},
+		{
+			stmt:     `SELECT moving_average(value, 2) FROM cpu`,
+			expected: `[{"name":"cpu","columns":["time","moving_average"],"values":[["1970-01-01T00:00:20Z",3.5],["1970-01-01T00:00:30Z",7],["1970-01-01T00:00:40Z",9.5]]}]`,
+		},
+		{
+			stmt:     `SELECT cumulative_sum(value) FROM cpu`,
+			expected: `[{"name":"cpu","columns":["time","cumulative_sum"],"values":[["1970-01-01T00:00:10Z",2],["1970-01-01T00:00:20Z",7],["1970-01-01T00:00:30Z",16],["1970-01-01T00:00:40Z",26]]}]`,
+		},
+		{
+			stmt:      `SELECT cumulative_sum(value) FROM cpu`,
+			chunkSize: 2,
+			expected:  `[{"name":"cpu","columns":["time","cumulative_sum"],"values":[["1970-01-01T00:00:10Z",2],["1970-01-01T00:00:20Z",7]]},{"name":"cpu","columns":["time","cumulative_sum"],"values":[["1970-01-01T00:00:30Z",16],["1970-01-01T00:00:40Z",26]]}]`,
+		},
+		{
+			stmt:     `SELECT elapsed(value, 1s) FROM cpu`,
+			expected: `[{"name":"cpu","columns":["time","elapsed"],"values":[["1970-01-01T00:00:20Z",10],["1970-01-01T00:00:30Z",10],["1970-01-01T00:00:40Z",10]]}]`,
+		},
+		{
+			stmt:     `SELECT difference(max(value)) FROM cpu WHERE time >= '1970-01-01T00:00:10Z' AND time < '1970-01-01T00:00:50Z' GROUP BY time(20s)`,
+			expected: `[{"name":"cpu","columns":["time","difference"],"values":[["1970-01-01T00:00:20Z",7],["1970-01-01T00:00:40Z",1]]}]`,
+		},
+		{
+			stmt:     `SELECT cumulative_sum(mean(value)) FROM cpu WHERE time >= '1970-01-01T00:00:10Z' AND time < '1970-01-01T00:00:50Z' GROUP BY time(20s)`,
+			expected: `[{"name":"cpu","columns":["time","cumulative_sum"],"values":[["1970-01-01T00:00:00Z",2],["1970-01-01T00:00:20Z",9],["1970-01-01T00:00:40Z",19]]}]`,
+		},
+		{
+			stmt:     `SELECT moving_average(sum(value), 2) FROM cpu WHERE time >= '1970-01-01T00:00:10Z' AND time < '1970-01-01T00:00:50Z' GROUP BY time(20s)`,
+			expected: `[{"name":"cpu","columns":["time","moving_average"],"values":[["1970-01-01T00:00:20Z",8],["1970-01-01T00:00:40Z",12]]}]`,
+		},
+		{
+			stmt:     `SELECT elapsed(count(value), 10s) FROM cpu WHERE time >= '1970-01-01T00:00:10Z' AND time < '1970-01-01T00:01:00Z' GROUP BY time(20s) fill(none)`,
+			expected: `[{"name":"cpu","columns":["time","elapsed"],"values":[["1970-01-01T00:00:20Z",2],["1970-01-01T00:00:40Z",2]]}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		if tt.skip {
+			t.Logf("Skipping test %s", tt.stmt)
+			continue
+		}
+		executor, err := query_executor.PlanSelect(mustParseSelectStatement(tt.stmt), tt.chunkSize)
+		if err != nil {
+			t.Fatalf("failed to plan query: %s", err.Error())
+		}
+		got := executeAndGetResults(executor)
+		if got != tt.expected {
+			t.Fatalf("Test %s\nexp: %s\ngot: %s\n", tt.stmt, tt.expected, got)
+		}
+	}
+}
+
 // Test to ensure the engine handles measurements across stores.
 func TestShowMeasurementsMultipleShards(t *testing.T) {
 	// Create two distinct stores, ensuring shard mappers will share nothing.
@@ -1293,7 +1544,7 @@ func (t *testQEShardMapper) CreateMapper(shard meta.ShardInfo, stmt influxql.Sta
 }
 
 func executeAndGetResults(executor tsdb.Executor) string {
-	ch := executor.Execute()
+	ch := executor.Execute(nil)
 
 	var rows []*models.Row
 	for r := range ch {
@@ -0,0 +1,195 @@
+package tsdb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/influxdb/influxdb/influxql"
+	"github.com/influxdb/influxdb/meta"
+	"github.com/influxdb/influxdb/models"
+)
+
+// executeExplainStatement describes the execution plan of a SELECT statement.
+// With ANALYZE the statement is also executed and the time spent by each mapper
+// and by the executor is reported. Each line of the plan is returned as a row.
+func (q *QueryExecutor) executeExplainStatement(stmt *influxql.ExplainStatement, closing <-chan struct{}) *influxql.Result {
+	start := time.Now()
+
+	plan, err := q.planSelect(stmt.Statement, IgnoredChunkSize)
+	if err != nil {
+		return &influxql.Result{Err: err}
+	}
+
+	var seriesN, valueN int
+	if stmt.Analyze {
+		for row := range plan.executor.Execute(closing) {
+			if row.Err != nil {
+				return &influxql.Result{Err: row.Err}
+			}
+			seriesN++
+			valueN += len(row.Values)
+		}
+	} else {
+		// Mappers are connected to remote nodes when created so must be closed.
+		plan.close()
+	}
+
+	lines := q.explainPlan(plan, stmt.Analyze, "")
+	if stmt.Analyze {
+		lines = append(lines,
+			fmt.Sprintf("output: %d series, %d values", seriesN, valueN),
+			fmt.Sprintf("total: %s", time.Since(start)),
+		)
+	}
+
+	row := &models.Row{Columns: []string{"QUERY PLAN"}}
+	for _, line := range lines {
+		row.Values = append(row.Values, []interface{}{line})
+	}
+	return &influxql.Result{Series: []*models.Row{row}}
+}
+
+// explainPlan returns the lines describing a plan, each prefixed with indent.
+func (q *QueryExecutor) explainPlan(plan *selectPlan, analyze bool, indent string) []string {
+	stmt := plan.stmt
+
+	var lines []string
+	lines = append(lines, stmt.String())
+	lines = append(lines, fmt.Sprintf("time range: %s to %s", plan.tmin.UTC().Format(time.RFC3339Nano), plan.tmax.UTC().Format(time.RFC3339Nano)))
+
+	// Describe how the mapper output is combined.
+	if (stmt.IsRawQuery && !stmt.HasDistinct()) || stmt.IsSimpleTransform() {
+		lines = append(lines, "execution: raw")
+	} else {
+		lines = append(lines, "execution: aggregate")
+		for _, c := range stmt.FunctionCalls() {
+			if !c.IsTransform() {
+				lines = append(lines, fmt.Sprintf("map/reduce: %s", c))
+			}
+		}
+		lines = append(lines, fmt.Sprintf("fill: %s", fillString(stmt)))
+	}
+	if c := stmt.TransformCall(); c != nil {
+		lines = append(lines, fmt.Sprintf("transform: %s", c))
+	}
+
+	// Describe the shards read and, once executed, the work done by their mappers.
+	for _, g := range plan.shardGroups {
+		lines = append(lines, fmt.Sprintf("shard group %d: %s to %s, %d shards", g.ID,
+			g.StartTime.UTC().Format(time.RFC3339Nano), g.EndTime.UTC().Format(time.RFC3339Nano), len(g.Shards)))
+	}
+	for i, m := range plan.executor.mappers {
+		var line string
+		switch m.Mapper.(type) {
+		case *SubQueryMapper:
+			line = "subquery mapper"
+			if len(plan.joins) > 0 {
+				line = "join mapper"
+			}
+		case *RawMapper, *AggregateMapper:
+			line = fmt.Sprintf("shard %d: local", plan.shards[i].ID)
+		default:
+			line = fmt.Sprintf("shard %d: remote, owners %s", plan.shards[i].ID, shardOwnersString(plan.shards[i].Owners))
+		}
+		if analyze {
+			line += fmt.Sprintf(", opened in %s, %d chunks, %d values in %s", m.openTime, m.chunkN, m.valueN, m.chunkTime)
+		}
+		lines = append(lines, line)
+	}
+
+	if plan.subQuery != nil {
+		lines = append(lines, "subquery:")
+		lines = append(lines, q.explainPlan(plan.subQuery, analyze, "  ")...)
+	} else if len(plan.joins) > 0 {
+		for _, j := range plan.joins {
+			lines = append(lines, "join:")
+			lines = append(lines, q.explainPlan(j, analyze, "  ")...)
+		}
+	} else {
+		lines = append(lines, q.explainMeasurements(stmt)...)
+	}
+
+	if plan.rank != nil {
+		lines = append(lines, fmt.Sprintf("series order: %s", plan.stmt.SeriesSortField()))
+		lines = append(lines, "rank:")
+		lines = append(lines, q.explainPlan(plan.rank, analyze, "  ")...)
+	}
+
+	if analyze {
+		lines = append(lines, fmt.Sprintf("reduce: %s", plan.executor.reduceTime))
+	}
+
+	for i := range lines {
+		lines[i] = indent + lines[i]
+	}
+	return lines
+}
+
+// explainMeasurements returns a line for each measurement read by stmt with the
+// number of tag sets and series selected, according to the local index.
+func (q *QueryExecutor) explainMeasurements(stmt *influxql.SelectStatement) []string {
+	var lines []string
+	seen := map[string]struct{}{}
+	for _, src := range stmt.Sources {
+		mm, ok := src.(*influxql.Measurement)
+		if !ok {
+			continue
+		} else if _, ok := seen[mm.Database]; ok {
+			continue
+		}
+		seen[mm.Database] = struct{}{}
+
+		db := q.Store.DatabaseIndex(mm.Database)
+		if db == nil {
+			lines = append(lines, fmt.Sprintf("database %s: not indexed on this node", mm.Database))
+			continue
+		}
+
+		s, err := db.RewriteSelectStatement(stmt.Clone())
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("database %s: %s", mm.Database, err))
+			continue
+		}
+
+		for _, m := range db.MeasurementsByName(s.SourceNames()) {
+			tagSets, err := m.DimensionTagSets(s)
+			if err != nil {
+				lines = append(lines, fmt.Sprintf("measurement %s: %s", m.Name, err))
+				continue
+			}
+			tagSets = s.LimitTagSets(tagSets)
+
+			var n int
+			for _, t := range tagSets {
+				n += len(t.SeriesKeys)
+			}
+			lines = append(lines, fmt.Sprintf("measurement %s: %d tag sets, %d series", m.Name, len(tagSets), n))
+		}
+	}
+	return lines
+}
+
+// fillString returns the fill option of stmt as written in a query.
+func fillString(stmt *influxql.SelectStatement) string {
+	switch stmt.Fill {
+	case influxql.NoFill:
+		return "none"
+	case influxql.NumberFill:
+		return fmt.Sprintf("%v", stmt.FillValue)
+	case influxql.PreviousFill:
+		return "previous"
+	case influxql.LinearFill:
+		return "linear"
+	}
+	return "null"
+}
+
+// shardOwnersString returns the comma-separated node IDs of the owners of a shard.
+func shardOwnersString(owners []meta.ShardOwner) string {
+	a := make([]string, len(owners))
+	for i, o := range owners {
+		a[i] = fmt.Sprintf("%d", o.NodeID)
+	}
+	return strings.Join(a, ", ")
+}
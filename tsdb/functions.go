@@ -7,7 +7,10 @@ package tsdb
 // When adding an aggregate function, define a mapper, a reducer, and add them in the switch statement in the MapreduceFuncs function
 
 import (
+	"bytes"
 	"container/heap"
+	"encoding"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -15,8 +18,11 @@ import (
 	"reflect"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/influxdb/influxdb/influxql"
+	"github.com/influxdb/influxdb/pkg/hll"
+	"github.com/influxdb/influxdb/pkg/tdigest"
 )
 
 type MapInput struct {
@@ -54,6 +60,15 @@ func initializeMapFunc(c *influxql.Call) (mapFunc, error) {
 		return MapRawQuery, nil
 	}
 
+	// Transformations are calculated by the executor. If the arg is another aggregate
+	// e.g. derivative(mean(value)), then use the map func for that nested aggregate.
+	if c.IsTransform() {
+		if fn, ok := c.Args[0].(*influxql.Call); ok {
+			return initializeMapFunc(fn)
+		}
+		return MapRawQuery, nil
+	}
+
 	// Retrieve map function by name.
 	switch c.Name {
 	case "count":
@@ -66,6 +81,8 @@ func initializeMapFunc(c *influxql.Call) (mapFunc, error) {
 			}
 		}
 		return MapCount, nil
+	case "approx_count_distinct":
+		return MapApproxCountDistinct, nil
 	case "distinct":
 		return MapDistinct, nil
 	case "sum":
@@ -106,13 +123,17 @@ func initializeMapFunc(c *influxql.Call) (mapFunc, error) {
 		}, nil
 	case "percentile":
 		return MapEcho, nil
-	case "derivative", "non_negative_derivative":
-		// If the arg is another aggregate e.g. derivative(mean(value)), then
-		// use the map func for that nested aggregate
-		if fn, ok := c.Args[0].(*influxql.Call); ok {
-			return initializeMapFunc(fn)
-		}
-		return MapRawQuery, nil
+	case "approx_percentile":
+		return MapApproxPercentile, nil
+	case "integral", "time_weighted_mean":
+		return MapIntegral, nil
+	case "histogram":
+		edges := histogramEdges(c)
+		return func(input *MapInput) interface{} {
+			return MapHistogram(input, edges)
+		}, nil
+	case "mode":
+		return MapMode, nil
 	default:
 		return nil, fmt.Errorf("function not found: %q", c.Name)
 	}
@@ -120,6 +141,15 @@ func initializeMapFunc(c *influxql.Call) (mapFunc, error) {
 
 // InitializereduceFunc takes an aggregate call from the query and returns the reduceFunc
 func initializeReduceFunc(c *influxql.Call) (reduceFunc, error) {
+	// Transformations are calculated by the executor. If the arg is another aggregate
+	// e.g. derivative(mean(value)), then use the reduce func for that nested aggregate.
+	if c.IsTransform() {
+		if fn, ok := c.Args[0].(*influxql.Call); ok {
+			return initializeReduceFunc(fn)
+		}
+		return nil, fmt.Errorf("expected function argument to %s", c.Name)
+	}
+
 	// Retrieve reduce function by name.
 	switch c.Name {
 	case "count":
@@ -132,6 +162,8 @@ func initializeReduceFunc(c *influxql.Call) (reduceFunc, error) {
 			}
 		}
 		return ReduceSum, nil
+	case "approx_count_distinct":
+		return ReduceApproxCountDistinct, nil
 	case "distinct":
 		return ReduceDistinct, nil
 	case "sum":
@@ -160,13 +192,20 @@ func initializeReduceFunc(c *influxql.Call) (reduceFunc, error) {
 		return func(values []interface{}) interface{} {
 			return ReducePercentile(values, c)
 		}, nil
-	case "derivative", "non_negative_derivative":
-		// If the arg is another aggregate e.g. derivative(mean(value)), then
-		// use the map func for that nested aggregate
-		if fn, ok := c.Args[0].(*influxql.Call); ok {
-			return initializeReduceFunc(fn)
-		}
-		return nil, fmt.Errorf("expected function argument to %s", c.Name)
+	case "approx_percentile":
+		return func(values []interface{}) interface{} {
+			return ReduceApproxPercentile(values, c)
+		}, nil
+	case "integral":
+		return func(values []interface{}) interface{} {
+			return ReduceIntegral(values, c)
+		}, nil
+	case "time_weighted_mean":
+		return ReduceTimeWeightedMean, nil
+	case "histogram":
+		return ReduceHistogram, nil
+	case "mode":
+		return ReduceMode, nil
 	default:
 		return nil, fmt.Errorf("function not found: %q", c.Name)
 	}
@@ -182,8 +221,20 @@ func InitializeUnmarshaller(c *influxql.Call) (UnmarshalFunc, error) {
 		}, nil
 	}
 
+	// Transformations over another aggregate e.g. derivative(mean(value)) carry
+	// the map output of that nested aggregate.
+	if c.IsTransform() {
+		if fn, ok := c.Args[0].(*influxql.Call); ok {
+			return InitializeUnmarshaller(fn)
+		}
+	}
+
 	// Retrieve marshal function by name
 	switch c.Name {
+	case "approx_count_distinct":
+		return binaryUnmarshaller(func() encoding.BinaryUnmarshaler { return &hll.Sketch{} }), nil
+	case "approx_percentile":
+		return binaryUnmarshaller(func() encoding.BinaryUnmarshaler { return &tdigest.Digest{} }), nil
 	case "mean":
 		return func(b []byte) (interface{}, error) {
 			var o meanMapOutput
@@ -196,6 +247,34 @@ func InitializeUnmarshaller(c *influxql.Call) (UnmarshalFunc, error) {
 			err := json.Unmarshal(b, &o)
 			return &o, err
 		}, nil
+	case "histogram":
+		return func(b []byte) (interface{}, error) {
+			var a []int64
+			err := json.Unmarshal(b, &a)
+			if a == nil {
+				return nil, err
+			}
+			return a, err
+		}, nil
+	case "mode":
+		return func(b []byte) (interface{}, error) {
+			var o modeMapOutput
+			err := json.Unmarshal(b, &o)
+			if o == nil {
+				return nil, err
+			}
+			return o, err
+		}, nil
+	case "integral", "time_weighted_mean":
+		return func(b []byte) (interface{}, error) {
+			// Mappers with no values return nil.
+			var o *integralMapOutput
+			err := json.Unmarshal(b, &o)
+			if o == nil {
+				return nil, err
+			}
+			return o, err
+		}, nil
 	case "distinct":
 		return func(b []byte) (interface{}, error) {
 			var val interfaceValues
@@ -235,6 +314,23 @@ func InitializeUnmarshaller(c *influxql.Call) (UnmarshalFunc, error) {
 	}
 }
 
+// binaryUnmarshaller returns an UnmarshalFunc for map outputs, such as sketches, that are
+// sent in their binary form. The output is decoded into the value returned by fn.
+func binaryUnmarshaller(fn func() encoding.BinaryUnmarshaler) UnmarshalFunc {
+	return func(b []byte) (interface{}, error) {
+		// Mappers with no values return nil instead of a sketch.
+		var data []byte
+		if err := json.Unmarshal(b, &data); err != nil || data == nil {
+			return nil, err
+		}
+		v := fn()
+		if err := v.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+}
+
 // MapCount computes the number of values in an iterator.
 func MapCount(input *MapInput) interface{} {
 	n := float64(0)
@@ -328,6 +424,63 @@ func MapCountDistinct(input *MapInput) interface{} {
 	return index
 }
 
+// MapApproxCountDistinct adds the values in an iterator to a HyperLogLog sketch.
+func MapApproxCountDistinct(input *MapInput) interface{} {
+	if len(input.Items) == 0 {
+		return nil
+	}
+
+	s := hll.NewDefaultSketch()
+	for _, item := range input.Items {
+		s.Add(distinctKey(item.Value))
+	}
+	return s
+}
+
+// ReduceApproxCountDistinct merges the sketches from each mapper and estimates the unique count of values.
+func ReduceApproxCountDistinct(values []interface{}) interface{} {
+	s := hll.NewDefaultSketch()
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		other, ok := v.(*hll.Sketch)
+		if !ok {
+			msg := fmt.Sprintf("expected *hll.Sketch, got: %T", v)
+			panic(msg)
+		}
+		if err := s.Merge(other); err != nil {
+			panic(err.Error())
+		}
+	}
+	return int64(s.Count())
+}
+
+// distinctKey returns the bytes of a value to add to a sketch. The type of the
+// value is included so values of different types are distinct as in count(distinct()).
+func distinctKey(v interface{}) []byte {
+	var b [9]byte
+	switch v := v.(type) {
+	case float64:
+		b[0] = 'f'
+		binary.BigEndian.PutUint64(b[1:], math.Float64bits(v))
+		return b[:]
+	case int64:
+		b[0] = 'i'
+		binary.BigEndian.PutUint64(b[1:], uint64(v))
+		return b[:]
+	case string:
+		return append([]byte{'s'}, v...)
+	case bool:
+		if v {
+			return []byte{'t'}
+		}
+		return []byte{'F'}
+	default:
+		return []byte(fmt.Sprintf("%T %v", v, v))
+	}
+}
+
 // ReduceCountDistinct finds the unique counts of values.
 func ReduceCountDistinct(values []interface{}) interface{} {
 	var index = make(map[interface{}]struct{})
@@ -896,6 +1049,254 @@ func ReduceSpread(values []interface{}) interface{} {
 	return nil
 }
 
+// integralMapOutput holds the area under the values of an interval, between its first
+// and last points. The boundary points are kept so the reducer can add the area between
+// the outputs of mappers, or chunks, reading consecutive parts of the same interval.
+type integralMapOutput struct {
+	First, Last integralPoint
+	Area        float64 // Trapezoidal area in value-nanoseconds.
+}
+
+type integralPoint struct {
+	Time  int64
+	Value float64
+}
+
+// MapIntegral computes the area under the values of an iterator.
+func MapIntegral(input *MapInput) interface{} {
+	var out *integralMapOutput
+	for _, item := range input.Items {
+		var p integralPoint
+		switch v := item.Value.(type) {
+		case float64:
+			p = integralPoint{Time: item.Timestamp, Value: v}
+		case int64:
+			p = integralPoint{Time: item.Timestamp, Value: float64(v)}
+		default:
+			continue
+		}
+
+		if out == nil {
+			out = &integralMapOutput{First: p, Last: p}
+			continue
+		}
+		out.Area += out.Last.area(p)
+		out.Last = p
+	}
+	if out == nil {
+		return nil
+	}
+	return out
+}
+
+// area returns the trapezoidal area between two points.
+func (p integralPoint) area(next integralPoint) float64 {
+	return (p.Value + next.Value) / 2 * float64(next.Time-p.Time)
+}
+
+// reduceIntegral combines the areas computed by the mappers, adding the area between
+// the last point of each output and the first point of the next one.
+func reduceIntegral(values []interface{}) *integralMapOutput {
+	var outputs []*integralMapOutput
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		outputs = append(outputs, v.(*integralMapOutput))
+	}
+	if len(outputs) == 0 {
+		return nil
+	}
+	sort.Sort(integralMapOutputs(outputs))
+
+	out := *outputs[0]
+	for _, o := range outputs[1:] {
+		out.Area += out.Last.area(o.First) + o.Area
+		out.Last = o.Last
+	}
+	return &out
+}
+
+type integralMapOutputs []*integralMapOutput
+
+func (a integralMapOutputs) Len() int           { return len(a) }
+func (a integralMapOutputs) Less(i, j int) bool { return a[i].First.Time < a[j].First.Time }
+func (a integralMapOutputs) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
+// ReduceIntegral computes the area under the values, in the unit of the call's second
+// argument. The unit defaults to a second.
+func ReduceIntegral(values []interface{}, c *influxql.Call) interface{} {
+	out := reduceIntegral(values)
+	if out == nil {
+		return nil
+	}
+
+	unit := time.Second
+	if len(c.Args) > 1 {
+		unit = c.Args[1].(*influxql.DurationLiteral).Val
+	}
+	return out.Area / float64(unit)
+}
+
+// ReduceTimeWeightedMean computes the mean of values weighted by the time between them.
+// Without any time between the first and last points, the first value is returned.
+func ReduceTimeWeightedMean(values []interface{}) interface{} {
+	out := reduceIntegral(values)
+	if out == nil {
+		return nil
+	} else if out.Last.Time == out.First.Time {
+		return out.First.Value
+	}
+	return out.Area / float64(out.Last.Time-out.First.Time)
+}
+
+// histogramEdges returns the bucket edges passed to a histogram call.
+func histogramEdges(c *influxql.Call) []float64 {
+	edges := make([]float64, 0, len(c.Args)-1)
+	for _, arg := range c.Args[1:] {
+		edges = append(edges, arg.(*influxql.NumberLiteral).Val)
+	}
+	return edges
+}
+
+// MapHistogram counts the values in an iterator falling into each bucket. For n edges
+// there are n+1 buckets, each including its lower edge, the first and last buckets
+// holding the values below the first edge and from the last edge on.
+func MapHistogram(input *MapInput, edges []float64) interface{} {
+	if len(input.Items) == 0 {
+		return nil
+	}
+
+	counts := make([]int64, len(edges)+1)
+	for _, item := range input.Items {
+		var v float64
+		switch val := item.Value.(type) {
+		case float64:
+			v = val
+		case int64:
+			v = float64(val)
+		default:
+			continue
+		}
+		counts[sort.Search(len(edges), func(i int) bool { return edges[i] > v })]++
+	}
+	return counts
+}
+
+// ReduceHistogram sums the bucket counts of each mapper.
+func ReduceHistogram(values []interface{}) interface{} {
+	var counts []int64
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		a := v.([]int64)
+		if counts == nil {
+			counts = make([]int64, len(a))
+		}
+		for i, n := range a {
+			counts[i] += n
+		}
+	}
+	if counts == nil {
+		return nil
+	}
+	return counts
+}
+
+// modeMapOutput counts the occurrences of each value. Values of different types are
+// counted separately, and keep their type when sent from a remote mapper.
+type modeMapOutput map[interface{}]int64
+
+type modeCount struct {
+	Type  influxql.DataType
+	Value json.RawMessage
+	Count int64
+}
+
+// MarshalJSON encodes the counts with the type of each value.
+func (o modeMapOutput) MarshalJSON() ([]byte, error) {
+	a := make([]modeCount, 0, len(o))
+	for v, n := range o {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, modeCount{Type: influxql.InspectDataType(v), Value: b, Count: n})
+	}
+	return json.Marshal(a)
+}
+
+// UnmarshalJSON decodes counts encoded by MarshalJSON.
+func (o *modeMapOutput) UnmarshalJSON(b []byte) error {
+	var a []modeCount
+	if err := json.Unmarshal(b, &a); err != nil || a == nil {
+		return err
+	}
+
+	*o = make(modeMapOutput, len(a))
+	for _, c := range a {
+		// Numbers are decoded as json.Number to be converted to the value's type.
+		var v interface{}
+		d := json.NewDecoder(bytes.NewReader(c.Value))
+		d.UseNumber()
+		if err := d.Decode(&v); err != nil {
+			return err
+		}
+
+		var err error
+		switch c.Type {
+		case influxql.Float:
+			v, err = v.(json.Number).Float64()
+		case influxql.Integer:
+			v, err = v.(json.Number).Int64()
+		case influxql.String, influxql.Boolean:
+		default:
+			err = fmt.Errorf("unexpected mode value type: %s", c.Type)
+		}
+		if err != nil {
+			return err
+		}
+		(*o)[v] += c.Count
+	}
+	return nil
+}
+
+// MapMode counts the occurrences of each value in an iterator.
+func MapMode(input *MapInput) interface{} {
+	if len(input.Items) == 0 {
+		return nil
+	}
+
+	out := make(modeMapOutput)
+	for _, item := range input.Items {
+		out[item.Value]++
+	}
+	return out
+}
+
+// ReduceMode returns the most frequent value. Ties are broken by the lowest value.
+func ReduceMode(values []interface{}) interface{} {
+	counts := make(modeMapOutput)
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		for value, n := range v.(modeMapOutput) {
+			counts[value] += n
+		}
+	}
+
+	var mode interface{}
+	var modeN int64
+	for value, n := range counts {
+		if n > modeN || (n == modeN && interfaceValues([]interface{}{value, mode}).Less(0, 1)) {
+			mode, modeN = value, n
+		}
+	}
+	return mode
+}
+
 // MapStddev collects the values to pass to the reducer
 func MapStddev(input *MapInput) interface{} {
 	var a []float64
@@ -1664,10 +2065,54 @@ func ReducePercentile(values []interface{}, c *influxql.Call) interface{} {
 	return allValues[index]
 }
 
+// MapApproxPercentile adds the values in an iterator to a t-digest.
+func MapApproxPercentile(input *MapInput) interface{} {
+	d := tdigest.NewDefault()
+	for _, item := range input.Items {
+		switch v := item.Value.(type) {
+		case int64:
+			d.Add(float64(v))
+		case float64:
+			d.Add(v)
+		}
+	}
+
+	if d.Count() == 0 {
+		return nil
+	}
+	return d
+}
+
+// ReduceApproxPercentile merges the t-digests from each mapper and estimates the percentile of the values.
+func ReduceApproxPercentile(values []interface{}, c *influxql.Call) interface{} {
+	// Checks that this arg exists and is a valid type are done in the parsing validation
+	// and have test coverage there
+	lit, _ := c.Args[1].(*influxql.NumberLiteral)
+	percentile := lit.Val
+
+	d := tdigest.NewDefault()
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		other, ok := v.(*tdigest.Digest)
+		if !ok {
+			msg := fmt.Sprintf("expected *tdigest.Digest, got: %T", v)
+			panic(msg)
+		}
+		d.Merge(other)
+	}
+
+	if d.Count() == 0 {
+		return nil
+	}
+	return d.Quantile(percentile / 100.0)
+}
+
 // IsNumeric returns whether a given aggregate can only be run on numeric fields.
 func IsNumeric(c *influxql.Call) bool {
 	switch c.Name {
-	case "count", "first", "last", "distinct":
+	case "count", "first", "last", "distinct", "elapsed", "approx_count_distinct", "mode":
 		return false
 	default:
 		return true
@@ -1,6 +1,8 @@
 package tsdb
 
 import (
+	"encoding/json"
+	"fmt"
 	"reflect"
 	"testing"
 	"time"
@@ -366,6 +368,266 @@ func TestReduceCountDistinctNil(t *testing.T) {
 	}
 }
 
+func TestMapApproxCountDistinct(t *testing.T) {
+	input := &MapInput{
+		Items: []MapItem{
+			{Timestamp: 1, Value: int64(1)},
+			{Timestamp: 2, Value: int64(1)},
+			{Timestamp: 3, Value: "1"},
+			{Timestamp: 4, Value: float64(1.0)},
+			{Timestamp: 5, Value: "1"},
+			{Timestamp: 6, Value: true},
+		},
+	}
+
+	if exp, got := int64(4), ReduceApproxCountDistinct([]interface{}{MapApproxCountDistinct(input)}); exp != got {
+		t.Errorf("Wrong count. exp %v got %v", exp, got)
+	}
+	if values := MapApproxCountDistinct(&MapInput{}); values != nil {
+		t.Errorf("Wrong values. exp nil got %v", spew.Sdump(values))
+	}
+}
+
+func TestReduceApproxCountDistinct(t *testing.T) {
+	newInput := func(min, max int) *MapInput {
+		input := &MapInput{}
+		for i := min; i < max; i++ {
+			input.Items = append(input.Items, MapItem{Timestamp: int64(i), Value: fmt.Sprintf("user%d", i)})
+		}
+		return input
+	}
+
+	// Sketches are merged across mappers.
+	got := ReduceApproxCountDistinct([]interface{}{MapApproxCountDistinct(newInput(0, 5000)), nil, MapApproxCountDistinct(newInput(2500, 10000))}).(int64)
+	if got < 9700 || got > 10300 {
+		t.Errorf("Wrong count. exp ~10000 got %v", got)
+	}
+
+	if got := ReduceApproxCountDistinct([]interface{}{nil}); got != int64(0) {
+		t.Errorf("Wrong count. exp 0 got %v", spew.Sdump(got))
+	}
+}
+
+// Ensure sketches can be sent from a remote mapper.
+func TestApproxCountDistinct_Unmarshal(t *testing.T) {
+	c := &influxql.Call{Name: "approx_count_distinct", Args: []influxql.Expr{&influxql.VarRef{Val: "field1"}}}
+	unmarshal, err := InitializeUnmarshaller(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := &MapInput{Items: []MapItem{{Timestamp: 1, Value: "a"}, {Timestamp: 2, Value: "b"}}}
+	b, err := json.Marshal(&MapperValue{Value: []interface{}{MapApproxCountDistinct(input), MapApproxCountDistinct(&MapInput{})}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mvj MapperValueJSON
+	if err := json.Unmarshal(b, &mvj); err != nil {
+		t.Fatal(err)
+	}
+	var values []interface{}
+	for _, data := range mvj.AggData {
+		v, err := unmarshal(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, v)
+	}
+
+	if values[1] != nil {
+		t.Errorf("Wrong value. exp nil got %v", spew.Sdump(values[1]))
+	} else if got := ReduceApproxCountDistinct(values); got != int64(2) {
+		t.Errorf("Wrong count. exp 2 got %v", got)
+	}
+}
+
+func TestReduceApproxPercentile(t *testing.T) {
+	c := &influxql.Call{Name: "approx_percentile", Args: []influxql.Expr{&influxql.VarRef{Val: "field1"}, &influxql.NumberLiteral{Val: 99}}}
+	newInput := func(min, max int) *MapInput {
+		input := &MapInput{}
+		for i := min; i < max; i++ {
+			input.Items = append(input.Items, MapItem{Timestamp: int64(i), Value: float64(i)})
+		}
+		return input
+	}
+
+	// Digests are merged across mappers.
+	got := ReduceApproxPercentile([]interface{}{MapApproxPercentile(newInput(0, 5000)), nil, MapApproxPercentile(newInput(5000, 10000))}, c).(float64)
+	if got < 9880 || got > 9920 {
+		t.Errorf("Wrong percentile. exp ~9900 got %v", got)
+	}
+
+	if got := ReduceApproxPercentile([]interface{}{MapApproxPercentile(&MapInput{})}, c); got != nil {
+		t.Errorf("Wrong percentile. exp nil got %v", spew.Sdump(got))
+	}
+}
+
+// Ensure digests can be sent from a remote mapper.
+func TestApproxPercentile_Unmarshal(t *testing.T) {
+	c := &influxql.Call{Name: "approx_percentile", Args: []influxql.Expr{&influxql.VarRef{Val: "field1"}, &influxql.NumberLiteral{Val: 50}}}
+	unmarshal, err := InitializeUnmarshaller(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := &MapInput{Items: []MapItem{{Timestamp: 1, Value: int64(1)}, {Timestamp: 2, Value: 2.0}, {Timestamp: 3, Value: int64(3)}}}
+	b, err := json.Marshal(&MapperValue{Value: []interface{}{MapApproxPercentile(input)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mvj MapperValueJSON
+	if err := json.Unmarshal(b, &mvj); err != nil {
+		t.Fatal(err)
+	}
+	v, err := unmarshal(mvj.AggData[0])
+	if err != nil {
+		t.Fatal(err)
+	} else if got := ReduceApproxPercentile([]interface{}{v}, c); got != 2.0 {
+		t.Errorf("Wrong percentile. exp 2 got %v", got)
+	}
+}
+
+func TestReduceIntegral(t *testing.T) {
+	c := &influxql.Call{Name: "integral", Args: []influxql.Expr{&influxql.VarRef{Val: "field1"}, &influxql.DurationLiteral{Val: time.Minute}}}
+	s := int64(time.Second)
+
+	// Irregular points, split across mappers, are stitched together at their boundaries.
+	a := MapIntegral(&MapInput{Items: []MapItem{{Timestamp: 0, Value: 10.0}, {Timestamp: 30 * s, Value: int64(20)}}})
+	b := MapIntegral(&MapInput{Items: []MapItem{{Timestamp: 40 * s, Value: 20.0}, {Timestamp: 120 * s, Value: 0.0}}})
+
+	// 30s*15 + 10s*20 + 80s*10 = 1450 value-seconds.
+	if got := ReduceIntegral([]interface{}{b, nil, a}, c); got != 1450.0/60 {
+		t.Errorf("Wrong integral. exp %v got %v", 1450.0/60, got)
+	}
+	if got := ReduceTimeWeightedMean([]interface{}{b, nil, a}); got != 1450.0/120 {
+		t.Errorf("Wrong time weighted mean. exp %v got %v", 1450.0/120, got)
+	}
+
+	// A single point has no area but is its own mean.
+	a = MapIntegral(&MapInput{Items: []MapItem{{Timestamp: 10 * s, Value: 5.0}}})
+	if got := ReduceIntegral([]interface{}{a}, c); got != 0.0 {
+		t.Errorf("Wrong integral. exp 0 got %v", got)
+	} else if got := ReduceTimeWeightedMean([]interface{}{a}); got != 5.0 {
+		t.Errorf("Wrong time weighted mean. exp 5 got %v", got)
+	}
+
+	if got := ReduceIntegral([]interface{}{MapIntegral(&MapInput{})}, c); got != nil {
+		t.Errorf("Wrong integral. exp nil got %v", spew.Sdump(got))
+	}
+}
+
+// Ensure boundary points are sent from a remote mapper.
+func TestIntegral_Unmarshal(t *testing.T) {
+	c := &influxql.Call{Name: "time_weighted_mean", Args: []influxql.Expr{&influxql.VarRef{Val: "field1"}}}
+	unmarshal, err := InitializeUnmarshaller(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := &MapInput{Items: []MapItem{{Timestamp: 0, Value: 1.0}, {Timestamp: 3, Value: 1.0}, {Timestamp: 4, Value: 5.0}}}
+	b, err := json.Marshal(&MapperValue{Value: []interface{}{MapIntegral(input), nil}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mvj MapperValueJSON
+	if err := json.Unmarshal(b, &mvj); err != nil {
+		t.Fatal(err)
+	}
+	v, err := unmarshal(mvj.AggData[0])
+	if err != nil {
+		t.Fatal(err)
+	} else if got := ReduceTimeWeightedMean([]interface{}{v}); got != 1.5 {
+		t.Errorf("Wrong time weighted mean. exp 1.5 got %v", got)
+	}
+
+	if v, err := unmarshal(mvj.AggData[1]); err != nil || v != nil {
+		t.Errorf("unexpected output: %v, %v", v, err)
+	}
+}
+
+func TestReduceHistogram(t *testing.T) {
+	edges := []float64{10, 100}
+	a := MapHistogram(&MapInput{Items: []MapItem{{Timestamp: 1, Value: 5.0}, {Timestamp: 2, Value: int64(10)}, {Timestamp: 3, Value: 99.9}}}, edges)
+	b := MapHistogram(&MapInput{Items: []MapItem{{Timestamp: 4, Value: 100.0}, {Timestamp: 5, Value: -1.0}}}, edges)
+
+	// Buckets include their lower edge.
+	if got, exp := ReduceHistogram([]interface{}{a, nil, b}), []int64{2, 2, 1}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("Wrong histogram. exp %v got %v", exp, got)
+	}
+	if got := ReduceHistogram([]interface{}{MapHistogram(&MapInput{}, edges)}); got != nil {
+		t.Errorf("Wrong histogram. exp nil got %v", spew.Sdump(got))
+	}
+}
+
+func TestReduceMode(t *testing.T) {
+	newInput := func(values ...interface{}) *MapInput {
+		input := &MapInput{}
+		for i, v := range values {
+			input.Items = append(input.Items, MapItem{Timestamp: int64(i), Value: v})
+		}
+		return input
+	}
+
+	for i, tt := range []struct {
+		inputs []*MapInput
+		exp    interface{}
+	}{
+		{inputs: []*MapInput{newInput("a", "b", "b"), newInput("a", "a")}, exp: "a"},
+		{inputs: []*MapInput{newInput(true, false), newInput(true)}, exp: true},
+		{inputs: []*MapInput{newInput(int64(1), 2.0, 1.0), newInput(int64(1))}, exp: int64(1)},
+		{inputs: []*MapInput{newInput(3.0, 2.0), newInput(3.0, 2.0)}, exp: 2.0}, // Ties return the lowest value.
+		{inputs: []*MapInput{newInput()}, exp: nil},
+	} {
+		var values []interface{}
+		for _, input := range tt.inputs {
+			values = append(values, MapMode(input))
+		}
+		if got := ReduceMode(values); got != tt.exp {
+			t.Errorf("%d. Wrong mode. exp %#v got %#v", i, tt.exp, got)
+		}
+	}
+}
+
+// Ensure histograms and value counts are sent from a remote mapper.
+func TestHistogramMode_Unmarshal(t *testing.T) {
+	input := &MapInput{Items: []MapItem{{Timestamp: 1, Value: int64(1) << 60}, {Timestamp: 2, Value: 2.0}, {Timestamp: 3, Value: int64(1) << 60}}}
+	b, err := json.Marshal(&MapperValue{Value: []interface{}{MapHistogram(input, []float64{3}), MapMode(input), MapMode(&MapInput{})}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mvj MapperValueJSON
+	if err := json.Unmarshal(b, &mvj); err != nil {
+		t.Fatal(err)
+	}
+
+	unmarshal, err := InitializeUnmarshaller(&influxql.Call{Name: "histogram", Args: []influxql.Expr{&influxql.VarRef{Val: "field1"}, &influxql.NumberLiteral{Val: 3}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := unmarshal(mvj.AggData[0]); err != nil {
+		t.Fatal(err)
+	} else if got, exp := ReduceHistogram([]interface{}{v}), []int64{1, 2}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("Wrong histogram. exp %v got %v", exp, got)
+	}
+
+	unmarshal, err = InitializeUnmarshaller(&influxql.Call{Name: "mode", Args: []influxql.Expr{&influxql.VarRef{Val: "field1"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := unmarshal(mvj.AggData[1]); err != nil {
+		t.Fatal(err)
+	} else if got := ReduceMode([]interface{}{v}); got != int64(1)<<60 {
+		t.Errorf("Wrong mode. exp %d got %#v", int64(1)<<60, got)
+	}
+	if v, err := unmarshal(mvj.AggData[2]); err != nil || v != nil {
+		t.Errorf("unexpected output: %v, %v", v, err)
+	}
+}
+
 var getSortedRangeData = []float64{
 	60, 61, 62, 63, 64, 65, 66, 67, 68, 69,
 	20, 21, 22, 23, 24, 25, 26, 27, 28, 29,
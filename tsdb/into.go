@@ -0,0 +1,157 @@
+package tsdb
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/influxdb/influxdb/influxql"
+	"github.com/influxdb/influxdb/models"
+)
+
+// IntoWriteRequest holds the points written by a SELECT ... INTO statement.
+type IntoWriteRequest struct {
+	Database        string
+	RetentionPolicy string
+	Points          []models.Point
+}
+
+// executeSelectIntoStatement executes a SELECT ... INTO statement, writing its rows into
+// the target measurement. A single row holding the number of points written is returned.
+func (q *QueryExecutor) executeSelectIntoStatement(statementID int, stmt *influxql.SelectStatement, results chan *influxql.Result, closing <-chan struct{}) error {
+	if q.IntoWriter == nil {
+		return errors.New("SELECT INTO is not supported")
+	}
+
+	// Mappers replace regex sources by the measurements they match, so keep the
+	// sources the target's capture groups are read from.
+	sources := stmt.Sources
+
+	e, err := q.PlanSelect(stmt, IgnoredChunkSize)
+	if err != nil {
+		return err
+	}
+
+	var points []models.Point
+	for row := range e.Execute(closing) {
+		if row.Err != nil {
+			return row.Err
+		}
+
+		part, err := ConvertRowToPoints(stmt.Target.MeasurementName(row.Name, sources), row)
+		if err != nil {
+			return err
+		}
+		points = append(points, part...)
+	}
+
+	if len(points) > 0 {
+		if err := q.IntoWriter.WritePointsInto(&IntoWriteRequest{
+			Database:        stmt.Target.Measurement.Database,
+			RetentionPolicy: stmt.Target.Measurement.RetentionPolicy,
+			Points:          points,
+		}); err != nil {
+			return err
+		}
+	}
+
+	results <- &influxql.Result{
+		StatementID: statementID,
+		Series: []*models.Row{{
+			Name:    "result",
+			Columns: []string{"time", "written"},
+			Values:  [][]interface{}{{time.Unix(0, 0).UTC(), int64(len(points))}},
+		}},
+	}
+	return nil
+}
+
+// ConvertRowToPoints converts a query result row into points written into measurementName.
+// Nil values are not written, and a point is only created for values with a field.
+func ConvertRowToPoints(measurementName string, row *models.Row) ([]models.Point, error) {
+	// Figure out which parts of the result are the time and which are the fields.
+	timeIndex := -1
+	fieldIndexes := make(map[string]int)
+	for i, c := range row.Columns {
+		if c == "time" {
+			timeIndex = i
+		} else {
+			fieldIndexes[c] = i
+		}
+	}
+
+	if timeIndex == -1 {
+		return nil, errors.New("error finding time index in result")
+	}
+
+	points := make([]models.Point, 0, len(row.Values))
+	for _, v := range row.Values {
+		vals := make(map[string]interface{})
+		for fieldName, fieldIndex := range fieldIndexes {
+			if v[fieldIndex] != nil {
+				vals[fieldName] = v[fieldIndex]
+			}
+		}
+		if len(vals) == 0 {
+			continue
+		}
+
+		t, ok := resultTime(v[timeIndex])
+		if !ok {
+			return nil, errors.New("error reading time in result")
+		}
+		points = append(points, models.NewPoint(measurementName, row.Tags, vals, time.Unix(0, t)))
+	}
+
+	return points, nil
+}
+
+// expandWildcardCalls returns a copy of stmt in which calls on every field, like mean(*),
+// are replaced by a call on each field of the measurements selected, according to the
+// local index. Numeric functions are only called on numeric fields.
+func (q *QueryExecutor) expandWildcardCalls(stmt *influxql.SelectStatement) (*influxql.SelectStatement, error) {
+	types := make(map[string]influxql.DataType)
+	for _, src := range stmt.Sources {
+		mm, ok := src.(*influxql.Measurement)
+		if !ok {
+			return nil, errors.New("wildcard calls can only select from measurements")
+		}
+
+		db := q.Store.DatabaseIndex(mm.Database)
+		if db == nil {
+			continue
+		}
+		sources, err := db.ExpandSources(influxql.Sources{mm})
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range sources {
+			for name, t := range q.Store.FieldTypes(mm.Database, s.(*influxql.Measurement).Name) {
+				if _, ok := types[name]; !ok {
+					types[name] = t
+				}
+			}
+		}
+	}
+
+	var err error
+	other := stmt.RewriteWildcardCalls(func(c *influxql.Call) []string {
+		var names []string
+		for name, t := range types {
+			if IsNumeric(c) && t != influxql.Float && t != influxql.Integer {
+				continue
+			}
+			names = append(names, name)
+		}
+		if len(names) == 0 && err == nil {
+			err = fmt.Errorf("%s(*) matches no fields", c.Name)
+		}
+		sort.Strings(names)
+		return names
+	})
+	if err != nil {
+		return nil, err
+	}
+	return other, nil
+}
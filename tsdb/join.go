@@ -0,0 +1,207 @@
+package tsdb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/influxdb/influxdb/influxql"
+	"github.com/influxdb/influxdb/models"
+)
+
+// JoinExecutor combines the rows returned by the executors of several statements.
+// Rows with the same tags and time are joined into a single row holding the columns
+// of every statement, missing values being nil.
+type JoinExecutor struct {
+	name      string
+	executors []Executor
+	columns   []string // Columns of the joined rows, excluding time.
+
+	// Rows of aggregates not grouped by time are joined on their tags only,
+	// and returned at tmin.
+	alignTime bool
+	tmin      int64
+}
+
+// joinTagSet holds the joined rows of a single tagset, keyed by time.
+type joinTagSet struct {
+	tags   map[string]string
+	values map[int64][]interface{}
+}
+
+// Execute runs each executor in turn and returns the joined rows, ordered by tagset and time.
+func (e *JoinExecutor) Execute(closing <-chan struct{}) <-chan *models.Row {
+	out := make(chan *models.Row, 0)
+	go e.execute(out, closing)
+	return out
+}
+
+func (e *JoinExecutor) execute(out chan *models.Row, closing <-chan struct{}) {
+	defer close(out)
+
+	index := make(map[string]int, len(e.columns))
+	for i, c := range e.columns {
+		index[c] = i + 1
+	}
+
+	set := make(map[string]*joinTagSet)
+	for _, ex := range e.executors {
+		for row := range ex.Execute(closing) {
+			if row.Err != nil {
+				out <- &models.Row{Err: row.Err}
+				return
+			}
+
+			key := string(MarshalTags(row.Tags))
+			ts := set[key]
+			if ts == nil {
+				ts = &joinTagSet{tags: row.Tags, values: make(map[int64][]interface{})}
+				set[key] = ts
+			}
+
+			for _, values := range row.Values {
+				t, ok := resultTime(values[0])
+				if !ok {
+					out <- &models.Row{Err: errors.New("joined statement returned a row without a time")}
+					return
+				} else if !e.alignTime {
+					t = e.tmin
+				}
+
+				v := ts.values[t]
+				if v == nil {
+					v = make([]interface{}, len(e.columns)+1)
+					v[0] = time.Unix(0, t).UTC()
+					ts.values[t] = v
+				}
+				for i, c := range row.Columns[1:] {
+					if j, ok := index[c]; ok && values[i+1] != nil {
+						v[j] = values[i+1]
+					}
+				}
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		ts := set[k]
+		times := make([]int64, 0, len(ts.values))
+		for t := range ts.values {
+			times = append(times, t)
+		}
+		sort.Sort(int64arr(times))
+
+		row := &models.Row{
+			Name:    e.name,
+			Tags:    ts.tags,
+			Columns: append([]string{"time"}, e.columns...),
+		}
+		for _, t := range times {
+			row.Values = append(row.Values, ts.values[t])
+		}
+		out <- row
+	}
+}
+
+// close closes the executors of the joined statements.
+func (e *JoinExecutor) close() {
+	for _, ex := range e.executors {
+		if ex, ok := ex.(*SelectExecutor); ok {
+			ex.close()
+		}
+	}
+}
+
+// planJoin creates an execution plan for a SelectStatement joining several measurements.
+// Each measurement is selected by a statement of its own and their rows, joined on tags
+// and time, are mapped for a raw statement evaluating the fields of the join.
+func (q *QueryExecutor) planJoin(plan *selectPlan, chunkSize int) error {
+	stmt := plan.stmt
+	outer, inners := stmt.RewriteJoin()
+
+	interval, err := stmt.GroupByInterval()
+	if err != nil {
+		return err
+	}
+
+	e := &JoinExecutor{
+		name:      strings.Join(stmt.SourceNames(), "_"),
+		alignTime: stmt.IsRawQuery || interval > 0,
+		tmin:      plan.tmin.UnixNano(),
+	}
+	for _, inner := range inners {
+		for _, f := range inner.Fields {
+			e.columns = append(e.columns, f.Alias)
+		}
+
+		closeJoins := func() {
+			for _, j := range plan.joins {
+				j.close()
+			}
+		}
+		if err := q.checkJoinSeries(inner); err != nil {
+			closeJoins()
+			return err
+		}
+		p, err := q.planSelect(inner, IgnoredChunkSize)
+		if err != nil {
+			closeJoins()
+			return err
+		}
+		plan.joins = append(plan.joins, p)
+		e.executors = append(e.executors, p.executor)
+	}
+
+	// The joined statements are already restricted to the time range of the join.
+	m := NewSubQueryMapper(outer, e, math.MinInt64, math.MaxInt64, chunkSize)
+	plan.executor = NewSelectExecutor(outer, []Mapper{m}, chunkSize)
+	return nil
+}
+
+// checkJoinSeries returns an error if a raw statement of a join would return the points
+// of several series in the same tagset. Rows are joined on their tags and time, so points
+// of different series at the same time would overwrite each other.
+func (q *QueryExecutor) checkJoinSeries(stmt *influxql.SelectStatement) error {
+	if !stmt.IsRawQuery {
+		return nil
+	}
+
+	sources, err := q.expandSources(stmt.Sources)
+	if err != nil {
+		return err
+	}
+	for _, src := range sources {
+		mm, ok := src.(*influxql.Measurement)
+		if !ok {
+			continue
+		}
+		index := q.Store.DatabaseIndex(mm.Database)
+		if index == nil {
+			continue
+		}
+		m := index.Measurement(mm.Name)
+		if m == nil {
+			continue
+		}
+
+		tagSets, err := m.DimensionTagSets(stmt)
+		if err != nil {
+			return err
+		}
+		for _, t := range tagSets {
+			if len(t.SeriesKeys) > 1 {
+				return fmt.Errorf("cannot join several series of %s in the same group, GROUP BY the tags which tell them apart", mm.Name)
+			}
+		}
+	}
+	return nil
+}
@@ -2,10 +2,12 @@ package tsdb
 
 import (
 	"container/heap"
+	"encoding"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/influxdb/influxdb/influxql"
 	"github.com/influxdb/influxdb/pkg/slices"
@@ -20,17 +22,39 @@ type Mapper interface {
 	Close()
 }
 
+// Interrupter is implemented by mappers which can stop a call to Open or NextChunk
+// that is in progress from another goroutine, such as mappers streaming from another node.
+// Calls in progress, and any later calls, return an error once a mapper is interrupted.
+type Interrupter interface {
+	Interrupt()
+}
+
 // StatefulMapper encapsulates a Mapper and some state that the executor needs to
 // track for that mapper.
 type StatefulMapper struct {
 	Mapper
 	bufferedChunk *MapperOutput // Last read chunk.
 	drained       bool
+
+	// Time spent opening the mapper and reading chunks, reported by EXPLAIN ANALYZE.
+	openTime  time.Duration
+	chunkTime time.Duration
+	chunkN    int
+	valueN    int
+}
+
+// Open opens the mapper, tracking the time taken.
+func (sm *StatefulMapper) Open() error {
+	start := time.Now()
+	defer func() { sm.openTime += time.Since(start) }()
+	return sm.Mapper.Open()
 }
 
 // NextChunk wraps a RawMapper and some state.
 func (sm *StatefulMapper) NextChunk() (*MapperOutput, error) {
+	start := time.Now()
 	c, err := sm.Mapper.NextChunk()
+	sm.chunkTime += time.Since(start)
 	if err != nil {
 		return nil, err
 	}
@@ -40,6 +64,10 @@ func (sm *StatefulMapper) NextChunk() (*MapperOutput, error) {
 			return nil, nil
 		}
 	}
+	if chunk != nil {
+		sm.chunkN++
+		sm.valueN += len(chunk.Values)
+	}
 	return chunk, nil
 }
 
@@ -77,6 +105,15 @@ func (mv *MapperValue) MarshalJSON() ([]byte, error) {
 		// Value contain a slice of more values. This happens only with
 		// aggregate output.
 		for _, v := range values {
+			// Values such as sketches are sent in their compact binary form.
+			if m, ok := v.(encoding.BinaryMarshaler); ok {
+				data, err := m.MarshalBinary()
+				if err != nil {
+					return nil, err
+				}
+				v = data
+			}
+
 			b, err := json.Marshal(v)
 			if err != nil {
 				return nil, err
@@ -150,8 +187,10 @@ type RawMapper struct {
 	selectFields []string
 	selectTags   []string
 	whereFields  []string
+	seriesN      int // Number of series read across all measurements.
 
-	ChunkSize int
+	ChunkSize  int
+	MaxSeriesN int // Maximum number of series that may be read. Zero means no limit.
 }
 
 // NewRawMapper returns a new instance of RawMapper.
@@ -230,6 +269,11 @@ func (m *RawMapper) openMeasurement(mm *Measurement) error {
 	}
 	tagSets = m.stmt.LimitTagSets(tagSets)
 
+	// Fail before opening any cursors if too many series would be read.
+	if m.seriesN, err = checkSeriesN(tagSets, m.seriesN, m.MaxSeriesN); err != nil {
+		return err
+	}
+
 	// Create all cursors for reading the data from this shard.
 	ascending := m.stmt.TimeAscending()
 	for _, t := range tagSets {
@@ -337,6 +381,7 @@ type AggregateMapper struct {
 	intervalN    int   // Maximum number of intervals to return.
 	intervalSize int64 // Size of each interval.
 	qminWindow   int64 // Minimum time of the query floored to start of interval.
+	window       groupByWindow
 
 	mapFuncs   []mapFunc // The mapping functions.
 	fieldNames []string  // the field name being read for mapping.
@@ -344,6 +389,9 @@ type AggregateMapper struct {
 	selectFields []string
 	selectTags   []string
 	whereFields  []string
+	seriesN      int // Number of series read across all measurements.
+
+	MaxSeriesN int // Maximum number of series that may be read. Zero means no limit.
 }
 
 // NewAggregateMapper returns a new instance of AggregateMapper.
@@ -376,19 +424,17 @@ func (m *AggregateMapper) Open() error {
 	}
 
 	// For GROUP BY time queries, limit the number of data points returned by the limit and offset
-	d, err := m.stmt.GroupByInterval()
+	m.window, err = newGroupByWindow(m.stmt)
 	if err != nil {
 		return err
 	}
 
-	m.intervalSize = d.Nanoseconds()
+	m.intervalSize = m.window.size
 	if m.qmin == 0 || m.intervalSize == 0 {
 		m.intervalN = 1
 		m.intervalSize = m.qmax - m.qmin
 	} else {
-		intervalTop := m.qmax/m.intervalSize*m.intervalSize + m.intervalSize
-		intervalBottom := m.qmin / m.intervalSize * m.intervalSize
-		m.intervalN = int((intervalTop - intervalBottom) / m.intervalSize)
+		m.intervalN = int(m.window.index(m.qmax) - m.window.index(m.qmin) + 1)
 	}
 
 	if m.stmt.Limit > 0 || m.stmt.Offset > 0 {
@@ -412,7 +458,7 @@ func (m *AggregateMapper) Open() error {
 	// Ensure that the start time for the results is on the start of the window.
 	m.qminWindow = m.qmin
 	if m.intervalSize > 0 && m.intervalN > 1 {
-		m.qminWindow = m.qminWindow / m.intervalSize * m.intervalSize
+		m.qminWindow = m.window.start(m.window.index(m.qmin))
 	}
 
 	// Get a read-only transaction.
@@ -460,6 +506,11 @@ func (m *AggregateMapper) openMeasurement(mm *Measurement) error {
 	}
 	tagSets = m.stmt.LimitTagSets(tagSets)
 
+	// Fail before opening any cursors if too many series would be read.
+	if m.seriesN, err = checkSeriesN(tagSets, m.seriesN, m.MaxSeriesN); err != nil {
+		return err
+	}
+
 	// Create all cursors for reading the data from this shard.
 	for _, t := range tagSets {
 		cursors := []*TagsCursor{}
@@ -633,18 +684,92 @@ func (m *AggregateMapper) NextChunk() (interface{}, error) {
 // nextInterval returns the next interval for which to return data.
 // If start is less than 0 there are no more intervals.
 func (m *AggregateMapper) nextInterval() (start, end int64) {
-	t := m.qminWindow + int64(m.interval+m.stmt.Offset)*m.intervalSize
+	var t, u int64
+	if m.intervalN > 1 {
+		// Windows are aligned, so take the boundaries from the window as they may
+		// not be a fixed size apart in the statement's time zone.
+		n := m.window.index(m.qminWindow) + int64(m.interval+m.stmt.Offset)
+		t, u = m.window.start(n), m.window.start(n+1)
+	} else {
+		t = m.qminWindow + int64(m.interval+m.stmt.Offset)*m.intervalSize
+		u = t + m.intervalSize
+	}
 
 	// On to next interval.
 	m.interval++
 	if t > m.qmax || m.interval > m.intervalN {
 		start, end = -1, 1
 	} else {
-		start, end = t, t+m.intervalSize
+		start, end = t, u
 	}
 	return
 }
 
+// groupByWindow calculates the boundaries of GROUP BY time() windows. Windows
+// are shifted by the statement's offset and aligned to its time zone.
+type groupByWindow struct {
+	size   int64 // nominal size of each window
+	offset int64 // offset of the windows from the epoch
+	loc    *time.Location
+}
+
+// newGroupByWindow returns the GROUP BY time() windows of stmt.
+func newGroupByWindow(stmt *influxql.SelectStatement) (groupByWindow, error) {
+	d, err := stmt.GroupByInterval()
+	if err != nil {
+		return groupByWindow{}, err
+	}
+	offset, err := stmt.GroupByOffset()
+	if err != nil {
+		return groupByWindow{}, err
+	}
+	return groupByWindow{size: d.Nanoseconds(), offset: offset.Nanoseconds(), loc: stmt.Location}, nil
+}
+
+// zone returns the offset of the window's time zone from UTC at t.
+func (w groupByWindow) zone(t int64) int64 {
+	if w.loc == nil {
+		return 0
+	}
+	_, offset := time.Unix(0, t).In(w.loc).Zone()
+	return int64(offset) * int64(time.Second)
+}
+
+// index returns the number of the window containing t.
+func (w groupByWindow) index(t int64) int64 {
+	t += w.zone(t) - w.offset
+	n := t / w.size
+	if t < 0 && t%w.size != 0 {
+		n--
+	}
+	return n
+}
+
+// start returns the start time of window n.
+func (w groupByWindow) start(n int64) int64 {
+	local := n*w.size + w.offset
+	t := local - w.zone(local)
+
+	// The zone may be different at the start of the window than at the local
+	// time, such as when the window starts just after a daylight saving change.
+	if zone := w.zone(t); zone != w.zone(local) {
+		t = local - zone
+	}
+	return t
+}
+
+// checkSeriesN adds the number of series in tagSets to n, the number of series
+// already read. Returns an error if the total is more than max, unless max is zero.
+func checkSeriesN(tagSets []*influxql.TagSet, n, max int) (int, error) {
+	for _, t := range tagSets {
+		n += len(t.SeriesKeys)
+	}
+	if max > 0 && n > max {
+		return n, ErrMaxSelectSeriesExceeded(n, max)
+	}
+	return n, nil
+}
+
 // uniqueStrings returns a slice of unique strings from all lists in a.
 func uniqueStrings(a ...[]string) []string {
 	// Calculate unique set of strings.
@@ -11,6 +11,7 @@ import (
 	"github.com/influxdb/influxdb/influxql"
 	"github.com/influxdb/influxdb/pkg/escape"
 	"github.com/influxdb/influxdb/tsdb/internal"
+	"github.com/influxdb/influxdb/tsdb/seriesindex"
 
 	"github.com/gogo/protobuf/proto"
 )
@@ -29,6 +30,15 @@ type DatabaseIndex struct {
 	measurements map[string]*Measurement // measurement name to object and index
 	series       map[string]*Series      // map series key to the Series object
 	lastID       uint64                  // last used series ID. They're in memory only for this shard
+
+	// disk-backed index of the series, nil if the series are only indexed in memory. The
+	// series of a measurement are then only loaded in memory while queries use them.
+	disk             *seriesindex.Index
+	maxLoadedSeriesN int                     // evict measurements once more series are loaded, 0 for no limit
+	loadedSeriesN    int64                   // number of series loaded in memory, updated atomically
+	evicting         int32                   // non-zero while measurements are evicted
+	shardSeries      map[uint64]seriesBitset // ids of the series persisted by each shard
+	indexedShards    map[uint64]bool         // shards whose series are all in the index, true while open
 }
 
 func NewDatabaseIndex() *DatabaseIndex {
@@ -42,11 +52,17 @@ func NewDatabaseIndex() *DatabaseIndex {
 func (d *DatabaseIndex) Series(key string) *Series {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
+	if d.disk != nil {
+		return d.diskSeries(key)
+	}
 	return d.series[key]
 }
 
 // SeriesN returns the number of series.
 func (d *DatabaseIndex) SeriesN() int {
+	if d.disk != nil {
+		return d.disk.SeriesN()
+	}
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 	return len(d.series)
@@ -79,15 +95,22 @@ func (d *DatabaseIndex) MeasurementSeriesCounts() (nMeasurements int, nSeries in
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 	nMeasurements, nSeries = len(d.measurements), len(d.series)
+	if d.disk != nil {
+		nSeries = d.disk.SeriesN()
+	}
 	return
 }
 
 // CreateSeriesIndexIfNotExists adds the series for the given measurement to the index and sets its ID or returns the existing series object
-func (s *DatabaseIndex) CreateSeriesIndexIfNotExists(measurementName string, series *Series) *Series {
+func (s *DatabaseIndex) CreateSeriesIndexIfNotExists(measurementName string, series *Series) (*Series, error) {
+	if s.disk != nil {
+		return s.createDiskSeriesIfNotExists(measurementName, series)
+	}
+
 	// if there is a measurement for this id, it's already been added
 	ss := s.series[series.Key]
 	if ss != nil {
-		return ss
+		return ss, nil
 	}
 
 	// get or create the measurement index
@@ -102,7 +125,7 @@ func (s *DatabaseIndex) CreateSeriesIndexIfNotExists(measurementName string, ser
 
 	m.AddSeries(series)
 
-	return series
+	return series, nil
 }
 
 // CreateMeasurementIndexIfNotExists creates or retrieves an in memory index object for the measurement
@@ -120,7 +143,12 @@ func (s *DatabaseIndex) CreateMeasurementIndexIfNotExists(name string) *Measurem
 func (s *DatabaseIndex) TagsForSeries(key string) map[string]string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	ss := s.series[key]
+	var ss *Series
+	if s.disk != nil {
+		ss = s.diskSeries(key)
+	} else {
+		ss = s.series[key]
+	}
 	if ss == nil {
 		return nil
 	}
@@ -203,7 +231,7 @@ func (db *DatabaseIndex) measurementsByTagFilters(filters []*TagFilter) Measurem
 	for _, m := range db.measurements {
 		// Iterate filters seeing if the measurement has a matching tag.
 		for _, f := range filters {
-			tagVals, ok := m.seriesByTagKeyValue[f.Key]
+			tagVals, ok := m.tagValueSet(f.Key)
 			if !ok {
 				continue
 			}
@@ -267,25 +295,35 @@ func (db *DatabaseIndex) Measurements() Measurements {
 }
 
 // DropMeasurement removes the measurement and all of its underlying series from the database index
-func (db *DatabaseIndex) DropMeasurement(name string) {
+func (db *DatabaseIndex) DropMeasurement(name string) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
 	m := db.measurements[name]
 	if m == nil {
-		return
+		return nil
+	}
+
+	if db.disk != nil {
+		return db.dropDiskMeasurement(m)
 	}
 
 	delete(db.measurements, name)
 	for _, s := range m.seriesByID {
 		delete(db.series, s.Key)
 	}
+	return nil
 }
 
 // DropSeries removes the series keys and their tags from the index
-func (db *DatabaseIndex) DropSeries(keys []string) {
+func (db *DatabaseIndex) DropSeries(keys []string) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
+
+	if db.disk != nil {
+		return db.dropDiskSeries(keys)
+	}
+
 	for _, k := range keys {
 		series := db.series[k]
 		if series == nil {
@@ -294,6 +332,7 @@ func (db *DatabaseIndex) DropSeries(keys []string) {
 		series.measurement.DropSeries(series.id)
 		delete(db.series, k)
 	}
+	return nil
 }
 
 // RewriteSelectStatement performs any necessary query re-writing.
@@ -446,6 +485,10 @@ type Measurement struct {
 	measurement         *Measurement
 	seriesByTagKeyValue map[string]map[string]SeriesIDs // map from tag key to value to sorted set of series ids
 	seriesIDs           SeriesIDs                       // sorted list of series IDs in this measurement
+
+	// disk-backed index fields
+	loaded   bool  // true once the series are loaded from the disk-backed index
+	lastUsed int64 // time the series were last used by a query, updated atomically
 }
 
 // NewMeasurement allocates and initializes a new Measurement.
@@ -473,6 +516,12 @@ func (m *Measurement) HasField(name string) bool {
 func (m *Measurement) SeriesByID(id uint64) *Series {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
+	if m.onDisk() {
+		if ds := m.index.disk.Series(id); ds != nil && ds.Name == m.Name {
+			return newDiskSeries(ds, m)
+		}
+		return nil
+	}
 	return m.seriesByID[id]
 }
 
@@ -481,6 +530,14 @@ func (m *Measurement) SeriesKeys() []string {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	var keys []string
+	if m.onDisk() {
+		for _, id := range m.index.disk.MeasurementSeriesIDs(m.Name) {
+			if ds := m.index.disk.Series(id); ds != nil {
+				keys = append(keys, ds.Key)
+			}
+		}
+		return keys
+	}
 	for _, s := range m.seriesByID {
 		keys = append(keys, s.Key)
 	}
@@ -504,6 +561,9 @@ func (m *Measurement) ValidateGroupBy(stmt *influxql.SelectStatement) error {
 func (m *Measurement) HasTagKey(k string) bool {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
+	if m.onDisk() {
+		return len(m.index.disk.TagValues(m.Name, k)) > 0
+	}
 	_, hasTag := m.seriesByTagKeyValue[k]
 	return hasTag
 }
@@ -512,6 +572,9 @@ func (m *Measurement) HasTagKey(k string) bool {
 func (m *Measurement) HasSeries() bool {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
+	if m.onDisk() {
+		return len(m.index.disk.MeasurementSeriesIDs(m.Name)) > 0
+	}
 	return len(m.seriesByID) > 0
 }
 
@@ -519,7 +582,11 @@ func (m *Measurement) HasSeries() bool {
 func (m *Measurement) AddSeries(s *Series) bool {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	return m.addSeries(s)
+}
 
+// addSeries adds a series to the measurementIndex. The caller must hold a write lock on the measurement.
+func (m *Measurement) addSeries(s *Series) bool {
 	if _, ok := m.seriesByID[s.id]; ok {
 		return false
 	}
@@ -636,6 +703,8 @@ func (m *Measurement) filters(stmt *influxql.SelectStatement) (map[uint64]influx
 // influx filter expression that goes with the series
 // TODO: this shouldn't be exported. However, until tx.go and the engine get refactored into tsdb, we need it.
 func (m *Measurement) TagSets(stmt *influxql.SelectStatement, dimensions []string) ([]*influxql.TagSet, error) {
+	m.load()
+
 	m.index.mu.RLock()
 	defer m.index.mu.RUnlock()
 	m.mu.RLock()
@@ -769,6 +838,11 @@ func mergeSeriesFilters(op influxql.Token, ids SeriesIDs, lfilters, rfilters Fil
 // idsForExpr will return a collection of series ids and a filter expression that should
 // be used to filter points from those series.
 func (m *Measurement) idsForExpr(n *influxql.BinaryExpr) (SeriesIDs, influxql.Expr, error) {
+	// Scalar functions are evaluated on field values, so they filter the points of every series.
+	if isScalarCall(n.LHS) || isScalarCall(n.RHS) {
+		return m.seriesIDs, n, nil
+	}
+
 	name, ok := n.LHS.(*influxql.VarRef)
 	value := n.RHS
 	if !ok {
@@ -833,6 +907,12 @@ func (m *Measurement) idsForExpr(n *influxql.BinaryExpr) (SeriesIDs, influxql.Ex
 	return nil, nil, nil
 }
 
+// isScalarCall returns true if expr is a call to a scalar function, such as strlen().
+func isScalarCall(expr influxql.Expr) bool {
+	c, ok := expr.(*influxql.Call)
+	return ok && c.IsScalar()
+}
+
 // FilterExprs represents a map of series IDs to filter expressions.
 type FilterExprs map[uint64]influxql.Expr
 
@@ -1457,6 +1537,9 @@ func timeBetweenInclusive(t, min, max time.Time) bool {
 func (m *Measurement) TagKeys() []string {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
+	if m.onDisk() {
+		return m.index.disk.TagKeys(m.Name)
+	}
 	keys := make([]string, 0, len(m.seriesByTagKeyValue))
 	for k := range m.seriesByTagKeyValue {
 		keys = append(keys, k)
@@ -1469,6 +1552,9 @@ func (m *Measurement) TagKeys() []string {
 func (m *Measurement) TagValues(key string) []string {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
+	if m.onDisk() {
+		return m.index.disk.TagValues(m.Name, key)
+	}
 	values := []string{}
 	for v := range m.seriesByTagKeyValue[key] {
 		values = append(values, v)
@@ -1476,6 +1562,20 @@ func (m *Measurement) TagValues(key string) []string {
 	return values
 }
 
+// tagValueN returns the number of values of a tag key, and whether value is one of them.
+func (m *Measurement) tagValueN(key, value string) (int, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.onDisk() {
+		if m.index.disk.HasTagValue(m.Name, key, value) {
+			return 0, true
+		}
+		return m.index.disk.TagValueN(m.Name, key), false
+	}
+	_, ok := m.seriesByTagKeyValue[key][value]
+	return len(m.seriesByTagKeyValue[key]), ok
+}
+
 // SetFieldName adds the field name to the measurement.
 func (m *Measurement) SetFieldName(name string) {
 	m.mu.Lock()
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"sort"
 	"time"
@@ -46,9 +47,27 @@ type QueryExecutor struct {
 		CreateMapper(shard meta.ShardInfo, stmt influxql.Statement, chunkSize int) (Mapper, error)
 	}
 
+	// Deletes points from shards for DELETE statements.
+	ShardDeleter interface {
+		DeleteFromShard(shard meta.ShardInfo, stmt *influxql.DeleteStatement) error
+	}
+
+	// Writes the results of SELECT ... INTO statements.
+	IntoWriter interface {
+		WritePointsInto(p *IntoWriteRequest) error
+	}
+
 	Logger          *log.Logger
 	QueryLogEnabled bool
 
+	// Tracks running queries for SHOW QUERIES and KILL QUERY.
+	QueryManager *QueryManager
+
+	// Limits on the resources used by queries. Zero disables a limit.
+	QueryTimeout      time.Duration
+	MaxSelectSeriesN  int
+	MaxSelectBucketsN int
+
 	// the local data store
 	Store *Store
 }
@@ -56,8 +75,9 @@ type QueryExecutor struct {
 // NewQueryExecutor returns an initialized QueryExecutor
 func NewQueryExecutor(store *Store) *QueryExecutor {
 	return &QueryExecutor{
-		Store:  store,
-		Logger: log.New(os.Stderr, "[query] ", log.LstdFlags),
+		Store:        store,
+		Logger:       log.New(os.Stderr, "[query] ", log.LstdFlags),
+		QueryManager: NewQueryManager(),
 	}
 }
 
@@ -124,14 +144,56 @@ func (q *QueryExecutor) Authorize(u *meta.UserInfo, query *influxql.Query, datab
 	return nil
 }
 
-// ExecuteQuery executes an InfluxQL query against the server.
-// It sends results down the passed in chan and closes it when done. It will close the chan
-// on the first statement that throws an error.
-func (q *QueryExecutor) ExecuteQuery(query *influxql.Query, database string, chunkSize int) (<-chan *influxql.Result, error) {
+// ExecuteQuery executes an InfluxQL query against the server on behalf of user, which
+// may be blank. It sends results down the passed in chan and closes it when done. It will
+// close the chan on the first statement that throws an error.
+// The query is interrupted when it is killed, when closing is closed or when it times out.
+func (q *QueryExecutor) ExecuteQuery(query *influxql.Query, database string, chunkSize int, user string, closing <-chan struct{}) (<-chan *influxql.Result, error) {
+	// Register the query so it can be listed and killed.
+	qid, interrupt := q.QueryManager.Attach(query.String(), database, user)
+	done := make(chan struct{})
+
+	// Kill the query once it runs for longer than the timeout.
+	var timer *time.Timer
+	var timeout <-chan time.Time
+	timedOut := make(chan struct{})
+	if q.QueryTimeout > 0 {
+		timer = time.NewTimer(q.QueryTimeout)
+		timeout = timer.C
+	}
+
+	if closing != nil || timeout != nil {
+		go func() {
+			if timer != nil {
+				defer timer.Stop()
+			}
+
+			select {
+			case <-closing:
+				q.QueryManager.Kill(qid)
+			case <-timeout:
+				close(timedOut)
+				q.QueryManager.Kill(qid)
+			case <-done:
+			}
+		}()
+	}
+
+	// Interrupted statements report the timeout if it caused the interruption.
+	statementError := func(err error) error {
+		if err == ErrQueryInterrupted && isClosed(timedOut) {
+			return ErrQueryTimeout
+		}
+		return err
+	}
+
 	// Execute each statement. Keep the iterator external so we can
 	// track how many of the statements were executed
 	results := make(chan *influxql.Result)
 	go func() {
+		defer q.QueryManager.Detach(qid)
+		defer close(done)
+
 		var i int
 		var stmt influxql.Statement
 		for i, stmt = range query.Statements {
@@ -159,37 +221,56 @@ func (q *QueryExecutor) ExecuteQuery(query *influxql.Query, database string, chu
 			var res *influxql.Result
 			switch stmt := stmt.(type) {
 			case *influxql.SelectStatement:
-				if err := q.executeStatement(i, stmt, database, results, chunkSize); err != nil {
-					results <- &influxql.Result{Err: err}
+				if stmt.Target != nil {
+					if err := q.executeSelectIntoStatement(i, stmt, results, interrupt); err != nil {
+						results <- &influxql.Result{Err: statementError(err)}
+					}
 					break
 				}
+				if err := q.executeStatement(i, stmt, database, results, chunkSize, interrupt); err != nil {
+					results <- &influxql.Result{Err: statementError(err)}
+					break
+				}
+			case *influxql.ExplainStatement:
+				res = q.executeExplainStatement(stmt, interrupt)
+				res.Err = statementError(res.Err)
 			case *influxql.DropSeriesStatement:
 				// TODO: handle this in a cluster
 				res = q.executeDropSeriesStatement(stmt, database)
 			case *influxql.ShowSeriesStatement:
 				res = q.executeShowSeriesStatement(stmt, database)
+			case *influxql.ShowSeriesCardinalityStatement:
+				res = q.executeShowSeriesCardinalityStatement(stmt, database)
+			case *influxql.ShowMeasurementCardinalityStatement:
+				res = q.executeShowMeasurementCardinalityStatement(stmt, database)
 			case *influxql.DropMeasurementStatement:
 				// TODO: handle this in a cluster
 				res = q.executeDropMeasurementStatement(stmt, database)
 			case *influxql.ShowMeasurementsStatement:
-				if err := q.executeStatement(i, stmt, database, results, chunkSize); err != nil {
-					results <- &influxql.Result{Err: err}
+				if err := q.executeStatement(i, stmt, database, results, chunkSize, interrupt); err != nil {
+					results <- &influxql.Result{Err: statementError(err)}
 					break
 				}
 			case *influxql.ShowTagKeysStatement:
-				if err := q.executeStatement(i, stmt, database, results, chunkSize); err != nil {
-					results <- &influxql.Result{Err: err}
+				if err := q.executeStatement(i, stmt, database, results, chunkSize, interrupt); err != nil {
+					results <- &influxql.Result{Err: statementError(err)}
 					break
 				}
 			case *influxql.ShowTagValuesStatement:
 				res = q.executeShowTagValuesStatement(stmt, database)
+			case *influxql.ShowTagValuesCardinalityStatement:
+				res = q.executeShowTagValuesCardinalityStatement(stmt, database)
 			case *influxql.ShowFieldKeysStatement:
 				res = q.executeShowFieldKeysStatement(stmt, database)
 			case *influxql.DeleteStatement:
-				res = &influxql.Result{Err: ErrInvalidQuery}
+				res = q.executeDeleteStatement(stmt)
 			case *influxql.DropDatabaseStatement:
 				// TODO: handle this in a cluster
 				res = q.executeDropDatabaseStatement(stmt)
+			case *influxql.ShowQueriesStatement:
+				res = q.executeShowQueriesStatement(stmt)
+			case *influxql.KillQueryStatement:
+				res = q.executeKillQueryStatement(stmt)
 			case *influxql.ShowStatsStatement, *influxql.ShowDiagnosticsStatement:
 				// Send monitor-related queries to the monitor service.
 				res = q.MonitorStatementExecutor.ExecuteStatement(stmt)
@@ -223,7 +304,51 @@ func (q *QueryExecutor) ExecuteQuery(query *influxql.Query, database string, chu
 
 // Plan creates an execution plan for the given SelectStatement and returns an Executor.
 func (q *QueryExecutor) PlanSelect(stmt *influxql.SelectStatement, chunkSize int) (Executor, error) {
-	shards := map[uint64]meta.ShardInfo{} // Shards requiring mappers.
+	plan, err := q.planSelect(stmt, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	return plan.executor, nil
+}
+
+// selectPlan describes how a SELECT statement is executed.
+type selectPlan struct {
+	stmt       *influxql.SelectStatement
+	tmin, tmax time.Time
+
+	shardGroups []meta.ShardGroupInfo
+	shards      []meta.ShardInfo // Shard mapped by each mapper.
+	subQuery    *selectPlan      // Plan of the subquery, if the statement selects from one.
+	joins       []*selectPlan    // Plans of each measurement, if the statement is a join.
+	rank        *selectPlan      // Plan of the aggregate series are ordered by, if any.
+
+	executor *SelectExecutor
+}
+
+// close closes the executor of the plan and of the plans it reads from.
+func (p *selectPlan) close() {
+	p.executor.close()
+	if p.subQuery != nil {
+		p.subQuery.close()
+	}
+	for _, j := range p.joins {
+		j.close()
+	}
+	if p.rank != nil {
+		p.rank.close()
+	}
+}
+
+// planSelect creates an execution plan for the given SelectStatement.
+func (q *QueryExecutor) planSelect(stmt *influxql.SelectStatement, chunkSize int) (*selectPlan, error) {
+	// Calls on every field, like mean(*), are expanded into a call on each field.
+	if stmt.HasWildcardCall() {
+		other, err := q.expandWildcardCalls(stmt)
+		if err != nil {
+			return nil, err
+		}
+		stmt = other
+	}
 
 	// It is important to "stamp" this time so that everywhere we evaluate `now()` in the statement is EXACTLY the same `now`
 	now := time.Now().UTC()
@@ -234,33 +359,97 @@ func (q *QueryExecutor) PlanSelect(stmt *influxql.SelectStatement, chunkSize int
 	if tmax.IsZero() {
 		tmax = now
 	}
+
+	// Fail before creating any mappers if too many GROUP BY time buckets would be created.
+	// Without a lower time bound all points are aggregated into a single bucket.
+	if q.MaxSelectBucketsN > 0 && !tmin.IsZero() {
+		w, err := newGroupByWindow(stmt)
+		if err != nil {
+			return nil, err
+		}
+		if w.size > 0 {
+			n := w.index(tmax.UnixNano()) - w.index(tmin.UnixNano()) + 1
+			if stmt.Limit > 0 && stmt.Having == nil && int64(stmt.Limit) < n {
+				n = int64(stmt.Limit)
+			}
+			if n > int64(q.MaxSelectBucketsN) {
+				return nil, ErrMaxSelectBucketsExceeded(n, int64(q.MaxSelectBucketsN))
+			}
+		}
+	}
+
 	if tmin.IsZero() {
 		tmin = time.Unix(0, 0)
 	}
+	plan := &selectPlan{stmt: stmt, tmin: tmin, tmax: tmax}
+
+	// Series ordered by an aggregate are ranked by a statement of their own.
+	if stmt.SeriesSortField() != nil {
+		if err := q.planSeriesOrder(plan, chunkSize); err != nil {
+			return nil, err
+		}
+		return plan, nil
+	}
+
+	// Statements selecting from a subquery are mapped from the results of the subquery.
+	for _, src := range stmt.Sources {
+		if _, ok := src.(*influxql.SubQuery); ok {
+			if err := q.planSubQuery(plan, chunkSize); err != nil {
+				return nil, err
+			}
+			return plan, nil
+		}
+	}
+
+	// Joins are mapped from the joined results of each of their measurements.
+	if stmt.IsJoin() {
+		if err := q.planJoin(plan, chunkSize); err != nil {
+			return nil, err
+		}
+		return plan, nil
+	}
+
+	if err := q.checkHistogramFields(stmt); err != nil {
+		return nil, err
+	}
+
+	// Fail before creating any mappers if the statement would read too many series.
+	mstmt := mapperStatement(stmt)
+	if q.MaxSelectSeriesN > 0 {
+		if err := q.checkSelectSeriesN(mstmt); err != nil {
+			return nil, err
+		}
+	}
 
+	// Build the set of target shards. Tracking shard IDs ensures each shard ID
+	// occurs only once.
+	seen := map[uint64]struct{}{}
 	for _, src := range stmt.Sources {
 		mm, ok := src.(*influxql.Measurement)
 		if !ok {
 			return nil, fmt.Errorf("invalid source type: %#v", src)
 		}
 
-		// Build the set of target shards. Using shard IDs as keys ensures each shard ID
-		// occurs only once.
 		shardGroups, err := q.MetaStore.ShardGroupsByTimeRange(mm.Database, mm.RetentionPolicy, tmin, tmax)
 		if err != nil {
 			return nil, err
 		}
 		for _, g := range shardGroups {
+			plan.shardGroups = append(plan.shardGroups, g)
 			for _, sh := range g.Shards {
-				shards[sh.ID] = sh
+				if _, ok := seen[sh.ID]; !ok {
+					seen[sh.ID] = struct{}{}
+					plan.shards = append(plan.shards, sh)
+				}
 			}
 		}
 	}
 
 	// Build the Mappers, one per shard.
 	mappers := []Mapper{}
-	for _, sh := range shards {
-		m, err := q.ShardMapper.CreateMapper(sh, stmt, chunkSize)
+	shards := plan.shards[:0]
+	for _, sh := range plan.shards {
+		m, err := q.ShardMapper.CreateMapper(sh, mstmt, chunkSize)
 		if err != nil {
 			return nil, err
 		}
@@ -269,14 +458,179 @@ func (q *QueryExecutor) PlanSelect(stmt *influxql.SelectStatement, chunkSize int
 			continue
 		}
 		mappers = append(mappers, m)
+		shards = append(shards, sh)
 	}
+	plan.shards = shards
 
-	executor := NewSelectExecutor(stmt, mappers, chunkSize)
-	return executor, nil
+	plan.executor = NewSelectExecutor(stmt, mappers, chunkSize)
+	return plan, nil
+}
+
+// checkHistogramFields returns an error if a histogram counts the values of a field
+// which isn't numeric, according to the local index.
+func (q *QueryExecutor) checkHistogramFields(stmt *influxql.SelectStatement) error {
+	var calls []*influxql.Call
+	for _, c := range stmt.FunctionCalls() {
+		if c.Name == "histogram" {
+			calls = append(calls, c)
+		}
+	}
+	if len(calls) == 0 {
+		return nil
+	}
+
+	sources, err := q.expandSources(stmt.Sources)
+	if err != nil {
+		return err
+	}
+	for _, src := range sources {
+		mm, ok := src.(*influxql.Measurement)
+		if !ok {
+			continue
+		}
+		types := q.Store.FieldTypes(mm.Database, mm.Name)
+		for _, c := range calls {
+			ref, ok := c.Args[0].(*influxql.VarRef)
+			if !ok {
+				continue
+			}
+			if t, ok := types[ref.Val]; ok && t != influxql.Float && t != influxql.Integer {
+				return fmt.Errorf("aggregate '%s' requires numerical field values. Field '%s' is of type %s", c.Name, ref.Val, t)
+			}
+		}
+	}
+	return nil
+}
+
+// mapperStatement returns the statement the mappers of stmt are created for. HAVING is
+// evaluated by the executor, so LIMIT, OFFSET, SLIMIT and SOFFSET are applied by the
+// executor to the results left by HAVING rather than by the mappers.
+func mapperStatement(stmt *influxql.SelectStatement) *influxql.SelectStatement {
+	if stmt.Having == nil {
+		return stmt
+	}
+	other := stmt.Clone()
+	other.Limit, other.Offset, other.SLimit, other.SOffset = 0, 0, 0, 0
+	return other
+}
+
+// checkSelectSeriesN returns an error if stmt would read more series than the
+// max-select-series limit. Series are counted from the database indexes, so the
+// limit applies to the whole statement rather than to each shard it reads.
+func (q *QueryExecutor) checkSelectSeriesN(stmt *influxql.SelectStatement) error {
+	// Regex sources are expanded so the series of every measurement they match are counted.
+	sources, err := q.expandSources(stmt.Sources)
+	if err != nil {
+		return err
+	}
+
+	var n int
+	seen := make(map[*Measurement]struct{})
+	for _, src := range sources {
+		mm, ok := src.(*influxql.Measurement)
+		if !ok {
+			continue
+		}
+		index := q.Store.DatabaseIndex(mm.Database)
+		if index == nil {
+			continue
+		}
+		m := index.Measurement(mm.Name)
+		if m == nil {
+			continue
+		} else if _, ok := seen[m]; ok {
+			continue
+		}
+		seen[m] = struct{}{}
+
+		tagSets, err := m.DimensionTagSets(stmt)
+		if err != nil {
+			return err
+		}
+		for _, t := range stmt.LimitTagSets(tagSets) {
+			n += len(t.SeriesKeys)
+		}
+	}
+	if n > q.MaxSelectSeriesN {
+		return ErrMaxSelectSeriesExceeded(n, q.MaxSelectSeriesN)
+	}
+	return nil
+}
+
+// planSubQuery creates an execution plan for a SelectStatement whose source is a subquery.
+// The subquery is planned as a separate executor, restricted to the time range of the
+// outer statement, and its rows are mapped for the outer statement.
+func (q *QueryExecutor) planSubQuery(plan *selectPlan, chunkSize int) error {
+	stmt, tmin, tmax := plan.stmt, plan.tmin, plan.tmax
+	if len(stmt.Sources) != 1 {
+		return errors.New("a subquery must be the only source of a SELECT statement")
+	}
+	sq := stmt.Sources[0].(*influxql.SubQuery)
+
+	inner := sq.Statement.Clone()
+	if influxql.HasTimeExpr(stmt.Condition) {
+		cond := &influxql.BinaryExpr{
+			Op:  influxql.AND,
+			LHS: &influxql.BinaryExpr{Op: influxql.GTE, LHS: &influxql.VarRef{Val: "time"}, RHS: &influxql.TimeLiteral{Val: tmin}},
+			RHS: &influxql.BinaryExpr{Op: influxql.LTE, LHS: &influxql.VarRef{Val: "time"}, RHS: &influxql.TimeLiteral{Val: tmax}},
+		}
+		if inner.Condition != nil {
+			inner.Condition = &influxql.BinaryExpr{Op: influxql.AND, LHS: &influxql.ParenExpr{Expr: inner.Condition}, RHS: cond}
+		} else {
+			inner.Condition = cond
+		}
+	}
+
+	sub, err := q.planSelect(inner, IgnoredChunkSize)
+	if err != nil {
+		return err
+	}
+	plan.subQuery = sub
+
+	// The time range of the subquery now includes the one of the outer statement.
+	qmin, qmax := influxql.TimeRange(inner.Condition)
+	if qmax.IsZero() {
+		qmax = tmax
+	}
+	if qmin.IsZero() {
+		qmin = tmin
+	}
+
+	m := NewSubQueryMapper(mapperStatement(stmt), sub.executor, qmin.UnixNano(), qmax.UnixNano(), chunkSize)
+	plan.executor = NewSelectExecutor(stmt, []Mapper{m}, chunkSize)
+	return nil
+}
+
+// planSeriesOrder creates an execution plan for a SelectStatement ordering series by an
+// aggregate. The aggregate is computed over the whole time range by a separate executor
+// first, then the statement reads the series ranked within SLIMIT and SOFFSET.
+func (q *QueryExecutor) planSeriesOrder(plan *selectPlan, chunkSize int) error {
+	stmt := plan.stmt
+	other, rank := stmt.RewriteSeriesOrder()
+
+	p, err := q.planSelect(other, chunkSize)
+	if err != nil {
+		return err
+	}
+	r, err := q.planSelect(rank, IgnoredChunkSize)
+	if err != nil {
+		p.close()
+		return err
+	}
+
+	*plan = *p
+	plan.stmt, plan.rank = stmt, r
+	plan.executor.ranking = &seriesRanking{
+		executor:  r.executor,
+		ascending: stmt.SeriesSortField().Ascending,
+		slimit:    stmt.SLimit,
+		soffset:   stmt.SOffset,
+	}
+	return nil
 }
 
 // executeSelectStatement plans and executes a select statement against a database.
-func (q *QueryExecutor) executeSelectStatement(statementID int, stmt *influxql.SelectStatement, results chan *influxql.Result, chunkSize int) error {
+func (q *QueryExecutor) executeSelectStatement(statementID int, stmt *influxql.SelectStatement, results chan *influxql.Result, chunkSize int, closing <-chan struct{}) error {
 	// Plan statement execution.
 	e, err := q.PlanSelect(stmt, chunkSize)
 	if err != nil {
@@ -284,7 +638,7 @@ func (q *QueryExecutor) executeSelectStatement(statementID int, stmt *influxql.S
 	}
 
 	// Execute plan.
-	ch := e.Execute()
+	ch := e.Execute(closing)
 
 	// Stream results from the channel. We should send an empty result if nothing comes through.
 	resultSent := false
@@ -363,6 +717,22 @@ func (q *QueryExecutor) expandSources(sources influxql.Sources) (influxql.Source
 	return expanded, nil
 }
 
+// executeShowQueriesStatement lists the queries running on the server.
+func (q *QueryExecutor) executeShowQueriesStatement(stmt *influxql.ShowQueriesStatement) *influxql.Result {
+	now := time.Now().UTC()
+	row := &models.Row{Columns: []string{"qid", "query", "database", "user", "start_time", "duration"}}
+	for _, qi := range q.QueryManager.Queries() {
+		d := now.Sub(qi.StartTime)
+		row.Values = append(row.Values, []interface{}{qi.ID, qi.Query, qi.Database, qi.User, qi.StartTime, d.String()})
+	}
+	return &influxql.Result{Series: []*models.Row{row}}
+}
+
+// executeKillQueryStatement interrupts a running query.
+func (q *QueryExecutor) executeKillQueryStatement(stmt *influxql.KillQueryStatement) *influxql.Result {
+	return &influxql.Result{Err: q.QueryManager.Kill(stmt.QueryID)}
+}
+
 // executeDropDatabaseStatement closes all local shards for the database and removes the directory. It then calls to the metastore to remove the database from there.
 // TODO: make this work in a cluster/distributed
 func (q *QueryExecutor) executeDropDatabaseStatement(stmt *influxql.DropDatabaseStatement) *influxql.Result {
@@ -403,11 +773,16 @@ func (q *QueryExecutor) executeDropMeasurementStatement(stmt *influxql.DropMeasu
 		return &influxql.Result{Err: ErrMeasurementNotFound(stmt.Name)}
 	}
 
+	// the keys must be read before the series are removed from a disk-backed index
+	seriesKeys := m.SeriesKeys()
+
 	// first remove from the index
-	db.DropMeasurement(m.Name)
+	if err := db.DropMeasurement(m.Name); err != nil {
+		return &influxql.Result{Err: err}
+	}
 
 	// now drop the raw data
-	if err := q.Store.deleteMeasurement(m.Name, m.SeriesKeys()); err != nil {
+	if err := q.Store.deleteMeasurement(m.Name, seriesKeys); err != nil {
 		return &influxql.Result{Err: err}
 	}
 
@@ -442,6 +817,8 @@ func (q *QueryExecutor) executeDropSeriesStatement(stmt *influxql.DropSeriesStat
 
 	var seriesKeys []string
 	for _, m := range measurements {
+		m.load()
+
 		var ids SeriesIDs
 		var filters FilterExprs
 		if stmt.Condition != nil {
@@ -475,7 +852,47 @@ func (q *QueryExecutor) executeDropSeriesStatement(stmt *influxql.DropSeriesStat
 		return &influxql.Result{Err: err}
 	}
 	// remove them from the index
-	db.DropSeries(seriesKeys)
+	if err := db.DropSeries(seriesKeys); err != nil {
+		return &influxql.Result{Err: err}
+	}
+
+	return &influxql.Result{}
+}
+
+func (q *QueryExecutor) executeDeleteStatement(stmt *influxql.DeleteStatement) *influxql.Result {
+	mm, ok := stmt.Source.(*influxql.Measurement)
+	if !ok {
+		return &influxql.Result{Err: fmt.Errorf("invalid source type: %#v", stmt.Source)}
+	}
+
+	// It is important to "stamp" this time so that every shard evaluates `now()` as EXACTLY the same `now`
+	now := time.Now().UTC()
+	stmt = &influxql.DeleteStatement{
+		Source:    stmt.Source,
+		Condition: influxql.Reduce(stmt.Condition, &influxql.NowValuer{Now: now}),
+	}
+
+	// Without a time range the delete applies to all points.
+	tmin, tmax := influxql.TimeRange(stmt.Condition)
+	if tmin.IsZero() {
+		tmin = time.Unix(0, math.MinInt64)
+	}
+	if tmax.IsZero() {
+		tmax = time.Unix(0, math.MaxInt64)
+	}
+
+	// Delete from every shard in the shard groups that overlap the time range.
+	shardGroups, err := q.MetaStore.ShardGroupsByTimeRange(mm.Database, mm.RetentionPolicy, tmin, tmax)
+	if err != nil {
+		return &influxql.Result{Err: err}
+	}
+	for _, g := range shardGroups {
+		for _, sh := range g.Shards {
+			if err := q.ShardDeleter.DeleteFromShard(sh, stmt); err != nil {
+				return &influxql.Result{Err: err}
+			}
+		}
+	}
 
 	return &influxql.Result{}
 }
@@ -511,6 +928,8 @@ func (q *QueryExecutor) executeShowSeriesStatement(stmt *influxql.ShowSeriesStat
 
 	// Loop through measurements to build result. One result row / measurement.
 	for _, m := range measurements {
+		m.load()
+
 		var ids SeriesIDs
 		var filters FilterExprs
 
@@ -574,6 +993,106 @@ func (q *QueryExecutor) executeShowSeriesStatement(stmt *influxql.ShowSeriesStat
 	return result
 }
 
+// executeShowSeriesCardinalityStatement counts the series in the database. Series are counted
+// per measurement when the statement selects measurements or filters series.
+func (q *QueryExecutor) executeShowSeriesCardinalityStatement(stmt *influxql.ShowSeriesCardinalityStatement, database string) *influxql.Result {
+	// Check for time in WHERE clause (not supported).
+	if influxql.HasTimeExpr(stmt.Condition) {
+		return &influxql.Result{Err: errors.New("SHOW SERIES CARDINALITY doesn't support time in WHERE clause")}
+	}
+
+	// Find the database.
+	db := q.Store.DatabaseIndex(database)
+	if db == nil {
+		return &influxql.Result{}
+	}
+
+	// Without a FROM or WHERE clause the index already knows the number of series.
+	if len(stmt.Sources) == 0 && stmt.Condition == nil {
+		return &influxql.Result{
+			Series: models.Rows{{Columns: []string{"count"}, Values: [][]interface{}{{db.SeriesN()}}}},
+		}
+	}
+
+	// Expand regex expressions in the FROM clause.
+	sources, err := q.expandSources(stmt.Sources)
+	if err != nil {
+		return &influxql.Result{Err: err}
+	}
+
+	// Get the list of measurements we're interested in.
+	measurements, err := measurementsFromSourcesOrDB(db, sources...)
+	if err != nil {
+		return &influxql.Result{Err: err}
+	}
+
+	result := &influxql.Result{
+		Series: make(models.Rows, 0, len(measurements)),
+	}
+	for _, m := range measurements {
+		m.load()
+		n := len(m.seriesIDs)
+
+		if stmt.Condition != nil {
+			// Get series IDs that match the WHERE clause.
+			ids, filters, err := m.walkWhereForSeriesIds(stmt.Condition)
+			if err != nil {
+				return &influxql.Result{Err: err}
+			}
+
+			// Delete boolean literal true filter expressions.
+			filters.DeleteBoolLiteralTrues()
+
+			// Check for unsupported field filters.
+			if filters.Len() > 0 {
+				return &influxql.Result{Err: errors.New("SHOW SERIES CARDINALITY doesn't support fields in WHERE clause")}
+			}
+			n = len(ids)
+		}
+
+		// Measurements without matching series are left out.
+		if n == 0 {
+			continue
+		}
+		result.Series = append(result.Series, &models.Row{
+			Name:    m.Name,
+			Columns: []string{"count"},
+			Values:  [][]interface{}{{n}},
+		})
+	}
+	return result
+}
+
+// executeShowMeasurementCardinalityStatement counts the measurements in the database.
+func (q *QueryExecutor) executeShowMeasurementCardinalityStatement(stmt *influxql.ShowMeasurementCardinalityStatement, database string) *influxql.Result {
+	// Check for time in WHERE clause (not supported).
+	if influxql.HasTimeExpr(stmt.Condition) {
+		return &influxql.Result{Err: errors.New("SHOW MEASUREMENT CARDINALITY doesn't support time in WHERE clause")}
+	}
+
+	// Find the database.
+	db := q.Store.DatabaseIndex(database)
+	if db == nil {
+		return &influxql.Result{}
+	}
+
+	// If a WHERE clause was specified, filter the measurements.
+	var n int
+	if stmt.Condition != nil {
+		measurements, err := db.measurementsByExpr(stmt.Condition)
+		if err != nil {
+			return &influxql.Result{Err: err}
+		}
+		n = len(measurements)
+	} else {
+		n = len(db.Measurements())
+	}
+
+	return &influxql.Result{
+		Series: models.Rows{{Columns: []string{"count"}, Values: [][]interface{}{{n}}}},
+	}
+}
+
 // filterShowSeriesResult will limit the number of series returned based on the limit and the offset.
 // Unlike limit and offset on SELECT statements, the limit and offset don't apply to the number of Rows, but
 // to the number of total Values returned, since each Value represents a unique series.
@@ -688,7 +1207,7 @@ func (q *QueryExecutor) PlanShowTagKeys(stmt *influxql.ShowTagKeysStatement, dat
 	return executor, nil
 }
 
-func (q *QueryExecutor) executeStatement(statementID int, stmt influxql.Statement, database string, results chan *influxql.Result, chunkSize int) error {
+func (q *QueryExecutor) executeStatement(statementID int, stmt influxql.Statement, database string, results chan *influxql.Result, chunkSize int, closing <-chan struct{}) error {
 	// Plan statement execution.
 	e, err := q.planStatement(stmt, database, chunkSize)
 	if err != nil {
@@ -696,7 +1215,7 @@ func (q *QueryExecutor) executeStatement(statementID int, stmt influxql.Statemen
 	}
 
 	// Execute plan.
-	ch := e.Execute()
+	ch := e.Execute(closing)
 
 	// Stream results from the channel. We should send an empty result if nothing comes through.
 	resultSent := false
@@ -727,32 +1246,93 @@ func (q *QueryExecutor) executeShowTagValuesStatement(stmt *influxql.ShowTagValu
 		return &influxql.Result{}
 	}
 
-	// Expand regex expressions in the FROM clause.
-	sources, err := q.expandSources(stmt.Sources)
+	tagValues, err := q.tagValues(db, stmt.Sources, stmt.TagKeys, stmt.Condition)
 	if err != nil {
 		return &influxql.Result{Err: err}
 	}
 
-	// Get the list of measurements we're interested in.
-	measurements, err := measurementsFromSourcesOrDB(db, sources...)
+	// Make result.
+	result := &influxql.Result{
+		Series: make(models.Rows, 0),
+	}
+
+	for k, v := range tagValues {
+		r := &models.Row{
+			Name:    k + "TagValues",
+			Columns: []string{k},
+		}
+
+		vals := v.list()
+		sort.Strings(vals)
+
+		for _, val := range vals {
+			v := interface{}(val)
+			r.Values = append(r.Values, []interface{}{v})
+		}
+
+		result.Series = append(result.Series, r)
+	}
+
+	sort.Sort(result.Series)
+	return result
+}
+
+// executeShowTagValuesCardinalityStatement counts the values of each tag key.
+func (q *QueryExecutor) executeShowTagValuesCardinalityStatement(stmt *influxql.ShowTagValuesCardinalityStatement, database string) *influxql.Result {
+	// Check for time in WHERE clause (not supported).
+	if influxql.HasTimeExpr(stmt.Condition) {
+		return &influxql.Result{Err: errors.New("SHOW TAG VALUES CARDINALITY doesn't support time in WHERE clause")}
+	}
+
+	// Find the database.
+	db := q.Store.DatabaseIndex(database)
+	if db == nil {
+		return &influxql.Result{}
+	}
+
+	tagValues, err := q.tagValues(db, stmt.Sources, stmt.TagKeys, stmt.Condition)
 	if err != nil {
 		return &influxql.Result{Err: err}
 	}
 
-	// Make result.
-	result := &influxql.Result{
-		Series: make(models.Rows, 0),
+	keys := make([]string, 0, len(tagValues))
+	for k := range tagValues {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	r := &models.Row{Columns: []string{"key", "count"}}
+	for _, k := range keys {
+		r.Values = append(r.Values, []interface{}{k, len(tagValues[k])})
+	}
+	return &influxql.Result{Series: models.Rows{r}}
+}
+
+// tagValues returns the set of values of each tag key in the series of sources matching condition.
+func (q *QueryExecutor) tagValues(db *DatabaseIndex, sources influxql.Sources, tagKeys []string, condition influxql.Expr) (map[string]stringSet, error) {
+	// Expand regex expressions in the FROM clause.
+	sources, err := q.expandSources(sources)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the list of measurements we're interested in.
+	measurements, err := measurementsFromSourcesOrDB(db, sources...)
+	if err != nil {
+		return nil, err
 	}
 
 	tagValues := make(map[string]stringSet)
 	for _, m := range measurements {
+		m.load()
+
 		var ids SeriesIDs
 
-		if stmt.Condition != nil {
+		if condition != nil {
 			// Get series IDs that match the WHERE clause.
-			ids, _, err = m.walkWhereForSeriesIds(stmt.Condition)
+			ids, _, err = m.walkWhereForSeriesIds(condition)
 			if err != nil {
-				return &influxql.Result{Err: err}
+				return nil, err
 			}
 
 			// If no series matched, then go to the next measurement.
@@ -766,7 +1346,7 @@ func (q *QueryExecutor) executeShowTagValuesStatement(stmt *influxql.ShowTagValu
 			ids = m.seriesIDs
 		}
 
-		for k, v := range m.tagValuesByKeyAndSeriesID(stmt.TagKeys, ids) {
+		for k, v := range m.tagValuesByKeyAndSeriesID(tagKeys, ids) {
 			_, ok := tagValues[k]
 			if !ok {
 				tagValues[k] = v
@@ -774,26 +1354,7 @@ func (q *QueryExecutor) executeShowTagValuesStatement(stmt *influxql.ShowTagValu
 			tagValues[k] = tagValues[k].union(v)
 		}
 	}
-
-	for k, v := range tagValues {
-		r := &models.Row{
-			Name:    k + "TagValues",
-			Columns: []string{k},
-		}
-
-		vals := v.list()
-		sort.Strings(vals)
-
-		for _, val := range vals {
-			v := interface{}(val)
-			r.Values = append(r.Values, []interface{}{v})
-		}
-
-		result.Series = append(result.Series, r)
-	}
-
-	sort.Sort(result.Series)
-	return result
+	return tagValues, nil
 }
 
 func (q *QueryExecutor) executeShowFieldKeysStatement(stmt *influxql.ShowFieldKeysStatement, database string) *influxql.Result {
@@ -970,8 +1531,24 @@ var (
 	// ErrNotExecuted is returned when a statement is not executed in a query.
 	// This can occur when a previous statement in the same query has errored.
 	ErrNotExecuted = errors.New("not executed")
+
+	// ErrQueryInterrupted is returned when a query is killed or its client goes away.
+	ErrQueryInterrupted = errors.New("query interrupted")
+
+	// ErrQueryTimeout is returned when a query runs for longer than the query timeout.
+	ErrQueryTimeout = errors.New("query-timeout limit exceeded")
 )
 
+// ErrMaxSelectSeriesExceeded returns an error for a statement reading n series when only max may be read.
+func ErrMaxSelectSeriesExceeded(n, max int) error {
+	return fmt.Errorf("max-select-series limit exceeded: (%d/%d)", n, max)
+}
+
+// ErrMaxSelectBucketsExceeded returns an error for a statement creating n GROUP BY time buckets when only max may be created.
+func ErrMaxSelectBucketsExceeded(n, max int64) error {
+	return fmt.Errorf("max-select-buckets limit exceeded: (%d/%d)", n, max)
+}
+
 func ErrDatabaseNotFound(name string) error { return fmt.Errorf("database not found: %s", name) }
 
 func ErrMeasurementNotFound(name string) error { return fmt.Errorf("measurement not found: %s", name) }
@@ -2,13 +2,16 @@ package tsdb_test
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/davecgh/go-spew/spew"
 	"github.com/influxdb/influxdb/influxql"
 	"github.com/influxdb/influxdb/meta"
 	"github.com/influxdb/influxdb/models"
@@ -169,6 +172,69 @@ func TestDropSeriesStatement(t *testing.T) {
 	}
 }
 
+func TestDeleteStatement(t *testing.T) {
+	store, executor := testStoreAndExecutor("")
+	defer os.RemoveAll(store.Path())
+
+	var points []models.Point
+	for _, host := range []string{"serverA", "serverB"} {
+		for i := int64(1); i <= 3; i++ {
+			points = append(points, models.NewPoint(
+				"cpu",
+				map[string]string{"host": host},
+				map[string]interface{}{"value": float64(i)},
+				time.Unix(i, 0),
+			))
+		}
+	}
+	if err := store.WriteToShard(shardID, points); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	got := executeAndGetJSON("DELETE FROM cpu WHERE host = 'serverA' AND time < '1970-01-01T00:00:03Z'", executor)
+	exepected := `[{}]`
+	if exepected != got {
+		t.Fatalf("exp: %s\ngot: %s", exepected, got)
+	}
+
+	got = executeAndGetJSON("SELECT * FROM cpu GROUP BY *", executor)
+	exepected = `[{"series":[{"name":"cpu","tags":{"host":"serverA"},"columns":["time","value"],"values":[["1970-01-01T00:00:03Z",3]]}]},{"series":[{"name":"cpu","tags":{"host":"serverB"},"columns":["time","value"],"values":[["1970-01-01T00:00:01Z",1],["1970-01-01T00:00:02Z",2],["1970-01-01T00:00:03Z",3]]}]}]`
+	if exepected != got {
+		t.Fatalf("exp: %s\ngot: %s", exepected, got)
+	}
+
+	got = executeAndGetJSON("DELETE FROM cpu WHERE value = 1", executor)
+	exepected = `[{"error":"DELETE doesn't support fields in WHERE clause"}]`
+	if exepected != got {
+		t.Fatalf("exp: %s\ngot: %s", exepected, got)
+	}
+
+	store.Close()
+	conf := store.EngineOptions.Config
+	store = tsdb.NewStore(store.Path())
+	store.EngineOptions.Config = conf
+	if err := store.Open(); err != nil {
+		t.Fatalf(err.Error())
+	}
+	executor.Store = store
+	executor.ShardMapper = &testShardMapper{store: store}
+	executor.ShardDeleter = &testShardDeleter{store: store}
+
+	got = executeAndGetJSON("SELECT * FROM cpu WHERE host = 'serverA'", executor)
+	exepected = `[{"series":[{"name":"cpu","columns":["time","host","value"],"values":[["1970-01-01T00:00:03Z","serverA",3]]}]}]`
+	if exepected != got {
+		t.Fatalf("exp: %s\ngot: %s", exepected, got)
+	}
+
+	// Delete the rest of the points without a time range.
+	executeAndGetJSON("DELETE FROM cpu", executor)
+	got = executeAndGetJSON("SELECT * FROM cpu", executor)
+	exepected = `[{}]`
+	if exepected != got {
+		t.Fatalf("exp: %s\ngot: %s", exepected, got)
+	}
+}
+
 func TestDropMeasurementStatement(t *testing.T) {
 	store, executor := testStoreAndExecutor("")
 	defer os.RemoveAll(store.Path())
@@ -316,6 +382,92 @@ func TestQueryNoData(t *testing.T) {
 	store.Close()
 }
 
+// Ensure the results of a subquery can be selected from.
+func TestSelectStatement_SubQuery(t *testing.T) {
+	store, executor := testStoreAndExecutor("")
+	defer os.RemoveAll(store.Path())
+
+	base := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, p := range []struct {
+		host  string
+		value float64
+		t     time.Duration
+	}{
+		{"a", 1, 10 * time.Second},
+		{"a", 3, 20 * time.Second},
+		{"a", 10, 70 * time.Second},
+		{"b", 5, 10 * time.Second},
+		{"b", 7, 20 * time.Second},
+		{"b", 20, 70 * time.Second},
+	} {
+		if err := store.WriteToShard(shardID, []models.Point{models.NewPoint(
+			"cpu",
+			map[string]string{"host": p.host},
+			map[string]interface{}{"value": p.value},
+			base.Add(p.t),
+		)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, tt := range []struct {
+		q   string
+		exp string
+	}{
+		{
+			q:   `SELECT max(mean) FROM (SELECT mean(value) FROM cpu WHERE time >= '2000-01-01T00:00:00Z' AND time < '2000-01-01T00:02:00Z' GROUP BY time(1m), host) WHERE time >= '2000-01-01T00:00:00Z' AND time < '2000-01-01T00:02:00Z' GROUP BY time(1m)`,
+			exp: `[{"series":[{"name":"cpu","columns":["time","max"],"values":[["2000-01-01T00:00:00Z",6],["2000-01-01T00:01:00Z",20]]}]}]`,
+		},
+		{
+			// The time range of the outer statement applies to the subquery.
+			q:   `SELECT sum(mean) FROM (SELECT mean(value) FROM cpu GROUP BY time(1m), host) WHERE time >= '2000-01-01T00:01:00Z' AND time < '2000-01-01T00:02:00Z'`,
+			exp: `[{"series":[{"name":"cpu","columns":["time","sum"],"values":[["2000-01-01T00:01:00Z",30]]}]}]`,
+		},
+		{
+			q:   `SELECT mean FROM (SELECT mean(value) FROM cpu WHERE time >= '2000-01-01T00:00:00Z' AND time < '2000-01-01T00:02:00Z' GROUP BY time(1m), host) WHERE host = 'a'`,
+			exp: `[{"series":[{"name":"cpu","columns":["time","mean"],"values":[["2000-01-01T00:00:00Z",2],["2000-01-01T00:01:00Z",10]]}]}]`,
+		},
+		{
+			q:   `SELECT max(value) FROM (SELECT value FROM cpu), cpu`,
+			exp: `[{"error":"a subquery must be the only source of a SELECT statement"}]`,
+		},
+	} {
+		if got := executeAndGetJSON(tt.q, executor); tt.exp != got {
+			t.Errorf("%d. %s\nexp: %s\ngot: %s", i, tt.q, tt.exp, got)
+		}
+	}
+}
+
+// Ensure closing a subquery mapper closes the mappers of the subquery, even if it was never opened.
+func TestSubQueryMapper_Close(t *testing.T) {
+	inner := &closeRecordingMapper{}
+	e := tsdb.NewSelectExecutor(mustParseSelectStatement(`SELECT value FROM cpu`), []tsdb.Mapper{inner}, 0)
+	m := tsdb.NewSubQueryMapper(mustParseSelectStatement(`SELECT mean(value) FROM (SELECT value FROM cpu)`), e, 0, 1, 0)
+	m.Close()
+	if !inner.closed {
+		t.Fatal("subquery mapper not closed")
+	}
+
+	// Closing the mapper again is a no-op.
+	m.Close()
+}
+
+// closeRecordingMapper is a mapper without data which records whether it was closed.
+type closeRecordingMapper struct {
+	closed bool
+}
+
+func (m *closeRecordingMapper) Open() error                     { return nil }
+func (m *closeRecordingMapper) TagSets() []string               { return nil }
+func (m *closeRecordingMapper) Fields() []string                { return nil }
+func (m *closeRecordingMapper) NextChunk() (interface{}, error) { return nil, nil }
+func (m *closeRecordingMapper) Close() {
+	if m.closed {
+		panic("mapper closed twice")
+	}
+	m.closed = true
+}
+
 // ensure that authenticate doesn't return an error if the user count is zero and they're attempting
 // to create a user.
 func TestAuthenticateIfUserCountZeroAndCreateUser(t *testing.T) {
@@ -367,12 +519,13 @@ func testStoreAndExecutor(storePath string) (*tsdb.Store, *tsdb.QueryExecutor) {
 	executor := tsdb.NewQueryExecutor(store)
 	executor.MetaStore = &testMetastore{}
 	executor.ShardMapper = &testShardMapper{store: store}
+	executor.ShardDeleter = &testShardDeleter{store: store}
 
 	return store, executor
 }
 
 func executeAndGetJSON(query string, executor *tsdb.QueryExecutor) string {
-	ch, err := executor.ExecuteQuery(mustParseQuery(query), "foo", 20)
+	ch, err := executor.ExecuteQuery(mustParseQuery(query), "foo", 20, "", nil)
 	if err != nil {
 		panic(err.Error())
 	}
@@ -483,6 +636,25 @@ func (t *testShardMapper) CreateMapper(shard meta.ShardInfo, stmt influxql.State
 	return m, err
 }
 
+type testShardDeleter struct {
+	store *tsdb.Store
+}
+
+func (t *testShardDeleter) DeleteFromShard(shard meta.ShardInfo, stmt *influxql.DeleteStatement) error {
+	return t.store.DeleteFromShard(shard.ID, stmt)
+}
+
+type testIntoWriter struct {
+	points []string
+}
+
+func (t *testIntoWriter) WritePointsInto(p *tsdb.IntoWriteRequest) error {
+	for _, pt := range p.Points {
+		t.points = append(t.points, pt.String())
+	}
+	return nil
+}
+
 // MustParseQuery parses an InfluxQL query. Panic on error.
 func mustParseQuery(s string) *influxql.Query {
 	q, err := influxql.NewParser(strings.NewReader(s)).ParseQuery()
@@ -491,3 +663,718 @@ func mustParseQuery(s string) *influxql.Query {
 	}
 	return q
 }
+
+// mustParseTime parses an RFC3339 timestamp. Panic on error.
+func mustParseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err.Error())
+	}
+	return t
+}
+
+// Ensure running queries are listed and unknown queries cannot be killed.
+func TestQueryExecutor_ShowQueries(t *testing.T) {
+	store, executor := testStoreAndExecutor("")
+	defer os.RemoveAll(store.Path())
+
+	ch, err := executor.ExecuteQuery(mustParseQuery("SHOW QUERIES; KILL QUERY 100"), "foo", 20, "bob", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var results []*influxql.Result
+	for r := range ch {
+		results = append(results, r)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("unexpected results: %s", spew.Sdump(results))
+	} else if row := results[0].Series[0]; len(row.Values) != 1 {
+		t.Fatalf("unexpected queries: %s", spew.Sdump(row))
+	} else if v := row.Values[0]; v[0] != uint64(1) || v[1] != "SHOW QUERIES;\nKILL QUERY 100" || v[2] != "foo" || v[3] != "bob" {
+		t.Fatalf("unexpected query: %v", v)
+	} else if err := results[1].Err; err == nil || err.Error() != "query not found: 100" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if queries := executor.QueryManager.Queries(); len(queries) != 0 {
+		t.Fatalf("queries still running: %v", queries)
+	}
+}
+
+// Ensure execution stops once a query is interrupted.
+func TestQueryExecutor_Interrupt(t *testing.T) {
+	store, executor := testStoreAndExecutor("")
+	defer os.RemoveAll(store.Path())
+
+	if err := store.WriteToShard(shardID, []models.Point{models.NewPoint(
+		"cpu",
+		map[string]string{"host": "server"},
+		map[string]interface{}{"value": 1.0},
+		time.Unix(1, 2),
+	)}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, q := range []string{"SELECT * FROM cpu", "SELECT count(value) FROM cpu"} {
+		stmt := mustParseQuery(q).Statements[0].(*influxql.SelectStatement)
+		e, err := executor.PlanSelect(stmt, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		closing := make(chan struct{})
+		close(closing)
+		if row := <-e.Execute(closing); row == nil || row.Err != tsdb.ErrQueryInterrupted {
+			t.Fatalf("%s: unexpected row: %s", q, spew.Sdump(row))
+		}
+	}
+}
+
+// Ensure statements fail early when they would exceed the query limits.
+func TestQueryExecutor_Limits(t *testing.T) {
+	store, executor := testStoreAndExecutor("")
+	defer os.RemoveAll(store.Path())
+
+	// Each shard holds a single series, the limit applies to the whole statement.
+	if err := store.CreateShard("foo", "bar", shardID+1); err != nil {
+		t.Fatal(err)
+	}
+	for i, host := range []string{"serverA", "serverB"} {
+		if err := store.WriteToShard(shardID+uint64(i), []models.Point{models.NewPoint(
+			"cpu",
+			map[string]string{"host": host},
+			map[string]interface{}{"value": 1.0},
+			mustParseTime("2000-01-01T00:00:01Z"),
+		)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	executor.MaxSelectSeriesN = 1
+	executor.MaxSelectBucketsN = 10
+
+	for _, tt := range []struct {
+		q   string
+		exp string
+	}{
+		{q: `SELECT * FROM cpu`, exp: `[{"error":"max-select-series limit exceeded: (2/1)"}]`},
+		{q: `SELECT count(value) FROM cpu`, exp: `[{"error":"max-select-series limit exceeded: (2/1)"}]`},
+		{q: `SELECT * FROM /.*/`, exp: `[{"error":"max-select-series limit exceeded: (2/1)"}]`},
+		{q: `SELECT * FROM /cp/ WHERE host = 'serverA'`, exp: `[{"series":[{"name":"cpu","columns":["time","host","value"],"values":[["2000-01-01T00:00:01Z","serverA",1]]}]}]`},
+		{q: `SELECT count(value) FROM cpu WHERE host = 'serverA'`, exp: `[{"series":[{"name":"cpu","columns":["time","count"],"values":[["1970-01-01T00:00:00Z",1]]}]}]`},
+		{q: `SELECT count(value) FROM cpu WHERE host = 'serverA' AND time >= '2000-01-01T00:00:00Z' AND time < '2000-01-01T00:01:00Z' GROUP BY time(1s)`, exp: `[{"error":"max-select-buckets limit exceeded: (60/10)"}]`},
+		{q: `SELECT count(value) FROM cpu WHERE host = 'serverA' AND time >= '2000-01-01T00:00:00Z' AND time < '2000-01-01T00:01:00Z' GROUP BY time(10s)`, exp: `[{"series":[{"name":"cpu","columns":["time","count"],"values":[["2000-01-01T00:00:00Z",1],["2000-01-01T00:00:10Z",null],["2000-01-01T00:00:20Z",null],["2000-01-01T00:00:30Z",null],["2000-01-01T00:00:40Z",null],["2000-01-01T00:00:50Z",null]]}]}]`},
+	} {
+		if got := executeAndGetJSON(tt.q, executor); got != tt.exp {
+			t.Errorf("%s:\nexp: %s\ngot: %s", tt.q, tt.exp, got)
+		}
+	}
+}
+
+// Ensure the results of aggregates and selectors can be combined in expressions.
+func TestQueryExecutor_AggregateMath(t *testing.T) {
+	store, executor := testStoreAndExecutor("")
+	defer os.RemoveAll(store.Path())
+
+	for i, host := range []string{"serverA", "serverB"} {
+		if err := store.WriteToShard(shardID, []models.Point{models.NewPoint(
+			"http",
+			map[string]string{"host": host},
+			map[string]interface{}{"requests": float64(10 * (i + 1)), "errors": float64(i)},
+			mustParseTime("2000-01-01T00:00:01Z").Add(time.Duration(i)*time.Second),
+		)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		q   string
+		exp string
+	}{
+		{
+			q:   `SELECT sum(errors) / sum(requests) * 100 FROM http WHERE time >= '2000-01-01T00:00:00Z' AND time < '2000-01-01T00:10:00Z' GROUP BY time(5m)`,
+			exp: `[{"series":[{"name":"http","columns":["time",""],"values":[["2000-01-01T00:00:00Z",3.3333333333333335],["2000-01-01T00:05:00Z",null]]}]}]`,
+		},
+		{
+			q:   `SELECT mean(requests) / 1024 AS kb FROM http`,
+			exp: `[{"series":[{"name":"http","columns":["time","kb"],"values":[["1970-01-01T00:00:00Z",0.0146484375]]}]}]`,
+		},
+		{
+			q:   `SELECT first(requests) + last(requests) FROM http`,
+			exp: `[{"series":[{"name":"http","columns":["time",""],"values":[["1970-01-01T00:00:00Z",30]]}]}]`,
+		},
+		{
+			q:   `SELECT max(requests) - min(requests) AS spread, count(requests) FROM http`,
+			exp: `[{"series":[{"name":"http","columns":["time","spread","count"],"values":[["1970-01-01T00:00:00Z",10,2]]}]}]`,
+		},
+		{
+			q:   `SELECT sum(requests) / sum(errors) FROM http WHERE host = 'serverA'`,
+			exp: `[{"series":[{"name":"http","columns":["time",""],"values":[["1970-01-01T00:00:00Z",null]]}]}]`,
+		},
+	} {
+		if got := executeAndGetJSON(tt.q, executor); got != tt.exp {
+			t.Errorf("%s:\nexp: %s\ngot: %s", tt.q, tt.exp, got)
+		}
+	}
+}
+
+// Ensure irregular values are integrated and averaged over time.
+func TestQueryExecutor_Integral(t *testing.T) {
+	store, executor := testStoreAndExecutor("")
+	defer os.RemoveAll(store.Path())
+
+	for _, p := range []struct {
+		offset time.Duration
+		value  float64
+	}{{0, 100}, {time.Minute, 100}, {4 * time.Minute, 400}, {6 * time.Minute, 0}, {10 * time.Minute, 50}} {
+		if err := store.WriteToShard(shardID, []models.Point{models.NewPoint(
+			"power",
+			nil,
+			map[string]interface{}{"watts": p.value},
+			mustParseTime("2000-01-01T00:00:00Z").Add(p.offset),
+		)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		q   string
+		exp string
+	}{
+		{
+			q:   `SELECT integral(watts, 1h) AS wh, time_weighted_mean(watts), mean(watts) FROM power`,
+			exp: `[{"series":[{"name":"power","columns":["time","wh","time_weighted_mean","mean"],"values":[["1970-01-01T00:00:00Z",22.5,135,130]]}]}]`,
+		},
+		{
+			q:   `SELECT integral(watts) FROM power WHERE time >= '2000-01-01T00:00:00Z' AND time < '2000-01-01T00:10:00Z' GROUP BY time(5m)`,
+			exp: `[{"series":[{"name":"power","columns":["time","integral"],"values":[["2000-01-01T00:00:00Z",51000],["2000-01-01T00:05:00Z",0]]}]}]`,
+		},
+	} {
+		if got := executeAndGetJSON(tt.q, executor); got != tt.exp {
+			t.Errorf("%s:\nexp: %s\ngot: %s", tt.q, tt.exp, got)
+		}
+	}
+}
+
+// Ensure values are counted into histogram buckets and the most frequent value is returned.
+func TestQueryExecutor_HistogramMode(t *testing.T) {
+	store, executor := testStoreAndExecutor("")
+	defer os.RemoveAll(store.Path())
+
+	statuses := []string{"200", "500", "200", "500", "200"}
+	for i, v := range []float64{5, 20, 25, 250, 20} {
+		if err := store.WriteToShard(shardID, []models.Point{models.NewPoint(
+			"http",
+			nil,
+			map[string]interface{}{"latency": v, "status": statuses[i]},
+			mustParseTime("2000-01-01T00:00:00Z").Add(time.Duration(i)*time.Second),
+		)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		q   string
+		exp string
+	}{
+		{
+			q:   `SELECT histogram(latency, 10, 100), mode(latency), mode(status) FROM http`,
+			exp: `[{"series":[{"name":"http","columns":["time","histogram","mode","mode"],"values":[["1970-01-01T00:00:00Z",[1,3,1],20,"200"]]}]}]`,
+		},
+		{
+			q:   `SELECT histogram(latency, 10, 100), count(latency) FROM http WHERE time >= '2000-01-01T00:00:00Z' AND time < '2000-01-01T00:00:10Z' GROUP BY time(5s) fill(0)`,
+			exp: `[{"series":[{"name":"http","columns":["time","histogram","count"],"values":[["2000-01-01T00:00:00Z",[1,3,1],5],["2000-01-01T00:00:05Z",null,0]]}]}]`,
+		},
+		{
+			q:   `SELECT histogram(status, 10) FROM http`,
+			exp: `[{"error":"aggregate 'histogram' requires numerical field values. Field 'status' is of type string"}]`,
+		},
+	} {
+		if got := executeAndGetJSON(tt.q, executor); got != tt.exp {
+			t.Errorf("%s:\nexp: %s\ngot: %s", tt.q, tt.exp, got)
+		}
+	}
+}
+
+// Ensure string functions are evaluated on the values of fields and aggregates.
+func TestQueryExecutor_StringFunctions(t *testing.T) {
+	store, executor := testStoreAndExecutor("")
+	defer os.RemoveAll(store.Path())
+
+	for i, msg := range []string{"app-12 started", "db-7 failed", "app-3 stopped"} {
+		if err := store.WriteToShard(shardID, []models.Point{models.NewPoint(
+			"logs",
+			nil,
+			map[string]interface{}{"msg": msg, "code": float64(i)},
+			mustParseTime("2000-01-01T00:00:00Z").Add(time.Duration(i)*time.Second),
+		)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		q   string
+		exp string
+	}{
+		{
+			q:   `SELECT upper(msg), strlen(msg) FROM logs`,
+			exp: `[{"series":[{"name":"logs","columns":["time","upper","strlen"],"values":[["2000-01-01T00:00:00Z","APP-12 STARTED",14],["2000-01-01T00:00:01Z","DB-7 FAILED",11],["2000-01-01T00:00:02Z","APP-3 STOPPED",13]]}]}]`,
+		},
+		{
+			q:   `SELECT regex_extract(msg, /^([a-z]+)-/, 1) AS "app", concat(substr(msg, 1, 2), code) AS "id" FROM logs WHERE regex_extract(msg, /[0-9]+/) = '12' OR strlen(msg) < 12`,
+			exp: `[{"series":[{"name":"logs","columns":["time","app","id"],"values":[["2000-01-01T00:00:00Z","app","ap0"],["2000-01-01T00:00:01Z","db","db1"]]}]}]`,
+		},
+		{
+			q:   `SELECT concat(last(msg), '!') FROM logs`,
+			exp: `[{"series":[{"name":"logs","columns":["time","concat"],"values":[["2000-01-01T00:00:02Z","app-3 stopped!"]]}]}]`,
+		},
+	} {
+		if got := executeAndGetJSON(tt.q, executor); got != tt.exp {
+			t.Errorf("%s:\nexp: %s\ngot: %s", tt.q, tt.exp, got)
+		}
+	}
+}
+
+// Ensure HAVING drops the results, and series, whose aggregates fail the condition.
+func TestQueryExecutor_Having(t *testing.T) {
+	store, executor := testStoreAndExecutor("")
+	defer os.RemoveAll(store.Path())
+
+	for i, host := range []string{"serverA", "serverB"} {
+		for j, v := range []float64{50, 95} {
+			if err := store.WriteToShard(shardID, []models.Point{models.NewPoint(
+				"cpu",
+				map[string]string{"host": host},
+				map[string]interface{}{"value": v - float64(10*i), "idle": 100 - v + float64(10*i)},
+				mustParseTime("2000-01-01T00:00:00Z").Add(time.Duration(j)*5*time.Minute),
+			)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for _, tt := range []struct {
+		q   string
+		exp string
+	}{
+		{
+			q:   `SELECT mean(value) FROM cpu GROUP BY host HAVING mean(value) > 70`,
+			exp: `[{"series":[{"name":"cpu","tags":{"host":"serverA"},"columns":["time","mean"],"values":[["1970-01-01T00:00:00Z",72.5]]}]}]`,
+		},
+		{
+			q:   `SELECT max(value) AS peak FROM cpu WHERE time >= '2000-01-01T00:00:00Z' AND time < '2000-01-01T00:10:00Z' GROUP BY time(5m), host HAVING peak > 80`,
+			exp: `[{"series":[{"name":"cpu","tags":{"host":"serverA"},"columns":["time","peak"],"values":[["2000-01-01T00:05:00Z",95]]}]},{"series":[{"name":"cpu","tags":{"host":"serverB"},"columns":["time","peak"],"values":[["2000-01-01T00:05:00Z",85]]}]}]`,
+		},
+		{
+			q:   `SELECT count(value) FROM cpu HAVING count(value) > 10`,
+			exp: `[{}]`,
+		},
+		{
+			q:   `SELECT mean(value), mean(idle) FROM cpu GROUP BY host HAVING mean(idle) > 30`,
+			exp: `[{"series":[{"name":"cpu","tags":{"host":"serverB"},"columns":["time","mean","mean"],"values":[["1970-01-01T00:00:00Z",62.5,37.5]]}]}]`,
+		},
+		{
+			q:   `SELECT max(value) FROM cpu WHERE time >= '2000-01-01T00:00:00Z' AND time < '2000-01-01T00:10:00Z' GROUP BY time(5m), host HAVING max(value) > 80 LIMIT 1`,
+			exp: `[{"series":[{"name":"cpu","tags":{"host":"serverA"},"columns":["time","max"],"values":[["2000-01-01T00:05:00Z",95]]}]},{"series":[{"name":"cpu","tags":{"host":"serverB"},"columns":["time","max"],"values":[["2000-01-01T00:05:00Z",85]]}]}]`,
+		},
+		{
+			q:   `SELECT max(value) FROM cpu WHERE time >= '2000-01-01T00:00:00Z' AND time < '2000-01-01T00:10:00Z' GROUP BY time(5m), host HAVING max(value) > 0 LIMIT 1 OFFSET 1`,
+			exp: `[{"series":[{"name":"cpu","tags":{"host":"serverA"},"columns":["time","max"],"values":[["2000-01-01T00:05:00Z",95]]}]},{"series":[{"name":"cpu","tags":{"host":"serverB"},"columns":["time","max"],"values":[["2000-01-01T00:05:00Z",85]]}]}]`,
+		},
+		{
+			q:   `SELECT mean(value) FROM cpu GROUP BY host HAVING mean(value) < 70 SLIMIT 1`,
+			exp: `[{"series":[{"name":"cpu","tags":{"host":"serverB"},"columns":["time","mean"],"values":[["1970-01-01T00:00:00Z",62.5]]}]}]`,
+		},
+		{
+			q:   `SELECT mean(value) FROM cpu GROUP BY host HAVING mean(value) > 0 SLIMIT 1 SOFFSET 1`,
+			exp: `[{"series":[{"name":"cpu","tags":{"host":"serverB"},"columns":["time","mean"],"values":[["1970-01-01T00:00:00Z",62.5]]}]}]`,
+		},
+		{
+			q:   `SELECT mean(value) FROM cpu GROUP BY host HAVING mean(value) > 70 ORDER BY mean(value) ASC SLIMIT 1`,
+			exp: `[{"series":[{"name":"cpu","tags":{"host":"serverA"},"columns":["time","mean"],"values":[["1970-01-01T00:00:00Z",72.5]]}]}]`,
+		},
+	} {
+		if got := executeAndGetJSON(tt.q, executor); got != tt.exp {
+			t.Errorf("%s:\nexp: %s\ngot: %s", tt.q, tt.exp, got)
+		}
+	}
+}
+
+// Ensure series can be ranked by an aggregate and limited to the top ones.
+func TestQueryExecutor_SeriesOrder(t *testing.T) {
+	store, executor := testStoreAndExecutor("")
+	defer os.RemoveAll(store.Path())
+
+	for i, host := range []string{"serverA", "serverB", "serverC"} {
+		for j := 0; j < 2; j++ {
+			if err := store.WriteToShard(shardID, []models.Point{models.NewPoint(
+				"cpu",
+				map[string]string{"host": host},
+				map[string]interface{}{"value": float64((i+1)%3*10 + j)},
+				mustParseTime("2000-01-01T00:00:00Z").Add(time.Duration(j)*5*time.Minute),
+			)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for _, tt := range []struct {
+		q   string
+		exp string
+	}{
+		{
+			q:   `SELECT mean(value) FROM cpu GROUP BY host ORDER BY mean(value) DESC SLIMIT 2`,
+			exp: `[{"series":[{"name":"cpu","tags":{"host":"serverB"},"columns":["time","mean"],"values":[["1970-01-01T00:00:00Z",20.5]]}]},{"series":[{"name":"cpu","tags":{"host":"serverA"},"columns":["time","mean"],"values":[["1970-01-01T00:00:00Z",10.5]]}]}]`,
+		},
+		{
+			q:   `SELECT max(value) FROM cpu WHERE time >= '2000-01-01T00:00:00Z' AND time < '2000-01-01T00:10:00Z' GROUP BY time(5m), host ORDER BY mean(value) ASC SLIMIT 1 SOFFSET 1`,
+			exp: `[{"series":[{"name":"cpu","tags":{"host":"serverA"},"columns":["time","max"],"values":[["2000-01-01T00:00:00Z",10],["2000-01-01T00:05:00Z",11]]}]}]`,
+		},
+		{
+			q:   `SELECT value FROM cpu GROUP BY host ORDER BY min(value) SLIMIT 1`,
+			exp: `[{"series":[{"name":"cpu","tags":{"host":"serverC"},"columns":["time","value"],"values":[["2000-01-01T00:00:00Z",0],["2000-01-01T00:05:00Z",1]]}]}]`,
+		},
+		{
+			q:   `SELECT mean(value) FROM cpu WHERE host != 'serverC' GROUP BY host ORDER BY mean(value) SLIMIT 1`,
+			exp: `[{"series":[{"name":"cpu","tags":{"host":"serverA"},"columns":["time","mean"],"values":[["1970-01-01T00:00:00Z",10.5]]}]}]`,
+		},
+		{
+			q:   `SELECT mean(value) FROM cpu GROUP BY host ORDER BY mean(value) SLIMIT 5`,
+			exp: `[{"series":[{"name":"cpu","tags":{"host":"serverC"},"columns":["time","mean"],"values":[["1970-01-01T00:00:00Z",0.5]]}]},{"series":[{"name":"cpu","tags":{"host":"serverA"},"columns":["time","mean"],"values":[["1970-01-01T00:00:00Z",10.5]]}]},{"series":[{"name":"cpu","tags":{"host":"serverB"},"columns":["time","mean"],"values":[["1970-01-01T00:00:00Z",20.5]]}]}]`,
+		},
+	} {
+		if got := executeAndGetJSON(tt.q, executor); got != tt.exp {
+			t.Errorf("%s:\nexp: %s\ngot: %s", tt.q, tt.exp, got)
+		}
+	}
+}
+
+// Ensure fields of several measurements can be combined on time and tags.
+func TestQueryExecutor_Join(t *testing.T) {
+	store, executor := testStoreAndExecutor("")
+	defer os.RemoveAll(store.Path())
+
+	for i, host := range []string{"serverA", "serverB"} {
+		for j := 0; j < 2; j++ {
+			ts := mustParseTime("2000-01-01T00:00:00Z").Add(time.Duration(j) * 5 * time.Minute)
+			if err := store.WriteToShard(shardID, []models.Point{
+				models.NewPoint("requests", map[string]string{"host": host}, map[string]interface{}{"count": float64(100 * (i + 1))}, ts),
+				models.NewPoint("errors", map[string]string{"host": host}, map[string]interface{}{"count": float64(j + 1)}, ts),
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for _, tt := range []struct {
+		q   string
+		exp string
+	}{
+		{
+			q:   `SELECT sum(errors.count) / sum(requests.count) AS ratio FROM requests, errors WHERE time >= '2000-01-01T00:00:00Z' AND time < '2000-01-01T00:10:00Z' GROUP BY time(5m), host`,
+			exp: `[{"series":[{"name":"requests_errors","tags":{"host":"serverA"},"columns":["time","ratio"],"values":[["2000-01-01T00:00:00Z",0.01],["2000-01-01T00:05:00Z",0.02]]}]},{"series":[{"name":"requests_errors","tags":{"host":"serverB"},"columns":["time","ratio"],"values":[["2000-01-01T00:00:00Z",0.005],["2000-01-01T00:05:00Z",0.01]]}]}]`,
+		},
+		{
+			q:   `SELECT sum(requests.count), sum(errors.count) FROM requests, errors`,
+			exp: `[{"series":[{"name":"requests_errors","columns":["time","sum","sum"],"values":[["1970-01-01T00:00:00Z",600,6]]}]}]`,
+		},
+		{
+			q:   `SELECT requests.count / errors.count FROM requests, errors WHERE host = 'serverB'`,
+			exp: `[{"series":[{"name":"requests_errors","columns":["time",""],"values":[["2000-01-01T00:00:00Z",200],["2000-01-01T00:05:00Z",100]]}]}]`,
+		},
+		{
+			q:   `SELECT requests.count / errors.count FROM requests, errors`,
+			exp: `[{"error":"cannot join several series of requests in the same group, GROUP BY the tags which tell them apart"}]`,
+		},
+		{
+			q:   `SELECT requests.count / errors.count FROM requests, errors GROUP BY host`,
+			exp: `[{"series":[{"name":"requests_errors","tags":{"host":"serverA"},"columns":["time",""],"values":[["2000-01-01T00:00:00Z",100],["2000-01-01T00:05:00Z",50]]}]},{"series":[{"name":"requests_errors","tags":{"host":"serverB"},"columns":["time",""],"values":[["2000-01-01T00:00:00Z",200],["2000-01-01T00:05:00Z",100]]}]}]`,
+		},
+	} {
+		if got := executeAndGetJSON(tt.q, executor); got != tt.exp {
+			t.Errorf("%s:\nexp: %s\ngot: %s", tt.q, tt.exp, got)
+		}
+	}
+}
+
+// Ensure calls on every field are expanded and SELECT INTO writes into the target measurements.
+func TestQueryExecutor_SelectInto(t *testing.T) {
+	store, executor := testStoreAndExecutor("")
+	defer os.RemoveAll(store.Path())
+
+	w := &testIntoWriter{}
+	executor.IntoWriter = w
+
+	ts := mustParseTime("2000-01-01T00:00:00Z")
+	if err := store.WriteToShard(shardID, []models.Point{
+		models.NewPoint("cpu1", nil, map[string]interface{}{"value": float64(10), "status": "ok"}, ts),
+		models.NewPoint("cpu1", nil, map[string]interface{}{"value": float64(20), "status": "ok"}, ts.Add(time.Minute)),
+		models.NewPoint("cpu2", nil, map[string]interface{}{"value": float64(30)}, ts),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		q      string
+		exp    string
+		points []string
+	}{
+		{
+			q:   `SELECT mean(*) FROM cpu1`,
+			exp: `[{"series":[{"name":"cpu1","columns":["time","mean_value"],"values":[["1970-01-01T00:00:00Z",15]]}]}]`,
+		},
+		{
+			q:   `SELECT count(*) AS n FROM cpu1`,
+			exp: `[{"series":[{"name":"cpu1","columns":["time","n_status","n_value"],"values":[["1970-01-01T00:00:00Z",2,2]]}]}]`,
+		},
+		{
+			q:      `SELECT max(*) INTO ":MEASUREMENT_max" FROM /cpu/`,
+			exp:    `[{"series":[{"name":"result","columns":["time","written"],"values":[["1970-01-01T00:00:00Z",2]]}]}]`,
+			points: []string{"cpu1_max max_value=20 946684860000000000", "cpu2_max max_value=30 946684800000000000"},
+		},
+		{
+			q:      `SELECT value INTO "host_${1}" FROM /^cpu([0-9])$/ WHERE time < '2000-01-01T00:01:00Z'`,
+			exp:    `[{"series":[{"name":"result","columns":["time","written"],"values":[["1970-01-01T00:00:00Z",2]]}]}]`,
+			points: []string{"host_1 value=10 946684800000000000", "host_2 value=30 946684800000000000"},
+		},
+	} {
+		w.points = nil
+		if got := executeAndGetJSON(tt.q, executor); got != tt.exp {
+			t.Errorf("%s:\nexp: %s\ngot: %s", tt.q, tt.exp, got)
+		} else if !reflect.DeepEqual(w.points, tt.points) {
+			t.Errorf("%s: unexpected points:\nexp: %v\ngot: %v", tt.q, tt.points, w.points)
+		}
+	}
+
+	if got, exp := executeAndGetJSON(`SELECT mean(*) FROM cpu3`, executor), `[{"error":"mean(*) matches no fields"}]`; got != exp {
+		t.Errorf("unexpected error:\nexp: %s\ngot: %s", exp, got)
+	}
+}
+
+// Ensure EXPLAIN describes the plan of a statement and EXPLAIN ANALYZE executes it.
+func TestQueryExecutor_Explain(t *testing.T) {
+	store, executor := testStoreAndExecutor("")
+	defer os.RemoveAll(store.Path())
+
+	for _, host := range []string{"serverA", "serverB"} {
+		if err := store.WriteToShard(shardID, []models.Point{models.NewPoint(
+			"cpu",
+			map[string]string{"host": host},
+			map[string]interface{}{"value": 1.0},
+			time.Unix(1, 2),
+		)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		q   string
+		exp []string
+	}{
+		{
+			q:   `EXPLAIN SELECT count(value) FROM cpu GROUP BY host`,
+			exp: []string{"execution: aggregate", "map/reduce: count(value)", "fill: null", "shard group 2: ", "shard 1: local", "measurement cpu: 2 tag sets, 2 series"},
+		},
+		{
+			q:   `EXPLAIN SELECT derivative(value) FROM cpu WHERE host = 'serverA'`,
+			exp: []string{"execution: raw", "transform: derivative(value)", "shard 1: local", "measurement cpu: 1 tag sets, 1 series"},
+		},
+		{
+			q:   `EXPLAIN ANALYZE SELECT value FROM cpu GROUP BY host`,
+			exp: []string{"execution: raw", "2 chunks, 2 values in ", "reduce: ", "output: 2 series, 2 values", "total: "},
+		},
+		{
+			q:   `EXPLAIN ANALYZE SELECT max(count) FROM (SELECT count(value) FROM cpu GROUP BY host)`,
+			exp: []string{"subquery mapper, opened in ", "subquery:", "  execution: aggregate", "  shard 1: local, opened in ", "output: 1 series, 1 values"},
+		},
+		{
+			q:   `EXPLAIN SELECT mean(value) FROM cpu GROUP BY host ORDER BY max(value) DESC SLIMIT 1`,
+			exp: []string{"map/reduce: mean(value)", "series order: max(value) DESC", "rank:", "  map/reduce: max(value)"},
+		},
+	} {
+		ch, err := executor.ExecuteQuery(mustParseQuery(tt.q), "foo", 20, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var results []*influxql.Result
+		for r := range ch {
+			results = append(results, r)
+		}
+		if len(results) != 1 || results[0].Err != nil || len(results[0].Series) != 1 {
+			t.Fatalf("%s: unexpected results: %s", tt.q, spew.Sdump(results))
+		}
+
+		var lines []string
+		for _, v := range results[0].Series[0].Values {
+			lines = append(lines, v[0].(string))
+		}
+
+		// Expected text must appear in the plan in order.
+		i := 0
+		for _, line := range lines {
+			if i < len(tt.exp) && strings.Contains(line, tt.exp[i]) {
+				i++
+			}
+		}
+		if i != len(tt.exp) {
+			t.Errorf("%s: missing %q in plan:\n%s", tt.q, tt.exp[i], strings.Join(lines, "\n"))
+		}
+	}
+}
+
+// Ensure series, measurements and tag values can be counted.
+func TestQueryExecutor_ShowCardinality(t *testing.T) {
+	store, executor := testStoreAndExecutor("")
+	defer os.RemoveAll(store.Path())
+
+	for _, p := range []struct {
+		name string
+		tags map[string]string
+	}{
+		{name: "cpu", tags: map[string]string{"host": "serverA", "region": "uswest"}},
+		{name: "cpu", tags: map[string]string{"host": "serverB", "region": "uswest"}},
+		{name: "cpu", tags: map[string]string{"host": "serverC", "region": "useast"}},
+		{name: "mem", tags: map[string]string{"host": "serverA", "region": "uswest"}},
+	} {
+		if err := store.WriteToShard(shardID, []models.Point{models.NewPoint(
+			p.name,
+			p.tags,
+			map[string]interface{}{"value": 1.0},
+			time.Unix(1, 2),
+		)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		q   string
+		exp string
+	}{
+		{q: `SHOW SERIES CARDINALITY`, exp: `[{"series":[{"columns":["count"],"values":[[4]]}]}]`},
+		{q: `SHOW SERIES CARDINALITY FROM /.*/`, exp: `[{"series":[{"name":"cpu","columns":["count"],"values":[[3]]},{"name":"mem","columns":["count"],"values":[[1]]}]}]`},
+		{q: `SHOW SERIES CARDINALITY FROM cpu WHERE region = 'uswest'`, exp: `[{"series":[{"name":"cpu","columns":["count"],"values":[[2]]}]}]`},
+		{q: `SHOW SERIES CARDINALITY WHERE host = 'serverC'`, exp: `[{"series":[{"name":"cpu","columns":["count"],"values":[[1]]}]}]`},
+		{q: `SHOW SERIES CARDINALITY WHERE value > 1`, exp: `[{"error":"SHOW SERIES CARDINALITY doesn't support fields in WHERE clause"}]`},
+		{q: `SHOW MEASUREMENT CARDINALITY`, exp: `[{"series":[{"columns":["count"],"values":[[2]]}]}]`},
+		{q: `SHOW MEASUREMENT CARDINALITY WHERE region = 'useast'`, exp: `[{"series":[{"columns":["count"],"values":[[1]]}]}]`},
+		{q: `SHOW TAG VALUES CARDINALITY WITH KEY IN (host, region)`, exp: `[{"series":[{"columns":["key","count"],"values":[["host",3],["region",2]]}]}]`},
+		{q: `SHOW TAG VALUES CARDINALITY FROM mem WITH KEY = host`, exp: `[{"series":[{"columns":["key","count"],"values":[["host",1]]}]}]`},
+		{q: `SHOW TAG VALUES CARDINALITY WITH KEY = host WHERE region = 'uswest'`, exp: `[{"series":[{"columns":["key","count"],"values":[["host",2]]}]}]`},
+	} {
+		if got := executeAndGetJSON(tt.q, executor); got != tt.exp {
+			t.Errorf("%s:\nexp: %s\ngot: %s", tt.q, tt.exp, got)
+		}
+	}
+}
+
+// Ensure queries read series from a disk-backed series index, before and after a restart.
+func TestQueryExecutor_DiskSeriesIndex(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(dir)
+
+	open := func(maxLoadedSeries int) (*tsdb.Store, *tsdb.QueryExecutor) {
+		store := tsdb.NewStore(dir)
+		store.EngineOptions.Config.WALDir = filepath.Join(dir, "wal")
+		store.EngineOptions.Config.SeriesIndex = tsdb.DiskSeriesIndex
+		store.EngineOptions.Config.SeriesIndexMaxLoadedSeries = maxLoadedSeries
+		if err := store.Open(); err != nil {
+			t.Fatal(err)
+		} else if err := store.CreateShard("foo", "bar", shardID); err != nil {
+			t.Fatal(err)
+		}
+
+		executor := tsdb.NewQueryExecutor(store)
+		executor.MetaStore = &testMetastore{}
+		executor.ShardMapper = &testShardMapper{store: store}
+		return store, executor
+	}
+	store, executor := open(0)
+
+	for _, p := range []struct {
+		name string
+		tags map[string]string
+	}{
+		{name: "cpu", tags: map[string]string{"host": "serverA", "region": "uswest"}},
+		{name: "cpu", tags: map[string]string{"host": "serverB", "region": "useast"}},
+		{name: "mem", tags: map[string]string{"host": "serverA"}},
+	} {
+		if err := store.WriteToShard(shardID, []models.Point{models.NewPoint(
+			p.name,
+			p.tags,
+			map[string]interface{}{"value": 1.0},
+			time.Unix(1, 2),
+		)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		q   string
+		exp string
+	}{
+		{q: `SELECT value FROM cpu GROUP BY host`, exp: `[{"series":[{"name":"cpu","tags":{"host":"serverA"},"columns":["time","value"],"values":[["1970-01-01T00:00:01.000000002Z",1]]}]},{"series":[{"name":"cpu","tags":{"host":"serverB"},"columns":["time","value"],"values":[["1970-01-01T00:00:01.000000002Z",1]]}]}]`},
+		{q: `SELECT value FROM cpu WHERE region = 'useast'`, exp: `[{"series":[{"name":"cpu","columns":["time","value"],"values":[["1970-01-01T00:00:01.000000002Z",1]]}]}]`},
+		{q: `SHOW MEASUREMENTS WHERE region = 'uswest'`, exp: `[{"series":[{"name":"measurements","columns":["name"],"values":[["cpu"]]}]}]`},
+		{q: `SHOW SERIES FROM cpu WHERE host = 'serverB'`, exp: `[{"series":[{"name":"cpu","columns":["_key","host","region"],"values":[["cpu,host=serverB,region=useast","serverB","useast"]]}]}]`},
+		{q: `SHOW TAG KEYS FROM cpu`, exp: `[{"series":[{"name":"cpu","columns":["tagKey"],"values":[["host"],["region"]]}]}]`},
+		{q: `SHOW TAG VALUES WITH KEY = host`, exp: `[{"series":[{"name":"hostTagValues","columns":["host"],"values":[["serverA"],["serverB"]]}]}]`},
+		{q: `SHOW SERIES CARDINALITY`, exp: `[{"series":[{"columns":["count"],"values":[[3]]}]}]`},
+	}
+	check := func(stage string) {
+		for _, tt := range tests {
+			if got := executeAndGetJSON(tt.q, executor); got != tt.exp {
+				t.Errorf("%s: %s:\nexp: %s\ngot: %s", stage, tt.q, tt.exp, got)
+			}
+		}
+	}
+	check("written")
+
+	// Reopen with a limit forcing measurements to be unloaded between queries.
+	store.Close()
+	store, executor = open(1)
+	defer store.Close()
+	check("reopened")
+
+	if got, exp := executeAndGetJSON(`DROP SERIES FROM cpu WHERE host = 'serverA'`, executor), `[{}]`; got != exp {
+		t.Fatalf("\nexp: %s\ngot: %s", exp, got)
+	}
+	if got, exp := executeAndGetJSON(`SHOW SERIES`, executor), `[{"series":[{"name":"cpu","columns":["_key","host","region"],"values":[["cpu,host=serverB,region=useast","serverB","useast"]]},{"name":"mem","columns":["_key","host"],"values":[["mem,host=serverA","serverA"]]}]}]`; got != exp {
+		t.Fatalf("\nexp: %s\ngot: %s", exp, got)
+	}
+}
+
+// Ensure queries running for longer than the query timeout are interrupted.
+func TestQueryExecutor_Timeout(t *testing.T) {
+	store, executor := testStoreAndExecutor("")
+	defer os.RemoveAll(store.Path())
+
+	executor.ShardMapper = &blockingShardMapper{}
+	executor.QueryTimeout = 10 * time.Millisecond
+
+	if got, exp := executeAndGetJSON("SELECT * FROM cpu", executor), `[{"error":"query-timeout limit exceeded"}]`; got != exp {
+		t.Fatalf("\nexp: %s\ngot: %s", exp, got)
+	}
+}
+
+// blockingShardMapper creates mappers which block until they are interrupted.
+type blockingShardMapper struct{}
+
+func (t *blockingShardMapper) CreateMapper(shard meta.ShardInfo, stmt influxql.Statement, chunkSize int) (tsdb.Mapper, error) {
+	return &blockingMapper{closing: make(chan struct{})}, nil
+}
+
+type blockingMapper struct {
+	closing chan struct{}
+}
+
+func (m *blockingMapper) Open() error       { return nil }
+func (m *blockingMapper) TagSets() []string { return nil }
+func (m *blockingMapper) Fields() []string  { return nil }
+func (m *blockingMapper) Close()            {}
+func (m *blockingMapper) Interrupt()        { close(m.closing) }
+func (m *blockingMapper) NextChunk() (interface{}, error) {
+	<-m.closing
+	return nil, errors.New("mapper interrupted")
+}
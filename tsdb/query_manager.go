@@ -0,0 +1,108 @@
+package tsdb
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// QueryManager keeps track of the queries running on the server so they can
+// be listed and killed.
+type QueryManager struct {
+	mu      sync.Mutex
+	nextID  uint64
+	queries map[uint64]*queryTask
+}
+
+// QueryInfo describes a running query.
+type QueryInfo struct {
+	ID        uint64
+	Query     string
+	Database  string
+	User      string
+	StartTime time.Time
+}
+
+// queryTask is a running query and the channel used to interrupt it.
+type queryTask struct {
+	info    QueryInfo
+	closing chan struct{}
+	killed  bool
+}
+
+// NewQueryManager returns a new instance of QueryManager.
+func NewQueryManager() *QueryManager {
+	return &QueryManager{
+		nextID:  1,
+		queries: make(map[uint64]*queryTask),
+	}
+}
+
+// Attach registers a query and returns its ID. The returned channel is closed
+// when the query is killed.
+func (m *QueryManager) Attach(query, database, user string) (uint64, <-chan struct{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := m.nextID
+	m.nextID++
+
+	t := &queryTask{
+		info: QueryInfo{
+			ID:        id,
+			Query:     query,
+			Database:  database,
+			User:      user,
+			StartTime: time.Now().UTC(),
+		},
+		closing: make(chan struct{}),
+	}
+	m.queries[id] = t
+	return id, t.closing
+}
+
+// Detach removes a query once it has finished running.
+func (m *QueryManager) Detach(id uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.queries, id)
+}
+
+// Kill interrupts the query with the given ID.
+func (m *QueryManager) Kill(id uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := m.queries[id]
+	if t == nil {
+		return ErrQueryNotFound(id)
+	}
+	if !t.killed {
+		close(t.closing)
+		t.killed = true
+	}
+	return nil
+}
+
+// Queries returns the running queries, ordered by ID.
+func (m *QueryManager) Queries() []QueryInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a := make([]QueryInfo, 0, len(m.queries))
+	for _, t := range m.queries {
+		a = append(a, t.info)
+	}
+	sort.Sort(queryInfos(a))
+	return a
+}
+
+type queryInfos []QueryInfo
+
+func (a queryInfos) Len() int           { return len(a) }
+func (a queryInfos) Less(i, j int) bool { return a[i].ID < a[j].ID }
+func (a queryInfos) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
+// ErrQueryNotFound returns an error for a query ID that is not running.
+func ErrQueryNotFound(id uint64) error { return fmt.Errorf("query not found: %d", id) }
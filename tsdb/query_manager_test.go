@@ -0,0 +1,47 @@
+package tsdb_test
+
+import (
+	"testing"
+
+	"github.com/influxdb/influxdb/tsdb"
+)
+
+// Ensure queries can be attached, listed, killed and detached.
+func TestQueryManager(t *testing.T) {
+	m := tsdb.NewQueryManager()
+	id1, closing1 := m.Attach("SELECT * FROM cpu", "db0", "bob")
+	id2, closing2 := m.Attach("SELECT * FROM mem", "db1", "")
+
+	if queries := m.Queries(); len(queries) != 2 {
+		t.Fatalf("unexpected queries: %v", queries)
+	} else if q := queries[0]; q.ID != id1 || q.Query != "SELECT * FROM cpu" || q.Database != "db0" || q.User != "bob" || q.StartTime.IsZero() {
+		t.Fatalf("unexpected query: %v", q)
+	} else if queries[1].ID != id2 {
+		t.Fatalf("unexpected query: %v", queries[1])
+	}
+
+	// Killing a query closes its channel only. It may be killed more than once.
+	if err := m.Kill(id1); err != nil {
+		t.Fatal(err)
+	} else if err := m.Kill(id1); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-closing1:
+	default:
+		t.Fatal("expected killed query to be closed")
+	}
+	select {
+	case <-closing2:
+		t.Fatal("unexpected close")
+	default:
+	}
+
+	// Detached queries are no longer listed and cannot be killed.
+	m.Detach(id1)
+	if queries := m.Queries(); len(queries) != 1 || queries[0].ID != id2 {
+		t.Fatalf("unexpected queries: %v", queries)
+	} else if err := m.Kill(id1); err == nil {
+		t.Fatal("expected error")
+	}
+}
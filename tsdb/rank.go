@@ -0,0 +1,213 @@
+package tsdb
+
+import (
+	"sort"
+
+	"github.com/influxdb/influxdb/influxql"
+	"github.com/influxdb/influxdb/models"
+)
+
+// seriesRanking orders the series returned by a SelectExecutor by the value of an
+// aggregate over each series, and applies SLIMIT and SOFFSET to the ranked series.
+// The aggregate is computed first, so the statement only reads the series it returns.
+type seriesRanking struct {
+	executor  *SelectExecutor // Returns the aggregate of each series.
+	ascending bool
+	slimit    int
+	soffset   int
+}
+
+// execute ranks the series by their aggregate, restricts the statement of e to the
+// ranked series within SLIMIT and SOFFSET and writes its rows to out, in rank order.
+func (r *seriesRanking) execute(e *SelectExecutor, out chan *models.Row, closing <-chan struct{}) {
+	defer close(out)
+
+	// Series without a value are always ranked last, so only the others are ranked here.
+	var ranked []*rankedSeries
+	for row := range r.executor.Execute(closing) {
+		if row.Err != nil {
+			out <- row
+			e.close()
+			return
+		}
+		if len(row.Values) == 0 || len(row.Values[0]) < 2 {
+			continue
+		}
+		if v, ok := rankValue(row.Values[0][1]); ok {
+			ranked = append(ranked, &rankedSeries{key: rankKey(row), name: row.Name, tags: row.Tags, value: v})
+		}
+	}
+	sort.Stable(&rankedSeriesSlice{a: ranked, ascending: r.ascending})
+
+	// If the ranked series fill SLIMIT, only they are read. Otherwise the series without
+	// a value are returned after them, in the order the statement returns them. HAVING
+	// may drop ranked series, so every series is read then.
+	exact := r.slimit > 0 && r.soffset+r.slimit <= len(ranked) && e.stmt.Having == nil
+	if exact {
+		ranked = ranked[r.soffset : r.soffset+r.slimit]
+		restrictSeries(e.stmt, ranked)
+	}
+
+	keys := make([]string, 0, len(ranked))
+	ranks := make(map[string]struct{}, len(ranked))
+	for _, s := range ranked {
+		keys = append(keys, s.key)
+		ranks[s.key] = struct{}{}
+	}
+
+	// Group the rows by series, the same series may be returned in several chunks.
+	rows := make(map[string][]*models.Row)
+	var unranked []string
+	in := make(chan *models.Row, 0)
+	e.execute(in)
+	for row := range in {
+		if row.Err != nil {
+			out <- row
+			return
+		}
+
+		k := rankKey(row)
+		if _, ok := ranks[k]; !ok {
+			if exact {
+				continue
+			} else if _, ok := rows[k]; !ok {
+				unranked = append(unranked, k)
+			}
+		}
+		rows[k] = append(rows[k], row)
+	}
+
+	if !exact {
+		// Ranked series without rows left, such as those dropped by HAVING, are skipped.
+		returned := keys[:0]
+		for _, k := range keys {
+			if _, ok := rows[k]; ok {
+				returned = append(returned, k)
+			}
+		}
+		keys = append(returned, unranked...)
+		if r.soffset > len(keys) {
+			return
+		}
+		keys = keys[r.soffset:]
+		if r.slimit > 0 && r.slimit < len(keys) {
+			keys = keys[:r.slimit]
+		}
+	}
+
+	for _, k := range keys {
+		for _, row := range rows[k] {
+			out <- row
+		}
+	}
+}
+
+// restrictSeries restricts the sources and condition of stmt to the measurements and
+// tag sets of series. Empty tag values can't be matched by a condition, so other series
+// may still be selected; their rows are dropped by the ranking.
+func restrictSeries(stmt *influxql.SelectStatement, series []*rankedSeries) {
+	if stmt.IsJoin() {
+		return
+	}
+	for _, src := range stmt.Sources {
+		if mm, ok := src.(*influxql.Measurement); !ok || mm.Regex != nil {
+			return
+		}
+	}
+
+	names := make(map[string]struct{})
+	var cond influxql.Expr
+	matchAll := false
+	for _, s := range series {
+		names[s.name] = struct{}{}
+
+		var expr influxql.Expr
+		for _, k := range sortedTagKeys(s.tags) {
+			if s.tags[k] == "" {
+				continue
+			}
+			eq := &influxql.BinaryExpr{Op: influxql.EQ, LHS: &influxql.VarRef{Val: k}, RHS: &influxql.StringLiteral{Val: s.tags[k]}}
+			if expr == nil {
+				expr = eq
+			} else {
+				expr = &influxql.BinaryExpr{Op: influxql.AND, LHS: expr, RHS: eq}
+			}
+		}
+		if expr == nil {
+			matchAll = true
+		} else if cond == nil {
+			cond = expr
+		} else {
+			cond = &influxql.BinaryExpr{Op: influxql.OR, LHS: cond, RHS: expr}
+		}
+	}
+
+	sources := stmt.Sources[:0]
+	for _, src := range stmt.Sources {
+		if _, ok := names[src.(*influxql.Measurement).Name]; ok {
+			sources = append(sources, src)
+		}
+	}
+	stmt.Sources = sources
+
+	if matchAll || cond == nil {
+		return
+	} else if stmt.Condition == nil {
+		stmt.Condition = cond
+		return
+	}
+	stmt.Condition = &influxql.BinaryExpr{
+		Op:  influxql.AND,
+		LHS: &influxql.ParenExpr{Expr: stmt.Condition},
+		RHS: &influxql.ParenExpr{Expr: cond},
+	}
+}
+
+// sortedTagKeys returns the keys of tags in sorted order.
+func sortedTagKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// rankKey returns the key identifying the series of a row.
+func rankKey(row *models.Row) string {
+	return row.Name + "|" + string(MarshalTags(row.Tags))
+}
+
+// rankedSeries is a series and the value of the aggregate it's ranked by.
+type rankedSeries struct {
+	key   string
+	name  string
+	tags  map[string]string
+	value float64
+}
+
+// rankedSeriesSlice sorts series by the value of their aggregate.
+type rankedSeriesSlice struct {
+	a         []*rankedSeries
+	ascending bool
+}
+
+func (s *rankedSeriesSlice) Len() int      { return len(s.a) }
+func (s *rankedSeriesSlice) Swap(i, j int) { s.a[i], s.a[j] = s.a[j], s.a[i] }
+func (s *rankedSeriesSlice) Less(i, j int) bool {
+	if s.ascending {
+		return s.a[i].value < s.a[j].value
+	}
+	return s.a[i].value > s.a[j].value
+}
+
+// rankValue returns the numeric value of an aggregate, or false if it has none.
+func rankValue(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
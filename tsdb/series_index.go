@@ -0,0 +1,381 @@
+package tsdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdb/influxdb/tsdb/seriesindex"
+)
+
+const (
+	// SeriesIndexDir is the directory of a database holding its disk-backed series index.
+	SeriesIndexDir = "_series"
+
+	// Types of series index.
+	InmemSeriesIndex = "inmem"
+	DiskSeriesIndex  = "disk"
+
+	// indexedShardsDir is the directory of a disk-backed series index holding a file
+	// for each closed shard whose series are all in the index.
+	indexedShardsDir = "shards"
+)
+
+// OpenDiskDatabaseIndex opens a database index whose series are stored in a disk-backed
+// index at path. At most maxLoadedSeriesN series are kept in memory, zero meaning no limit.
+func OpenDiskDatabaseIndex(path string, maxLoadedSeriesN int) (*DatabaseIndex, error) {
+	disk := seriesindex.NewIndex(path)
+	if err := disk.Open(); err != nil {
+		return nil, err
+	}
+
+	d := NewDatabaseIndex()
+	d.disk = disk
+	d.maxLoadedSeriesN = maxLoadedSeriesN
+	d.shardSeries = make(map[uint64]seriesBitset)
+	d.indexedShards = make(map[uint64]bool)
+
+	// Measurements are indexed without their series, which are loaded when queried.
+	for _, name := range disk.MeasurementNames() {
+		d.measurements[name] = NewMeasurement(name, d)
+	}
+
+	fis, err := ioutil.ReadDir(filepath.Join(path, indexedShardsDir))
+	if err != nil && !os.IsNotExist(err) {
+		disk.Close()
+		return nil, err
+	}
+	for _, fi := range fis {
+		if id, err := strconv.ParseUint(fi.Name(), 10, 64); err == nil {
+			d.indexedShards[id] = false
+		}
+	}
+	return d, nil
+}
+
+// SeriesIndexed returns true if the series of a shard are all in the disk-backed index,
+// so engines don't need to load them again when the shard is opened.
+func (d *DatabaseIndex) SeriesIndexed(sh *Shard) bool {
+	if d.disk == nil || sh == nil {
+		return false
+	}
+	opened, ok := d.indexedShards[sh.id]
+	return ok && !opened
+}
+
+// openShard is called once a shard is opened. Series written to it from now on could be
+// lost from the index by a crash, so its series are loaded again when it's next opened,
+// unless it's closed cleanly. The caller must hold a write lock on the index.
+func (d *DatabaseIndex) openShard(shardID uint64) error {
+	if d.disk == nil {
+		return nil
+	}
+	if err := os.Remove(d.indexedShardPath(shardID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	d.indexedShards[shardID] = true
+	return nil
+}
+
+// closeShard records that all the series of a shard that was opened are in the index,
+// once the index is flushed to disk.
+func (d *DatabaseIndex) closeShard(shardID uint64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.disk == nil || !d.indexedShards[shardID] {
+		return nil
+	}
+
+	if err := d.disk.Sync(); err != nil {
+		return err
+	}
+	path := d.indexedShardPath(shardID)
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, nil, 0666); err != nil {
+		return err
+	}
+	d.indexedShards[shardID] = false
+	return nil
+}
+
+// indexedShardPath returns the path of the file recording that the series of a shard
+// are all in the index.
+func (d *DatabaseIndex) indexedShardPath(shardID uint64) string {
+	return filepath.Join(d.disk.Path(), indexedShardsDir, strconv.FormatUint(shardID, 10))
+}
+
+// Close closes the disk-backed index of the series. It's a no-op for in-memory indexes.
+func (d *DatabaseIndex) Close() error {
+	if d.disk == nil {
+		return nil
+	}
+	return d.disk.Close()
+}
+
+// diskSeries returns a series of the disk-backed index by key, or nil if it doesn't exist.
+// The series of a loaded measurement is returned if there is one.
+func (d *DatabaseIndex) diskSeries(key string) *Series {
+	ds := d.disk.SeriesByKey(key)
+	if ds == nil {
+		return nil
+	}
+	if m := d.measurements[ds.Name]; m != nil {
+		m.mu.RLock()
+		s := m.seriesByID[ds.ID]
+		m.mu.RUnlock()
+		if s != nil {
+			return s
+		}
+	}
+	return newDiskSeries(ds, nil)
+}
+
+// createDiskSeriesIfNotExists adds a series to the disk-backed index, and to its measurement
+// if the series of the measurement are loaded.
+func (d *DatabaseIndex) createDiskSeriesIfNotExists(measurementName string, series *Series) (*Series, error) {
+	m := d.CreateMeasurementIndexIfNotExists(measurementName)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, err := d.disk.CreateSeriesIfNotExists(series.Key, m.Name, series.Tags)
+	if err != nil {
+		return nil, err
+	}
+	series.id = id
+	series.measurement = m
+
+	if m.loaded {
+		if ss := m.seriesByID[id]; ss != nil {
+			return ss, nil
+		}
+		m.addSeries(series)
+		atomic.AddInt64(&d.loadedSeriesN, 1)
+	}
+	return series, nil
+}
+
+// dropDiskMeasurement removes a measurement and its series from the disk-backed index.
+func (d *DatabaseIndex) dropDiskMeasurement(m *Measurement) error {
+	if err := d.disk.DropMeasurement(m.Name); err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	if m.loaded {
+		atomic.AddInt64(&d.loadedSeriesN, -int64(len(m.seriesIDs)))
+	}
+	m.mu.RUnlock()
+
+	delete(d.measurements, m.Name)
+	return nil
+}
+
+// dropDiskSeries removes series from the disk-backed index by key.
+func (d *DatabaseIndex) dropDiskSeries(keys []string) error {
+	var ids []uint64
+	for _, k := range keys {
+		ds := d.disk.SeriesByKey(k)
+		if ds == nil {
+			continue
+		}
+		ids = append(ids, ds.ID)
+
+		if m := d.measurements[ds.Name]; m != nil {
+			m.mu.RLock()
+			_, ok := m.seriesByID[ds.ID]
+			m.mu.RUnlock()
+			if ok {
+				m.DropSeries(ds.ID)
+				atomic.AddInt64(&d.loadedSeriesN, -1)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return d.disk.DropSeries(ids)
+}
+
+// seriesInShard returns true if the series with key was persisted by a shard. The caller
+// must hold a lock on the index.
+func (d *DatabaseIndex) seriesInShard(key string, shardID uint64) bool {
+	if d.disk == nil {
+		ss := d.series[key]
+		return ss != nil && ss.shardIDs[shardID]
+	}
+	id := d.disk.SeriesID(key)
+	return id != 0 && d.shardSeries[shardID].contains(id)
+}
+
+// addSeriesToShard records the series with key as persisted by a shard. The caller must
+// hold a write lock on the index.
+func (d *DatabaseIndex) addSeriesToShard(key string, shardID uint64) {
+	if d.disk == nil {
+		if ss := d.series[key]; ss != nil {
+			ss.shardIDs[shardID] = true
+		}
+		return
+	}
+
+	id := d.disk.SeriesID(key)
+	if id == 0 {
+		return
+	}
+	d.shardSeries[shardID] = d.shardSeries[shardID].add(id)
+}
+
+// removeShard forgets the series persisted by a deleted shard.
+func (d *DatabaseIndex) removeShard(shardID uint64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.disk == nil {
+		for _, ss := range d.series {
+			delete(ss.shardIDs, shardID)
+		}
+		return nil
+	}
+	delete(d.shardSeries, shardID)
+	delete(d.indexedShards, shardID)
+	if err := os.Remove(d.indexedShardPath(shardID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// evict unloads the least recently used measurements until the number of loaded series
+// is under the limit. Evicted measurements are replaced by unloaded copies, so queries
+// holding them keep reading consistent series.
+func (d *DatabaseIndex) evict() {
+	defer atomic.StoreInt32(&d.evicting, 0)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var loaded measurementsByLastUsed
+	for _, m := range d.measurements {
+		m.mu.RLock()
+		if m.loaded {
+			loaded = append(loaded, m)
+		}
+		m.mu.RUnlock()
+	}
+	sort.Sort(loaded)
+
+	for _, m := range loaded {
+		if atomic.LoadInt64(&d.loadedSeriesN) <= int64(d.maxLoadedSeriesN) {
+			break
+		}
+
+		m.mu.RLock()
+		other := NewMeasurement(m.Name, d)
+		for name := range m.fieldNames {
+			other.fieldNames[name] = struct{}{}
+		}
+		n := len(m.seriesIDs)
+		m.mu.RUnlock()
+
+		d.measurements[m.Name] = other
+		atomic.AddInt64(&d.loadedSeriesN, -int64(n))
+	}
+}
+
+// onDisk returns true if the series of the measurement are only in the disk-backed index.
+// The caller must hold a lock on the measurement.
+func (m *Measurement) onDisk() bool {
+	return !m.loaded && m.index != nil && m.index.disk != nil
+}
+
+// load reads the series of the measurement from the disk-backed index into memory, if
+// they aren't already loaded, and marks the measurement as used. It must be called before
+// the in-memory series of the measurement are read without a lock on the index.
+func (m *Measurement) load() {
+	if m.index == nil || m.index.disk == nil {
+		return
+	}
+	d := m.index
+	atomic.StoreInt64(&m.lastUsed, time.Now().UnixNano())
+
+	m.mu.Lock()
+	if m.loaded {
+		m.mu.Unlock()
+		return
+	}
+	ids := d.disk.MeasurementSeriesIDs(m.Name)
+	for _, id := range ids {
+		if ds := d.disk.Series(id); ds != nil {
+			m.addSeries(newDiskSeries(ds, m))
+		}
+	}
+	m.loaded = true
+	m.mu.Unlock()
+
+	n := atomic.AddInt64(&d.loadedSeriesN, int64(len(ids)))
+	if d.maxLoadedSeriesN > 0 && n > int64(d.maxLoadedSeriesN) && atomic.CompareAndSwapInt32(&d.evicting, 0, 1) {
+		go d.evict()
+	}
+}
+
+// tagValueSet returns the values of a tag key mapped to the ids of their series. Ids are only
+// set if the series of the measurement are loaded.
+func (m *Measurement) tagValueSet(key string) (map[string]SeriesIDs, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if !m.onDisk() {
+		values, ok := m.seriesByTagKeyValue[key]
+		return values, ok
+	}
+
+	a := m.index.disk.TagValues(m.Name, key)
+	if len(a) == 0 {
+		return nil, false
+	}
+	values := make(map[string]SeriesIDs, len(a))
+	for _, v := range a {
+		values[v] = nil
+	}
+	return values, true
+}
+
+// newDiskSeries returns a series of a measurement read from the disk-backed index.
+func newDiskSeries(ds *seriesindex.Series, m *Measurement) *Series {
+	s := NewSeries(ds.Key, ds.Tags)
+	s.id = ds.ID
+	s.measurement = m
+	return s
+}
+
+// seriesBitset is a set of series ids.
+type seriesBitset []uint64
+
+// contains returns true if id is in the set.
+func (b seriesBitset) contains(id uint64) bool {
+	i := id / 64
+	return i < uint64(len(b)) && b[i]&(1<<(id%64)) != 0
+}
+
+// add adds id to the set and returns the set, grown if needed.
+func (b seriesBitset) add(id uint64) seriesBitset {
+	i := id / 64
+	if i >= uint64(len(b)) {
+		other := make(seriesBitset, i+1, 2*(i+1))
+		copy(other, b)
+		b = other
+	}
+	b[i] |= 1 << (id % 64)
+	return b
+}
+
+type measurementsByLastUsed []*Measurement
+
+func (a measurementsByLastUsed) Len() int { return len(a) }
+func (a measurementsByLastUsed) Less(i, j int) bool {
+	return atomic.LoadInt64(&a[i].lastUsed) < atomic.LoadInt64(&a[j].lastUsed)
+}
+func (a measurementsByLastUsed) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
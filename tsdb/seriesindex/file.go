@@ -0,0 +1,557 @@
+package seriesindex
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+)
+
+// An index file is written by a compaction and memory-mapped when opened. It starts with
+// a header and ends with a trailer locating its sections:
+//
+//	header       magic (4 bytes), version (1 byte)
+//	series       entries: id, key, measurement name, tags
+//	id table     offset of the entry of each series id, zero if the series was dropped
+//	key table    offsets of the series entries sorted by key
+//	measurements for each measurement the entries of its tag values, then of its tag
+//	             keys, each holding the offsets of its values, and an entry with the
+//	             measurement name, the offsets of its tag keys and its series ids
+//	name table   offsets of the measurement entries sorted by name
+//	trailer      id table offset, max id, key table offset, series count, name table
+//	             offset, measurement count (8 bytes each), magic (4 bytes)
+//
+// Strings are prefixed by their uvarint length, and lists of series ids are a uvarint
+// count followed by the uvarint deltas of the sorted ids.
+const (
+	indexFileMagic   uint32 = 0x5349444B
+	indexFileVersion        = 1
+
+	indexFileHeaderSize  = 5
+	indexFileTrailerSize = 6*8 + 4
+)
+
+// ErrIndexFileCorrupt is returned when an index file cannot be opened.
+var ErrIndexFileCorrupt = errors.New("index file corrupt")
+
+// indexFile is a read-only, memory-mapped index file.
+type indexFile struct {
+	seq  int
+	path string
+	f    *os.File
+	data []byte
+
+	maxID     uint64
+	idTable   []byte
+	keyTable  []byte
+	nameTable []byte
+}
+
+// openIndexFile opens and maps the index file at path.
+func openIndexFile(seq int, path string) (*indexFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if fi.Size() < indexFileHeaderSize+indexFileTrailerSize {
+		f.Close()
+		return nil, ErrIndexFileCorrupt
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	file := &indexFile{seq: seq, path: path, f: f, data: data}
+	if err := file.readTrailer(); err != nil {
+		file.close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// readTrailer locates the tables of the file.
+func (f *indexFile) readTrailer() error {
+	size := uint64(len(f.data))
+	t := f.data[size-indexFileTrailerSize:]
+	if binary.BigEndian.Uint32(f.data[0:4]) != indexFileMagic || f.data[4] != indexFileVersion ||
+		binary.BigEndian.Uint32(t[48:52]) != indexFileMagic {
+		return ErrIndexFileCorrupt
+	}
+
+	table := func(off, n uint64) ([]byte, error) {
+		if off > size || n > (size-off)/8 {
+			return nil, ErrIndexFileCorrupt
+		}
+		return f.data[off : off+n*8], nil
+	}
+
+	var err error
+	f.maxID = binary.BigEndian.Uint64(t[8:16])
+	if f.idTable, err = table(binary.BigEndian.Uint64(t[0:8]), f.maxID); err != nil {
+		return err
+	}
+	if f.keyTable, err = table(binary.BigEndian.Uint64(t[16:24]), binary.BigEndian.Uint64(t[24:32])); err != nil {
+		return err
+	}
+	if f.nameTable, err = table(binary.BigEndian.Uint64(t[32:40]), binary.BigEndian.Uint64(t[40:48])); err != nil {
+		return err
+	}
+	return nil
+}
+
+// close unmaps and closes the file.
+func (f *indexFile) close() error {
+	if f.data != nil {
+		if err := syscall.Munmap(f.data); err != nil {
+			return err
+		}
+		f.data = nil
+	}
+	return f.f.Close()
+}
+
+// seriesN returns the number of series in the file.
+func (f *indexFile) seriesN() int { return len(f.keyTable) / 8 }
+
+// decoderAt returns a decoder reading the file at off.
+func (f *indexFile) decoderAt(off uint64) *decoder {
+	if off >= uint64(len(f.data)) {
+		return &decoder{err: true}
+	}
+	return &decoder{buf: f.data[off:]}
+}
+
+// seriesAt decodes the series entry at off.
+func (f *indexFile) seriesAt(off uint64) *Series {
+	d := f.decoderAt(off)
+	s := &Series{ID: d.uvarint(), Key: d.string(), Name: d.string(), Tags: d.tags()}
+	if d.err {
+		return nil
+	}
+	return s
+}
+
+// seriesByID returns the series with an id, or nil if it isn't in the file.
+func (f *indexFile) seriesByID(id uint64) *Series {
+	if id == 0 || id > f.maxID {
+		return nil
+	}
+	off := binary.BigEndian.Uint64(f.idTable[(id-1)*8:])
+	if off == 0 {
+		return nil
+	}
+	return f.seriesAt(off)
+}
+
+// seriesByKey returns the series with a key, or nil if it isn't in the file.
+func (f *indexFile) seriesByKey(key string) *Series {
+	if off := f.searchKey(key); off != 0 {
+		return f.seriesAt(off)
+	}
+	return nil
+}
+
+// seriesID returns the id and measurement name of the series with a key, or a zero id
+// if it isn't in the file.
+func (f *indexFile) seriesID(key string) (uint64, string) {
+	off := f.searchKey(key)
+	if off == 0 {
+		return 0, ""
+	}
+	d := f.decoderAt(off)
+	id := d.uvarint()
+	d.bytes()
+	name := d.string()
+	if d.err {
+		return 0, ""
+	}
+	return id, name
+}
+
+// searchKey returns the offset of the entry of the series with a key, or zero if it
+// isn't in the file.
+func (f *indexFile) searchKey(key string) uint64 {
+	n := f.seriesN()
+	i := sort.Search(n, func(i int) bool {
+		d := f.decoderAt(tableOffset(f.keyTable, i))
+		d.uvarint()
+		return string(d.bytes()) >= key
+	})
+	if i == n {
+		return 0
+	}
+
+	off := tableOffset(f.keyTable, i)
+	d := f.decoderAt(off)
+	d.uvarint()
+	if string(d.bytes()) != key || d.err {
+		return 0
+	}
+	return off
+}
+
+// measurementNames returns the sorted names of the measurements in the file.
+func (f *indexFile) measurementNames() []string {
+	names := make([]string, len(f.nameTable)/8)
+	for i := range names {
+		names[i] = f.decoderAt(tableOffset(f.nameTable, i)).string()
+	}
+	return names
+}
+
+// measurement returns a decoder positioned after the name of a measurement entry,
+// or nil if the measurement isn't in the file.
+func (f *indexFile) measurement(name string) *decoder {
+	return f.search(f.nameTable, name)
+}
+
+// search returns a decoder positioned after the name of the entry found by name in a
+// table of entries sorted by name, or nil if there is no such entry.
+func (f *indexFile) search(table []byte, name string) *decoder {
+	n := len(table) / 8
+	i := sort.Search(n, func(i int) bool {
+		return string(f.decoderAt(tableOffset(table, i)).bytes()) >= name
+	})
+	if i == n {
+		return nil
+	}
+	d := f.decoderAt(tableOffset(table, i))
+	if string(d.bytes()) != name || d.err {
+		return nil
+	}
+	return d
+}
+
+// measurementSeriesIDs returns the sorted ids of the series of a measurement.
+func (f *indexFile) measurementSeriesIDs(name string) []uint64 {
+	d := f.measurement(name)
+	if d == nil {
+		return nil
+	}
+	d.table()
+	return d.ids()
+}
+
+// tagKeys returns the sorted tag keys of a measurement.
+func (f *indexFile) tagKeys(name string) []string {
+	d := f.measurement(name)
+	if d == nil {
+		return nil
+	}
+	table := d.table()
+	keys := make([]string, len(table)/8)
+	for i := range keys {
+		keys[i] = f.decoderAt(tableOffset(table, i)).string()
+	}
+	return keys
+}
+
+// tagKey returns the table of the values of a tag key of a measurement.
+func (f *indexFile) tagKey(name, key string) []byte {
+	d := f.measurement(name)
+	if d == nil {
+		return nil
+	}
+	if d = f.search(d.table(), key); d == nil {
+		return nil
+	}
+	return d.table()
+}
+
+// tagValues returns the sorted values of a tag key of a measurement.
+func (f *indexFile) tagValues(name, key string) []string {
+	table := f.tagKey(name, key)
+	values := make([]string, len(table)/8)
+	for i := range values {
+		values[i] = f.decoderAt(tableOffset(table, i)).string()
+	}
+	return values
+}
+
+// tagValueSeriesIDs returns the sorted ids of the series of a measurement with a tag value.
+func (f *indexFile) tagValueSeriesIDs(name, key, value string) []uint64 {
+	d := f.search(f.tagKey(name, key), value)
+	if d == nil {
+		return nil
+	}
+	return d.ids()
+}
+
+// tableOffset returns the i-th offset of a table.
+func tableOffset(table []byte, i int) uint64 {
+	return binary.BigEndian.Uint64(table[i*8:])
+}
+
+// writeIndexFile writes the series of a view to a new index file at path. The file is
+// written to a temporary file first and only renamed to path once synced to disk.
+func writeIndexFile(path string, v *view) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+
+	w := &indexWriter{w: bufio.NewWriterSize(f, 64*1024)}
+	w.write(v)
+	if err := w.err; err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := w.w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir flushes the entries of a directory to disk.
+func syncDir(path string) error {
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// indexWriter writes an index file, tracking the offset written to. The first error
+// encountered stops all writes.
+type indexWriter struct {
+	w   *bufio.Writer
+	n   uint64
+	err error
+}
+
+// bytes writes b and returns the offset it was written at.
+func (w *indexWriter) bytes(b []byte) uint64 {
+	off := w.n
+	if w.err != nil {
+		return off
+	}
+	n, err := w.w.Write(b)
+	w.n += uint64(n)
+	w.err = err
+	return off
+}
+
+// table writes a table of offsets and returns its offset.
+func (w *indexWriter) table(offsets []uint64) uint64 {
+	buf := make([]byte, len(offsets)*8)
+	for i, off := range offsets {
+		binary.BigEndian.PutUint64(buf[i*8:], off)
+	}
+	return w.bytes(buf)
+}
+
+// write writes the series of a view.
+func (w *indexWriter) write(v *view) {
+	var hdr [indexFileHeaderSize]byte
+	binary.BigEndian.PutUint32(hdr[0:4], indexFileMagic)
+	hdr[4] = indexFileVersion
+	w.bytes(hdr[:])
+
+	// Write the series in id order, the series of the index file being older than
+	// the ones of its logs.
+	maxID := v.maxID()
+	byID := make([]uint64, maxID)
+	v.eachSeries(func(s *Series) {
+		byID[s.ID-1] = w.bytes(encodeSeries(s))
+	})
+	idTable := w.table(byID)
+
+	var seriesN int
+	keyTable := w.n
+	v.eachSeriesByKey(func(s *Series) {
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], byID[s.ID-1])
+		w.bytes(buf[:])
+		seriesN++
+	})
+	byID = nil
+
+	// Write the measurements and the inverted index of their tags.
+	var names []uint64
+	for _, name := range v.measurementNames() {
+		ids := v.measurementSeriesIDs(name)
+		if len(ids) == 0 {
+			continue
+		}
+
+		var tagKeys []uint64
+		for _, key := range v.tagKeys(name) {
+			var values []uint64
+			for _, value := range v.tagValues(name, key) {
+				buf := appendString(nil, value)
+				buf = appendIDs(buf, v.tagValueSeriesIDs(name, key, value))
+				values = append(values, w.bytes(buf))
+			}
+			tagKeys = append(tagKeys, w.bytes(appendTable(appendString(nil, key), values)))
+		}
+
+		buf := appendTable(appendString(nil, name), tagKeys)
+		names = append(names, w.bytes(appendIDs(buf, ids)))
+	}
+	nameTable := w.table(names)
+
+	var trailer [indexFileTrailerSize]byte
+	binary.BigEndian.PutUint64(trailer[0:8], idTable)
+	binary.BigEndian.PutUint64(trailer[8:16], maxID)
+	binary.BigEndian.PutUint64(trailer[16:24], keyTable)
+	binary.BigEndian.PutUint64(trailer[24:32], uint64(seriesN))
+	binary.BigEndian.PutUint64(trailer[32:40], nameTable)
+	binary.BigEndian.PutUint64(trailer[40:48], uint64(len(names)))
+	binary.BigEndian.PutUint32(trailer[48:52], indexFileMagic)
+	w.bytes(trailer[:])
+}
+
+type uint64Slice []uint64
+
+func (a uint64Slice) Len() int           { return len(a) }
+func (a uint64Slice) Less(i, j int) bool { return a[i] < a[j] }
+func (a uint64Slice) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
+// appendUvarint appends the uvarint encoding of v to buf.
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+// appendString appends a length-prefixed string to buf.
+func appendString(buf []byte, s string) []byte {
+	buf = appendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// appendTags appends the number of tags followed by their sorted keys and values to buf.
+func appendTags(buf []byte, tags map[string]string) []byte {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf = appendUvarint(buf, uint64(len(keys)))
+	for _, k := range keys {
+		buf = appendString(buf, k)
+		buf = appendString(buf, tags[k])
+	}
+	return buf
+}
+
+// appendIDs appends the number of ids followed by the deltas of the sorted ids to buf.
+func appendIDs(buf []byte, ids []uint64) []byte {
+	buf = appendUvarint(buf, uint64(len(ids)))
+	var prev uint64
+	for _, id := range ids {
+		buf = appendUvarint(buf, id-prev)
+		prev = id
+	}
+	return buf
+}
+
+// appendTable appends the number of offsets followed by the offsets to buf.
+func appendTable(buf []byte, offsets []uint64) []byte {
+	buf = appendUvarint(buf, uint64(len(offsets)))
+	for _, off := range offsets {
+		var tmp [8]byte
+		binary.BigEndian.PutUint64(tmp[:], off)
+		buf = append(buf, tmp[:]...)
+	}
+	return buf
+}
+
+// decoder reads the encoded values of a buffer. A value that cannot be decoded sets err
+// and the zero value is returned for it and any value read after it.
+type decoder struct {
+	buf []byte
+	err bool
+}
+
+func (d *decoder) fail() {
+	d.buf, d.err = nil, true
+}
+
+func (d *decoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.uvarint()
+	if n > uint64(len(d.buf)) {
+		d.fail()
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) string() string { return string(d.bytes()) }
+
+func (d *decoder) tags() map[string]string {
+	n := d.uvarint()
+	tags := make(map[string]string)
+	for i := uint64(0); i < n && !d.err; i++ {
+		k := d.string()
+		tags[k] = d.string()
+	}
+	return tags
+}
+
+func (d *decoder) ids() []uint64 {
+	n := d.uvarint()
+	if n > uint64(len(d.buf)) {
+		d.fail()
+		return nil
+	}
+	ids := make([]uint64, 0, n)
+	var prev uint64
+	for i := uint64(0); i < n && !d.err; i++ {
+		prev += d.uvarint()
+		ids = append(ids, prev)
+	}
+	return ids
+}
+
+// table returns a table of offsets.
+func (d *decoder) table() []byte {
+	n := d.uvarint()
+	if n > uint64(len(d.buf))/8 {
+		d.fail()
+		return nil
+	}
+	table := d.buf[:n*8]
+	d.buf = d.buf[n*8:]
+	return table
+}
@@ -0,0 +1,557 @@
+// Package seriesindex implements a persistent index of the series of a database and
+// of their tags.
+//
+// New series are appended to a log and indexed in memory. Once the log is large enough
+// it is compacted with the previous index file into a new index file. Index files are
+// memory-mapped, so looking up a series or the series with a tag value only reads the
+// pages of the file holding them.
+package seriesindex
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+const (
+	// DefaultMaxLogSize is the size of the log above which it's compacted.
+	DefaultMaxLogSize = 16 * 1024 * 1024 // 16MB
+
+	// Extensions of the files of an index.
+	indexFileExt = ".idx"
+	logFileExt   = ".log"
+	tmpFileExt   = ".tmp"
+)
+
+// Series is a series stored in the index. Series returned by the index must not be modified.
+type Series struct {
+	ID   uint64
+	Key  string
+	Name string
+	Tags map[string]string
+}
+
+// Index is a persistent index of series stored in a directory. Series are given ids,
+// starting at 1, which are never reused.
+type Index struct {
+	mu         sync.RWMutex
+	path       string
+	file       *indexFile
+	logs       []*logFile // oldest first, the last one is written to
+	maxID      uint64
+	seriesN    int
+	opened     bool
+	compacting bool
+	wg         sync.WaitGroup
+
+	// MaxLogSize is the size of the log above which it's compacted in the background.
+	// Zero disables compactions, except the ones run by Compact().
+	MaxLogSize int64
+
+	logger *log.Logger
+}
+
+// NewIndex returns a new index stored in path.
+func NewIndex(path string) *Index {
+	return &Index{
+		path:       path,
+		MaxLogSize: DefaultMaxLogSize,
+		logger:     log.New(os.Stderr, "[seriesindex] ", log.LstdFlags),
+	}
+}
+
+// Path returns the path of the directory of the index.
+func (i *Index) Path() string { return i.path }
+
+// SetLogOutput sets the writer used to log errors of background compactions.
+func (i *Index) SetLogOutput(w io.Writer) {
+	i.logger = log.New(w, "[seriesindex] ", log.LstdFlags)
+}
+
+// Open opens the index, creating its directory if it doesn't exist, and replays its logs.
+func (i *Index) Open() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if err := os.MkdirAll(i.path, 0777); err != nil {
+		return err
+	}
+	i.maxID, i.seriesN = 0, 0
+
+	fis, err := ioutil.ReadDir(i.path)
+	if err != nil {
+		return err
+	}
+
+	var fileSeqs, logSeqs []int
+	for _, fi := range fis {
+		ext := filepath.Ext(fi.Name())
+		if ext == tmpFileExt {
+			// Left by an interrupted compaction.
+			if err := os.Remove(filepath.Join(i.path, fi.Name())); err != nil {
+				return err
+			}
+			continue
+		}
+
+		seq, err := strconv.Atoi(fi.Name()[:len(fi.Name())-len(ext)])
+		if err != nil {
+			continue
+		}
+		switch ext {
+		case indexFileExt:
+			fileSeqs = append(fileSeqs, seq)
+		case logFileExt:
+			logSeqs = append(logSeqs, seq)
+		}
+	}
+	sort.Ints(fileSeqs)
+	sort.Ints(logSeqs)
+
+	// The last index file holds the series of the logs up to its sequence number. Older
+	// files and logs are left when a compaction is interrupted before removing them.
+	var seq int
+	if len(fileSeqs) > 0 {
+		seq = fileSeqs[len(fileSeqs)-1]
+		f, err := openIndexFile(seq, i.filePath(seq, indexFileExt))
+		if err != nil {
+			return fmt.Errorf("open %s: %s", i.filePath(seq, indexFileExt), err)
+		}
+		i.file = f
+		i.maxID = f.maxID
+		i.seriesN = f.seriesN()
+
+		for _, s := range fileSeqs[:len(fileSeqs)-1] {
+			if err := os.Remove(i.filePath(s, indexFileExt)); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, s := range logSeqs {
+		if s <= seq {
+			if err := os.Remove(i.filePath(s, logFileExt)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := i.replay(newLogFile(s, i.filePath(s, logFileExt))); err != nil {
+			return err
+		}
+		seq = s
+	}
+
+	// Append to the last log, or start one.
+	if len(i.logs) == 0 {
+		i.logs = append(i.logs, newLogFile(seq+1, i.filePath(seq+1, logFileExt)))
+	}
+	if err := i.active().open(); err != nil {
+		return err
+	}
+
+	i.opened = true
+	return nil
+}
+
+// replay reads the records of a log and adds the log to the index.
+func (i *Index) replay(l *logFile) error {
+	i.logs = append(i.logs, l)
+	if err := readLogRecords(l.path, func(typ byte, payload []byte) error {
+		switch typ {
+		case logSeries:
+			s, err := decodeSeries(payload)
+			if err != nil {
+				return err
+			}
+			i.applySeries(l, s)
+		case logDropSeries:
+			d := decoder{buf: payload}
+			id := d.uvarint()
+			if d.err {
+				return ErrLogRecordCorrupt
+			}
+			i.applyDropSeries(l, id)
+		case logDropMeasurement:
+			d := decoder{buf: payload}
+			name := d.string()
+			if d.err {
+				return ErrLogRecordCorrupt
+			}
+			i.applyDropMeasurement(l, name)
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("replay %s: %s", l.path, err)
+	}
+
+	fi, err := os.Stat(l.path)
+	if err != nil {
+		return err
+	}
+	l.size = fi.Size()
+	return nil
+}
+
+// Close waits for a running compaction to finish and closes the files of the index.
+func (i *Index) Close() error {
+	i.mu.Lock()
+	i.opened = false
+	i.mu.Unlock()
+
+	i.wg.Wait()
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for _, l := range i.logs {
+		if err := l.close(); err != nil {
+			return err
+		}
+	}
+	i.logs = nil
+
+	if i.file != nil {
+		if err := i.file.close(); err != nil {
+			return err
+		}
+		i.file = nil
+	}
+	return nil
+}
+
+// Sync flushes the series written to the log to disk.
+func (i *Index) Sync() error {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if len(i.logs) == 0 {
+		return nil
+	}
+	return i.active().sync()
+}
+
+// filePath returns the path of the file of the index with a sequence number.
+func (i *Index) filePath(seq int, ext string) string {
+	return filepath.Join(i.path, fmt.Sprintf("%08d%s", seq, ext))
+}
+
+// active returns the log written to.
+func (i *Index) active() *logFile { return i.logs[len(i.logs)-1] }
+
+// view returns a view of the file and all the logs of the index.
+func (i *Index) view() *view { return &view{file: i.file, logs: i.logs} }
+
+// SeriesN returns the number of series in the index.
+func (i *Index) SeriesN() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.seriesN
+}
+
+// Series returns a series by id, or nil if it doesn't exist.
+func (i *Index) Series(id uint64) *Series {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.view().seriesByID(id)
+}
+
+// SeriesID returns the id of a series by key, or zero if it doesn't exist.
+func (i *Index) SeriesID(key string) uint64 {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.view().seriesID(key)
+}
+
+// SeriesByKey returns a series by key, or nil if it doesn't exist.
+func (i *Index) SeriesByKey(key string) *Series {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.view().seriesByKey(key)
+}
+
+// MeasurementNames returns the sorted names of the measurements with series.
+func (i *Index) MeasurementNames() []string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	v := i.view()
+	var names []string
+	for _, name := range v.measurementNames() {
+		if v.hasDeletes(name) && len(v.measurementSeriesIDs(name)) == 0 {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// MeasurementSeriesIDs returns the sorted ids of the series of a measurement.
+func (i *Index) MeasurementSeriesIDs(name string) []uint64 {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.view().measurementSeriesIDs(name)
+}
+
+// TagKeys returns the sorted tag keys of the series of a measurement.
+func (i *Index) TagKeys(name string) []string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.view().tagKeys(name)
+}
+
+// TagValues returns the sorted values of a tag key of the series of a measurement.
+func (i *Index) TagValues(name, key string) []string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.view().tagValues(name, key)
+}
+
+// TagValueN returns the number of values of a tag key of the series of a measurement.
+// Unless series were dropped since the last compaction, values aren't read to be counted.
+func (i *Index) TagValueN(name, key string) int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.view().tagValueN(name, key)
+}
+
+// HasTagValue returns true if a series of a measurement has a tag value.
+func (i *Index) HasTagValue(name, key, value string) bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.view().hasTagValue(name, key, value)
+}
+
+// TagValueSeriesIDs returns the sorted ids of the series of a measurement with a tag value.
+func (i *Index) TagValueSeriesIDs(name, key, value string) []uint64 {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.view().tagValueSeriesIDs(name, key, value)
+}
+
+// CreateSeriesIfNotExists adds a series to the index and returns its id, or returns the
+// id of the existing series with the same key.
+func (i *Index) CreateSeriesIfNotExists(key, name string, tags map[string]string) (uint64, error) {
+	i.mu.RLock()
+	s := i.view().seriesByKey(key)
+	i.mu.RUnlock()
+	if s != nil {
+		return s.ID, nil
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if s := i.view().seriesByKey(key); s != nil {
+		return s.ID, nil
+	}
+
+	s = &Series{ID: i.maxID + 1, Key: key, Name: name, Tags: make(map[string]string, len(tags))}
+	for k, v := range tags {
+		s.Tags[k] = v
+	}
+
+	l := i.active()
+	if err := l.append(logSeries, encodeSeries(s)); err != nil {
+		return 0, err
+	}
+	i.applySeries(l, s)
+
+	if i.opened && !i.compacting && i.MaxLogSize > 0 && l.size >= i.MaxLogSize {
+		i.compacting = true
+		i.wg.Add(1)
+		go func() {
+			defer i.wg.Done()
+			if err := i.compact(); err != nil {
+				i.logger.Printf("compaction of %s failed: %s", i.path, err)
+			}
+		}()
+	}
+
+	return s.ID, nil
+}
+
+// DropSeries removes series from the index.
+func (i *Index) DropSeries(ids []uint64) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	l := i.active()
+	for _, id := range ids {
+		if i.view().seriesByID(id) == nil {
+			continue
+		}
+		if err := l.append(logDropSeries, appendUvarint(nil, id)); err != nil {
+			return err
+		}
+		i.applyDropSeries(l, id)
+	}
+	return l.sync()
+}
+
+// DropMeasurement removes the series of a measurement from the index.
+func (i *Index) DropMeasurement(name string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	l := i.active()
+	if err := l.append(logDropMeasurement, appendString(nil, name)); err != nil {
+		return err
+	}
+	i.applyDropMeasurement(l, name)
+	return l.sync()
+}
+
+// applySeries adds a series to a log, the last one of the index.
+func (i *Index) applySeries(l *logFile, s *Series) {
+	// Tag values are counted by the log adding them to the index first.
+	v := i.view()
+	var values map[string]string
+	for k, value := range s.Tags {
+		if !v.containsTagValue(s.Name, k, value) {
+			if values == nil {
+				values = make(map[string]string)
+			}
+			values[k] = value
+		}
+	}
+
+	l.addSeries(s)
+	l.addNewTagValues(s.Name, values)
+	if s.ID > i.maxID {
+		i.maxID = s.ID
+	}
+	i.seriesN++
+}
+
+// applyDropSeries removes a series in a log.
+func (i *Index) applyDropSeries(l *logFile, id uint64) {
+	if i.view().seriesByID(id) == nil {
+		return
+	}
+	l.dropSeries(id)
+	i.seriesN--
+}
+
+// applyDropMeasurement removes the series of a measurement in a log.
+func (i *Index) applyDropMeasurement(l *logFile, name string) {
+	i.seriesN -= len(i.view().measurementSeriesIDs(name))
+	l.dropMeasurement(name)
+}
+
+// Compact writes the series of the index file and of the logs to a new index file.
+// It's a no-op if a compaction is already running.
+func (i *Index) Compact() error {
+	i.mu.Lock()
+	if i.compacting {
+		i.mu.Unlock()
+		return nil
+	}
+	i.compacting = true
+	i.mu.Unlock()
+
+	return i.compact()
+}
+
+// compact compacts the index file and the logs, except the one written to, which is
+// replaced by a new log first if it isn't empty. The caller must set compacting.
+func (i *Index) compact() error {
+	i.mu.Lock()
+	if !i.active().empty() {
+		if err := i.rotate(); err != nil {
+			i.compacting = false
+			i.mu.Unlock()
+			return err
+		}
+	}
+	v := &view{file: i.file, logs: i.logs[:len(i.logs)-1]}
+	i.mu.Unlock()
+
+	// The index file and the logs compacted are no longer modified, so they're read
+	// without holding the lock.
+	var f *indexFile
+	var err error
+	if len(v.logs) > 0 {
+		seq := v.logs[len(v.logs)-1].seq
+		path := i.filePath(seq, indexFileExt)
+		if err = writeIndexFile(path, v); err == nil {
+			f, err = openIndexFile(seq, path)
+		}
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.compacting = false
+	if f == nil {
+		return err
+	}
+
+	// Replace the previous file and the compacted logs by the new file.
+	prev := i.file
+	i.file = f
+	i.logs = i.logs[len(v.logs):]
+	i.recountTagValues()
+
+	if prev != nil {
+		if err := prev.close(); err != nil {
+			return err
+		}
+		if err := os.Remove(prev.path); err != nil {
+			return err
+		}
+	}
+	for _, l := range v.logs {
+		if err := l.close(); err != nil {
+			return err
+		}
+		if err := os.Remove(l.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recountTagValues finds the tag values added to the index by each log again. The
+// values of series dropped before a compaction aren't in the new index file, so they
+// may have been counted by the logs left as existing ones.
+func (i *Index) recountTagValues() {
+	for j, l := range i.logs {
+		older := &view{file: i.file, logs: i.logs[:j]}
+		for name, m := range l.measurements {
+			m.newValues = make(map[string]map[string]struct{})
+			for k, values := range m.tags {
+				for value := range values {
+					if !older.containsTagValue(name, k, value) {
+						if m.newValues[k] == nil {
+							m.newValues[k] = make(map[string]struct{})
+						}
+						m.newValues[k][value] = struct{}{}
+					}
+				}
+			}
+		}
+	}
+}
+
+// rotate closes the active log and starts a new one.
+func (i *Index) rotate() error {
+	prev := i.active()
+	l := newLogFile(prev.seq+1, i.filePath(prev.seq+1, logFileExt))
+	if err := l.open(); err != nil {
+		return err
+	}
+	if err := prev.sync(); err != nil {
+		l.close()
+		return err
+	}
+	if err := prev.close(); err != nil {
+		l.close()
+		return err
+	}
+	i.logs = append(i.logs, l)
+	return nil
+}
@@ -0,0 +1,321 @@
+package seriesindex_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/influxdb/influxdb/tsdb/seriesindex"
+)
+
+// Ensure series are indexed by key, id, measurement and tag value.
+func TestIndex_CreateSeriesIfNotExists(t *testing.T) {
+	idx := MustOpenIndex()
+	defer idx.Close()
+
+	idx.MustCreateSeries("cpu,host=a,region=west", "cpu", map[string]string{"host": "a", "region": "west"})
+	idx.MustCreateSeries("cpu,host=b,region=west", "cpu", map[string]string{"host": "b", "region": "west"})
+	idx.MustCreateSeries("mem,host=a", "mem", map[string]string{"host": "a"})
+
+	if id := idx.MustCreateSeries("cpu,host=b,region=west", "cpu", map[string]string{"host": "b", "region": "west"}); id != 2 {
+		t.Fatalf("unexpected id for existing series: %d", id)
+	}
+
+	check := func(stage string) {
+		if n := idx.SeriesN(); n != 3 {
+			t.Fatalf("%s: unexpected series count: %d", stage, n)
+		}
+		if s := idx.SeriesByKey("cpu,host=b,region=west"); s == nil || s.ID != 2 || s.Name != "cpu" || !reflect.DeepEqual(s.Tags, map[string]string{"host": "b", "region": "west"}) {
+			t.Fatalf("%s: unexpected series by key: %#v", stage, s)
+		}
+		if id := idx.SeriesID("cpu,host=a,region=west"); id != 1 {
+			t.Fatalf("%s: unexpected series id: %d", stage, id)
+		}
+		if s := idx.Series(3); s == nil || s.Key != "mem,host=a" {
+			t.Fatalf("%s: unexpected series by id: %#v", stage, s)
+		}
+		if s := idx.SeriesByKey("cpu,host=c"); s != nil {
+			t.Fatalf("%s: unexpected series: %#v", stage, s)
+		}
+		if ids := idx.MeasurementSeriesIDs("cpu"); !reflect.DeepEqual(ids, []uint64{1, 2}) {
+			t.Fatalf("%s: unexpected measurement series: %v", stage, ids)
+		}
+		if keys := idx.TagKeys("cpu"); !reflect.DeepEqual(keys, []string{"host", "region"}) {
+			t.Fatalf("%s: unexpected tag keys: %v", stage, keys)
+		}
+		if values := idx.TagValues("cpu", "host"); !reflect.DeepEqual(values, []string{"a", "b"}) {
+			t.Fatalf("%s: unexpected tag values: %v", stage, values)
+		}
+		if ids := idx.TagValueSeriesIDs("cpu", "region", "west"); !reflect.DeepEqual(ids, []uint64{1, 2}) {
+			t.Fatalf("%s: unexpected tag value series: %v", stage, ids)
+		}
+		if ids := idx.TagValueSeriesIDs("mem", "host", "b"); len(ids) != 0 {
+			t.Fatalf("%s: unexpected tag value series: %v", stage, ids)
+		}
+		if n := idx.TagValueN("cpu", "host"); n != 2 {
+			t.Fatalf("%s: unexpected tag value count: %d", stage, n)
+		}
+		if n := idx.TagValueN("cpu", "region"); n != 1 {
+			t.Fatalf("%s: unexpected tag value count: %d", stage, n)
+		}
+		if !idx.HasTagValue("cpu", "region", "west") || idx.HasTagValue("cpu", "host", "c") || idx.HasTagValue("mem", "region", "west") {
+			t.Fatalf("%s: unexpected tag value membership", stage)
+		}
+	}
+
+	check("log")
+	idx.MustReopen()
+	check("replayed log")
+	if err := idx.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	check("compacted")
+	idx.MustReopen()
+	check("reopened")
+
+	// Series created after a compaction are read with the ones of the index file.
+	idx.MustCreateSeries("cpu,host=c,region=east", "cpu", map[string]string{"host": "c", "region": "east"})
+	if ids := idx.MeasurementSeriesIDs("cpu"); !reflect.DeepEqual(ids, []uint64{1, 2, 4}) {
+		t.Fatalf("unexpected measurement series: %v", ids)
+	}
+	if values := idx.TagValues("cpu", "region"); !reflect.DeepEqual(values, []string{"east", "west"}) {
+		t.Fatalf("unexpected tag values: %v", values)
+	}
+	if n := idx.TagValueN("cpu", "region"); n != 2 {
+		t.Fatalf("unexpected tag value count: %d", n)
+	}
+	if n := idx.TagValueN("cpu", "host"); n != 3 {
+		t.Fatalf("unexpected tag value count: %d", n)
+	}
+	if !idx.HasTagValue("cpu", "region", "east") {
+		t.Fatal("expected tag value")
+	}
+}
+
+// Ensure dropped series are removed from the index and their ids aren't reused.
+func TestIndex_DropSeries(t *testing.T) {
+	idx := MustOpenIndex()
+	defer idx.Close()
+
+	idx.MustCreateSeries("cpu,host=a", "cpu", map[string]string{"host": "a"})
+	idx.MustCreateSeries("cpu,host=b", "cpu", map[string]string{"host": "b"})
+	if err := idx.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	idx.MustCreateSeries("cpu,host=c", "cpu", map[string]string{"host": "c"})
+
+	// Drop a series of the index file and one of the log.
+	if err := idx.DropSeries([]uint64{1, 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(stage string) {
+		if n := idx.SeriesN(); n != 1 {
+			t.Fatalf("%s: unexpected series count: %d", stage, n)
+		}
+		if s := idx.SeriesByKey("cpu,host=a"); s != nil {
+			t.Fatalf("%s: unexpected series: %#v", stage, s)
+		}
+		if s := idx.Series(3); s != nil {
+			t.Fatalf("%s: unexpected series: %#v", stage, s)
+		}
+		if id := idx.SeriesID("cpu,host=c"); id != 0 {
+			t.Fatalf("%s: unexpected series id: %d", stage, id)
+		}
+		if ids := idx.MeasurementSeriesIDs("cpu"); !reflect.DeepEqual(ids, []uint64{2}) {
+			t.Fatalf("%s: unexpected measurement series: %v", stage, ids)
+		}
+		if values := idx.TagValues("cpu", "host"); !reflect.DeepEqual(values, []string{"b"}) {
+			t.Fatalf("%s: unexpected tag values: %v", stage, values)
+		}
+		if n := idx.TagValueN("cpu", "host"); n != 1 {
+			t.Fatalf("%s: unexpected tag value count: %d", stage, n)
+		}
+		if idx.HasTagValue("cpu", "host", "a") || idx.HasTagValue("cpu", "host", "c") || !idx.HasTagValue("cpu", "host", "b") {
+			t.Fatalf("%s: unexpected tag value membership", stage)
+		}
+	}
+
+	check("log")
+	idx.MustReopen()
+	check("replayed log")
+	if err := idx.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	check("compacted")
+	idx.MustReopen()
+	check("reopened")
+
+	if id := idx.MustCreateSeries("cpu,host=a", "cpu", map[string]string{"host": "a"}); id != 4 {
+		t.Fatalf("unexpected id for recreated series: %d", id)
+	}
+	if n := idx.TagValueN("cpu", "host"); n != 2 {
+		t.Fatalf("unexpected tag value count: %d", n)
+	}
+	if !idx.HasTagValue("cpu", "host", "a") {
+		t.Fatal("expected tag value")
+	}
+}
+
+// Ensure dropping a measurement removes all of its series.
+func TestIndex_DropMeasurement(t *testing.T) {
+	idx := MustOpenIndex()
+	defer idx.Close()
+
+	idx.MustCreateSeries("cpu,host=a", "cpu", map[string]string{"host": "a"})
+	idx.MustCreateSeries("mem,host=a", "mem", map[string]string{"host": "a"})
+	if err := idx.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	idx.MustCreateSeries("cpu,host=b", "cpu", map[string]string{"host": "b"})
+
+	if err := idx.DropMeasurement("cpu"); err != nil {
+		t.Fatal(err)
+	}
+	if names := idx.MeasurementNames(); !reflect.DeepEqual(names, []string{"mem"}) {
+		t.Fatalf("unexpected measurements: %v", names)
+	}
+	idx.MustCreateSeries("cpu,region=east", "cpu", map[string]string{"region": "east"})
+
+	check := func(stage string) {
+		if n := idx.SeriesN(); n != 2 {
+			t.Fatalf("%s: unexpected series count: %d", stage, n)
+		}
+		if ids := idx.MeasurementSeriesIDs("cpu"); !reflect.DeepEqual(ids, []uint64{4}) {
+			t.Fatalf("%s: unexpected measurement series: %v", stage, ids)
+		}
+		if names := idx.MeasurementNames(); !reflect.DeepEqual(names, []string{"cpu", "mem"}) {
+			t.Fatalf("%s: unexpected measurements: %v", stage, names)
+		}
+		if keys := idx.TagKeys("cpu"); !reflect.DeepEqual(keys, []string{"region"}) {
+			t.Fatalf("%s: unexpected tag keys: %v", stage, keys)
+		}
+		if s := idx.SeriesByKey("mem,host=a"); s == nil || s.ID != 2 {
+			t.Fatalf("%s: unexpected series: %#v", stage, s)
+		}
+	}
+
+	check("log")
+	idx.MustReopen()
+	check("replayed log")
+	if err := idx.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	check("compacted")
+	idx.MustReopen()
+	check("reopened")
+}
+
+// Ensure the log is compacted in the background once it reaches its maximum size.
+func TestIndex_MaxLogSize(t *testing.T) {
+	idx := MustOpenIndex()
+	defer idx.Close()
+	idx.MaxLogSize = 1024
+
+	for i := 0; i < 1000; i++ {
+		idx.MustCreateSeries(fmt.Sprintf("cpu,host=%d", i), "cpu", map[string]string{"host": fmt.Sprintf("%d", i)})
+	}
+	idx.MustReopen()
+
+	if n := idx.SeriesN(); n != 1000 {
+		t.Fatalf("unexpected series count: %d", n)
+	}
+	for i := 0; i < 1000; i++ {
+		if s := idx.SeriesByKey(fmt.Sprintf("cpu,host=%d", i)); s == nil || s.ID != uint64(i+1) {
+			t.Fatalf("unexpected series %d: %#v", i, s)
+		}
+	}
+	if values := idx.TagValues("cpu", "host"); len(values) != 1000 {
+		t.Fatalf("unexpected tag value count: %d", len(values))
+	}
+
+	fis, err := ioutil.ReadDir(idx.Path())
+	if err != nil {
+		t.Fatal(err)
+	} else if len(fis) > 3 {
+		t.Fatalf("logs not compacted: %d files", len(fis))
+	}
+}
+
+// Ensure a partially written log record is ignored when the log is replayed.
+func TestIndex_Open_TruncatedLog(t *testing.T) {
+	idx := MustOpenIndex()
+	defer idx.Close()
+
+	idx.MustCreateSeries("cpu,host=a", "cpu", map[string]string{"host": "a"})
+	idx.MustCreateSeries("cpu,host=b", "cpu", map[string]string{"host": "b"})
+	if err := idx.Index.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Cut the last record.
+	path := idx.Path() + "/00000001.log"
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := os.Truncate(path, fi.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := idx.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if n := idx.SeriesN(); n != 1 {
+		t.Fatalf("unexpected series count: %d", n)
+	}
+	if id := idx.MustCreateSeries("cpu,host=c", "cpu", map[string]string{"host": "c"}); id != 2 {
+		t.Fatalf("unexpected id: %d", id)
+	}
+	idx.MustReopen()
+	if s := idx.SeriesByKey("cpu,host=c"); s == nil {
+		t.Fatal("series appended after truncated record not found")
+	}
+}
+
+// Index is a test wrapper for seriesindex.Index.
+type Index struct {
+	*seriesindex.Index
+}
+
+// MustOpenIndex returns a new, open index in a temporary directory. Panic on error.
+func MustOpenIndex() *Index {
+	path, err := ioutil.TempDir("", "seriesindex-")
+	if err != nil {
+		panic(err)
+	}
+	idx := &Index{Index: seriesindex.NewIndex(path)}
+	if err := idx.Open(); err != nil {
+		panic(err)
+	}
+	return idx
+}
+
+// Close closes the index and removes its directory.
+func (idx *Index) Close() error {
+	defer os.RemoveAll(idx.Path())
+	return idx.Index.Close()
+}
+
+// MustReopen closes and reopens the index. Panic on error.
+func (idx *Index) MustReopen() {
+	maxLogSize := idx.MaxLogSize
+	if err := idx.Index.Close(); err != nil {
+		panic(err)
+	}
+	idx.Index = seriesindex.NewIndex(idx.Path())
+	idx.MaxLogSize = maxLogSize
+	if err := idx.Open(); err != nil {
+		panic(err)
+	}
+}
+
+// MustCreateSeries creates a series and returns its id. Panic on error.
+func (idx *Index) MustCreateSeries(key, name string, tags map[string]string) uint64 {
+	id, err := idx.CreateSeriesIfNotExists(key, name, tags)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
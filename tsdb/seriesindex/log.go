@@ -0,0 +1,278 @@
+package seriesindex
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+const (
+	// Types of the log records.
+	logSeries           = 1
+	logDropSeries       = 2
+	logDropMeasurement  = 3
+	logRecordHeaderSize = 5 // type and payload size
+	logRecordFooterSize = 4 // checksum of the payload
+)
+
+// ErrLogRecordCorrupt is returned when a record of a log cannot be decoded.
+var ErrLogRecordCorrupt = errors.New("log record corrupt")
+
+// logFile holds the series written or dropped since the log was created. Changes are
+// appended to the file and applied to maps used to read them until the log is compacted.
+// Only the active log, the last one of an index, is ever written to.
+type logFile struct {
+	seq  int
+	path string
+	f    *os.File
+	size int64
+
+	// Highest id given to a series of the log, even if it was dropped since.
+	maxID uint64
+
+	series       map[string]*Series         // series created in the log by key
+	seriesByID   map[uint64]*Series         // series created in the log by id
+	measurements map[string]*logMeasurement // measurements of the series created in the log
+	tombstones   map[uint64]struct{}        // series of older logs or the index file dropped in the log
+	dropped      map[string]struct{}        // measurements of older logs or the index file dropped in the log
+}
+
+// logMeasurement indexes the series of a measurement created in a log.
+type logMeasurement struct {
+	ids  map[uint64]struct{}
+	tags map[string]map[string]map[uint64]struct{} // tag key to value to series ids
+
+	// Tag values by key which weren't in the index file or older logs when the log added them.
+	newValues map[string]map[string]struct{}
+}
+
+func newLogFile(seq int, path string) *logFile {
+	return &logFile{
+		seq:          seq,
+		path:         path,
+		series:       make(map[string]*Series),
+		seriesByID:   make(map[uint64]*Series),
+		measurements: make(map[string]*logMeasurement),
+		tombstones:   make(map[uint64]struct{}),
+		dropped:      make(map[string]struct{}),
+	}
+}
+
+// open opens the log file for appending, creating it if it doesn't exist.
+func (l *logFile) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_RDWR, 0666)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Seek(fi.Size(), os.SEEK_SET); err != nil {
+		f.Close()
+		return err
+	}
+	l.f, l.size = f, fi.Size()
+	return nil
+}
+
+// close closes the file of the log. Its records can still be read.
+func (l *logFile) close() error {
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// empty returns true if nothing was written to the log.
+func (l *logFile) empty() bool { return l.size == 0 }
+
+// readLogRecords reads the records of the log file at path and calls fn for each of them.
+// A partially written record at the end of the file, left by a crash, is truncated.
+func readLogRecords(path string, fn func(typ byte, payload []byte) error) error {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var n int
+	for n < len(buf) {
+		typ, payload, sz := decodeLogRecord(buf[n:])
+		if sz == 0 {
+			break
+		}
+		if err := fn(typ, payload); err != nil {
+			return err
+		}
+		n += sz
+	}
+
+	if n < len(buf) {
+		return os.Truncate(path, int64(n))
+	}
+	return nil
+}
+
+// decodeLogRecord returns the type and payload of the record at the start of buf, and the size
+// of the record. The size is zero if the record is incomplete or its checksum doesn't match.
+func decodeLogRecord(buf []byte) (typ byte, payload []byte, n int) {
+	if len(buf) < logRecordHeaderSize {
+		return 0, nil, 0
+	}
+	sz := int(binary.BigEndian.Uint32(buf[1:logRecordHeaderSize]))
+	n = logRecordHeaderSize + sz + logRecordFooterSize
+	if sz < 0 || n > len(buf) {
+		return 0, nil, 0
+	}
+
+	payload = buf[logRecordHeaderSize : logRecordHeaderSize+sz]
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(buf[n-logRecordFooterSize:n]) {
+		return 0, nil, 0
+	}
+	return buf[0], payload, n
+}
+
+// append writes a record to the log file.
+func (l *logFile) append(typ byte, payload []byte) error {
+	buf := make([]byte, logRecordHeaderSize+len(payload)+logRecordFooterSize)
+	buf[0] = typ
+	binary.BigEndian.PutUint32(buf[1:logRecordHeaderSize], uint32(len(payload)))
+	copy(buf[logRecordHeaderSize:], payload)
+	binary.BigEndian.PutUint32(buf[len(buf)-logRecordFooterSize:], crc32.ChecksumIEEE(payload))
+
+	if _, err := l.f.Write(buf); err != nil {
+		// Remove a partially written record, records appended after it couldn't be read.
+		l.f.Truncate(l.size)
+		l.f.Seek(l.size, os.SEEK_SET)
+		return err
+	}
+	l.size += int64(len(buf))
+	return nil
+}
+
+// sync flushes the log file to disk.
+func (l *logFile) sync() error { return l.f.Sync() }
+
+// addSeries indexes a series created in the log.
+func (l *logFile) addSeries(s *Series) {
+	l.series[s.Key] = s
+	l.seriesByID[s.ID] = s
+	if s.ID > l.maxID {
+		l.maxID = s.ID
+	}
+
+	m := l.measurements[s.Name]
+	if m == nil {
+		m = &logMeasurement{
+			ids:       make(map[uint64]struct{}),
+			tags:      make(map[string]map[string]map[uint64]struct{}),
+			newValues: make(map[string]map[string]struct{}),
+		}
+		l.measurements[s.Name] = m
+	}
+	m.ids[s.ID] = struct{}{}
+
+	for k, v := range s.Tags {
+		values := m.tags[k]
+		if values == nil {
+			values = make(map[string]map[uint64]struct{})
+			m.tags[k] = values
+		}
+		ids := values[v]
+		if ids == nil {
+			ids = make(map[uint64]struct{})
+			values[v] = ids
+		}
+		ids[s.ID] = struct{}{}
+	}
+}
+
+// dropSeries removes a series created in the log, or records a tombstone for the series
+// if it was created in an older log or the index file.
+func (l *logFile) dropSeries(id uint64) {
+	s := l.seriesByID[id]
+	if s == nil {
+		l.tombstones[id] = struct{}{}
+		return
+	}
+
+	delete(l.series, s.Key)
+	delete(l.seriesByID, id)
+
+	m := l.measurements[s.Name]
+	delete(m.ids, id)
+	if len(m.ids) == 0 {
+		delete(l.measurements, s.Name)
+		return
+	}
+	for k, v := range s.Tags {
+		delete(m.tags[k][v], id)
+		if len(m.tags[k][v]) == 0 {
+			delete(m.tags[k], v)
+			delete(m.newValues[k], v)
+		}
+		if len(m.tags[k]) == 0 {
+			delete(m.tags, k)
+		}
+	}
+}
+
+// addNewTagValues records tag values of a measurement as added to the index by the log.
+func (l *logFile) addNewTagValues(name string, tags map[string]string) {
+	m := l.measurements[name]
+	for k, v := range tags {
+		if m.newValues[k] == nil {
+			m.newValues[k] = make(map[string]struct{})
+		}
+		m.newValues[k][v] = struct{}{}
+	}
+}
+
+// dropMeasurement removes the series of a measurement created in the log, and records
+// the measurement as dropped from older logs and the index file.
+func (l *logFile) dropMeasurement(name string) {
+	if m := l.measurements[name]; m != nil {
+		for id := range m.ids {
+			delete(l.series, l.seriesByID[id].Key)
+			delete(l.seriesByID, id)
+		}
+		delete(l.measurements, name)
+	}
+	l.dropped[name] = struct{}{}
+}
+
+// sortedIDs returns the ids of a set in ascending order.
+func sortedIDs(set map[uint64]struct{}) []uint64 {
+	ids := make([]uint64, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Sort(uint64Slice(ids))
+	return ids
+}
+
+// encodeSeries returns the payload of a series record.
+func encodeSeries(s *Series) []byte {
+	var buf []byte
+	buf = appendUvarint(buf, s.ID)
+	buf = appendString(buf, s.Key)
+	buf = appendString(buf, s.Name)
+	buf = appendTags(buf, s.Tags)
+	return buf
+}
+
+// decodeSeries decodes the payload of a series record.
+func decodeSeries(buf []byte) (*Series, error) {
+	d := decoder{buf: buf}
+	s := &Series{ID: d.uvarint(), Key: d.string(), Name: d.string(), Tags: d.tags()}
+	if d.err {
+		return nil, ErrLogRecordCorrupt
+	}
+	return s, nil
+}
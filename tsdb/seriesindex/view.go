@@ -0,0 +1,367 @@
+package seriesindex
+
+import (
+	"sort"
+)
+
+// view reads the series of an index file and of the logs written after it, the series
+// dropped in a log being hidden from the file and older logs.
+type view struct {
+	file *indexFile // nil if no compaction has run yet
+	logs []*logFile // oldest first
+}
+
+// maxID returns the highest id ever given to a series of the view.
+func (v *view) maxID() uint64 {
+	var max uint64
+	if v.file != nil {
+		max = v.file.maxID
+	}
+	for _, l := range v.logs {
+		if l.maxID > max {
+			max = l.maxID
+		}
+	}
+	return max
+}
+
+// live returns true if a series read from the log at layer, or from the index file if
+// layer is -1, isn't dropped by a newer log.
+func (v *view) live(id uint64, name string, layer int) bool {
+	for _, l := range v.logs[layer+1:] {
+		if _, ok := l.tombstones[id]; ok {
+			return false
+		} else if _, ok := l.dropped[name]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// liveIDs returns the ids of the series of a measurement read from the log at layer,
+// or from the index file if layer is -1, which aren't dropped by a newer log.
+func (v *view) liveIDs(ids []uint64, name string, layer int) []uint64 {
+	var tombstones []map[uint64]struct{}
+	for _, l := range v.logs[layer+1:] {
+		if _, ok := l.dropped[name]; ok {
+			return nil
+		} else if len(l.tombstones) > 0 {
+			tombstones = append(tombstones, l.tombstones)
+		}
+	}
+	if len(tombstones) == 0 {
+		return ids
+	}
+
+	a := make([]uint64, 0, len(ids))
+outer:
+	for _, id := range ids {
+		for _, m := range tombstones {
+			if _, ok := m[id]; ok {
+				continue outer
+			}
+		}
+		a = append(a, id)
+	}
+	return a
+}
+
+// hasDeletes returns true if a log drops series that may belong to a measurement.
+func (v *view) hasDeletes(name string) bool {
+	for _, l := range v.logs {
+		if _, ok := l.dropped[name]; ok || len(l.tombstones) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// seriesByKey returns the series with a key, or nil if it doesn't exist.
+func (v *view) seriesByKey(key string) *Series {
+	for j := len(v.logs) - 1; j >= 0; j-- {
+		if s := v.logs[j].series[key]; s != nil {
+			if v.live(s.ID, s.Name, j) {
+				return s
+			}
+			return nil
+		}
+	}
+	if v.file != nil {
+		if s := v.file.seriesByKey(key); s != nil && v.live(s.ID, s.Name, -1) {
+			return s
+		}
+	}
+	return nil
+}
+
+// seriesID returns the id of the series with a key, or zero if it doesn't exist.
+func (v *view) seriesID(key string) uint64 {
+	for j := len(v.logs) - 1; j >= 0; j-- {
+		if s := v.logs[j].series[key]; s != nil {
+			if v.live(s.ID, s.Name, j) {
+				return s.ID
+			}
+			return 0
+		}
+	}
+	if v.file != nil {
+		if id, name := v.file.seriesID(key); id != 0 && v.live(id, name, -1) {
+			return id
+		}
+	}
+	return 0
+}
+
+// seriesByID returns the series with an id, or nil if it doesn't exist.
+func (v *view) seriesByID(id uint64) *Series {
+	for j := len(v.logs) - 1; j >= 0; j-- {
+		if s := v.logs[j].seriesByID[id]; s != nil {
+			if v.live(s.ID, s.Name, j) {
+				return s
+			}
+			return nil
+		}
+	}
+	if v.file != nil {
+		if s := v.file.seriesByID(id); s != nil && v.live(s.ID, s.Name, -1) {
+			return s
+		}
+	}
+	return nil
+}
+
+// eachSeries calls fn for each series in id order.
+func (v *view) eachSeries(fn func(s *Series)) {
+	if v.file != nil {
+		for id := uint64(1); id <= v.file.maxID; id++ {
+			if s := v.file.seriesByID(id); s != nil && v.live(s.ID, s.Name, -1) {
+				fn(s)
+			}
+		}
+	}
+
+	// Ids are given in increasing order so the series of a log follow the older ones.
+	for j, l := range v.logs {
+		ids := make([]uint64, 0, len(l.seriesByID))
+		for id := range l.seriesByID {
+			ids = append(ids, id)
+		}
+		sort.Sort(uint64Slice(ids))
+		for _, id := range ids {
+			if s := l.seriesByID[id]; v.live(s.ID, s.Name, j) {
+				fn(s)
+			}
+		}
+	}
+}
+
+// eachSeriesByKey calls fn for each series in key order.
+func (v *view) eachSeriesByKey(fn func(s *Series)) {
+	var logSeries []*Series
+	for j, l := range v.logs {
+		for _, s := range l.series {
+			if v.live(s.ID, s.Name, j) {
+				logSeries = append(logSeries, s)
+			}
+		}
+	}
+	sort.Sort(seriesByKey(logSeries))
+
+	// Merge the series of the logs with the ones of the file, already sorted by key.
+	if v.file != nil {
+		for i, n := 0, v.file.seriesN(); i < n; i++ {
+			s := v.file.seriesAt(tableOffset(v.file.keyTable, i))
+			if s == nil || !v.live(s.ID, s.Name, -1) {
+				continue
+			}
+			for len(logSeries) > 0 && logSeries[0].Key < s.Key {
+				fn(logSeries[0])
+				logSeries = logSeries[1:]
+			}
+			fn(s)
+		}
+	}
+	for _, s := range logSeries {
+		fn(s)
+	}
+}
+
+// measurementNames returns the sorted names of the measurements which may have series.
+func (v *view) measurementNames() []string {
+	set := make(map[string]struct{})
+	if v.file != nil {
+		for _, name := range v.file.measurementNames() {
+			set[name] = struct{}{}
+		}
+	}
+	for _, l := range v.logs {
+		for name := range l.measurements {
+			set[name] = struct{}{}
+		}
+	}
+	return sortedStrings(set)
+}
+
+// measurementSeriesIDs returns the sorted ids of the series of a measurement.
+func (v *view) measurementSeriesIDs(name string) []uint64 {
+	var ids []uint64
+	if v.file != nil {
+		ids = v.liveIDs(v.file.measurementSeriesIDs(name), name, -1)
+	}
+	for j, l := range v.logs {
+		if m := l.measurements[name]; m != nil {
+			ids = unionIDs(ids, v.liveIDs(sortedIDs(m.ids), name, j))
+		}
+	}
+	return ids
+}
+
+// tagKeys returns the sorted keys of the tags of the series of a measurement.
+func (v *view) tagKeys(name string) []string {
+	set := make(map[string]struct{})
+	if v.file != nil {
+		for _, k := range v.file.tagKeys(name) {
+			set[k] = struct{}{}
+		}
+	}
+	for _, l := range v.logs {
+		if m := l.measurements[name]; m != nil {
+			for k := range m.tags {
+				set[k] = struct{}{}
+			}
+		}
+	}
+
+	// Keys whose series are all dropped are left out.
+	if v.hasDeletes(name) {
+		for k := range set {
+			if len(v.tagValues(name, k)) == 0 {
+				delete(set, k)
+			}
+		}
+	}
+	return sortedStrings(set)
+}
+
+// tagValues returns the sorted values of a tag key of the series of a measurement.
+func (v *view) tagValues(name, key string) []string {
+	set := make(map[string]struct{})
+	if v.file != nil {
+		for _, value := range v.file.tagValues(name, key) {
+			set[value] = struct{}{}
+		}
+	}
+	for _, l := range v.logs {
+		if m := l.measurements[name]; m != nil {
+			for value := range m.tags[key] {
+				set[value] = struct{}{}
+			}
+		}
+	}
+
+	// Values whose series are all dropped are left out.
+	if v.hasDeletes(name) {
+		for value := range set {
+			if len(v.tagValueSeriesIDs(name, key, value)) == 0 {
+				delete(set, value)
+			}
+		}
+	}
+	return sortedStrings(set)
+}
+
+// tagValueN returns the number of values of a tag key of the series of a measurement.
+func (v *view) tagValueN(name, key string) int {
+	// Values whose series are all dropped are only left out by tagValues.
+	if v.hasDeletes(name) {
+		return len(v.tagValues(name, key))
+	}
+
+	var n int
+	if v.file != nil {
+		n = len(v.file.tagKey(name, key)) / 8
+	}
+	for _, l := range v.logs {
+		if m := l.measurements[name]; m != nil {
+			n += len(m.newValues[key])
+		}
+	}
+	return n
+}
+
+// hasTagValue returns true if a series of a measurement has a tag value.
+func (v *view) hasTagValue(name, key, value string) bool {
+	if v.hasDeletes(name) {
+		return len(v.tagValueSeriesIDs(name, key, value)) > 0
+	}
+	return v.containsTagValue(name, key, value)
+}
+
+// containsTagValue returns true if the index file or a log holds a series of a
+// measurement with a tag value, including series dropped by a newer log.
+func (v *view) containsTagValue(name, key, value string) bool {
+	if v.file != nil && v.file.search(v.file.tagKey(name, key), value) != nil {
+		return true
+	}
+	for _, l := range v.logs {
+		if m := l.measurements[name]; m != nil && m.tags[key][value] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// tagValueSeriesIDs returns the sorted ids of the series of a measurement with a tag value.
+func (v *view) tagValueSeriesIDs(name, key, value string) []uint64 {
+	var ids []uint64
+	if v.file != nil {
+		ids = v.liveIDs(v.file.tagValueSeriesIDs(name, key, value), name, -1)
+	}
+	for j, l := range v.logs {
+		if m := l.measurements[name]; m != nil && m.tags[key][value] != nil {
+			ids = unionIDs(ids, v.liveIDs(sortedIDs(m.tags[key][value]), name, j))
+		}
+	}
+	return ids
+}
+
+// unionIDs returns the sorted union of two sorted lists of ids.
+func unionIDs(a, b []uint64) []uint64 {
+	if len(a) == 0 {
+		return b
+	} else if len(b) == 0 {
+		return a
+	}
+
+	ids := make([]uint64, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		if a[0] == b[0] {
+			ids = append(ids, a[0])
+			a, b = a[1:], b[1:]
+		} else if a[0] < b[0] {
+			ids = append(ids, a[0])
+			a = a[1:]
+		} else {
+			ids = append(ids, b[0])
+			b = b[1:]
+		}
+	}
+	ids = append(ids, a...)
+	return append(ids, b...)
+}
+
+// sortedStrings returns the strings of a set in ascending order.
+func sortedStrings(set map[string]struct{}) []string {
+	a := make([]string, 0, len(set))
+	for s := range set {
+		a = append(a, s)
+	}
+	sort.Strings(a)
+	return a
+}
+
+type seriesByKey []*Series
+
+func (a seriesByKey) Len() int           { return len(a) }
+func (a seriesByKey) Less(i, j int) bool { return a[i].Key < a[j].Key }
+func (a seriesByKey) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
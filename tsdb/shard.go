@@ -25,6 +25,7 @@ const (
 	statFieldsCreate    = "fields_create"
 	statWritePointsFail = "write_points_fail"
 	statWritePointsOK   = "write_points_ok"
+	statWritePointsDrop = "write_points_dropped"
 	statWriteBytes      = "write_bytes"
 )
 
@@ -43,6 +44,17 @@ var (
 	ErrFieldUnmappedID = errors.New("field ID not mapped")
 )
 
+// PartialWriteError is returned when some points of a write are dropped, while the
+// others are written.
+type PartialWriteError struct {
+	Reason  string
+	Dropped int
+}
+
+func (e PartialWriteError) Error() string {
+	return fmt.Sprintf("partial write: %s dropped=%d", e.Reason, e.Dropped)
+}
+
 // Shard represents a self-contained time series database. An inverted index of
 // the measurement and tag data is kept along with the raw time series data.
 // Data can be split across many shards. The query engine in TSDB is responsible
@@ -129,7 +141,7 @@ func (s *Shard) Open() error {
 			return fmt.Errorf("load metadata index: %s", err)
 		}
 
-		return nil
+		return s.index.openShard(s.id)
 	}(); err != nil {
 		s.close()
 		return err
@@ -142,7 +154,12 @@ func (s *Shard) Open() error {
 func (s *Shard) Close() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	return s.close()
+	if err := s.close(); err != nil {
+		return err
+	}
+
+	// The series of a shard closed cleanly aren't loaded again when it's opened.
+	return s.index.closeShard(s.id)
 }
 
 func (s *Shard) close() error {
@@ -199,29 +216,64 @@ type SeriesCreate struct {
 func (s *Shard) WritePoints(points []models.Point) error {
 	s.statMap.Add(statWriteReq, 1)
 
-	seriesToCreate, fieldsToCreate, seriesToAddShardTo, err := s.validateSeriesAndFields(points)
-	if err != nil {
+	points, seriesToCreate, fieldsToCreate, seriesToAddShardTo, err := s.validateSeriesAndFields(points)
+	partialErr, ok := err.(PartialWriteError)
+	if err != nil && !ok {
 		return err
 	}
-	s.statMap.Add(statSeriesCreate, int64(len(seriesToCreate)))
-	s.statMap.Add(statFieldsCreate, int64(len(fieldsToCreate)))
 
 	// add any new series to the in-memory index
 	if len(seriesToCreate) > 0 {
 		s.index.mu.Lock()
+
+		// Concurrent writes may have created series since the limits were checked under
+		// the read lock, so the new series are checked again before any is created.
+		limits := newCardinalityLimits(s.index, s.options.Config)
+		var dropped map[string]struct{}
+		created := seriesToCreate[:0]
 		for _, ss := range seriesToCreate {
-			s.index.CreateSeriesIndexIfNotExists(ss.Measurement, ss.Series)
+			if reason := limits.check(ss.Measurement, ss.Series.Key, ss.Series.Tags); reason != "" {
+				if partialErr.Reason == "" {
+					partialErr.Reason = reason
+				}
+				if dropped == nil {
+					dropped = make(map[string]struct{})
+				}
+				dropped[ss.Series.Key] = struct{}{}
+				continue
+			}
+			created = append(created, ss)
+		}
+		seriesToCreate = created
+
+		for _, ss := range seriesToCreate {
+			if _, err := s.index.CreateSeriesIndexIfNotExists(ss.Measurement, ss.Series); err != nil {
+				s.index.mu.Unlock()
+				return err
+			}
 		}
 		s.index.mu.Unlock()
+
+		if len(dropped) > 0 {
+			var n int
+			points, fieldsToCreate, seriesToAddShardTo, n = dropSeriesPoints(dropped, points, fieldsToCreate, seriesToAddShardTo)
+			partialErr.Dropped += n
+		}
+	}
+
+	if partialErr.Dropped > 0 {
+		s.statMap.Add(statWritePointsDrop, int64(partialErr.Dropped))
+		if len(points) == 0 {
+			return partialErr
+		}
 	}
+	s.statMap.Add(statSeriesCreate, int64(len(seriesToCreate)))
+	s.statMap.Add(statFieldsCreate, int64(len(fieldsToCreate)))
 
 	if len(seriesToAddShardTo) > 0 {
 		s.index.mu.Lock()
 		for _, k := range seriesToAddShardTo {
-			ss := s.index.series[k]
-			if ss != nil {
-				ss.shardIDs[s.id] = true
-			}
+			s.index.addSeriesToShard(k, s.id)
 		}
 		s.index.mu.Unlock()
 	}
@@ -266,9 +318,42 @@ func (s *Shard) WritePoints(points []models.Point) error {
 	}
 	s.statMap.Add(statWritePointsOK, int64(len(points)))
 
+	if partialErr.Dropped > 0 {
+		return partialErr
+	}
 	return nil
 }
 
+// dropSeriesPoints removes the points of dropped series from a write, along with the fields
+// of measurements left without points and the series to add to the shard. It returns the
+// number of points removed.
+func dropSeriesPoints(dropped map[string]struct{}, points []models.Point, fieldsToCreate []*FieldCreate, seriesToAddShardTo []string) ([]models.Point, []*FieldCreate, []string, int) {
+	valid := points[:0:0]
+	names := make(map[string]struct{})
+	for _, p := range points {
+		if _, ok := dropped[string(p.Key())]; ok {
+			continue
+		}
+		valid = append(valid, p)
+		names[p.Name()] = struct{}{}
+	}
+
+	fields := fieldsToCreate[:0]
+	for _, f := range fieldsToCreate {
+		if _, ok := names[f.Measurement]; ok {
+			fields = append(fields, f)
+		}
+	}
+
+	keys := seriesToAddShardTo[:0]
+	for _, k := range seriesToAddShardTo {
+		if _, ok := dropped[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	return valid, fields, keys, len(points) - len(valid)
+}
+
 func (s *Shard) ValidateAggregateFieldsInStatement(measurementName string, stmt *influxql.SelectStatement) error {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -326,6 +411,11 @@ func (s *Shard) DeleteSeries(keys []string) error {
 	return s.engine.DeleteSeries(keys)
 }
 
+// DeleteSeriesRange deletes the points of the series between min and max, inclusive.
+func (s *Shard) DeleteSeriesRange(keys []string, min, max int64) error {
+	return s.engine.DeleteSeriesRange(keys, min, max)
+}
+
 // DeleteMeasurement deletes a measurement and all underlying series.
 func (s *Shard) DeleteMeasurement(name string, seriesKeys []string) error {
 	s.mu.Lock()
@@ -381,11 +471,14 @@ func (s *Shard) createFieldsAndMeasurements(fieldsToCreate []*FieldCreate) (map[
 	return measurementsToSave, nil
 }
 
-// validateSeriesAndFields checks which series and fields are new and whose metadata should be saved and indexed
-func (s *Shard) validateSeriesAndFields(points []models.Point) ([]*SeriesCreate, []*FieldCreate, []string, error) {
+// validateSeriesAndFields checks which series and fields are new and whose metadata should be saved and indexed.
+// Points creating series over the cardinality limits are dropped from the returned points, and reported
+// with a PartialWriteError.
+func (s *Shard) validateSeriesAndFields(points []models.Point) ([]models.Point, []*SeriesCreate, []*FieldCreate, []string, error) {
 	var seriesToCreate []*SeriesCreate
 	var fieldsToCreate []*FieldCreate
 	var seriesToAddShardTo []string
+	var partialErr PartialWriteError
 
 	// get the mutex for the in memory index, which is shared across shards
 	s.index.mu.RLock()
@@ -395,9 +488,26 @@ func (s *Shard) validateSeriesAndFields(points []models.Point) ([]*SeriesCreate,
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	limits := newCardinalityLimits(s.index, s.options.Config)
+	valid := points[:0:0]
 	for _, p := range points {
+		if reason := limits.check(p.Name(), string(p.Key()), p.Tags()); reason != "" {
+			if partialErr.Reason == "" {
+				partialErr.Reason = reason
+			}
+			partialErr.Dropped++
+			continue
+		}
+		valid = append(valid, p)
+
 		// see if the series should be added to the index
-		if ss := s.index.series[string(p.Key())]; ss == nil {
+		if s.index.disk != nil {
+			// the disk-backed index only tracks which shards persisted the series
+			if key := string(p.Key()); !s.index.seriesInShard(key, s.id) {
+				seriesToCreate = append(seriesToCreate, &SeriesCreate{p.Name(), NewSeries(key, p.Tags())})
+				seriesToAddShardTo = append(seriesToAddShardTo, key)
+			}
+		} else if ss := s.index.series[string(p.Key())]; ss == nil {
 			series := NewSeries(string(p.Key()), p.Tags())
 			seriesToCreate = append(seriesToCreate, &SeriesCreate{p.Name(), series})
 			seriesToAddShardTo = append(seriesToAddShardTo, series.Key)
@@ -421,7 +531,7 @@ func (s *Shard) validateSeriesAndFields(points []models.Point) ([]*SeriesCreate,
 			if f := mf.Fields[name]; f != nil {
 				// Field present in shard metadata, make sure there is no type conflict.
 				if f.Type != influxql.InspectDataType(value) {
-					return nil, nil, nil, fmt.Errorf("field type conflict: input field \"%s\" on measurement \"%s\" is type %T, already exists as type %s", name, p.Name(), value, f.Type)
+					return nil, nil, nil, nil, fmt.Errorf("field type conflict: input field \"%s\" on measurement \"%s\" is type %T, already exists as type %s", name, p.Name(), value, f.Type)
 				}
 
 				continue // Field is present, and it's of the same type. Nothing more to do.
@@ -431,7 +541,96 @@ func (s *Shard) validateSeriesAndFields(points []models.Point) ([]*SeriesCreate,
 		}
 	}
 
-	return seriesToCreate, fieldsToCreate, seriesToAddShardTo, nil
+	if partialErr.Dropped > 0 {
+		return valid, seriesToCreate, fieldsToCreate, seriesToAddShardTo, partialErr
+	}
+	return valid, seriesToCreate, fieldsToCreate, seriesToAddShardTo, nil
+}
+
+// cardinalityLimits checks the points of a write against the maximum number of series of
+// a database and of values of a tag, counting the series and values created by the write.
+// The caller must hold a lock on the index.
+type cardinalityLimits struct {
+	index         *DatabaseIndex
+	maxSeriesN    int
+	maxTagValuesN int
+	seriesN       int
+	newSeries     map[string]struct{}
+	newTagValues  map[string]map[string]struct{} // by measurement and tag key
+}
+
+func newCardinalityLimits(index *DatabaseIndex, c Config) *cardinalityLimits {
+	return &cardinalityLimits{
+		index:         index,
+		maxSeriesN:    c.MaxSeriesPerDatabase,
+		maxTagValuesN: c.MaxValuesPerTag,
+		seriesN:       -1,
+		newSeries:     make(map[string]struct{}),
+		newTagValues:  make(map[string]map[string]struct{}),
+	}
+}
+
+// check returns the limit exceeded by a series, or an empty string if the series can be
+// written. Existing series are always accepted.
+func (l *cardinalityLimits) check(name, key string, tags map[string]string) string {
+	if l.maxSeriesN <= 0 && l.maxTagValuesN <= 0 {
+		return ""
+	}
+
+	if _, ok := l.newSeries[key]; ok {
+		return ""
+	} else if l.index.disk != nil && l.index.disk.SeriesID(key) != 0 {
+		return ""
+	} else if l.index.disk == nil && l.index.series[key] != nil {
+		return ""
+	}
+
+	if l.maxSeriesN > 0 {
+		if l.seriesN < 0 {
+			if l.index.disk != nil {
+				l.seriesN = l.index.disk.SeriesN()
+			} else {
+				l.seriesN = len(l.index.series)
+			}
+		}
+		if n := l.seriesN + len(l.newSeries); n >= l.maxSeriesN {
+			return fmt.Sprintf("max-series-per-database limit exceeded: (%d/%d) series=%q", n, l.maxSeriesN, key)
+		}
+	}
+
+	// collect the tag values created by the series before counting it
+	var newValues []string
+	if l.maxTagValuesN > 0 {
+		m := l.index.measurements[name]
+		for k, v := range tags {
+			tagKey := name + "\x00" + k
+			if _, ok := l.newTagValues[tagKey][v]; ok {
+				continue
+			}
+
+			var n int
+			if m != nil {
+				var exists bool
+				if n, exists = m.tagValueN(k, v); exists {
+					continue
+				}
+			}
+			if n += len(l.newTagValues[tagKey]); n >= l.maxTagValuesN {
+				return fmt.Sprintf("max-values-per-tag limit exceeded: (%d/%d) measurement=%q tag=%q value=%q", n, l.maxTagValuesN, name, k, v)
+			}
+			newValues = append(newValues, k, v)
+		}
+	}
+
+	l.newSeries[key] = struct{}{}
+	for i := 0; i < len(newValues); i += 2 {
+		tagKey := name + "\x00" + newValues[i]
+		if l.newTagValues[tagKey] == nil {
+			l.newTagValues[tagKey] = make(map[string]struct{})
+		}
+		l.newTagValues[tagKey][newValues[i+1]] = struct{}{}
+	}
+	return ""
 }
 
 // SeriesCount returns the number of series buckets on the shard.
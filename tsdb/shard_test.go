@@ -7,6 +7,9 @@ import (
 	"path"
 	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -140,6 +143,167 @@ func TestShardWriteAddNewField(t *testing.T) {
 
 }
 
+// Ensure points over the cardinality limits are dropped and the others are written.
+func TestShard_WritePoints_CardinalityLimits(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir("", "shard_test")
+	defer os.RemoveAll(tmpDir)
+
+	index := tsdb.NewDatabaseIndex()
+	opts := tsdb.NewEngineOptions()
+	opts.Config.WALDir = filepath.Join(tmpDir, "wal")
+	opts.Config.MaxSeriesPerDatabase = 3
+	opts.Config.MaxValuesPerTag = 2
+
+	sh := tsdb.NewShard(1, index, filepath.Join(tmpDir, "shard"), filepath.Join(tmpDir, "wal"), opts)
+	if err := sh.Open(); err != nil {
+		t.Fatalf("error opening shard: %s", err.Error())
+	}
+	defer sh.Close()
+
+	point := func(name, host string) models.Point {
+		return models.NewPoint(name, map[string]string{"host": host}, map[string]interface{}{"value": 1.0}, time.Unix(1, 2))
+	}
+
+	// The third value of the host tag is dropped.
+	err := sh.WritePoints([]models.Point{point("cpu", "a"), point("cpu", "b"), point("cpu", "c"), point("cpu", "a")})
+	if err, ok := err.(tsdb.PartialWriteError); !ok || err.Dropped != 1 {
+		t.Fatalf("unexpected error: %v", err)
+	} else if !strings.Contains(err.Error(), "max-values-per-tag limit exceeded") {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	values := index.Measurement("cpu").TagValues("host")
+	sort.Strings(values)
+	if !reflect.DeepEqual(values, []string{"a", "b"}) {
+		t.Fatalf("unexpected tag values: %v", values)
+	}
+
+	// The fourth series of the database is dropped, while existing series are still written.
+	err = sh.WritePoints([]models.Point{point("mem", "a"), point("mem", "b"), point("cpu", "b")})
+	if err, ok := err.(tsdb.PartialWriteError); !ok || err.Dropped != 1 {
+		t.Fatalf("unexpected error: %v", err)
+	} else if !strings.Contains(err.Error(), "max-series-per-database limit exceeded") {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n := index.SeriesN(); n != 3 {
+		t.Fatalf("unexpected series count: %d", n)
+	}
+	if index.Series("mem,host=a") == nil || index.Series("mem,host=b") != nil {
+		t.Fatal("unexpected series in index")
+	}
+
+	if err := sh.WritePoints([]models.Point{point("cpu", "a"), point("mem", "a")}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+// Ensure concurrent writes can't create series over the cardinality limits together.
+func TestShard_WritePoints_CardinalityLimits_Concurrent(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir("", "shard_test")
+	defer os.RemoveAll(tmpDir)
+
+	index := tsdb.NewDatabaseIndex()
+	opts := tsdb.NewEngineOptions()
+	opts.Config.WALDir = filepath.Join(tmpDir, "wal")
+	opts.Config.MaxSeriesPerDatabase = 10
+	opts.Config.MaxValuesPerTag = 3
+
+	sh := tsdb.NewShard(1, index, filepath.Join(tmpDir, "shard"), filepath.Join(tmpDir, "wal"), opts)
+	if err := sh.Open(); err != nil {
+		t.Fatalf("error opening shard: %s", err.Error())
+	}
+	defer sh.Close()
+
+	// Start the writes together so they validate their series at the same time.
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var points []models.Point
+			for j := 0; j < 5; j++ {
+				tags := map[string]string{"host": fmt.Sprintf("host%d", i*5+j)}
+				points = append(points, models.NewPoint(fmt.Sprintf("m%d", i%4), tags, map[string]interface{}{"value": 1.0}, time.Unix(1, 2)))
+			}
+			<-start
+			if err := sh.WritePoints(points); err != nil {
+				if _, ok := err.(tsdb.PartialWriteError); !ok {
+					t.Errorf("unexpected error: %s", err)
+				}
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	if n := index.SeriesN(); n > 10 {
+		t.Fatalf("series count over the limit: %d", n)
+	}
+	for _, m := range index.MeasurementsByName([]string{"m0", "m1", "m2", "m3"}) {
+		if n := len(m.TagValues("host")); n > 3 {
+			t.Fatalf("tag value count over the limit for %s: %d", m.Name, n)
+		}
+	}
+}
+
+// Ensure the series of a shard closed cleanly aren't indexed again when it's opened.
+func TestShard_Open_SeriesIndexed(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir("", "shard_test")
+	defer os.RemoveAll(tmpDir)
+	indexPath := filepath.Join(tmpDir, tsdb.SeriesIndexDir)
+	opts := tsdb.NewEngineOptions()
+	opts.Config.WALDir = filepath.Join(tmpDir, "wal")
+
+	open := func() (*tsdb.DatabaseIndex, *tsdb.Shard) {
+		index, err := tsdb.OpenDiskDatabaseIndex(indexPath, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return index, tsdb.NewShard(1, index, filepath.Join(tmpDir, "shard"), filepath.Join(tmpDir, "wal"), opts)
+	}
+	point := func(host string) models.Point {
+		return models.NewPoint("cpu", map[string]string{"host": host}, map[string]interface{}{"value": 1.0}, time.Unix(1, 2))
+	}
+
+	index, sh := open()
+	if index.SeriesIndexed(sh) {
+		t.Fatal("new shard indexed")
+	} else if err := sh.Open(); err != nil {
+		t.Fatal(err)
+	} else if err := sh.WritePoints([]models.Point{point("a")}); err != nil {
+		t.Fatal(err)
+	} else if err := sh.Close(); err != nil {
+		t.Fatal(err)
+	}
+	index.Close()
+
+	index, sh = open()
+	defer index.Close()
+	if !index.SeriesIndexed(sh) {
+		t.Fatal("closed shard not indexed")
+	} else if err := sh.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer sh.Close()
+	if index.SeriesIndexed(sh) {
+		t.Fatal("open shard indexed")
+	} else if err := sh.WritePoints([]models.Point{point("b")}); err != nil {
+		t.Fatal(err)
+	}
+	if n := index.SeriesN(); n != 2 {
+		t.Fatalf("unexpected series count: %d", n)
+	} else if tags := index.TagsForSeries("cpu,host=a"); tags["host"] != "a" {
+		t.Fatalf("unexpected tags: %v", tags)
+	}
+
+	// The series of a shard that wasn't closed are indexed again, they may have been lost.
+	other, _ := open()
+	defer other.Close()
+	if other.SeriesIndexed(sh) {
+		t.Fatal("shard not closed indexed")
+	}
+}
+
 // Ensure the shard will automatically flush the WAL after a threshold has been reached.
 func TestShard_Autoflush(t *testing.T) {
 	path, _ := ioutil.TempDir("", "shard_test")
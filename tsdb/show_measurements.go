@@ -26,7 +26,7 @@ func NewShowMeasurementsExecutor(stmt *influxql.ShowMeasurementsStatement, mappe
 }
 
 // Execute begins execution of the query and returns a channel to receive rows.
-func (e *ShowMeasurementsExecutor) Execute() <-chan *models.Row {
+func (e *ShowMeasurementsExecutor) Execute(closing <-chan struct{}) <-chan *models.Row {
 	// Create output channel and stream data in a separate goroutine.
 	out := make(chan *models.Row, 0)
 
@@ -43,6 +43,11 @@ func (e *ShowMeasurementsExecutor) Execute() <-chan *models.Row {
 		set := map[string]struct{}{}
 		// Iterate through mappers collecting measurement names.
 		for _, m := range e.mappers {
+			if isClosed(closing) {
+				out <- &models.Row{Err: ErrQueryInterrupted}
+				return
+			}
+
 			// Get the data from the mapper.
 			c, err := m.NextChunk()
 			if err != nil {
@@ -26,7 +26,7 @@ func NewShowTagKeysExecutor(stmt *influxql.ShowTagKeysStatement, mappers []Mappe
 }
 
 // Execute begins execution of the query and returns a channel to receive rows.
-func (e *ShowTagKeysExecutor) Execute() <-chan *models.Row {
+func (e *ShowTagKeysExecutor) Execute(closing <-chan struct{}) <-chan *models.Row {
 	// Create output channel and stream data in a separate goroutine.
 	out := make(chan *models.Row, 0)
 
@@ -49,6 +49,11 @@ func (e *ShowTagKeysExecutor) Execute() <-chan *models.Row {
 		for _, m := range e.mappers {
 			// Read all data from the mapper.
 			for {
+				if isClosed(closing) {
+					out <- &models.Row{Err: ErrQueryInterrupted}
+					return
+				}
+
 				c, err := m.NextChunk()
 				if err != nil {
 					out <- &models.Row{Err: err}
@@ -1,9 +1,11 @@
 package tsdb
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -45,6 +47,10 @@ type Store struct {
 	EngineOptions EngineOptions
 	Logger        *log.Logger
 
+	// Maximum number of series a SELECT statement may read from a single shard.
+	// Zero means no limit. The query executor enforces the limit per statement.
+	MaxSelectSeriesN int
+
 	closing chan struct{}
 	wg      sync.WaitGroup
 	opened  bool
@@ -103,7 +109,10 @@ func (s *Store) CreateShard(database, retentionPolicy string, shardID uint64) er
 	// create the database index if it does not exist
 	db, ok := s.databaseIndexes[database]
 	if !ok {
-		db = NewDatabaseIndex()
+		var err error
+		if db, err = s.openDatabaseIndex(database); err != nil {
+			return err
+		}
 		s.databaseIndexes[database] = db
 	}
 
@@ -141,6 +150,9 @@ func (s *Store) DeleteShard(shardID uint64) error {
 		return err
 	}
 
+	if err := sh.index.removeShard(shardID); err != nil {
+		return err
+	}
 	delete(s.shards, shardID)
 
 	return nil
@@ -156,6 +168,9 @@ func (s *Store) DeleteDatabase(name string, shardIDs []uint64) error {
 			shard.Close()
 		}
 	}
+	if db := s.databaseIndexes[name]; db != nil {
+		db.Close()
+	}
 	if err := os.RemoveAll(filepath.Join(s.path, name)); err != nil {
 		return err
 	}
@@ -212,6 +227,33 @@ func (s *Store) Measurement(database, name string) *Measurement {
 	return db.Measurement(name)
 }
 
+// FieldTypes returns the type of each field of a measurement, read from the shards of
+// the database stored on this node.
+func (s *Store) FieldTypes(database, name string) map[string]influxql.DataType {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	types := make(map[string]influxql.DataType)
+	db := s.databaseIndexes[database]
+	if db == nil {
+		return types
+	}
+	for _, sh := range s.shards {
+		if sh.index != db {
+			continue
+		}
+
+		sh.mu.RLock()
+		if m := sh.measurementFields[name]; m != nil {
+			for _, f := range m.Fields {
+				types[f.Name] = f.Type
+			}
+		}
+		sh.mu.RUnlock()
+	}
+	return types
+}
+
 // DiskSize returns the size of all the shard files in bytes.  This size does not include the WAL size.
 func (s *Store) DiskSize() (int64, error) {
 	s.mu.RLock()
@@ -252,6 +294,76 @@ func (s *Store) deleteMeasurement(name string, seriesKeys []string) error {
 	return nil
 }
 
+// DeleteFromShard removes the points matching the DELETE statement from a local shard.
+// Any now() in the condition must already be reduced to a time so every shard deletes
+// the same range. It's a no-op if the shard doesn't exist on this node.
+func (s *Store) DeleteFromShard(shardID uint64, stmt *influxql.DeleteStatement) error {
+	sh := s.Shard(shardID)
+	if sh == nil {
+		return nil
+	}
+
+	// Find the measurements in the source, expanding a regex if one was given.
+	var measurements Measurements
+	switch src := stmt.Source.(type) {
+	case *influxql.Measurement:
+		if src.Regex != nil {
+			measurements = sh.index.measurementsByRegex(src.Regex.Val)
+		} else if m := sh.index.Measurement(src.Name); m != nil {
+			measurements = append(measurements, m)
+		}
+	default:
+		return errors.New("identifiers in FROM clause must be measurement names")
+	}
+
+	var seriesKeys []string
+	for _, m := range measurements {
+		m.load()
+		ids := m.seriesIDs
+		if stmt.Condition != nil {
+			// Get series IDs that match the WHERE clause.
+			var filters FilterExprs
+			var err error
+			ids, filters, err = m.walkWhereForSeriesIds(stmt.Condition)
+			if err != nil {
+				return err
+			}
+
+			// Delete boolean literal true filter expressions.
+			// These are returned for tag and time expressions and are okay.
+			filters.DeleteBoolLiteralTrues()
+
+			// Check for unsupported field filters.
+			// Any remaining filters means there were fields (e.g., `WHERE value = 1.2`).
+			if filters.Len() > 0 {
+				return errors.New("DELETE doesn't support fields in WHERE clause")
+			}
+		}
+
+		for _, id := range ids {
+			seriesKeys = append(seriesKeys, m.seriesByID[id].Key)
+		}
+	}
+	if len(seriesKeys) == 0 {
+		return nil
+	}
+
+	// Default to an unbounded range if the condition doesn't limit time.
+	min, max := int64(math.MinInt64), int64(math.MaxInt64)
+	tmin, tmax := influxql.TimeRange(stmt.Condition)
+	if !tmin.IsZero() {
+		min = tmin.UnixNano()
+	}
+	if !tmax.IsZero() {
+		max = tmax.UnixNano()
+	}
+	if min > max {
+		return nil
+	}
+
+	return sh.DeleteSeriesRange(seriesKeys, min, max)
+}
+
 func (s *Store) loadIndexes() error {
 	dbs, err := ioutil.ReadDir(s.path)
 	if err != nil {
@@ -262,11 +374,28 @@ func (s *Store) loadIndexes() error {
 			s.Logger.Printf("Skipping database dir: %s. Not a directory", db.Name())
 			continue
 		}
-		s.databaseIndexes[db.Name()] = NewDatabaseIndex()
+		idx, err := s.openDatabaseIndex(db.Name())
+		if err != nil {
+			return err
+		}
+		s.databaseIndexes[db.Name()] = idx
 	}
 	return nil
 }
 
+// openDatabaseIndex returns the index of the series of a database, of the type set in the config.
+func (s *Store) openDatabaseIndex(name string) (*DatabaseIndex, error) {
+	switch typ := s.EngineOptions.Config.SeriesIndex; typ {
+	case "", InmemSeriesIndex:
+		return NewDatabaseIndex(), nil
+	case DiskSeriesIndex:
+		path := filepath.Join(s.path, name, SeriesIndexDir)
+		return OpenDiskDatabaseIndex(path, s.EngineOptions.Config.SeriesIndexMaxLoadedSeries)
+	default:
+		return nil, fmt.Errorf("unknown series index: %s", typ)
+	}
+}
+
 func (s *Store) loadShards() error {
 	// loop through the current database indexes
 	for db := range s.databaseIndexes {
@@ -280,6 +409,8 @@ func (s *Store) loadShards() error {
 			if !rp.IsDir() {
 				s.Logger.Printf("Skipping retention policy dir: %s. Not a directory", rp.Name())
 				continue
+			} else if rp.Name() == SeriesIndexDir {
+				continue
 			}
 
 			shards, err := ioutil.ReadDir(filepath.Join(s.path, db, rp.Name()))
@@ -399,12 +530,15 @@ func (s *Store) CreateMapper(shardID uint64, stmt influxql.Statement, chunkSize
 
 	switch stmt := stmt.(type) {
 	case *influxql.SelectStatement:
-		if (stmt.IsRawQuery && !stmt.HasDistinct()) || stmt.IsSimpleDerivative() {
+		if (stmt.IsRawQuery && !stmt.HasDistinct()) || stmt.IsSimpleTransform() {
 			m := NewRawMapper(shard, stmt)
 			m.ChunkSize = chunkSize
+			m.MaxSeriesN = s.MaxSelectSeriesN
 			return m, nil
 		}
-		return NewAggregateMapper(shard, stmt), nil
+		m := NewAggregateMapper(shard, stmt)
+		m.MaxSeriesN = s.MaxSelectSeriesN
+		return m, nil
 
 	case *influxql.ShowMeasurementsStatement:
 		m := NewShowMeasurementsMapper(shard, stmt)
@@ -431,6 +565,11 @@ func (s *Store) Close() error {
 			return err
 		}
 	}
+	for _, db := range s.databaseIndexes {
+		if err := db.Close(); err != nil {
+			return err
+		}
+	}
 	s.opened = false
 	s.shards = nil
 	s.databaseIndexes = nil
@@ -444,6 +583,11 @@ func IsRetryable(err error) bool {
 		return true
 	}
 
+	// Points dropped by a shard would be dropped again.
+	if _, ok := err.(PartialWriteError); ok {
+		return false
+	}
+
 	if strings.Contains(err.Error(), "field type conflict") {
 		return false
 	}
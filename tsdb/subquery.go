@@ -0,0 +1,493 @@
+package tsdb
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/influxdb/influxdb/influxql"
+)
+
+// SubQueryMapper runs the map phase of a SELECT statement against the rows returned
+// by the executor of a subquery. The rows of the subquery are grouped by the tagsets
+// of the subquery rather than those of the outer statement, so they are buffered in
+// full and regrouped when the mapper is opened.
+type SubQueryMapper struct {
+	stmt       *influxql.SelectStatement
+	executor   Executor
+	qmin, qmax int64 // query time range
+
+	closing   chan struct{} // Closed to interrupt the subquery.
+	interrupt sync.Once
+
+	tagSets     []*subQueryTagSet
+	tagSetIndex int
+
+	columns      []string // Columns of the subquery, excluding time.
+	selectFields []string
+
+	interval     int   // Current interval for which data is being fetched.
+	intervalN    int   // Maximum number of intervals to return.
+	intervalSize int64 // Size of each interval.
+	qminWindow   int64 // Minimum time of the query floored to start of interval.
+	window       groupByWindow
+
+	mapFuncs   []mapFunc // The mapping functions.
+	fieldNames []string  // the field name being read for mapping.
+
+	ChunkSize int
+}
+
+// subQueryTagSet holds the subquery points which belong to a single tagset of the outer statement.
+type subQueryTagSet struct {
+	name   string
+	tags   map[string]string
+	key    string
+	points []*subQueryPoint
+	index  int // Next point to return for raw queries.
+}
+
+// subQueryPoint is a single row returned by a subquery.
+type subQueryPoint struct {
+	time   int64
+	fields map[string]interface{}
+	tags   map[string]string
+}
+
+// NewSubQueryMapper returns a new instance of SubQueryMapper. Only rows of the
+// subquery between qmin and qmax, inclusive, are mapped.
+func NewSubQueryMapper(stmt *influxql.SelectStatement, e Executor, qmin, qmax int64, chunkSize int) *SubQueryMapper {
+	return &SubQueryMapper{
+		stmt:      stmt,
+		executor:  e,
+		qmin:      qmin,
+		qmax:      qmax,
+		closing:   make(chan struct{}),
+		ChunkSize: chunkSize,
+	}
+}
+
+// Interrupt stops the execution of the subquery.
+func (m *SubQueryMapper) Interrupt() {
+	m.interrupt.Do(func() { close(m.closing) })
+}
+
+// Open executes the subquery and groups its rows into the tagsets of the outer statement.
+func (m *SubQueryMapper) Open() error {
+	// Conditions on time are handled by the time range of the mapper and the
+	// subquery itself. Everything else is evaluated against each row.
+	filter := filterTimeExpr(m.stmt.Condition)
+
+	rows := m.executor.Execute(m.closing)
+	defer func() {
+		// The subquery is interrupted if it isn't read to the end, and waited for.
+		m.Interrupt()
+		for range rows {
+		}
+	}()
+
+	columns := newStringSet()
+	set := make(map[string]*subQueryTagSet)
+	for row := range rows {
+		if row.Err != nil {
+			return row.Err
+		}
+		columns.add(row.Columns[1:]...)
+
+		tags := m.dimensionTags(row.Tags)
+		key := row.Name
+		if len(tags) > 0 {
+			key = strings.Join([]string{row.Name, string(MarshalTags(tags))}, "|")
+		}
+
+		for _, values := range row.Values {
+			t, ok := resultTime(values[0])
+			if !ok {
+				return errors.New("subquery returned a row without a time")
+			} else if t < m.qmin || t > m.qmax {
+				continue
+			}
+
+			p := &subQueryPoint{
+				time:   t,
+				fields: make(map[string]interface{}, len(values)-1),
+				tags:   row.Tags,
+			}
+			for i, v := range values[1:] {
+				if v != nil {
+					p.fields[row.Columns[i+1]] = v
+				}
+			}
+
+			if filter != nil && !influxql.EvalBool(filter, p.filterValues()) {
+				continue
+			}
+
+			ts := set[key]
+			if ts == nil {
+				ts = &subQueryTagSet{name: row.Name, tags: tags, key: key}
+				set[key] = ts
+			}
+			ts.points = append(ts.points, p)
+		}
+	}
+	m.columns = columns.list()
+
+	// Order the tagsets, and the points within each tagset, the same way cursors would.
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ascending := m.stmt.TimeAscending() || !m.stmt.IsRawQuery
+	for _, k := range keys {
+		ts := set[k]
+		if ascending {
+			sort.Stable(subQueryPoints(ts.points))
+		} else {
+			sort.Stable(sort.Reverse(subQueryPoints(ts.points)))
+		}
+		m.tagSets = append(m.tagSets, ts)
+	}
+	m.tagSets = m.limitTagSets(m.tagSets)
+
+	// Determine the subquery columns read by the statement.
+	if m.stmt.HasFieldWildcard() {
+		m.selectFields = m.columns
+	} else {
+		for _, name := range uniqueStrings(m.stmt.NamesInSelect()) {
+			if name != "time" && columns.contains(name) {
+				m.selectFields = append(m.selectFields, name)
+			}
+		}
+		sort.Strings(m.selectFields)
+	}
+
+	if (m.stmt.IsRawQuery && !m.stmt.HasDistinct()) || m.stmt.IsSimpleTransform() {
+		return nil
+	}
+	return m.initializeIntervals()
+}
+
+// dimensionTags returns the subset of the subquery tags grouped on by the statement.
+func (m *SubQueryMapper) dimensionTags(tags map[string]string) map[string]string {
+	if m.stmt.HasDimensionWildcard() {
+		return tags
+	}
+
+	a := make(map[string]string)
+	for _, d := range m.stmt.Dimensions {
+		if ref, ok := d.Expr.(*influxql.VarRef); ok {
+			if v, ok := tags[ref.Val]; ok {
+				a[ref.Val] = v
+			}
+		}
+	}
+	return a
+}
+
+// limitTagSets applies SLIMIT and SOFFSET of the statement to the tagsets.
+func (m *SubQueryMapper) limitTagSets(a []*subQueryTagSet) []*subQueryTagSet {
+	if m.stmt.SLimit == 0 && m.stmt.SOffset == 0 {
+		return a
+	}
+
+	if m.stmt.SOffset > len(a) {
+		return nil
+	}
+
+	end := len(a)
+	if m.stmt.SLimit > 0 && m.stmt.SOffset+m.stmt.SLimit < end {
+		end = m.stmt.SOffset + m.stmt.SLimit
+	}
+	return a[m.stmt.SOffset:end]
+}
+
+// initializeIntervals sets up the map functions and GROUP BY intervals for aggregate queries.
+func (m *SubQueryMapper) initializeIntervals() error {
+	aggregates := m.stmt.FunctionCalls()
+	m.mapFuncs = make([]mapFunc, len(aggregates))
+	m.fieldNames = make([]string, len(aggregates))
+	for i, c := range aggregates {
+		mfn, err := initializeMapFunc(c)
+		if err != nil {
+			return err
+		}
+		m.mapFuncs[i] = mfn
+
+		// Check for calls like `derivative(mean(value), 1d)`
+		var nested *influxql.Call = c
+		if fn, ok := c.Args[0].(*influxql.Call); ok {
+			nested = fn
+		}
+		switch lit := nested.Args[0].(type) {
+		case *influxql.VarRef:
+			m.fieldNames[i] = lit.Val
+		case *influxql.Distinct:
+			m.fieldNames[i] = lit.Val
+		default:
+			return fmt.Errorf("aggregate call didn't contain a field %s", c.String())
+		}
+	}
+
+	window, err := newGroupByWindow(m.stmt)
+	if err != nil {
+		return err
+	}
+	m.window = window
+
+	m.intervalSize = m.window.size
+	if m.qmin == 0 || m.intervalSize == 0 {
+		m.intervalN = 1
+		m.intervalSize = m.qmax - m.qmin
+	} else {
+		m.intervalN = int(m.window.index(m.qmax) - m.window.index(m.qmin) + 1)
+	}
+
+	if m.stmt.Limit > 0 || m.stmt.Offset > 0 {
+		// Ensure that the offset isn't higher than the number of points we'd get.
+		if m.stmt.Offset > m.intervalN {
+			m.tagSets = nil
+			return nil
+		}
+
+		if m.stmt.Limit > 0 && m.stmt.Limit < m.intervalN {
+			m.intervalN = m.stmt.Limit
+		}
+	}
+
+	if m.intervalN > MaxGroupByPoints {
+		return errors.New("too many points in the group by interval. maybe you forgot to specify a where time clause?")
+	}
+
+	m.qminWindow = m.qmin
+	if m.intervalSize > 0 && m.intervalN > 1 {
+		m.qminWindow = m.window.start(m.window.index(m.qmin))
+	}
+	return nil
+}
+
+// Close interrupts the subquery and closes its executor, releasing the mappers of the
+// subquery even if the mapper was never opened.
+func (m *SubQueryMapper) Close() {
+	m.Interrupt()
+	switch e := m.executor.(type) {
+	case *SelectExecutor:
+		e.close()
+	case *JoinExecutor:
+		e.close()
+	}
+}
+
+// TagSets returns the list of tag sets for which this mapper has data.
+func (m *SubQueryMapper) TagSets() []string {
+	keys := make([]string, 0, len(m.tagSets))
+	for _, ts := range m.tagSets {
+		keys = append(keys, ts.key)
+	}
+	return keys
+}
+
+// Fields returns all columns returned by the subquery.
+func (m *SubQueryMapper) Fields() []string { return m.columns }
+
+// NextChunk returns the next chunk of data.
+// Data is ordered the same as TagSets. Each chunk contains one tag set.
+// If there is no more data for any tagset, nil will be returned.
+func (m *SubQueryMapper) NextChunk() (interface{}, error) {
+	if m.mapFuncs == nil {
+		return m.nextRawChunk(), nil
+	}
+	return m.nextAggregateChunk(), nil
+}
+
+// nextRawChunk returns the next chunk of points for a raw query.
+func (m *SubQueryMapper) nextRawChunk() interface{} {
+	var output *MapperOutput
+	for {
+		// All tagsets processed.
+		if m.tagSetIndex == len(m.tagSets) {
+			if output != nil {
+				return output
+			}
+			return nil
+		}
+
+		ts := m.tagSets[m.tagSetIndex]
+		if ts.index == len(ts.points) {
+			// Tagset is empty, move to next one.
+			m.tagSetIndex++
+			if output != nil {
+				return output
+			}
+			continue
+		}
+
+		p := ts.points[ts.index]
+		ts.index++
+
+		// Filter out single field, if specified.
+		var value interface{} = p.fields
+		if len(m.selectFields) == 1 {
+			if value = p.fields[m.selectFields[0]]; value == nil {
+				continue
+			}
+		}
+
+		if output == nil {
+			output = &MapperOutput{
+				Name:      ts.name,
+				Tags:      ts.tags,
+				Fields:    m.selectFields,
+				cursorKey: ts.key,
+			}
+		}
+
+		output.Values = append(output.Values, &MapperValue{
+			Time:  p.time,
+			Value: value,
+			Tags:  p.tags,
+		})
+
+		if len(output.Values) == m.ChunkSize {
+			return output
+		}
+	}
+}
+
+// nextAggregateChunk returns the next interval of data for an aggregate query.
+func (m *SubQueryMapper) nextAggregateChunk() interface{} {
+	var tmin, tmax int64
+	for {
+		// All tagsets processed.
+		if m.tagSetIndex == len(m.tagSets) {
+			return nil
+		}
+
+		// All intervals complete for this tagset. Move to the next tagset.
+		tmin, tmax = m.nextInterval()
+		if tmin < 0 {
+			m.interval = 0
+			m.tagSetIndex++
+			continue
+		}
+		break
+	}
+
+	ts := m.tagSets[m.tagSetIndex]
+	output := &MapperOutput{
+		Name:      ts.name,
+		Tags:      ts.tags,
+		Fields:    m.selectFields,
+		Values:    []*MapperValue{{Time: tmin, Value: make([]interface{}, 0, len(m.mapFuncs))}},
+		cursorKey: ts.key,
+	}
+
+	// Clamp the interval to the time range of the query.
+	qmin, qmax := tmin, tmax
+	if qmin < m.qmin {
+		qmin = m.qmin
+	}
+	if qmax > m.qmax {
+		qmax = m.qmax + 1
+	}
+
+	// Points of aggregate queries are sorted by ascending time, so search the ones of the interval.
+	lo := sort.Search(len(ts.points), func(i int) bool { return ts.points[i].time >= qmin })
+	hi := lo + sort.Search(len(ts.points)-lo, func(i int) bool { return ts.points[lo+i].time >= qmax })
+	points := ts.points[lo:hi]
+
+	for i, fn := range m.mapFuncs {
+		input := &MapInput{TMin: -1}
+		if len(m.stmt.Dimensions) > 0 && !m.stmt.HasTimeFieldSpecified() {
+			input.TMin = tmin
+		}
+
+		for _, p := range points {
+			v, ok := p.fields[m.fieldNames[i]]
+			if !ok {
+				continue
+			}
+			input.Items = append(input.Items, MapItem{
+				Timestamp: p.time,
+				Value:     v,
+				Fields:    p.fields,
+				Tags:      p.tags,
+			})
+		}
+
+		output.Values[0].Value = append(output.Values[0].Value.([]interface{}), fn(input))
+	}
+
+	return output
+}
+
+// nextInterval returns the next interval for which to return data.
+// If start is less than 0 there are no more intervals.
+func (m *SubQueryMapper) nextInterval() (start, end int64) {
+	var t, u int64
+	if m.intervalN > 1 {
+		// Windows are aligned, so take the boundaries from the window as they may
+		// not be a fixed size apart in the statement's time zone.
+		n := m.window.index(m.qminWindow) + int64(m.interval+m.stmt.Offset)
+		t, u = m.window.start(n), m.window.start(n+1)
+	} else {
+		t = m.qminWindow + int64(m.interval+m.stmt.Offset)*m.intervalSize
+		u = t + m.intervalSize
+	}
+
+	// On to next interval.
+	m.interval++
+	if t > m.qmax || m.interval > m.intervalN {
+		start, end = -1, 1
+	} else {
+		start, end = t, u
+	}
+	return
+}
+
+// filterValues returns the fields and tags of the point for evaluating a condition.
+func (p *subQueryPoint) filterValues() map[string]interface{} {
+	m := make(map[string]interface{}, len(p.fields)+len(p.tags))
+	for k, v := range p.tags {
+		m[k] = v
+	}
+	for k, v := range p.fields {
+		m[k] = v
+	}
+	return m
+}
+
+type subQueryPoints []*subQueryPoint
+
+func (a subQueryPoints) Len() int           { return len(a) }
+func (a subQueryPoints) Less(i, j int) bool { return a[i].time < a[j].time }
+func (a subQueryPoints) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
+// filterTimeExpr returns expr with all conditions on time removed. Returns nil
+// if nothing but time conditions remain.
+func filterTimeExpr(expr influxql.Expr) influxql.Expr {
+	if expr == nil {
+		return nil
+	}
+
+	expr = influxql.RewriteFunc(influxql.CloneExpr(expr), func(n influxql.Node) influxql.Node {
+		if e, ok := n.(*influxql.BinaryExpr); ok {
+			if ref, ok := e.LHS.(*influxql.VarRef); ok && strings.ToLower(ref.Val) == "time" {
+				return &influxql.BooleanLiteral{Val: true}
+			} else if ref, ok := e.RHS.(*influxql.VarRef); ok && strings.ToLower(ref.Val) == "time" {
+				return &influxql.BooleanLiteral{Val: true}
+			}
+		}
+		return n
+	}).(influxql.Expr)
+
+	expr = influxql.Reduce(expr, nil)
+	if lit, ok := expr.(*influxql.BooleanLiteral); ok && lit.Val {
+		return nil
+	}
+	return expr
+}
@@ -0,0 +1,255 @@
+package tsdb
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/influxdb/influxdb/influxql"
+)
+
+// Transformer calculates a transformation, such as derivative() or cumulative_sum(),
+// over the consecutive values of a series. Values may be passed in over several calls
+// so a Transformer keeps any state it needs from the values it has already seen.
+type Transformer interface {
+	Process(input []*MapperValue) []*MapperValue
+}
+
+// NewTransformer returns the Transformer for the transformation call c of stmt.
+func NewTransformer(stmt *influxql.SelectStatement, c *influxql.Call) (Transformer, error) {
+	// Transformations over raw values are processed a chunk at a time.
+	_, raw := c.Args[0].(*influxql.VarRef)
+
+	switch c.Name {
+	case "derivative", "non_negative_derivative":
+		interval, err := derivativeInterval(stmt)
+		if err != nil {
+			return nil, err
+		}
+		if raw {
+			return &RawQueryDerivativeProcessor{
+				IsNonNegative:      c.Name == "non_negative_derivative",
+				DerivativeInterval: interval,
+			}, nil
+		}
+		return &aggregateDerivativeProcessor{
+			isNonNegative: c.Name == "non_negative_derivative",
+			interval:      interval,
+		}, nil
+	case "difference":
+		return &differenceProcessor{}, nil
+	case "moving_average":
+		lit, ok := c.Args[1].(*influxql.NumberLiteral)
+		if !ok {
+			return nil, fmt.Errorf("expected integer argument in moving_average()")
+		}
+		return &movingAverageProcessor{n: int(lit.Val)}, nil
+	case "cumulative_sum":
+		return &cumulativeSumProcessor{}, nil
+	case "elapsed":
+		unit := time.Nanosecond
+		if len(c.Args) == 2 {
+			unit = c.Args[1].(*influxql.DurationLiteral).Val
+		}
+		return &elapsedProcessor{unit: unit}, nil
+	default:
+		return nil, fmt.Errorf("function not found: %q", c.Name)
+	}
+}
+
+// transformResults applies t to the first value of each aggregate result.
+// Times of the returned results are those of the results passed in.
+func transformResults(t Transformer, results [][]interface{}) [][]interface{} {
+	times := make(map[int64]interface{}, len(results))
+	input := make([]*MapperValue, 0, len(results))
+	for _, vals := range results {
+		tm, _ := resultTime(vals[0])
+		times[tm] = vals[0]
+
+		// Selectors return the value along with the time of the point selected.
+		v := vals[1]
+		if p, ok := v.(PositionPoint); ok {
+			v = p.Value
+		}
+		input = append(input, &MapperValue{Time: tm, Value: v})
+	}
+
+	output := t.Process(input)
+	transformed := make([][]interface{}, 0, len(output))
+	for _, v := range output {
+		transformed = append(transformed, []interface{}{times[v.Time], v.Value})
+	}
+	return transformed
+}
+
+// aggregateDerivativeProcessor calculates the derivatives of aggregate results.
+// Results that are nil or not numeric produce a nil derivative.
+type aggregateDerivativeProcessor struct {
+	isNonNegative bool // Whether to drop negative differences
+	interval      time.Duration
+	prev          *MapperValue
+}
+
+func (p *aggregateDerivativeProcessor) Process(input []*MapperValue) []*MapperValue {
+	// If we only have 1 value, then the value did not change, so return
+	// a single value of 0.0
+	if len(input) == 1 && p.prev == nil {
+		return []*MapperValue{{Time: input[0].Time, Value: 0.0}}
+	}
+
+	// Otherwise calculate the derivatives as the difference between consecutive
+	// points divided by the elapsed time.  Then normalize to the requested
+	// interval.
+	derivatives := make([]*MapperValue, 0, len(input))
+	for _, v := range input {
+		prev := p.prev
+		p.prev = v
+		if prev == nil {
+			continue
+		}
+
+		if !isNumericValue(prev.Value) || !isNumericValue(v.Value) {
+			derivatives = append(derivatives, &MapperValue{Time: v.Time, Value: nil})
+			continue
+		}
+
+		elapsed := v.Time - prev.Time
+		diff := int64toFloat64(v.Value) - int64toFloat64(prev.Value)
+		value := 0.0
+		if elapsed > 0 {
+			value = diff / (float64(elapsed) / float64(p.interval))
+		}
+
+		// Drop negative values for non-negative derivatives
+		if p.isNonNegative && diff < 0 {
+			continue
+		}
+
+		derivatives = append(derivatives, &MapperValue{Time: v.Time, Value: value})
+	}
+	return derivatives
+}
+
+// differenceProcessor calculates the difference between consecutive numeric values.
+// The difference of two integers is an integer.
+type differenceProcessor struct {
+	prev *MapperValue
+}
+
+func (p *differenceProcessor) Process(input []*MapperValue) []*MapperValue {
+	differences := make([]*MapperValue, 0, len(input))
+	for _, v := range input {
+		if !isNumericValue(v.Value) {
+			continue
+		}
+
+		prev := p.prev
+		p.prev = v
+		if prev == nil {
+			continue
+		}
+
+		var value interface{}
+		if a, ok := prev.Value.(int64); ok {
+			if b, ok := v.Value.(int64); ok {
+				value = b - a
+			}
+		}
+		if value == nil {
+			value = int64toFloat64(v.Value) - int64toFloat64(prev.Value)
+		}
+		differences = append(differences, &MapperValue{Time: v.Time, Value: value})
+	}
+	return differences
+}
+
+// movingAverageProcessor calculates the mean of each window of n consecutive numeric values.
+type movingAverageProcessor struct {
+	n      int
+	window []float64
+}
+
+func (p *movingAverageProcessor) Process(input []*MapperValue) []*MapperValue {
+	averages := make([]*MapperValue, 0, len(input))
+	for _, v := range input {
+		if !isNumericValue(v.Value) {
+			continue
+		}
+
+		p.window = append(p.window, int64toFloat64(v.Value))
+		if len(p.window) > p.n {
+			p.window = p.window[1:]
+		} else if len(p.window) < p.n {
+			continue
+		}
+
+		var sum float64
+		for _, f := range p.window {
+			sum += f
+		}
+		averages = append(averages, &MapperValue{Time: v.Time, Value: sum / float64(p.n)})
+	}
+	return averages
+}
+
+// cumulativeSumProcessor calculates the running total of numeric values.
+// The total stays an integer until a float is added.
+type cumulativeSumProcessor struct {
+	sum interface{}
+}
+
+func (p *cumulativeSumProcessor) Process(input []*MapperValue) []*MapperValue {
+	sums := make([]*MapperValue, 0, len(input))
+	for _, v := range input {
+		if !isNumericValue(v.Value) {
+			continue
+		}
+
+		switch sum := p.sum.(type) {
+		case nil:
+			p.sum = v.Value
+		case int64:
+			if i, ok := v.Value.(int64); ok {
+				p.sum = sum + i
+			} else {
+				p.sum = float64(sum) + int64toFloat64(v.Value)
+			}
+		case float64:
+			p.sum = sum + int64toFloat64(v.Value)
+		}
+		sums = append(sums, &MapperValue{Time: v.Time, Value: p.sum})
+	}
+	return sums
+}
+
+// elapsedProcessor calculates the time between consecutive non-nil values as
+// a number of units.
+type elapsedProcessor struct {
+	unit time.Duration
+	prev *MapperValue
+}
+
+func (p *elapsedProcessor) Process(input []*MapperValue) []*MapperValue {
+	elapsed := make([]*MapperValue, 0, len(input))
+	for _, v := range input {
+		if v.Value == nil {
+			continue
+		}
+
+		prev := p.prev
+		p.prev = v
+		if prev == nil {
+			continue
+		}
+		elapsed = append(elapsed, &MapperValue{Time: v.Time, Value: (v.Time - prev.Time) / int64(p.unit)})
+	}
+	return elapsed
+}
+
+// isNumericValue returns true if v is an int64 or float64.
+func isNumericValue(v interface{}) bool {
+	switch v.(type) {
+	case int64, float64:
+		return true
+	}
+	return false
+}